@@ -0,0 +1,172 @@
+// Package accesstokenmanager manages the personal access tokens, which the users create to script against the API
+// from the CLI or from their pipelines without storing a password
+package accesstokenmanager
+
+import (
+	"strings"
+	"time"
+
+	"github.com/globalsign/mgo"
+	log "github.com/golang/glog"
+
+	"github.com/mayadata-io/kubera-auth/manager/jwtmanager"
+	"github.com/mayadata-io/kubera-auth/manager/usermanager"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/store"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/digest"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/random"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/uuid"
+)
+
+const (
+	// Prefix tells the personal access tokens apart from the JWTs, it also lets the secret scanners find leaked tokens
+	Prefix = "kpat_"
+	// DefaultExpiryDays is the lifetime of a token created without an expiry
+	DefaultExpiryDays = 30
+	// MaxExpiryDays is the longest lifetime of a token
+	MaxExpiryDays = 366
+	// tokenLength is the number of random bytes in a personal access token
+	tokenLength = 32
+)
+
+// IsPersonalAccessToken checks if the bearer token is a personal access token rather than a JWT
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, Prefix)
+}
+
+// CreateToken creates a personal access token of the user, the token is returned only here. The token needs a scope,
+// so that it can't be used for managing the user's login or its other tokens, and expires after the given days.
+// The service accounts only get short-lived tokens and can't create personal access tokens.
+func CreateToken(tokenStore *store.PersonalAccessTokenStore, user *models.UserCredentials, name, scope string, expiryDays int) (*models.PersonalAccessToken, string, error) {
+	if user.Kind == models.ServiceAccountAuth {
+		return nil, "", errors.ErrAccessDenied
+	}
+	if name == "" || expiryDays < 0 || expiryDays > MaxExpiryDays {
+		return nil, "", errors.ErrInvalidRequest
+	}
+	if expiryDays == 0 {
+		expiryDays = DefaultExpiryDays
+	}
+	scope, err := jwtmanager.NormalizeScope(scope)
+	if err != nil {
+		return nil, "", err
+	} else if scope == "" {
+		return nil, "", errors.ErrInvalidScope
+	}
+
+	secret, err := random.GetSecureRandomString(tokenLength)
+	if err != nil {
+		return nil, "", err
+	}
+	token := Prefix + secret
+
+	createdAt := time.Now()
+	accessToken := &models.PersonalAccessToken{
+		TokenID:   uuid.Must(uuid.NewRandom()).String(),
+		Hash:      digest.SHA256(token),
+		UserUID:   user.UID,
+		Name:      name,
+		Scope:     scope,
+		CreatedAt: createdAt,
+		ExpiresAt: createdAt.AddDate(0, 0, expiryDays),
+	}
+	if err = tokenStore.Set(accessToken); err != nil {
+		return nil, "", err
+	}
+	return accessToken, token, nil
+}
+
+// GetTokens lists the personal access tokens of the user
+func GetTokens(tokenStore *store.PersonalAccessTokenStore, user *models.UserCredentials) ([]*models.PersonalAccessToken, error) {
+	tokens, err := tokenStore.GetByUserUID(user.UID)
+	if tokens == nil {
+		tokens = []*models.PersonalAccessToken{}
+	}
+	return tokens, err
+}
+
+// RevokeToken removes the personal access token of the user, it can't be used anymore
+func RevokeToken(tokenStore *store.PersonalAccessTokenStore, user *models.UserCredentials, tokenID string) error {
+	err := tokenStore.Remove(user.UID, tokenID)
+	if err == mgo.ErrNotFound {
+		return errors.ErrTokenNotFound
+	}
+	return err
+}
+
+// ParseToken validates a personal access token and gives its user, the scope of the token has to meet the required
+// scope. The tokens created before all the tokens of the user were revoked are rejected. The time and the ip of the
+// request are recorded as the last use of the token.
+func ParseToken(userStore *store.UserStore, tokenStore *store.PersonalAccessTokenStore, token, scope, clientIP string) (*models.UserCredentials, error) {
+	hash := digest.SHA256(token)
+	accessToken, user, err := validateToken(userStore, tokenStore, hash)
+	if err != nil {
+		return nil, err
+	}
+	if !models.HasScope(accessToken.Scope, scope) {
+		return nil, errors.ErrInsufficientScope
+	}
+
+	// The request is served even if its use couldn't be recorded
+	if err = tokenStore.MarkUsed(hash, clientIP); err != nil {
+		log.Errorln("Error recording the use of the personal access token ", err)
+	}
+	return user, nil
+}
+
+// IntrospectToken tells if the personal access token is active, along with its scope and expiry as for the JWTs,
+// see jwtmanager.IntrospectToken. A token is inactive if it is unknown, expired or revoked, or if its user doesn't
+// exist or has been removed. Only failures of the stores are returned as error.
+func IntrospectToken(userStore *store.UserStore, tokenStore *store.PersonalAccessTokenStore, token string) (*jwtmanager.Introspection, error) {
+	inactive := &jwtmanager.Introspection{Active: false}
+
+	accessToken, user, err := validateToken(userStore, tokenStore, digest.SHA256(token))
+	switch err {
+	case nil:
+	case errors.ErrInvalidAccessToken, errors.ErrExpiredAccessToken, errors.ErrRevokedAccessToken, errors.ErrInvalidUser:
+		return inactive, nil
+	default:
+		return nil, err
+	}
+
+	if user.State == models.StateRemoved {
+		return inactive, nil
+	}
+
+	return &jwtmanager.Introspection{
+		Active:    true,
+		Subject:   user.UID,
+		UserName:  user.UserName,
+		Role:      user.Role,
+		ExpiresAt: accessToken.ExpiresAt.Unix(),
+		IssuedAt:  accessToken.CreatedAt.Unix(),
+		Type:      models.TokenPersonalAccess,
+		Scope:     accessToken.Scope,
+	}, nil
+}
+
+// validateToken gives the personal access token having the hash along with its user, the unknown, expired and
+// revoked tokens are rejected
+func validateToken(userStore *store.UserStore, tokenStore *store.PersonalAccessTokenStore, hash string) (*models.PersonalAccessToken, *models.UserCredentials, error) {
+	accessToken, err := tokenStore.Get(hash)
+	if err == mgo.ErrNotFound {
+		return nil, nil, errors.ErrInvalidAccessToken
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	// Mongo removes the expired tokens only from time to time
+	if accessToken.ExpiresAt.Before(time.Now()) {
+		return nil, nil, errors.ErrExpiredAccessToken
+	}
+
+	user, err := usermanager.GetUserByUID(userStore, accessToken.UserUID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user.TokensRevokedAt != nil && !accessToken.CreatedAt.After(*user.TokensRevokedAt) {
+		return nil, nil, errors.ErrRevokedAccessToken
+	}
+	return accessToken, user, nil
+}
@@ -0,0 +1,194 @@
+package accesstokenmanager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mayadata-io/kubera-auth/manager/jwtmanager"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/testutil"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/digest"
+)
+
+func TestCreateToken(t *testing.T) {
+	stores := testutil.NewStores(t)
+	user := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+	serviceAccount := testutil.NewUser(t, stores.User, "ci", models.RoleUser)
+	serviceAccount.Kind = models.ServiceAccountAuth
+
+	for _, test := range []struct {
+		name       string
+		user       *models.UserCredentials
+		scope      string
+		expiryDays int
+		err        error
+	}{
+		{name: "no scope", user: user, scope: "", err: errors.ErrInvalidScope},
+		{name: "unknown scope", user: user, scope: "users:admin", err: errors.ErrInvalidScope},
+		{name: "negative expiry", user: user, scope: models.ScopeUsersRead, expiryDays: -1, err: errors.ErrInvalidRequest},
+		{name: "too long expiry", user: user, scope: models.ScopeUsersRead, expiryDays: MaxExpiryDays + 1, err: errors.ErrInvalidRequest},
+		{name: "service account", user: serviceAccount, scope: models.ScopeUsersRead, err: errors.ErrAccessDenied},
+	} {
+		if _, _, err := CreateToken(stores.PersonalAccessToken, test.user, test.name, test.scope, test.expiryDays); err != test.err {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+
+	accessToken, token, err := CreateToken(stores.PersonalAccessToken, user, "ci", models.ScopeUsersWrite+" "+models.ScopeUsersRead, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsPersonalAccessToken(token) || accessToken.Hash != digest.SHA256(token) {
+		t.Errorf("expected only the hash of the prefixed token to be stored, got %+v", accessToken)
+	}
+	if accessToken.Scope != models.ScopeUsersRead+" "+models.ScopeUsersWrite {
+		t.Errorf("expected the scope to be normalized, got %q", accessToken.Scope)
+	}
+	if days := accessToken.ExpiresAt.Sub(accessToken.CreatedAt).Hours() / 24; days < DefaultExpiryDays-1 || days > DefaultExpiryDays+1 {
+		t.Errorf("expected the token to expire after %d days by default, got %v", DefaultExpiryDays, days)
+	}
+}
+
+func TestParseTokenScope(t *testing.T) {
+	stores := testutil.NewStores(t)
+	user := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+	_, token, err := CreateToken(stores.PersonalAccessToken, user, "ci", models.ScopeUsersRead, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		scope string
+		err   error
+	}{
+		{scope: models.ScopeUsersRead, err: nil},
+		{scope: models.ScopeUsersWrite, err: errors.ErrInsufficientScope},
+		// The routes without a scope manage the login and the tokens of the user
+		{scope: "", err: errors.ErrInsufficientScope},
+	} {
+		if _, err := ParseToken(stores.User, stores.PersonalAccessToken, token, test.scope, "10.0.0.1"); err != test.err {
+			t.Errorf("scope %q: expected %v, got %v", test.scope, test.err, err)
+		}
+	}
+
+	tokens, err := GetTokens(stores.PersonalAccessToken, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 1 || tokens[0].LastUsedAt == nil || tokens[0].LastUsedIP != "10.0.0.1" {
+		t.Errorf("expected the use of the token to be recorded, got %+v", tokens)
+	}
+	if _, err := ParseToken(stores.User, stores.PersonalAccessToken, Prefix+"unknown", models.ScopeUsersRead, "10.0.0.1"); err != errors.ErrInvalidAccessToken {
+		t.Errorf("expected an unknown token to be rejected, got %v", err)
+	}
+}
+
+func TestParseTokenExpired(t *testing.T) {
+	stores := testutil.NewStores(t)
+	user := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+	token := Prefix + "expired"
+	createdAt := time.Now().AddDate(0, 0, -2)
+	err := stores.PersonalAccessToken.Set(&models.PersonalAccessToken{
+		TokenID:   "expired",
+		Hash:      digest.SHA256(token),
+		UserUID:   user.UID,
+		Name:      "ci",
+		Scope:     models.ScopeUsersRead,
+		CreatedAt: createdAt,
+		ExpiresAt: createdAt.AddDate(0, 0, 1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = ParseToken(stores.User, stores.PersonalAccessToken, token, models.ScopeUsersRead, "10.0.0.1"); err != errors.ErrExpiredAccessToken {
+		t.Errorf("expected the expired token to be rejected, got %v", err)
+	}
+}
+
+func TestRevokeToken(t *testing.T) {
+	stores := testutil.NewStores(t)
+	user := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+	otherUser := testutil.NewUser(t, stores.User, "asmith", models.RoleUser)
+	accessToken, token, err := CreateToken(stores.PersonalAccessToken, user, "ci", models.ScopeUsersRead, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = RevokeToken(stores.PersonalAccessToken, otherUser, accessToken.TokenID); err != errors.ErrTokenNotFound {
+		t.Errorf("expected the token of another user not to be found, got %v", err)
+	}
+	if _, err = ParseToken(stores.User, stores.PersonalAccessToken, token, models.ScopeUsersRead, "10.0.0.1"); err != nil {
+		t.Fatalf("expected the token to stay valid, got %v", err)
+	}
+	if err = RevokeToken(stores.PersonalAccessToken, user, accessToken.TokenID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ParseToken(stores.User, stores.PersonalAccessToken, token, models.ScopeUsersRead, "10.0.0.1"); err != errors.ErrInvalidAccessToken {
+		t.Errorf("expected the revoked token to be rejected, got %v", err)
+	}
+	if err = RevokeToken(stores.PersonalAccessToken, user, accessToken.TokenID); err != errors.ErrTokenNotFound {
+		t.Errorf("expected the revoked token not to be found, got %v", err)
+	}
+}
+
+func TestRevokeUserTokens(t *testing.T) {
+	stores := testutil.NewStores(t)
+	user := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+	_, token, err := CreateToken(stores.PersonalAccessToken, user, "ci", models.ScopeUsersRead, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = jwtmanager.RevokeUserTokens(stores.User, stores.RefreshToken, user.UID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ParseToken(stores.User, stores.PersonalAccessToken, token, models.ScopeUsersRead, "10.0.0.1"); err != errors.ErrRevokedAccessToken {
+		t.Errorf("expected the tokens created before the revocation to be rejected, got %v", err)
+	}
+
+	// Mongo stores the times in milliseconds
+	time.Sleep(time.Millisecond * 10)
+	_, token, err = CreateToken(stores.PersonalAccessToken, user, "ci", models.ScopeUsersRead, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ParseToken(stores.User, stores.PersonalAccessToken, token, models.ScopeUsersRead, "10.0.0.1"); err != nil {
+		t.Errorf("expected the tokens created after the revocation to be accepted, got %v", err)
+	}
+}
+
+func TestIntrospectToken(t *testing.T) {
+	stores := testutil.NewStores(t)
+	user := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+	accessToken, token, err := CreateToken(stores.PersonalAccessToken, user, "ci", models.ScopeUsersRead+" "+models.ScopeConfigRead, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	introspection, err := IntrospectToken(stores.User, stores.PersonalAccessToken, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !introspection.Active || introspection.Subject != user.UID || introspection.Type != models.TokenPersonalAccess {
+		t.Errorf("expected the token to be active for %s, got %+v", user.UID, introspection)
+	}
+	if introspection.Scope != accessToken.Scope || introspection.ExpiresAt != accessToken.ExpiresAt.Unix() {
+		t.Errorf("expected the scope %q expiring at %d, got %+v", accessToken.Scope, accessToken.ExpiresAt.Unix(), introspection)
+	}
+
+	introspection, err = IntrospectToken(stores.User, stores.PersonalAccessToken, Prefix+"unknown")
+	if err != nil || introspection.Active {
+		t.Errorf("expected an unknown token to be inactive, got %+v, %v", introspection, err)
+	}
+
+	user.State = models.StateRemoved
+	if err = stores.User.UpdateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	introspection, err = IntrospectToken(stores.User, stores.PersonalAccessToken, token)
+	if err != nil || introspection.Active {
+		t.Errorf("expected the token of a removed user to be inactive, got %+v, %v", introspection, err)
+	}
+}
@@ -0,0 +1,175 @@
+// Package devicemanager implements the device authorization grant of RFC 8628, with which the CLI logs a user in from
+// a terminal without a browser. The CLI shows a user code, which the user enters in the portal after logging in there
+// with any of the logins of the portal, while the CLI polls for its token with the device code.
+package devicemanager
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/globalsign/mgo"
+
+	"github.com/mayadata-io/kubera-auth/manager/jwtmanager"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/store"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/digest"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/random"
+)
+
+const (
+	// DeviceCodeExp is the time within which the user has to approve the device
+	DeviceCodeExp = time.Minute * 10
+	// PollInterval is the minimum time in seconds between two polls of the device
+	PollInterval = 5
+	// slowDownInterval is added to the polling interval each time the device polls too fast
+	slowDownInterval = 5
+	// deviceCodeLength is the number of random bytes in a device code
+	deviceCodeLength = 32
+	// userCodeLength is the number of characters in a user code, which is shown as XXXX-XXXX
+	userCodeLength = 8
+	// userCodeAlphabet has no vowels, so that the user codes don't spell words, and no characters which look alike
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	// maxUserCodeAttempts is the number of user codes tried before giving up on the collisions
+	maxUserCodeAttempts = 3
+)
+
+// CreateDeviceAuthorization starts the login of a device for the scope, if any. The device code is returned only here.
+func CreateDeviceAuthorization(deviceAuthorizationStore *store.DeviceAuthorizationStore, scope string) (*models.DeviceAuthorization, string, error) {
+	scope, err := jwtmanager.NormalizeScope(scope)
+	if err != nil {
+		return nil, "", err
+	}
+
+	deviceCode, err := random.GetSecureRandomString(deviceCodeLength)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for attempt := 0; attempt < maxUserCodeAttempts; attempt++ {
+		userCode, err := newUserCode()
+		if err != nil {
+			return nil, "", err
+		}
+
+		createdAt := time.Now()
+		authorization := &models.DeviceAuthorization{
+			DeviceCodeHash: digest.SHA256(deviceCode),
+			UserCode:       userCode,
+			Scope:          scope,
+			Status:         models.DeviceAuthorizationPending,
+			Interval:       PollInterval,
+			CreatedAt:      createdAt,
+			ExpiresAt:      createdAt.Add(DeviceCodeExp),
+		}
+		err = deviceAuthorizationStore.Set(authorization)
+		if mgo.IsDup(err) {
+			continue
+		} else if err != nil {
+			return nil, "", err
+		}
+		return authorization, deviceCode, nil
+	}
+	return nil, "", errors.ErrTemporarilyUnavailable
+}
+
+// DecideDeviceAuthorization approves the login of the device showing the user code for the logged in user, or denies it
+func DecideDeviceAuthorization(deviceAuthorizationStore *store.DeviceAuthorizationStore, userCode string, user *models.UserCredentials, approved bool) (*models.DeviceAuthorization, error) {
+	status, uid := models.DeviceAuthorizationDenied, ""
+	if approved {
+		status, uid = models.DeviceAuthorizationApproved, user.UID
+	}
+
+	authorization, err := deviceAuthorizationStore.Decide(NormalizeUserCode(userCode), status, uid)
+	if err == mgo.ErrNotFound {
+		return nil, errors.ErrInvalidRequest
+	} else if err != nil {
+		return nil, err
+	}
+	// Mongo removes the expired authorizations only from time to time
+	if authorization.ExpiresAt.Before(time.Now()) {
+		return nil, errors.ErrExpiredToken
+	}
+	return authorization, nil
+}
+
+// RedeemDeviceCode gives the approved device authorization of the device code, which can be redeemed only once.
+// While the user hasn't decided ErrAuthorizationPending is returned, or ErrSlowDown if the device polls faster than
+// its interval, which is then increased.
+func RedeemDeviceCode(deviceAuthorizationStore *store.DeviceAuthorizationStore, deviceCode string) (*models.DeviceAuthorization, error) {
+	if deviceCode == "" {
+		return nil, errors.ErrInvalidRequest
+	}
+	deviceCodeHash := digest.SHA256(deviceCode)
+	authorization, err := deviceAuthorizationStore.Poll(deviceCodeHash)
+	if err == mgo.ErrNotFound {
+		return nil, errors.ErrInvalidGrant
+	} else if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if authorization.ExpiresAt.Before(now) {
+		return nil, errors.ErrExpiredToken
+	}
+	interval := time.Duration(authorization.Interval) * time.Second
+	if authorization.LastPolledAt != nil && now.Sub(*authorization.LastPolledAt) < interval {
+		// The device authorization is gone if a concurrent poll has redeemed it meanwhile
+		err = deviceAuthorizationStore.SlowDown(deviceCodeHash, slowDownInterval)
+		if err == mgo.ErrNotFound {
+			return nil, errors.ErrInvalidGrant
+		} else if err != nil {
+			return nil, err
+		}
+		return nil, errors.ErrSlowDown
+	}
+
+	switch authorization.Status {
+	case models.DeviceAuthorizationPending:
+		return nil, errors.ErrAuthorizationPending
+	case models.DeviceAuthorizationDenied:
+		if _, err = deviceAuthorizationStore.Take(deviceCodeHash, models.DeviceAuthorizationDenied); err != nil && err != mgo.ErrNotFound {
+			return nil, err
+		}
+		return nil, errors.ErrAccessDenied
+	}
+
+	authorization, err = deviceAuthorizationStore.Take(deviceCodeHash, models.DeviceAuthorizationApproved)
+	if err == mgo.ErrNotFound {
+		return nil, errors.ErrInvalidGrant
+	} else if err != nil {
+		return nil, err
+	}
+	return authorization, nil
+}
+
+// FormatUserCode formats the user code as shown to the user, such as BCDF-GHJK
+func FormatUserCode(userCode string) string {
+	return userCode[:userCodeLength/2] + "-" + userCode[userCodeLength/2:]
+}
+
+// NormalizeUserCode gives the user code as stored from the code entered by the user, the case and the separators
+// are ignored
+func NormalizeUserCode(userCode string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(userCodeAlphabet, r) {
+			return r
+		}
+		return -1
+	}, strings.ToUpper(userCode))
+}
+
+// newUserCode generates a random user code
+func newUserCode() (string, error) {
+	code := make([]byte, userCodeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(userCodeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		code[i] = userCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
@@ -0,0 +1,175 @@
+package devicemanager
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/testutil"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/digest"
+)
+
+func TestNormalizeUserCode(t *testing.T) {
+	for userCode, expected := range map[string]string{
+		"BCDF-GHJK":   "BCDFGHJK",
+		"bcdf-ghjk":   "BCDFGHJK",
+		" bcdf ghjk ": "BCDFGHJK",
+		"BCDF-GHJA":   "BCDFGHJ",
+	} {
+		if normalized := NormalizeUserCode(userCode); normalized != expected {
+			t.Errorf("NormalizeUserCode(%q) = %q, expected %q", userCode, normalized, expected)
+		}
+	}
+}
+
+func TestDecideDeviceAuthorization(t *testing.T) {
+	stores := testutil.NewStores(t)
+	user := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+	authorization, _, err := CreateDeviceAuthorization(stores.DeviceAuthorization, models.ScopeUsersRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userCode := strings.ToLower(FormatUserCode(authorization.UserCode))
+	decided, err := DecideDeviceAuthorization(stores.DeviceAuthorization, userCode, user, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decided.Status != models.DeviceAuthorizationApproved || decided.UserUID != user.UID || decided.Scope != models.ScopeUsersRead {
+		t.Errorf("expected the device to be approved for the user, got %+v", decided)
+	}
+	if _, err = DecideDeviceAuthorization(stores.DeviceAuthorization, userCode, user, false); err != errors.ErrInvalidRequest {
+		t.Errorf("expected a decided device not to be decided again, got %v", err)
+	}
+	if _, err = DecideDeviceAuthorization(stores.DeviceAuthorization, "BCDF-GHJK", user, true); err != errors.ErrInvalidRequest {
+		t.Errorf("expected an unknown user code to be rejected, got %v", err)
+	}
+}
+
+func TestRedeemDeviceCodeSlowDown(t *testing.T) {
+	stores := testutil.NewStores(t)
+	user := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+	authorization, deviceCode, err := CreateDeviceAuthorization(stores.DeviceAuthorization, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = RedeemDeviceCode(stores.DeviceAuthorization, deviceCode); err != errors.ErrAuthorizationPending {
+		t.Fatalf("expected the device to wait for the user, got %v", err)
+	}
+	if _, err = RedeemDeviceCode(stores.DeviceAuthorization, deviceCode); err != errors.ErrSlowDown {
+		t.Fatalf("expected the device polling too fast to slow down, got %v", err)
+	}
+
+	// Polling too fast doesn't redeem the approved device either
+	if _, err = DecideDeviceAuthorization(stores.DeviceAuthorization, authorization.UserCode, user, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = RedeemDeviceCode(stores.DeviceAuthorization, deviceCode); err != errors.ErrSlowDown {
+		t.Fatalf("expected the device polling too fast to slow down, got %v", err)
+	}
+
+	polled, err := stores.DeviceAuthorization.Poll(digest.SHA256(deviceCode))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if polled.Interval != PollInterval+2*slowDownInterval {
+		t.Errorf("expected the interval to be increased on each slow down, got %d", polled.Interval)
+	}
+	if polled.Status != models.DeviceAuthorizationApproved {
+		t.Errorf("expected the approved device to stay redeemable, got %s", polled.Status)
+	}
+}
+
+func TestRedeemDeviceCodeOnce(t *testing.T) {
+	stores := testutil.NewStores(t)
+	user := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+	authorization, deviceCode, err := CreateDeviceAuthorization(stores.DeviceAuthorization, models.ScopeUsersRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = DecideDeviceAuthorization(stores.DeviceAuthorization, authorization.UserCode, user, true); err != nil {
+		t.Fatal(err)
+	}
+
+	const attempts = 5
+	var wg sync.WaitGroup
+	results := make(chan error, attempts)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			redeemed, err := RedeemDeviceCode(stores.DeviceAuthorization, deviceCode)
+			if err == nil && redeemed.UserUID != user.UID {
+				t.Errorf("expected the authorization of the user to be redeemed, got %+v", redeemed)
+			}
+			results <- err
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	redemptions := 0
+	for err := range results {
+		if err == nil {
+			redemptions++
+		} else if err != errors.ErrSlowDown && err != errors.ErrInvalidGrant {
+			t.Errorf("expected the other polls to fail, got %v", err)
+		}
+	}
+	if redemptions != 1 {
+		t.Errorf("expected the device code to be redeemed once, got %d redemptions", redemptions)
+	}
+	if _, err = RedeemDeviceCode(stores.DeviceAuthorization, deviceCode); err != errors.ErrInvalidGrant {
+		t.Errorf("expected the redeemed device code to be rejected, got %v", err)
+	}
+}
+
+func TestRedeemDeviceCodeDenied(t *testing.T) {
+	stores := testutil.NewStores(t)
+	user := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+	authorization, deviceCode, err := CreateDeviceAuthorization(stores.DeviceAuthorization, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = DecideDeviceAuthorization(stores.DeviceAuthorization, authorization.UserCode, user, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = RedeemDeviceCode(stores.DeviceAuthorization, deviceCode); err != errors.ErrAccessDenied {
+		t.Fatalf("expected the denied device to be refused, got %v", err)
+	}
+	if _, err = RedeemDeviceCode(stores.DeviceAuthorization, deviceCode); err != errors.ErrInvalidGrant {
+		t.Errorf("expected the denied device code to be removed, got %v", err)
+	}
+}
+
+func TestRedeemDeviceCodeExpired(t *testing.T) {
+	stores := testutil.NewStores(t)
+	deviceCode := "expired"
+	createdAt := time.Now().Add(-DeviceCodeExp * 2)
+	err := stores.DeviceAuthorization.Set(&models.DeviceAuthorization{
+		DeviceCodeHash: digest.SHA256(deviceCode),
+		UserCode:       "BCDFGHJK",
+		Status:         models.DeviceAuthorizationPending,
+		Interval:       PollInterval,
+		CreatedAt:      createdAt,
+		ExpiresAt:      createdAt.Add(DeviceCodeExp),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = RedeemDeviceCode(stores.DeviceAuthorization, deviceCode); err != errors.ErrExpiredToken {
+		t.Errorf("expected the expired device code to be rejected, got %v", err)
+	}
+	if _, err = RedeemDeviceCode(stores.DeviceAuthorization, ""); err != errors.ErrInvalidRequest {
+		t.Errorf("expected a missing device code to be rejected, got %v", err)
+	}
+	if _, err = RedeemDeviceCode(stores.DeviceAuthorization, "unknown"); err != errors.ErrInvalidGrant {
+		t.Errorf("expected an unknown device code to be rejected, got %v", err)
+	}
+}
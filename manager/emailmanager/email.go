@@ -17,6 +17,7 @@ type EmailType string
 const (
 	VerificationEmail  EmailType = "Verification"
 	ResetPasswordEmail EmailType = "Reset"
+	MagicLinkEmail     EmailType = "MagicLink"
 )
 
 func SendEmail(accessGenerate *generates.JWTAccessGenerate, userInfo *models.PublicUserInfo, emailType EmailType) error {
@@ -25,7 +26,17 @@ func SendEmail(accessGenerate *generates.JWTAccessGenerate, userInfo *models.Pub
 		AccessTokenExp: time.Minute * types.VerificationLinkExpirationTimeUnit,
 	}
 
-	tokenInfo, err := jwtmanager.GenerateAuthToken(accessGenerate, tgr, models.TokenEmail)
+	// Each link carries a token of its own type, which is only accepted for the purpose of the link
+	var tokenType models.TokenType
+	switch emailType {
+	case VerificationEmail:
+		tokenType = models.TokenEmailVerification
+	case ResetPasswordEmail:
+		tokenType = models.TokenPasswordReset
+	case MagicLinkEmail:
+		tokenType = models.TokenMagicLink
+	}
+	tokenInfo, err := jwtmanager.GenerateAuthToken(accessGenerate, tgr, tokenType)
 	if err != nil {
 		return err
 	}
@@ -62,6 +73,19 @@ func SendEmail(accessGenerate *generates.JWTAccessGenerate, userInfo *models.Pub
 			log.Error("Error occurred while getting email body for user: " + userInfo.UID + "error: " + err.Error())
 			return err
 		}
+	case MagicLinkEmail:
+		email = userInfo.Email
+		templateVar := generates.TemplateVariables{
+			Username: userInfo.Name,
+			Link:     types.PortalURL + "/magic-link?access=" + tokenInfo.Access,
+		}
+		subject = "Sign in to Kubera"
+
+		buf, err = generates.GetEmailBody(types.MagicLinkEmailTemplatePath, templateVar)
+		if err != nil {
+			log.Error("Error occurred while getting email body for user: " + userInfo.UID + "error: " + err.Error())
+			return err
+		}
 	}
 
 	err = generates.SendEmail(email, subject, buf.String())
@@ -0,0 +1,66 @@
+package jwtmanager
+
+import (
+	"github.com/dgrijalva/jwt-go"
+
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/generates"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/store"
+)
+
+// Introspection is the state of a token as described in RFC 7662, an inactive token carries no other information
+type Introspection struct {
+	Active    bool             `json:"active"`
+	Subject   string           `json:"sub,omitempty"`
+	UserName  string           `json:"username,omitempty"`
+	Role      models.Role      `json:"role,omitempty"`
+	ExpiresAt int64            `json:"exp,omitempty"`
+	IssuedAt  int64            `json:"iat,omitempty"`
+	Type      models.TokenType `json:"type,omitempty"`
+	Scope     string           `json:"scope,omitempty"`
+	ClientID  string           `json:"client_id,omitempty"`
+	Issuer    string           `json:"iss,omitempty"`
+	Audience  []string         `json:"aud,omitempty"`
+}
+
+// IntrospectToken tells if the token is active. A token is inactive if it is invalid, expired or revoked,
+// if it isn't a login token, or if its user doesn't exist or has been removed. Only failures of the stores
+// are returned as error.
+func IntrospectToken(userStore *store.UserStore, revocationStore *store.RevocationStore, accessGenerate *generates.JWTAccessGenerate, tokenString string) (*Introspection, error) {
+	inactive := &Introspection{Active: false}
+
+	claims, user, err := validateToken(userStore, revocationStore, accessGenerate, tokenString)
+	if err != nil && isInvalidTokenError(err) {
+		return inactive, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// The tokens of the other types only grant their own purpose, like an MFA challenge or a password reset
+	if claims.Type != models.TokenLogin || user.State == models.StateRemoved {
+		return inactive, nil
+	}
+
+	return &Introspection{
+		Active:    true,
+		Subject:   user.UID,
+		UserName:  user.UserName,
+		Role:      user.Role,
+		ExpiresAt: claims.ExpiresAt,
+		IssuedAt:  claims.IssuedAt,
+		Type:      claims.Type,
+		Scope:     claims.Scope,
+		ClientID:  claims.ClientID,
+		Issuer:    claims.Issuer,
+		Audience:  claims.Audience,
+	}, nil
+}
+
+// isInvalidTokenError tells if the error is caused by the token itself rather than by a failure of the stores
+func isInvalidTokenError(err error) bool {
+	if _, ok := err.(*jwt.ValidationError); ok {
+		return true
+	}
+	return err == errors.ErrInvalidAccessToken || err == errors.ErrRevokedAccessToken || err == errors.ErrInvalidUser
+}
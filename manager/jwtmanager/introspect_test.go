@@ -0,0 +1,57 @@
+package jwtmanager
+
+import (
+	"testing"
+
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/testutil"
+)
+
+func TestIntrospectToken(t *testing.T) {
+	stores := testutil.NewStores(t)
+	accessGenerate := testutil.NewAccessGenerate(t)
+	user := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+
+	for _, test := range []struct {
+		tokenType models.TokenType
+		active    bool
+	}{
+		{models.TokenLogin, true},
+		{models.TokenMFA, false},
+		{models.TokenMagicLink, false},
+		{models.TokenEmailVerification, false},
+		{models.TokenPasswordReset, false},
+	} {
+		token := newToken(t, accessGenerate, user, test.tokenType)
+		introspection, err := IntrospectToken(stores.User, stores.Revocation, accessGenerate, token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if introspection.Active != test.active {
+			t.Errorf("%s token: expected active %v, got %v", test.tokenType, test.active, introspection.Active)
+		}
+		if !test.active && introspection.Subject != "" {
+			t.Errorf("%s token: expected no information about the inactive token, got %+v", test.tokenType, introspection)
+		}
+	}
+
+	token := newToken(t, accessGenerate, user, models.TokenLogin)
+	if err := RevokeToken(stores.Revocation, accessGenerate, token); err != nil {
+		t.Fatal(err)
+	}
+	introspection, err := IntrospectToken(stores.User, stores.Revocation, accessGenerate, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if introspection.Active {
+		t.Error("expected the revoked token to be inactive")
+	}
+
+	introspection, err = IntrospectToken(stores.User, stores.Revocation, accessGenerate, "not-a-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if introspection.Active {
+		t.Error("expected the malformed token to be inactive")
+	}
+}
@@ -1,53 +1,162 @@
 package jwtmanager
 
 import (
+	"strings"
 	"time"
 
 	"github.com/mayadata-io/kubera-auth/manager/usermanager"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
 	"github.com/mayadata-io/kubera-auth/pkg/generates"
 	"github.com/mayadata-io/kubera-auth/pkg/models"
 	"github.com/mayadata-io/kubera-auth/pkg/store"
+	"github.com/mayadata-io/kubera-auth/pkg/types"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/uuid"
 )
 
 // TokenGenerateRequest provide to generate the token request parameters
 type TokenGenerateRequest struct {
 	UserInfo       *models.PublicUserInfo
 	AccessTokenExp time.Duration
+	// ClientID and Audience are set when the token is requested by an OpenID Connect client
+	ClientID string
+	Audience []string
+	// Scope restricts the routes on which the login token can be used, see models.HasScope
+	Scope string
 }
 
 // Config authorization configuration parameters
 type Config struct {
 	// access token expiration time, 0 means it doesn't expire
 	AccessTokenExp time.Duration
+	// refresh token expiration time
+	RefreshTokenExp time.Duration
 }
 
 // default configs
 var (
-	DefaultTokenCfg = &Config{AccessTokenExp: time.Hour * 24}
+	DefaultTokenCfg = &Config{AccessTokenExp: time.Minute * 15, RefreshTokenExp: time.Hour * 24 * 7}
 )
 
-// ParseToken validates the token
-func ParseToken(userStore *store.UserStore, accessGenerate *generates.JWTAccessGenerate, tokenString string) (*models.UserCredentials, error) {
-	claimedUser, err := accessGenerate.Parse(tokenString)
+// MaxTokenExp gives the longest lifetime of the tokens signed by the server
+func MaxTokenExp() time.Duration {
+	emailTokenExp := time.Minute * types.VerificationLinkExpirationTimeUnit
+	if DefaultTokenCfg.AccessTokenExp > emailTokenExp {
+		return DefaultTokenCfg.AccessTokenExp
+	}
+	return emailTokenExp
+}
+
+// ParseLoginToken validates a login token, the only tokens accepted by the API. A token which has been revoked
+// either by itself or along with all the other tokens of its user is rejected, as well as a token whose scope
+// doesn't meet the required scope. The tokens of the service accounts are rejected on the routes without a scope,
+// which manage the login of a person, whatever their scope.
+func ParseLoginToken(userStore *store.UserStore, revocationStore *store.RevocationStore, accessGenerate *generates.JWTAccessGenerate, tokenString, scope string) (*models.UserCredentials, error) {
+	claims, user, err := validateToken(userStore, revocationStore, accessGenerate, tokenString)
 	if err != nil {
 		return nil, err
 	}
-	user, err := usermanager.GetUserByUID(userStore, claimedUser.UID)
+	if claims.Type != models.TokenLogin {
+		return nil, errors.ErrInvalidAccessToken
+	}
+	if !claims.HasScope(scope) || (scope == "" && user.Kind == models.ServiceAccountAuth) {
+		return nil, errors.ErrInsufficientScope
+	}
+	return user, nil
+}
+
+// NormalizeScope validates the scope requested on login, which is either empty for a token usable on all the
+// routes or a space separated list of the API scopes. The scopes are deduplicated and sorted as in models.APIScopes.
+func NormalizeScope(scope string) (string, error) {
+	requested := map[string]bool{}
+	for _, s := range strings.Fields(scope) {
+		requested[s] = true
+	}
+
+	var scopes []string
+	for _, s := range models.APIScopes {
+		if requested[s] {
+			scopes = append(scopes, s)
+			delete(requested, s)
+		}
+	}
+	if len(requested) > 0 {
+		return "", errors.ErrInvalidScope
+	}
+	return strings.Join(scopes, " "), nil
+}
+
+// ParseMFAToken validates an MFA challenge token
+func ParseMFAToken(userStore *store.UserStore, revocationStore *store.RevocationStore, accessGenerate *generates.JWTAccessGenerate, tokenString string) (*models.UserCredentials, error) {
+	return parseTokenOfType(userStore, revocationStore, accessGenerate, tokenString, models.TokenMFA)
+}
+
+// ParseMagicLinkToken validates the token of a magic link
+func ParseMagicLinkToken(userStore *store.UserStore, revocationStore *store.RevocationStore, accessGenerate *generates.JWTAccessGenerate, tokenString string) (*models.UserCredentials, error) {
+	return parseTokenOfType(userStore, revocationStore, accessGenerate, tokenString, models.TokenMagicLink)
+}
+
+// ParseEmailVerificationToken validates the token of an email verification link
+func ParseEmailVerificationToken(userStore *store.UserStore, revocationStore *store.RevocationStore, accessGenerate *generates.JWTAccessGenerate, tokenString string) (*models.UserCredentials, error) {
+	return parseTokenOfType(userStore, revocationStore, accessGenerate, tokenString, models.TokenEmailVerification)
+}
+
+// ParsePasswordResetToken validates the token of a password reset link
+func ParsePasswordResetToken(userStore *store.UserStore, revocationStore *store.RevocationStore, accessGenerate *generates.JWTAccessGenerate, tokenString string) (*models.UserCredentials, error) {
+	return parseTokenOfType(userStore, revocationStore, accessGenerate, tokenString, models.TokenPasswordReset)
+}
+
+// parseTokenOfType validates a token which can only be used for the purpose of its type
+func parseTokenOfType(userStore *store.UserStore, revocationStore *store.RevocationStore, accessGenerate *generates.JWTAccessGenerate, tokenString string, tokenType models.TokenType) (*models.UserCredentials, error) {
+	claims, user, err := validateToken(userStore, revocationStore, accessGenerate, tokenString)
 	if err != nil {
 		return nil, err
 	}
+	if claims.Type != tokenType {
+		return nil, errors.ErrInvalidAccessToken
+	}
 	return user, nil
 }
 
+// validateToken validates the token and gives its claims along with the stored user of the token
+func validateToken(userStore *store.UserStore, revocationStore *store.RevocationStore, accessGenerate *generates.JWTAccessGenerate, tokenString string) (*generates.JWTAccessClaims, *models.UserCredentials, error) {
+	claims, err := accessGenerate.ParseClaims(tokenString)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if claims.Id != "" {
+		revoked, err := revocationStore.IsRevoked(claims.Id)
+		if err != nil {
+			return nil, nil, err
+		} else if revoked {
+			return nil, nil, errors.ErrRevokedAccessToken
+		}
+	}
+
+	user, err := usermanager.GetUserByUID(userStore, claims.UID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if user.TokensRevokedAt != nil && claims.IssuedAt <= user.TokensRevokedAt.Unix() {
+		return nil, nil, errors.ErrRevokedAccessToken
+	}
+	return claims, user, nil
+}
+
 // GenerateAuthToken generate the authorization token(code)
 func GenerateAuthToken(accessGenerate *generates.JWTAccessGenerate, tgr *TokenGenerateRequest, jwtType models.TokenType) (*models.Token, error) {
 	ti := models.NewToken(jwtType)
+	ti.SetID(uuid.Must(uuid.NewRandom()).String())
 
 	createAt := time.Now()
 	td := &generates.GenerateBasic{
 		UserInfo:  tgr.UserInfo,
 		CreateAt:  &createAt,
 		TokenInfo: ti,
+		ClientID:  tgr.ClientID,
+		Audience:  tgr.Audience,
+		Scope:     tgr.Scope,
 	}
 
 	cfg := DefaultTokenCfg
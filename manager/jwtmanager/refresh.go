@@ -0,0 +1,80 @@
+package jwtmanager
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo"
+	log "github.com/golang/glog"
+
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/store"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/digest"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/random"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/uuid"
+)
+
+// refreshTokenLength is the number of random bytes in a refresh token
+const refreshTokenLength = 32
+
+// GenerateRefreshToken generates a refresh token for the user of the token generate request and
+// sets it on the given token. An empty familyID starts a new family of refresh tokens, which
+// should be done on every fresh login.
+func GenerateRefreshToken(refreshTokenStore *store.RefreshTokenStore, tgr *TokenGenerateRequest, ti *models.Token, familyID string) error {
+	refresh, err := random.GetSecureRandomString(refreshTokenLength)
+	if err != nil {
+		return err
+	}
+
+	if familyID == "" {
+		familyID = uuid.Must(uuid.NewRandom()).String()
+	}
+
+	createAt := time.Now()
+	rexp := DefaultTokenCfg.RefreshTokenExp
+	err = refreshTokenStore.Set(&models.RefreshToken{
+		Hash:      digest.SHA256(refresh),
+		FamilyID:  familyID,
+		UserUID:   tgr.UserInfo.UID,
+		ClientID:  tgr.ClientID,
+		Scope:     tgr.Scope,
+		CreatedAt: createAt,
+		ExpiresAt: createAt.Add(rexp),
+	})
+	if err != nil {
+		return err
+	}
+
+	ti.SetRefresh(refresh)
+	ti.SetRefreshCreateAt(createAt)
+	ti.SetRefreshExpiresIn(rexp)
+	return nil
+}
+
+// UseRefreshToken consumes a refresh token so that it can't be used again.
+// A refresh token which has been used already is treated as stolen, in which case
+// the whole family of the token is revoked so that neither the attacker nor
+// the legitimate user can use the newer tokens of that family anymore.
+// A refresh token can only be used by the client to which it was issued, an empty
+// clientID stands for the portal.
+func UseRefreshToken(refreshTokenStore *store.RefreshTokenStore, refresh, clientID string) (*models.RefreshToken, error) {
+	hash := digest.SHA256(refresh)
+	storedToken, err := refreshTokenStore.MarkUsed(hash)
+	if err == mgo.ErrNotFound {
+		usedToken, getErr := refreshTokenStore.Get(hash)
+		if getErr == nil && usedToken != nil {
+			log.Warningln("Refresh token reuse detected, revoking token family of user uid: ", usedToken.UserUID)
+			if err = refreshTokenStore.RemoveFamily(usedToken.FamilyID); err != nil {
+				return nil, err
+			}
+		}
+		return nil, errors.ErrInvalidGrant
+	} else if err != nil {
+		return nil, err
+	}
+
+	if storedToken.ExpiresAt.Before(time.Now()) || storedToken.ClientID != clientID {
+		return nil, errors.ErrInvalidGrant
+	}
+	return storedToken, nil
+}
@@ -0,0 +1,73 @@
+package jwtmanager
+
+import (
+	"testing"
+
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/testutil"
+)
+
+func newRefreshToken(t *testing.T, stores *testutil.Stores, clientID, familyID string) string {
+	t.Helper()
+	tgr := &TokenGenerateRequest{
+		UserInfo: &models.PublicUserInfo{UID: "user-uid"},
+		ClientID: clientID,
+	}
+	ti := models.NewToken(models.TokenLogin)
+	if err := GenerateRefreshToken(stores.RefreshToken, tgr, ti, familyID); err != nil {
+		t.Fatal(err)
+	}
+	return ti.GetRefresh()
+}
+
+func TestUseRefreshTokenRotation(t *testing.T) {
+	stores := testutil.NewStores(t)
+	first := newRefreshToken(t, stores, "", "")
+
+	storedToken, err := UseRefreshToken(stores.RefreshToken, first, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if storedToken.UserUID != "user-uid" || storedToken.FamilyID == "" {
+		t.Fatalf("unexpected refresh token %+v", storedToken)
+	}
+	second := newRefreshToken(t, stores, "", storedToken.FamilyID)
+
+	if _, err = UseRefreshToken(stores.RefreshToken, second, ""); err != nil {
+		t.Fatalf("expected the rotated refresh token to be valid, got %v", err)
+	}
+}
+
+func TestUseRefreshTokenReuseRevokesFamily(t *testing.T) {
+	stores := testutil.NewStores(t)
+	first := newRefreshToken(t, stores, "", "")
+	storedToken, err := UseRefreshToken(stores.RefreshToken, first, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := newRefreshToken(t, stores, "", storedToken.FamilyID)
+	other := newRefreshToken(t, stores, "", "")
+
+	if _, err = UseRefreshToken(stores.RefreshToken, first, ""); err != errors.ErrInvalidGrant {
+		t.Fatalf("expected the reused refresh token to be rejected, got %v", err)
+	}
+	if _, err = UseRefreshToken(stores.RefreshToken, second, ""); err != errors.ErrInvalidGrant {
+		t.Fatalf("expected the family of the reused refresh token to be revoked, got %v", err)
+	}
+	if _, err = UseRefreshToken(stores.RefreshToken, other, ""); err != nil {
+		t.Fatalf("expected the refresh token of another family to stay valid, got %v", err)
+	}
+}
+
+func TestUseRefreshTokenOfAnotherClient(t *testing.T) {
+	stores := testutil.NewStores(t)
+	refresh := newRefreshToken(t, stores, "client", "")
+
+	if _, err := UseRefreshToken(stores.RefreshToken, refresh, ""); err != errors.ErrInvalidGrant {
+		t.Fatalf("expected the refresh token of a client to be rejected for the portal, got %v", err)
+	}
+	if _, err := UseRefreshToken(stores.RefreshToken, "unknown", ""); err != errors.ErrInvalidGrant {
+		t.Fatalf("expected an unknown refresh token to be rejected, got %v", err)
+	}
+}
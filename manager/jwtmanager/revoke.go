@@ -0,0 +1,69 @@
+package jwtmanager
+
+import (
+	"time"
+
+	"github.com/mayadata-io/kubera-auth/manager/usermanager"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/generates"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/store"
+)
+
+// RevokeToken adds the given token to the revocation list till it expires
+func RevokeToken(revocationStore *store.RevocationStore, accessGenerate *generates.JWTAccessGenerate, tokenString string) error {
+	claims, err := accessGenerate.ParseClaims(tokenString)
+	if err != nil {
+		return err
+	}
+	// Tokens issued before the revocation list existed can only be revoked along with all the tokens of the user
+	if claims.Id == "" {
+		return nil
+	}
+
+	return revocationStore.Set(&models.RevokedToken{
+		JTI:       claims.Id,
+		UserUID:   claims.UID,
+		RevokedAt: time.Now(),
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+	})
+}
+
+// ConsumeToken revokes a single-use token, a token which has already been consumed is rejected
+func ConsumeToken(revocationStore *store.RevocationStore, accessGenerate *generates.JWTAccessGenerate, tokenString string) error {
+	claims, err := accessGenerate.ParseClaims(tokenString)
+	if err != nil {
+		return err
+	}
+	if claims.Id == "" {
+		return errors.ErrInvalidAccessToken
+	}
+
+	claimed, err := revocationStore.Claim(&models.RevokedToken{
+		JTI:       claims.Id,
+		UserUID:   claims.UID,
+		RevokedAt: time.Now(),
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+	})
+	if err != nil {
+		return err
+	} else if !claimed {
+		return errors.ErrRevokedAccessToken
+	}
+	return nil
+}
+
+// RevokeUserTokens revokes all the access and refresh tokens issued to a user till now
+func RevokeUserTokens(userStore *store.UserStore, refreshTokenStore *store.RefreshTokenStore, uid string) error {
+	storedUser, err := usermanager.GetUserByUID(userStore, uid)
+	if err != nil {
+		return err
+	}
+
+	err = refreshTokenStore.RemoveByUserUID(storedUser.UID)
+	if err != nil {
+		return err
+	}
+
+	return userStore.RevokeTokens(storedUser.UID, time.Now())
+}
@@ -0,0 +1,97 @@
+package jwtmanager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/generates"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/testutil"
+)
+
+func newToken(t *testing.T, accessGenerate *generates.JWTAccessGenerate, user *models.UserCredentials, tokenType models.TokenType) string {
+	t.Helper()
+	ti, err := GenerateAuthToken(accessGenerate, &TokenGenerateRequest{UserInfo: user.GetPublicInfo()}, tokenType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ti.GetAccess()
+}
+
+func TestRevokeToken(t *testing.T) {
+	stores := testutil.NewStores(t)
+	accessGenerate := testutil.NewAccessGenerate(t)
+	user := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+	token := newToken(t, accessGenerate, user, models.TokenLogin)
+	otherToken := newToken(t, accessGenerate, user, models.TokenLogin)
+
+	if _, err := ParseLoginToken(stores.User, stores.Revocation, accessGenerate, token, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := RevokeToken(stores.Revocation, accessGenerate, token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseLoginToken(stores.User, stores.Revocation, accessGenerate, token, ""); err != errors.ErrRevokedAccessToken {
+		t.Fatalf("expected the revoked token to be rejected, got %v", err)
+	}
+	if _, err := ParseLoginToken(stores.User, stores.Revocation, accessGenerate, otherToken, ""); err != nil {
+		t.Fatalf("expected the other token of the user to stay valid, got %v", err)
+	}
+}
+
+func TestRevokeUserTokens(t *testing.T) {
+	stores := testutil.NewStores(t)
+	accessGenerate := testutil.NewAccessGenerate(t)
+	user := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+	otherUser := testutil.NewUser(t, stores.User, "asmith", models.RoleUser)
+	token := newToken(t, accessGenerate, user, models.TokenLogin)
+	otherToken := newToken(t, accessGenerate, otherUser, models.TokenLogin)
+
+	if err := RevokeUserTokens(stores.User, stores.RefreshToken, user.UID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseLoginToken(stores.User, stores.Revocation, accessGenerate, token, ""); err != errors.ErrRevokedAccessToken {
+		t.Fatalf("expected the tokens issued before the revocation to be rejected, got %v", err)
+	}
+	if _, err := ParseLoginToken(stores.User, stores.Revocation, accessGenerate, otherToken, ""); err != nil {
+		t.Fatalf("expected the tokens of the other users to stay valid, got %v", err)
+	}
+
+	// The issue times of the tokens are in seconds
+	time.Sleep(time.Second)
+	newerToken := newToken(t, accessGenerate, user, models.TokenLogin)
+	if _, err := ParseLoginToken(stores.User, stores.Revocation, accessGenerate, newerToken, ""); err != nil {
+		t.Fatalf("expected the tokens issued after the revocation to be valid, got %v", err)
+	}
+}
+
+func TestRevokeUserTokensNotRevertedByStaleUser(t *testing.T) {
+	stores := testutil.NewStores(t)
+	accessGenerate := testutil.NewAccessGenerate(t)
+	user := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+	token := newToken(t, accessGenerate, user, models.TokenLogin)
+
+	staleUser, err := stores.User.GetUserByID(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = RevokeUserTokens(stores.User, stores.RefreshToken, user.UID); err != nil {
+		t.Fatal(err)
+	}
+	staleUser.Name = "John Doe"
+	if err = stores.User.UpdateUser(staleUser); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = ParseLoginToken(stores.User, stores.Revocation, accessGenerate, token, ""); err != errors.ErrRevokedAccessToken {
+		t.Fatalf("expected the revocation to survive the update of a stale user, got %v", err)
+	}
+	storedUser, err := stores.User.GetUserByID(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if storedUser.Name != "John Doe" {
+		t.Fatalf("expected the other fields to be updated, got %+v", storedUser)
+	}
+}
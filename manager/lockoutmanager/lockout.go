@@ -0,0 +1,176 @@
+// lockoutmanager protects the local and LDAP logins, the MFA codes and the password resets from brute-force attacks.
+// The failed attempts are counted per user and per client ip, each of them is locked with an exponential backoff after
+// too many failures.
+package lockoutmanager
+
+import (
+	"time"
+
+	"github.com/mayadata-io/kubera-auth/manager/usermanager"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/store"
+)
+
+const (
+	// UserThreshold is the number of consecutive failed logins after which the login of a user is locked
+	UserThreshold = 5
+	// IPThreshold is the number of failed attempts after which a client ip is locked, it is higher than
+	// UserThreshold as the clients behind a NAT share their ip
+	IPThreshold = 20
+	// BaseLockout is the lockout once a threshold is reached, it doubles with each further failure up to MaxLockout
+	BaseLockout = time.Minute
+	MaxLockout  = time.Hour
+	// AttemptWindow is the time after the last failure at which the failures of a client ip are forgotten
+	AttemptWindow = time.Hour * 24
+	// ChallengeThreshold is the number of wrong codes after which an MFA challenge can't be used anymore
+	ChallengeThreshold = 3
+)
+
+// Scope separates the attempts of the different requests of a client
+type Scope string
+
+const (
+	// LoginScope counts the failed logins
+	LoginScope Scope = "login"
+	// PasswordResetScope counts the password reset requests, which mail the user
+	PasswordResetScope Scope = "password_reset"
+	// MFAScope counts the wrong MFA codes
+	MFAScope Scope = "mfa"
+	// ClientCredentialsScope counts the failed authentications of the service accounts
+	ClientCredentialsScope Scope = "client_credentials"
+)
+
+// Lockout gives the time for which a client is locked after the given number of failures, zero below the threshold
+func Lockout(failures, threshold int) time.Duration {
+	if failures < threshold {
+		return 0
+	}
+	lockout := BaseLockout
+	for i := threshold; i < failures && lockout < MaxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > MaxLockout {
+		lockout = MaxLockout
+	}
+	return lockout
+}
+
+// CheckUser returns ErrAccountLocked while the login of the user is locked
+func CheckUser(user *models.UserCredentials) error {
+	if user.IsLocked() {
+		return errors.ErrAccountLocked
+	}
+	return nil
+}
+
+// RecordUserFailure counts a failed login of the user, locking its login once UserThreshold is reached
+func RecordUserFailure(userStore *store.UserStore, user *models.UserCredentials) error {
+	updatedUser, err := userStore.IncrementFailedLogins(user.UID)
+	if err != nil {
+		return err
+	}
+	if lockout := Lockout(updatedUser.FailedLogins, UserThreshold); lockout > 0 {
+		return userStore.LockUser(user.UID, time.Now().Add(lockout))
+	}
+	return nil
+}
+
+// ResetUser forgets the failed logins of the user after a successful login
+func ResetUser(userStore *store.UserStore, user *models.UserCredentials) error {
+	if user.FailedLogins == 0 && user.LockedUntil == nil {
+		return nil
+	}
+	return userStore.ResetFailedLogins(user.UID)
+}
+
+// UnlockUser unlocks the login of the user having the given uid, on behalf of an admin
+func UnlockUser(userStore *store.UserStore, uid string) (*models.PublicUserInfo, error) {
+	user, err := usermanager.GetUserByUID(userStore, uid)
+	if err != nil {
+		return nil, err
+	}
+	err = userStore.ResetFailedLogins(user.UID)
+	if err != nil {
+		return nil, err
+	}
+	user.FailedLogins, user.LockedUntil = 0, nil
+	return user.GetPublicInfo(), nil
+}
+
+// CheckClient returns ErrTooManyRequests while the client ip is locked for the requests of the scope
+func CheckClient(loginAttemptStore *store.LoginAttemptStore, scope Scope, clientIP string) error {
+	return checkLocked(loginAttemptStore, clientKey(scope, clientIP))
+}
+
+// RecordClientFailure counts a failed attempt of the client ip, locking the client once IPThreshold is reached
+func RecordClientFailure(loginAttemptStore *store.LoginAttemptStore, scope Scope, clientIP string) error {
+	return recordFailure(loginAttemptStore, clientKey(scope, clientIP), IPThreshold)
+}
+
+// CheckUserAttempts returns ErrTooManyRequests while the user is locked for the requests of the scope
+func CheckUserAttempts(loginAttemptStore *store.LoginAttemptStore, scope Scope, user *models.UserCredentials) error {
+	return checkLocked(loginAttemptStore, userKey(scope, user))
+}
+
+// RecordUserAttemptFailure counts a failed attempt of the user for the scope, locking the user for the requests
+// of the scope once UserThreshold is reached
+func RecordUserAttemptFailure(loginAttemptStore *store.LoginAttemptStore, scope Scope, user *models.UserCredentials) error {
+	return recordFailure(loginAttemptStore, userKey(scope, user), UserThreshold)
+}
+
+// ResetUserAttempts forgets the failed attempts of the user for the scope
+func ResetUserAttempts(loginAttemptStore *store.LoginAttemptStore, scope Scope, user *models.UserCredentials) error {
+	return loginAttemptStore.Remove(userKey(scope, user))
+}
+
+// ThrottleUser counts a request of the scope for the user, which is refused with ErrTooManyRequests once
+// UserThreshold requests are made within AttemptWindow
+func ThrottleUser(loginAttemptStore *store.LoginAttemptStore, scope Scope, user *models.UserCredentials) error {
+	err := CheckUserAttempts(loginAttemptStore, scope, user)
+	if err != nil {
+		return err
+	}
+	return RecordUserAttemptFailure(loginAttemptStore, scope, user)
+}
+
+// RecordChallengeFailure counts a wrong code for the MFA challenge having the given id, and reports whether
+// ChallengeThreshold is reached
+func RecordChallengeFailure(loginAttemptStore *store.LoginAttemptStore, challengeID string) (bool, error) {
+	attempt, err := loginAttemptStore.Increment(string(MFAScope)+":challenge:"+challengeID, time.Now().Add(AttemptWindow))
+	if err != nil {
+		return false, err
+	}
+	return attempt.Failures >= ChallengeThreshold, nil
+}
+
+func checkLocked(loginAttemptStore *store.LoginAttemptStore, key string) error {
+	attempt, err := loginAttemptStore.Get(key)
+	if err != nil {
+		return err
+	}
+	if attempt != nil && attempt.LockedUntil != nil && time.Now().Before(*attempt.LockedUntil) {
+		return errors.ErrTooManyRequests
+	}
+	return nil
+}
+
+func recordFailure(loginAttemptStore *store.LoginAttemptStore, key string, threshold int) error {
+	now := time.Now()
+	attempt, err := loginAttemptStore.Increment(key, now.Add(AttemptWindow))
+	if err != nil {
+		return err
+	}
+	if lockout := Lockout(attempt.Failures, threshold); lockout > 0 {
+		return loginAttemptStore.Lock(key, now.Add(lockout))
+	}
+	return nil
+}
+
+func clientKey(scope Scope, clientIP string) string {
+	return string(scope) + ":ip:" + clientIP
+}
+
+func userKey(scope Scope, user *models.UserCredentials) string {
+	return string(scope) + ":user:" + user.UID
+}
@@ -0,0 +1,154 @@
+package lockoutmanager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mayadata-io/kubera-auth/manager/usermanager"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/testutil"
+)
+
+func TestLockout(t *testing.T) {
+	for _, test := range []struct {
+		failures int
+		lockout  time.Duration
+	}{
+		{failures: 0, lockout: 0},
+		{failures: UserThreshold - 1, lockout: 0},
+		{failures: UserThreshold, lockout: BaseLockout},
+		{failures: UserThreshold + 1, lockout: BaseLockout * 2},
+		{failures: UserThreshold + 2, lockout: BaseLockout * 4},
+		{failures: UserThreshold + 6, lockout: MaxLockout},
+		{failures: UserThreshold + 100, lockout: MaxLockout},
+	} {
+		if lockout := Lockout(test.failures, UserThreshold); lockout != test.lockout {
+			t.Errorf("Lockout(%d) = %v, expected %v", test.failures, lockout, test.lockout)
+		}
+	}
+}
+
+// lockedFor gives the time for which the login of the user is locked
+func lockedFor(t *testing.T, stores *testutil.Stores, user *models.UserCredentials) time.Duration {
+	t.Helper()
+	storedUser, err := usermanager.GetUserByUID(stores.User, user.UID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = CheckUser(storedUser); storedUser.LockedUntil == nil {
+		if err != nil {
+			t.Fatalf("expected an unlocked user to be accepted, got %v", err)
+		}
+		return 0
+	} else if err != errors.ErrAccountLocked {
+		t.Fatalf("expected a locked user to be refused, got %v", err)
+	}
+	return time.Until(*storedUser.LockedUntil).Round(time.Minute)
+}
+
+func TestRecordUserFailure(t *testing.T) {
+	stores := testutil.NewStores(t)
+	user := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+
+	for i := 1; i < UserThreshold; i++ {
+		if err := RecordUserFailure(stores.User, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if lockout := lockedFor(t, stores, user); lockout != 0 {
+		t.Fatalf("expected the user not to be locked below the threshold, got %v", lockout)
+	}
+
+	// The lockout doubles with each failure past the threshold
+	for _, expected := range []time.Duration{BaseLockout, BaseLockout * 2, BaseLockout * 4} {
+		if err := RecordUserFailure(stores.User, user); err != nil {
+			t.Fatal(err)
+		}
+		if lockout := lockedFor(t, stores, user); lockout != expected {
+			t.Fatalf("expected the user to be locked for %v, got %v", expected, lockout)
+		}
+	}
+
+	// Writing the stale copy of the user doesn't unlock it
+	if err := stores.User.UpdateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	if lockout := lockedFor(t, stores, user); lockout != BaseLockout*4 {
+		t.Fatalf("expected the user to stay locked after an update, got %v", lockout)
+	}
+
+	storedUser, err := usermanager.GetUserByUID(stores.User, user.UID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ResetUser(stores.User, storedUser); err != nil {
+		t.Fatal(err)
+	}
+	if lockout := lockedFor(t, stores, user); lockout != 0 {
+		t.Fatalf("expected the user to be unlocked after a successful login, got %v", lockout)
+	}
+}
+
+func TestUnlockUser(t *testing.T) {
+	stores := testutil.NewStores(t)
+	user := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+	for i := 0; i < UserThreshold; i++ {
+		if err := RecordUserFailure(stores.User, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	userInfo, err := UnlockUser(stores.User, user.UID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userInfo.Locked {
+		t.Fatal("expected the unlocked user not to be reported as locked")
+	}
+	if lockout := lockedFor(t, stores, user); lockout != 0 {
+		t.Fatalf("expected the user to be unlocked by the admin, got %v", lockout)
+	}
+}
+
+func TestRecordClientFailure(t *testing.T) {
+	stores := testutil.NewStores(t)
+
+	for i := 0; i < IPThreshold; i++ {
+		if err := CheckClient(stores.LoginAttempt, LoginScope, "192.0.2.1"); err != nil {
+			t.Fatalf("expected the client to be accepted below the threshold, got %v", err)
+		}
+		if err := RecordClientFailure(stores.LoginAttempt, LoginScope, "192.0.2.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := CheckClient(stores.LoginAttempt, LoginScope, "192.0.2.1"); err != errors.ErrTooManyRequests {
+		t.Fatalf("expected the client to be locked, got %v", err)
+	}
+
+	// The lock is limited to the client ip and to the scope
+	if err := CheckClient(stores.LoginAttempt, LoginScope, "192.0.2.2"); err != nil {
+		t.Fatalf("expected another client to be accepted, got %v", err)
+	}
+	if err := CheckClient(stores.LoginAttempt, PasswordResetScope, "192.0.2.1"); err != nil {
+		t.Fatalf("expected the client to be accepted for another scope, got %v", err)
+	}
+}
+
+func TestThrottleUser(t *testing.T) {
+	stores := testutil.NewStores(t)
+	user := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+	otherUser := testutil.NewUser(t, stores.User, "asmith", models.RoleUser)
+
+	for i := 0; i < UserThreshold; i++ {
+		if err := ThrottleUser(stores.LoginAttempt, PasswordResetScope, user); err != nil {
+			t.Fatalf("expected request %d to be accepted, got %v", i+1, err)
+		}
+	}
+	if err := ThrottleUser(stores.LoginAttempt, PasswordResetScope, user); err != errors.ErrTooManyRequests {
+		t.Fatalf("expected the user to be throttled, got %v", err)
+	}
+	if err := ThrottleUser(stores.LoginAttempt, PasswordResetScope, otherUser); err != nil {
+		t.Fatalf("expected another user not to be throttled, got %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+package loginmanager
+
+import (
+	"github.com/mayadata-io/kubera-auth/manager/devicemanager"
+	"github.com/mayadata-io/kubera-auth/manager/jwtmanager"
+	"github.com/mayadata-io/kubera-auth/manager/usermanager"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/generates"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/store"
+)
+
+// DeviceCodeLoginUser logs in the user who approved the device authorization of the device code, the device gets
+// a login token and a refresh token of the requested scope like any other login
+func DeviceCodeLoginUser(userStore *store.UserStore, refreshTokenStore *store.RefreshTokenStore, deviceAuthorizationStore *store.DeviceAuthorizationStore, accessGenerate *generates.JWTAccessGenerate, deviceCode string) (*models.Token, error) {
+	authorization, err := devicemanager.RedeemDeviceCode(deviceAuthorizationStore, deviceCode)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := usermanager.GetUserByUID(userStore, authorization.UserUID)
+	if err == errors.ErrInvalidUser {
+		return nil, errors.ErrInvalidGrant
+	} else if err != nil {
+		return nil, err
+	}
+	if user.State == models.StateRemoved {
+		return nil, errors.ErrInvalidGrant
+	}
+
+	tgr := &jwtmanager.TokenGenerateRequest{
+		UserInfo: user.GetPublicInfo(),
+		Scope:    authorization.Scope,
+	}
+	return completeLocalLogin(userStore, refreshTokenStore, accessGenerate, tgr)
+}
@@ -0,0 +1,60 @@
+package loginmanager
+
+import (
+	"testing"
+
+	"github.com/mayadata-io/kubera-auth/manager/devicemanager"
+	"github.com/mayadata-io/kubera-auth/manager/jwtmanager"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/testutil"
+)
+
+func TestDeviceCodeLoginUser(t *testing.T) {
+	stores := testutil.NewStores(t)
+	accessGenerate := testutil.NewAccessGenerate(t)
+	user := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+	authorization, deviceCode, err := devicemanager.CreateDeviceAuthorization(stores.DeviceAuthorization, models.ScopeUsersRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = DeviceCodeLoginUser(stores.User, stores.RefreshToken, stores.DeviceAuthorization, accessGenerate, deviceCode); err != errors.ErrAuthorizationPending {
+		t.Fatalf("expected the device to wait for the user, got %v", err)
+	}
+	if _, err = devicemanager.DecideDeviceAuthorization(stores.DeviceAuthorization, authorization.UserCode, user, true); err != nil {
+		t.Fatal(err)
+	}
+	// The device polled just before, the approval is redeemed once it waits for its interval
+	if _, err = DeviceCodeLoginUser(stores.User, stores.RefreshToken, stores.DeviceAuthorization, accessGenerate, deviceCode); err != errors.ErrSlowDown {
+		t.Fatalf("expected the device polling too fast to slow down, got %v", err)
+	}
+
+	authorization, deviceCode, err = devicemanager.CreateDeviceAuthorization(stores.DeviceAuthorization, models.ScopeUsersRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = devicemanager.DecideDeviceAuthorization(stores.DeviceAuthorization, authorization.UserCode, user, true); err != nil {
+		t.Fatal(err)
+	}
+	ti, err := DeviceCodeLoginUser(stores.User, stores.RefreshToken, stores.DeviceAuthorization, accessGenerate, deviceCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ti.GetRefresh() == "" {
+		t.Error("expected the device to get a refresh token")
+	}
+	loggedIn, err := jwtmanager.ParseLoginToken(stores.User, stores.Revocation, accessGenerate, ti.GetAccess(), models.ScopeUsersRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loggedIn.UID != user.UID {
+		t.Errorf("expected the user who approved the device to be logged in, got %s", loggedIn.UserName)
+	}
+	if _, err = jwtmanager.ParseLoginToken(stores.User, stores.Revocation, accessGenerate, ti.GetAccess(), models.ScopeUsersWrite); err != errors.ErrInsufficientScope {
+		t.Errorf("expected the token to be restricted to the requested scope, got %v", err)
+	}
+	if _, err = DeviceCodeLoginUser(stores.User, stores.RefreshToken, stores.DeviceAuthorization, accessGenerate, deviceCode); err != errors.ErrInvalidGrant {
+		t.Errorf("expected the device code to be redeemed once, got %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package loginmanager
+
+import (
+	"strings"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	log "github.com/golang/glog"
+
+	"github.com/mayadata-io/kubera-auth/manager/jwtmanager"
+	"github.com/mayadata-io/kubera-auth/manager/lockoutmanager"
+	"github.com/mayadata-io/kubera-auth/manager/usermanager"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/generates"
+	"github.com/mayadata-io/kubera-auth/pkg/ldap"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/store"
+)
+
+// LDAPLoginUser binds as the user to the LDAP directory and provisions the user on its first login, the name, email
+// and role of the user are updated from the directory on every login. The users who have to complete the login with
+// MFA are given an MFA challenge instead of a login token. The failed logins lock the client ip for a while, as for
+// the local logins. The login token is restricted to the scope, if any.
+func LDAPLoginUser(userStore *store.UserStore, refreshTokenStore *store.RefreshTokenStore, loginAttemptStore *store.LoginAttemptStore, accessGenerate *generates.JWTAccessGenerate, directory *ldap.Config, username, password, scope, clientIP string, enforceAdminMFA bool) (*models.Token, *models.MFAChallenge, error) {
+	err := lockoutmanager.CheckClient(loginAttemptStore, lockoutmanager.LoginScope, clientIP)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entry, err := directory.Authenticate(username, password)
+	switch err {
+	case nil:
+	case ldap.ErrInvalidCredentials:
+		recordFailure(userStore, loginAttemptStore, nil, clientIP)
+		return nil, nil, errors.ErrInvalidPassword
+	case ldap.ErrUserNotFound:
+		recordFailure(userStore, loginAttemptStore, nil, clientIP)
+		return nil, nil, errors.ErrInvalidUser
+	default:
+		log.Errorln("Error authenticating the user with the ldap directory ", err)
+		return nil, nil, errors.ErrTemporarilyUnavailable
+	}
+
+	if len(directory.AllowedGroups) > 0 && !directory.IsMember(entry, directory.AllowedGroups) {
+		return nil, nil, errors.ErrMembershipRequired
+	}
+
+	user := newLDAPUser(directory, entry, username)
+	storedUser, err := provisionUser(userStore, user, len(directory.AdminGroups) > 0, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if storedUser.Kind == models.LDAPAuth {
+		if err = syncLDAPUser(userStore, storedUser, user); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	tgr := &jwtmanager.TokenGenerateRequest{
+		UserInfo: storedUser.GetPublicInfo(),
+		Scope:    scope,
+	}
+	return loginOrChallenge(userStore, refreshTokenStore, accessGenerate, tgr, storedUser, enforceAdminMFA)
+}
+
+// syncLDAPUser updates the name and the email of the user provisioned by the directory. The email is kept
+// when another user owns the email of the directory.
+func syncLDAPUser(userStore *store.UserStore, storedUser, user *models.UserCredentials) error {
+	changed := false
+	if storedUser.Name != user.Name {
+		storedUser.Name = user.Name
+		changed = true
+	}
+	if user.Email != "" && storedUser.Email != user.Email {
+		_, err := usermanager.GetUser(userStore, bson.M{"email": user.Email})
+		if err == errors.ErrInvalidUser {
+			storedUser.Email = user.Email
+			changed = true
+		} else if err != nil {
+			return err
+		} else {
+			log.Warningln("Email of the ldap user is owned by another user, keeping the email of uid: ", storedUser.UID)
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return userStore.UpdateUser(storedUser)
+}
+
+// newLDAPUser maps the attributes of the entry of the directory onto a user
+func newLDAPUser(directory *ldap.Config, entry *ldap.Entry, username string) *models.UserCredentials {
+	// The username is matched case insensitively by the directories
+	ldapUsername := entry.Get(directory.UserAttribute)
+	if ldapUsername == "" {
+		ldapUsername = username
+	}
+	name := entry.Get(directory.NameAttribute)
+	if name == "" {
+		name = ldapUsername
+	}
+
+	currTime := time.Now()
+	user := &models.UserCredentials{
+		Name:         name,
+		Kind:         models.LDAPAuth,
+		Role:         models.RoleUser,
+		State:        models.StateActive,
+		LoggedIn:     true,
+		SocialAuthID: strings.ToLower(ldapUsername),
+		CreatedAt:    &currTime,
+	}
+	// The emails of the directory are managed by its administrators, they are considered as verified
+	if email := entry.Get(directory.EmailAttribute); email != "" {
+		user.Email = email
+		user.OnBoardingState = models.BoardingStateEmailVerified
+	}
+	if directory.IsMember(entry, directory.AdminGroups) {
+		user.Role = models.RoleAdmin
+	}
+	return user
+}
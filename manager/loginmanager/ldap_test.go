@@ -0,0 +1,85 @@
+package loginmanager
+
+import (
+	"testing"
+
+	"github.com/mayadata-io/kubera-auth/manager/lockoutmanager"
+	"github.com/mayadata-io/kubera-auth/manager/usermanager"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/ldap"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/testutil"
+)
+
+func TestLDAPLoginUserLocksClient(t *testing.T) {
+	stores := testutil.NewStores(t)
+	accessGenerate := testutil.NewAccessGenerate(t)
+	for i := 0; i < lockoutmanager.IPThreshold; i++ {
+		if err := lockoutmanager.RecordClientFailure(stores.LoginAttempt, lockoutmanager.LoginScope, clientIP); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The directory isn't reached once the client is locked
+	directory := &ldap.Config{URL: "ldap://127.0.0.1:1"}
+	_, _, err := LDAPLoginUser(stores.User, stores.RefreshToken, stores.LoginAttempt, accessGenerate, directory, "jdoe", "password", "", clientIP, false)
+	if err != errors.ErrTooManyRequests {
+		t.Fatalf("expected the client to be locked, got %v", err)
+	}
+}
+
+func TestLDAPUserIsNotLinkedToOtherAccounts(t *testing.T) {
+	stores := testutil.NewStores(t)
+	localUser := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+
+	user := newSocialUser(models.LDAPAuth, "jdoe", localUser.Email, models.RoleAdmin)
+	if _, err := provisionUser(stores.User, user, true, false); err != errors.ErrUserExists {
+		t.Fatalf("expected the ldap user not to be linked to the local user owning its email, got %v", err)
+	}
+	storedUser, err := usermanager.GetUserByUID(stores.User, localUser.UID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if storedUser.Role != models.RoleUser || len(storedUser.Identities) != 0 {
+		t.Fatalf("expected the local user to be left as is, got %+v", storedUser)
+	}
+}
+
+func TestSyncLDAPUserKeepsEmailOfOtherUsers(t *testing.T) {
+	stores := testutil.NewStores(t)
+	localUser := testutil.NewUser(t, stores.User, "asmith", models.RoleUser)
+	provisionedUser, err := provisionUser(stores.User, newSocialUser(models.LDAPAuth, "jdoe", "jdoe@example.org", models.RoleUser), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storedUser, err := usermanager.GetUserByUID(stores.User, provisionedUser.UID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The directory gives the email of another user
+	user := newSocialUser(models.LDAPAuth, "jdoe", localUser.Email, models.RoleUser)
+	user.Name = "Jane Doe"
+	if err = syncLDAPUser(stores.User, storedUser, user); err != nil {
+		t.Fatal(err)
+	}
+	storedUser, err = usermanager.GetUserByUID(stores.User, storedUser.UID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if storedUser.Name != "Jane Doe" || storedUser.Email != "jdoe@example.org" {
+		t.Fatalf("expected the name to be synced and the email to be kept, got %+v", storedUser)
+	}
+
+	user.Email = "jane.doe@example.org"
+	if err = syncLDAPUser(stores.User, storedUser, user); err != nil {
+		t.Fatal(err)
+	}
+	storedUser, err = usermanager.GetUserByUID(stores.User, storedUser.UID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if storedUser.Email != "jane.doe@example.org" {
+		t.Fatalf("expected the free email to be synced, got %+v", storedUser)
+	}
+}
@@ -1,50 +1,194 @@
 package loginmanager
 
 import (
+	"time"
+
+	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
-	"golang.org/x/crypto/bcrypt"
+	log "github.com/golang/glog"
 
 	"github.com/mayadata-io/kubera-auth/manager/jwtmanager"
+	"github.com/mayadata-io/kubera-auth/manager/lockoutmanager"
+	"github.com/mayadata-io/kubera-auth/manager/mfamanager"
 	"github.com/mayadata-io/kubera-auth/manager/usermanager"
 	"github.com/mayadata-io/kubera-auth/pkg/errors"
 	"github.com/mayadata-io/kubera-auth/pkg/generates"
 	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/passwordhash"
 	"github.com/mayadata-io/kubera-auth/pkg/store"
 )
 
-// LocalLoginUser verifies user password
-func LocalLoginUser(userStore *store.UserStore, accessGenerate *generates.JWTAccessGenerate, username, password string) (*models.Token, error) {
-	tgr, err := validationAuthenticateRequest(userStore, username, password)
+// LocalLoginUser verifies user password. The users who have to complete the login with MFA
+// are given an MFA challenge instead of a login token. The failed logins lock the user and the
+// client ip for a while, see lockoutmanager. The login token is restricted to the scope, if any.
+func LocalLoginUser(userStore *store.UserStore, refreshTokenStore *store.RefreshTokenStore, loginAttemptStore *store.LoginAttemptStore, accessGenerate *generates.JWTAccessGenerate, hasher *passwordhash.Hasher, username, password, scope, clientIP string, enforceAdminMFA bool) (*models.Token, *models.MFAChallenge, error) {
+	err := lockoutmanager.CheckClient(loginAttemptStore, lockoutmanager.LoginScope, clientIP)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tgr, user, err := validationAuthenticateRequest(userStore, loginAttemptStore, hasher, username, password, clientIP)
+	if err != nil {
+		return nil, nil, err
+	}
+	tgr.Scope = scope
+	return loginOrChallenge(userStore, refreshTokenStore, accessGenerate, tgr, user, enforceAdminMFA)
+}
+
+// loginOrChallenge logs in the user authenticated by its first factor, the users who have to complete the login
+// with MFA are given an MFA challenge instead, which carries the requested scope till the login is completed
+func loginOrChallenge(userStore *store.UserStore, refreshTokenStore *store.RefreshTokenStore, accessGenerate *generates.JWTAccessGenerate, tgr *jwtmanager.TokenGenerateRequest, user *models.UserCredentials, enforceAdminMFA bool) (*models.Token, *models.MFAChallenge, error) {
+	if mfamanager.IsRequired(user, enforceAdminMFA) {
+		tgr.AccessTokenExp = mfamanager.ChallengeExp
+		ti, err := jwtmanager.GenerateAuthToken(accessGenerate, tgr, models.TokenMFA)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, &models.MFAChallenge{
+			MFAToken:  ti.GetAccess(),
+			ExpiresIn: int64(ti.GetAccessExpiresIn() / time.Second),
+			Enrolled:  mfamanager.IsEnrolled(user),
+			Methods:   mfamanager.Methods(user),
+		}, nil
+	}
+
+	ti, err := completeLocalLogin(userStore, refreshTokenStore, accessGenerate, tgr)
+	return ti, nil, err
+}
+
+// MFALoginUser completes the login of a user with the MFA challenge token and a code of the user,
+// the challenge token can be used only once. The wrong codes lock the user and the client ip for a
+// while, see lockoutmanager, and the challenge is revoked after too many of them.
+func MFALoginUser(userStore *store.UserStore, refreshTokenStore *store.RefreshTokenStore, revocationStore *store.RevocationStore, loginAttemptStore *store.LoginAttemptStore, accessGenerate *generates.JWTAccessGenerate, mfaToken, code, clientIP string) (*models.Token, error) {
+	err := lockoutmanager.CheckClient(loginAttemptStore, lockoutmanager.MFAScope, clientIP)
 	if err != nil {
 		return nil, err
 	}
 
-	ti, err := jwtmanager.GenerateAuthToken(accessGenerate, tgr, models.TokenLogin)
+	user, err := jwtmanager.ParseMFAToken(userStore, revocationStore, accessGenerate, mfaToken)
+	if err != nil {
+		return nil, errors.ErrInvalidGrant
+	}
+	err = lockoutmanager.CheckUserAttempts(loginAttemptStore, lockoutmanager.MFAScope, user)
+	if err != nil {
+		return nil, err
+	}
+
+	err = mfamanager.Verify(userStore, user, code)
+	if err == errors.ErrInvalidMFACode {
+		recordMFAFailure(revocationStore, loginAttemptStore, accessGenerate, user, mfaToken, clientIP)
+		return nil, err
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = lockoutmanager.ResetUserAttempts(loginAttemptStore, lockoutmanager.MFAScope, user)
+	if err != nil {
+		return nil, err
+	}
+	return CompleteMFALogin(userStore, refreshTokenStore, revocationStore, accessGenerate, user, mfaToken)
+}
+
+// recordMFAFailure records the wrong code against the user, the client ip and the challenge, which is revoked once
+// it reaches lockoutmanager.ChallengeThreshold. The login has failed in any case, the errors are only logged.
+func recordMFAFailure(revocationStore *store.RevocationStore, loginAttemptStore *store.LoginAttemptStore, accessGenerate *generates.JWTAccessGenerate, user *models.UserCredentials, mfaToken, clientIP string) {
+	if err := lockoutmanager.RecordUserAttemptFailure(loginAttemptStore, lockoutmanager.MFAScope, user); err != nil {
+		log.Errorln("Error recording the wrong MFA code of the user ", err)
+	}
+	if err := lockoutmanager.RecordClientFailure(loginAttemptStore, lockoutmanager.MFAScope, clientIP); err != nil {
+		log.Errorln("Error recording the wrong MFA code of the client ", err)
+	}
+
+	claims, err := accessGenerate.ParseClaims(mfaToken)
+	if err != nil {
+		log.Errorln("Error parsing the MFA challenge ", err)
+		return
+	}
+	exhausted, err := lockoutmanager.RecordChallengeFailure(loginAttemptStore, claims.Id)
 	if err != nil {
+		log.Errorln("Error recording the wrong MFA code of the challenge ", err)
+	} else if exhausted {
+		if err = jwtmanager.RevokeToken(revocationStore, accessGenerate, mfaToken); err != nil {
+			log.Errorln("Error revoking the MFA challenge ", err)
+		}
+	}
+}
+
+// CompleteMFALogin consumes the MFA challenge token of the user and logs in the user with the scope requested
+// along with the challenge. A challenge which has already been consumed is rejected with ErrInvalidGrant.
+func CompleteMFALogin(userStore *store.UserStore, refreshTokenStore *store.RefreshTokenStore, revocationStore *store.RevocationStore, accessGenerate *generates.JWTAccessGenerate, user *models.UserCredentials, mfaToken string) (*models.Token, error) {
+	claims, err := accessGenerate.ParseClaims(mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	err = jwtmanager.ConsumeToken(revocationStore, accessGenerate, mfaToken)
+	if err == errors.ErrRevokedAccessToken {
+		return nil, errors.ErrInvalidGrant
+	} else if err != nil {
 		return nil, err
 	}
 
-	storedUser, err := usermanager.GetUser(userStore, bson.M{"username": username, "kind": models.LocalAuth})
+	tgr := &jwtmanager.TokenGenerateRequest{
+		UserInfo: user.GetPublicInfo(),
+		Scope:    claims.Scope,
+	}
+	return completeLocalLogin(userStore, refreshTokenStore, accessGenerate, tgr)
+}
+
+// PasswordlessLoginUser logs in the user who has been authenticated without a password, such as with a passkey
+func PasswordlessLoginUser(userStore *store.UserStore, refreshTokenStore *store.RefreshTokenStore, accessGenerate *generates.JWTAccessGenerate, user *models.UserCredentials) (*models.Token, error) {
+	tgr := &jwtmanager.TokenGenerateRequest{
+		UserInfo: user.GetPublicInfo(),
+	}
+	return completeLocalLogin(userStore, refreshTokenStore, accessGenerate, tgr)
+}
+
+// completeLocalLogin generates the login token of the local user and marks the user as logged in
+func completeLocalLogin(userStore *store.UserStore, refreshTokenStore *store.RefreshTokenStore, accessGenerate *generates.JWTAccessGenerate, tgr *jwtmanager.TokenGenerateRequest) (*models.Token, error) {
+	ti, err := generateLoginToken(refreshTokenStore, accessGenerate, tgr, "")
 	if err != nil {
 		return nil, err
 	}
-	storedUser.LoggedIn = true
-	return ti, userStore.UpdateUser(storedUser)
+
+	return ti, userStore.SetLoggedIn(tgr.UserInfo.UID, true)
 }
 
-// SocialLoginUser get the user information
-func SocialLoginUser(userStore *store.UserStore, accessGenerate *generates.JWTAccessGenerate, user *models.UserCredentials) (*models.Token, error) {
-	query := bson.M{"social_auth_id": user.SocialAuthID, "kind": user.Kind}
-	storedUser, err := usermanager.GetUser(userStore, query)
+// SocialLoginUser get the user information and gives the one-time login code the portal exchanges for the tokens
+// of the user, see LoginCodeLoginUser. The tokens themselves never travel in the redirect to the portal.
+// syncRole updates the role of an existing user to the one given by the provider.
+// When linkEmail is set, an account of the provider which isn't linked yet is linked to the user owning the email the
+// provider asserts as verified. It must only be set for the providers trusted to verify the emails.
+func SocialLoginUser(userStore *store.UserStore, loginCodeStore *store.LoginCodeStore, user *models.UserCredentials, syncRole, linkEmail bool) (string, error) {
+	storedUser, err := provisionUser(userStore, user, syncRole, linkEmail)
+	if err != nil {
+		return "", err
+	}
+	return generateLoginCode(loginCodeStore, storedUser.UID)
+}
+
+// provisionUser gives the stored user of the identity given by an external provider, creating it if it does not exist.
+// The role is only synced for the users provisioned by the provider, not for the accounts it is linked to.
+func provisionUser(userStore *store.UserStore, user *models.UserCredentials, syncRole, linkEmail bool) (*models.UserCredentials, error) {
+	storedUser, err := usermanager.GetUserByIdentity(userStore, user.Kind, user.SocialAuthID)
+	if err == errors.ErrInvalidUser && linkEmail && user.Email != "" {
+		storedUser, err = linkVerifiedEmail(userStore, user)
+	}
 	if err == nil && storedUser != nil {
 		// If user exists, set loggedIn to true & update photo
+		var role models.Role
+		if syncRole && storedUser.Kind == user.Kind {
+			role = user.Role
+		}
+		err = userStore.SetSocialLogin(storedUser.UID, user.Photo, role)
+		if err != nil {
+			return nil, err
+		}
 		storedUser.LoggedIn = true
 		if user.Photo != "" {
 			storedUser.Photo = user.Photo
 		}
-		err = userStore.UpdateUser(storedUser)
-		if err != nil {
-			return nil, err
+		if role != "" {
+			storedUser.Role = role
 		}
 	} else if err == errors.ErrInvalidUser {
 		// If user does not exist
@@ -57,37 +201,144 @@ func SocialLoginUser(userStore *store.UserStore, accessGenerate *generates.JWTAc
 		// Error other than user exists
 		return nil, err
 	}
+	return storedUser, nil
+}
+
+// linkVerifiedEmail links the account of the provider to the user having the same verified email
+func linkVerifiedEmail(userStore *store.UserStore, user *models.UserCredentials) (*models.UserCredentials, error) {
+	storedUser, err := usermanager.GetUser(userStore, bson.M{"email": user.Email})
+	if err != nil {
+		return nil, err
+	}
+
+	identity := usermanager.NewIdentity(user)
+	err = usermanager.LinkIdentity(userStore, storedUser.UID, identity)
+	if err != nil {
+		return nil, err
+	}
+	storedUser.Identities = append(storedUser.Identities, identity)
+	return storedUser, nil
+}
+
+// RefreshLoginUser exchanges a refresh token for a new access token and rotates the refresh token,
+// the new tokens keep the scope of the login
+func RefreshLoginUser(userStore *store.UserStore, refreshTokenStore *store.RefreshTokenStore, accessGenerate *generates.JWTAccessGenerate, refresh string) (*models.Token, error) {
+	storedToken, err := jwtmanager.UseRefreshToken(refreshTokenStore, refresh, "")
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := usermanager.GetUserByUID(userStore, storedToken.UserUID)
+	if err == errors.ErrInvalidUser {
+		return nil, errors.ErrInvalidGrant
+	} else if err != nil {
+		return nil, err
+	}
+	if user.State == models.StateRemoved {
+		return nil, errors.ErrInvalidGrant
+	}
 
 	tgr := &jwtmanager.TokenGenerateRequest{
-		UserInfo: storedUser.GetPublicInfo(),
+		UserInfo: user.GetPublicInfo(),
+		Scope:    storedToken.Scope,
 	}
-	return jwtmanager.GenerateAuthToken(accessGenerate, tgr, models.TokenLogin)
+	return generateLoginToken(refreshTokenStore, accessGenerate, tgr, storedToken.FamilyID)
 }
 
-// validationAuthenticateRequest the authenticate request validation
-func validationAuthenticateRequest(userStore *store.UserStore, username, password string) (*jwtmanager.TokenGenerateRequest, error) {
-	user, err := userStore.GetUser(bson.M{"username": username, "kind": models.LocalAuth})
+// generateLoginToken generates a login access token along with a refresh token of the given family
+func generateLoginToken(refreshTokenStore *store.RefreshTokenStore, accessGenerate *generates.JWTAccessGenerate, tgr *jwtmanager.TokenGenerateRequest, familyID string) (*models.Token, error) {
+	ti, err := jwtmanager.GenerateAuthToken(accessGenerate, tgr, models.TokenLogin)
 	if err != nil {
 		return nil, err
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	err = jwtmanager.GenerateRefreshToken(refreshTokenStore, tgr, ti, familyID)
 	if err != nil {
-		return nil, errors.ErrInvalidPassword
+		return nil, err
+	}
+	return ti, nil
+}
+
+// validationAuthenticateRequest the authenticate request validation, the failed attempts are recorded.
+// The password is rehashed once verified if it was hashed with another algorithm or other parameters.
+func validationAuthenticateRequest(userStore *store.UserStore, loginAttemptStore *store.LoginAttemptStore, hasher *passwordhash.Hasher, username, password, clientIP string) (*jwtmanager.TokenGenerateRequest, *models.UserCredentials, error) {
+	user, err := userStore.GetUser(bson.M{"username": username, "kind": models.LocalAuth})
+	if err == mgo.ErrNotFound {
+		recordFailure(userStore, loginAttemptStore, nil, clientIP)
+		return nil, nil, err
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	// The password of a locked user isn't even compared, the guesses are of no use till the user is unlocked
+	err = lockoutmanager.CheckUser(user)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	matched, err := passwordhash.Verify(user.Password, password)
+	if err != nil {
+		return nil, nil, err
+	} else if !matched {
+		recordFailure(userStore, loginAttemptStore, user, clientIP)
+		return nil, nil, errors.ErrInvalidPassword
+	}
+
+	err = lockoutmanager.ResetUser(userStore, user)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if hasher.NeedsRehash(user.Password) {
+		// The login succeeds even if the password couldn't be rehashed, it is rehashed on the next login
+		if err = rehashPassword(userStore, hasher, user, password); err != nil {
+			log.Errorln("Error rehashing the password of the user ", err)
+		}
 	}
 
 	req := &jwtmanager.TokenGenerateRequest{
 		UserInfo: user.GetPublicInfo(),
 	}
-	return req, nil
+	return req, user, nil
 }
 
-// LogoutUser verifies user password
-func LogoutUser(userStore *store.UserStore, id bson.ObjectId) error {
+// rehashPassword replaces the hash of the password of the user by a hash with the current algorithm and parameters
+func rehashPassword(userStore *store.UserStore, hasher *passwordhash.Hasher, user *models.UserCredentials, password string) error {
+	hash, err := hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+	err = userStore.UpdatePasswordHash(user.UID, hash)
+	if err != nil {
+		return err
+	}
+	user.Password = hash
+	return nil
+}
+
+// recordFailure records the failed login of the user, if any, and of the client ip. The login has failed in any
+// case, the errors of the records are only logged.
+func recordFailure(userStore *store.UserStore, loginAttemptStore *store.LoginAttemptStore, user *models.UserCredentials, clientIP string) {
+	if user != nil {
+		if err := lockoutmanager.RecordUserFailure(userStore, user); err != nil {
+			log.Errorln("Error recording the failed login of the user ", err)
+		}
+	}
+	if err := lockoutmanager.RecordClientFailure(loginAttemptStore, lockoutmanager.LoginScope, clientIP); err != nil {
+		log.Errorln("Error recording the failed login of the client ", err)
+	}
+}
+
+// LogoutUser marks the user as logged out and revokes all of its refresh tokens
+func LogoutUser(userStore *store.UserStore, refreshTokenStore *store.RefreshTokenStore, id bson.ObjectId) error {
 	storedUser, err := userStore.GetUserByID(id)
 	if err != nil {
 		return err
 	}
-	storedUser.LoggedIn = false
-	return userStore.UpdateUser(storedUser)
+
+	err = refreshTokenStore.RemoveByUserUID(storedUser.UID)
+	if err != nil {
+		return err
+	}
+	return userStore.SetLoggedIn(storedUser.UID, false)
 }
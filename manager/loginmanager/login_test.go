@@ -0,0 +1,121 @@
+package loginmanager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mayadata-io/kubera-auth/manager/usermanager"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/testutil"
+)
+
+// newSocialUser gives the user returned by the provider for its account
+func newSocialUser(provider models.AuthType, socialAuthID, email string, role models.Role) *models.UserCredentials {
+	createdAt := time.Now()
+	return &models.UserCredentials{
+		Name:            "John Doe",
+		Email:           email,
+		Kind:            provider,
+		Role:            role,
+		State:           models.StateActive,
+		OnBoardingState: models.BoardingStateEmailVerified,
+		SocialAuthID:    socialAuthID,
+		CreatedAt:       &createdAt,
+	}
+}
+
+func TestSocialLoginUserLinksEmailOfTrustedProviders(t *testing.T) {
+	stores := testutil.NewStores(t)
+	accessGenerate := testutil.NewAccessGenerate(t)
+	localUser := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+
+	user := newSocialUser(models.GithubAuth, "42", localUser.Email, models.RoleUser)
+	if _, err := SocialLoginUser(stores.User, stores.LoginCode, user, false, false); err != errors.ErrUserExists {
+		t.Fatalf("expected the account of an untrusted provider not to be linked, got %v", err)
+	}
+	if _, err := usermanager.GetUserByIdentity(stores.User, models.GithubAuth, "42"); err != errors.ErrInvalidUser {
+		t.Fatalf("expected the account not to be linked, got %v", err)
+	}
+
+	user = newSocialUser(models.GithubAuth, "42", localUser.Email, models.RoleUser)
+	code, err := SocialLoginUser(stores.User, stores.LoginCode, user, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	linkedUser, err := usermanager.GetUserByIdentity(stores.User, models.GithubAuth, "42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if linkedUser.UID != localUser.UID {
+		t.Fatalf("expected the account to be linked to the user owning the email, got %+v", linkedUser)
+	}
+	token, _, err := LoginCodeLoginUser(stores.User, stores.RefreshToken, stores.LoginCode, accessGenerate, code, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := accessGenerate.ParseClaims(token.GetAccess())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UID != localUser.UID {
+		t.Fatalf("expected the token of the user owning the email, got %v", claims.UID)
+	}
+}
+
+func TestSocialLoginUserDoesNotLinkUnverifiedEmail(t *testing.T) {
+	stores := testutil.NewStores(t)
+	localUser := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+
+	user := newSocialUser(models.GitlabAuth, "42", "", models.RoleUser)
+	user.UnverifiedEmail = localUser.Email
+	if _, err := SocialLoginUser(stores.User, stores.LoginCode, user, false, true); err != nil {
+		t.Fatal(err)
+	}
+	socialUser, err := usermanager.GetUserByIdentity(stores.User, models.GitlabAuth, "42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if socialUser.UID == localUser.UID {
+		t.Fatal("expected the account having an unverified email not to be linked")
+	}
+}
+
+func TestSocialLoginUserSyncsRoleOfProvisionedUsers(t *testing.T) {
+	stores := testutil.NewStores(t)
+
+	// The role of the users provisioned by the provider follows the provider
+	user := newSocialUser(models.GithubAuth, "42", "jdoe@example.org", models.RoleUser)
+	if _, err := SocialLoginUser(stores.User, stores.LoginCode, user, true, false); err != nil {
+		t.Fatal(err)
+	}
+	user = newSocialUser(models.GithubAuth, "42", "jdoe@example.org", models.RoleAdmin)
+	if _, err := SocialLoginUser(stores.User, stores.LoginCode, user, true, false); err != nil {
+		t.Fatal(err)
+	}
+	socialUser, err := usermanager.GetUserByIdentity(stores.User, models.GithubAuth, "42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if socialUser.Role != models.RoleAdmin {
+		t.Fatalf("expected the role of the provisioned user to be synced, got %v", socialUser.Role)
+	}
+
+	// The role of the accounts the provider is linked to is managed by kubera
+	localUser := testutil.NewUser(t, stores.User, "asmith", models.RoleUser)
+	err = usermanager.LinkIdentity(stores.User, localUser.UID, usermanager.NewIdentity(newSocialUser(models.GithubAuth, "43", "", models.RoleUser)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	user = newSocialUser(models.GithubAuth, "43", "asmith@example.org", models.RoleAdmin)
+	if _, err := SocialLoginUser(stores.User, stores.LoginCode, user, true, false); err != nil {
+		t.Fatal(err)
+	}
+	linkedUser, err := usermanager.GetUserByUID(stores.User, localUser.UID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if linkedUser.Role != models.RoleUser {
+		t.Fatalf("expected the role of the linked user to be kept, got %v", linkedUser.Role)
+	}
+}
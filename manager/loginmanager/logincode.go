@@ -0,0 +1,68 @@
+package loginmanager
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo"
+
+	"github.com/mayadata-io/kubera-auth/manager/jwtmanager"
+	"github.com/mayadata-io/kubera-auth/manager/usermanager"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/generates"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/store"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/digest"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/random"
+)
+
+const (
+	// LoginCodeExp is the time the portal has to exchange the login code it is redirected with
+	LoginCodeExp = time.Minute
+	// loginCodeLength is the number of random bytes in a login code
+	loginCodeLength = 32
+)
+
+// generateLoginCode stores a new one-time login code of the user and gives it back
+func generateLoginCode(loginCodeStore *store.LoginCodeStore, uid string) (string, error) {
+	code, err := random.GetSecureRandomString(loginCodeLength)
+	if err != nil {
+		return "", err
+	}
+
+	err = loginCodeStore.Set(&models.LoginCode{
+		Hash:      digest.SHA256(code),
+		UserUID:   uid,
+		ExpiresAt: time.Now().Add(LoginCodeExp),
+	})
+	if err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// LoginCodeLoginUser exchanges the one-time login code of a social login for the tokens of the user.
+// A code can be exchanged only once, the unknown, expired or already exchanged codes are rejected with ErrInvalidGrant.
+// The users who have to complete the login with MFA are given an MFA challenge instead of a login token.
+func LoginCodeLoginUser(userStore *store.UserStore, refreshTokenStore *store.RefreshTokenStore, loginCodeStore *store.LoginCodeStore, accessGenerate *generates.JWTAccessGenerate, code string, enforceAdminMFA bool) (*models.Token, *models.MFAChallenge, error) {
+	loginCode, err := loginCodeStore.Take(digest.SHA256(code))
+	if err == mgo.ErrNotFound {
+		return nil, nil, errors.ErrInvalidGrant
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := usermanager.GetUserByUID(userStore, loginCode.UserUID)
+	if err == errors.ErrInvalidUser {
+		return nil, nil, errors.ErrInvalidGrant
+	} else if err != nil {
+		return nil, nil, err
+	}
+	if user.State == models.StateRemoved {
+		return nil, nil, errors.ErrInvalidGrant
+	}
+
+	tgr := &jwtmanager.TokenGenerateRequest{
+		UserInfo: user.GetPublicInfo(),
+	}
+	return loginOrChallenge(userStore, refreshTokenStore, accessGenerate, tgr, user, enforceAdminMFA)
+}
@@ -0,0 +1,58 @@
+package loginmanager
+
+import (
+	"testing"
+
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/testutil"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/digest"
+)
+
+func TestLoginCodeLoginUser(t *testing.T) {
+	stores := testutil.NewStores(t)
+	accessGenerate := testutil.NewAccessGenerate(t)
+	user := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+
+	code, err := generateLoginCode(stores.LoginCode, user.UID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ti, _, err := LoginCodeLoginUser(stores.User, stores.RefreshToken, stores.LoginCode, accessGenerate, code, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ti.GetAccess() == "" || ti.GetRefresh() == "" {
+		t.Fatalf("expected an access and a refresh token, got %+v", ti)
+	}
+
+	if _, _, err = LoginCodeLoginUser(stores.User, stores.RefreshToken, stores.LoginCode, accessGenerate, code, false); err != errors.ErrInvalidGrant {
+		t.Fatalf("expected the exchanged login code to be rejected, got %v", err)
+	}
+	if _, _, err = LoginCodeLoginUser(stores.User, stores.RefreshToken, stores.LoginCode, accessGenerate, "unknown", false); err != errors.ErrInvalidGrant {
+		t.Fatalf("expected an unknown login code to be rejected, got %v", err)
+	}
+}
+
+func TestLoginCodeLoginUserExpired(t *testing.T) {
+	stores := testutil.NewStores(t)
+	accessGenerate := testutil.NewAccessGenerate(t)
+	user := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+
+	code, err := generateLoginCode(stores.LoginCode, user.UID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := stores.LoginCode.Take(digest.SHA256(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired.ExpiresAt = expired.ExpiresAt.Add(-2 * LoginCodeExp)
+	if err = stores.LoginCode.Set(expired); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err = LoginCodeLoginUser(stores.User, stores.RefreshToken, stores.LoginCode, accessGenerate, code, false); err != errors.ErrInvalidGrant {
+		t.Fatalf("expected the expired login code to be rejected, got %v", err)
+	}
+}
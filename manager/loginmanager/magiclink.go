@@ -0,0 +1,73 @@
+package loginmanager
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/mayadata-io/kubera-auth/manager/emailmanager"
+	"github.com/mayadata-io/kubera-auth/manager/jwtmanager"
+	"github.com/mayadata-io/kubera-auth/manager/usermanager"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/generates"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/store"
+)
+
+const (
+	// MagicLinkInterval is the time a user has to wait before requesting another magic link
+	MagicLinkInterval = time.Minute
+	// MagicLinkWindow is the period in which at most MagicLinkLimit magic links are mailed to a user
+	MagicLinkWindow = time.Hour
+	// MagicLinkLimit is the number of magic links mailed to a user within MagicLinkWindow
+	MagicLinkLimit = 5
+)
+
+// sendEmail mails the magic links, it is replaced in the tests
+var sendEmail = emailmanager.SendEmail
+
+// RequestMagicLink mails a single-use sign-in link to the local user owning the verified email. A user gets at most
+// a link per MagicLinkInterval and MagicLinkLimit of them per MagicLinkWindow, the other requests are refused with
+// ErrTooManyRequests.
+func RequestMagicLink(userStore *store.UserStore, accessGenerate *generates.JWTAccessGenerate, email string) error {
+	user, err := usermanager.GetUser(userStore, bson.M{"email": email, "kind": models.LocalAuth, "state": bson.M{"$ne": models.StateRemoved}})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = userStore.AddMagicLinkRequest(user.UID, now, now.Add(-MagicLinkInterval), now.Add(-MagicLinkWindow), MagicLinkLimit)
+	if err == mgo.ErrNotFound {
+		return errors.ErrTooManyRequests
+	} else if err != nil {
+		return err
+	}
+	return sendEmail(accessGenerate, user.GetPublicInfo(), emailmanager.MagicLinkEmail)
+}
+
+// MagicLinkLoginUser exchanges the token of a magic link for a login token, the token can be used only once.
+// The users who have to complete the login with MFA are given an MFA challenge instead of a login token.
+func MagicLinkLoginUser(userStore *store.UserStore, refreshTokenStore *store.RefreshTokenStore, revocationStore *store.RevocationStore, accessGenerate *generates.JWTAccessGenerate, magicLinkToken string, enforceAdminMFA bool) (*models.Token, *models.MFAChallenge, error) {
+	user, err := jwtmanager.ParseMagicLinkToken(userStore, revocationStore, accessGenerate, magicLinkToken)
+	if err != nil {
+		return nil, nil, errors.ErrInvalidGrant
+	}
+	// The link was mailed to the verified email, which the user may have changed since
+	claims, err := accessGenerate.ParseClaims(magicLinkToken)
+	if err != nil || user.Email == "" || claims.Email != user.Email {
+		return nil, nil, errors.ErrInvalidGrant
+	}
+
+	err = jwtmanager.ConsumeToken(revocationStore, accessGenerate, magicLinkToken)
+	if err == errors.ErrRevokedAccessToken {
+		return nil, nil, errors.ErrInvalidGrant
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	tgr := &jwtmanager.TokenGenerateRequest{
+		UserInfo: user.GetPublicInfo(),
+	}
+	return loginOrChallenge(userStore, refreshTokenStore, accessGenerate, tgr, user, enforceAdminMFA)
+}
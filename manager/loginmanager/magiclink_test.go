@@ -0,0 +1,158 @@
+package loginmanager
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/mayadata-io/kubera-auth/manager/emailmanager"
+	"github.com/mayadata-io/kubera-auth/manager/jwtmanager"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/generates"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/testutil"
+)
+
+func newMagicLinkToken(t *testing.T, accessGenerate *generates.JWTAccessGenerate, user *models.UserCredentials) string {
+	t.Helper()
+	tgr := &jwtmanager.TokenGenerateRequest{
+		UserInfo: user.GetPublicInfo(),
+	}
+	ti, err := jwtmanager.GenerateAuthToken(accessGenerate, tgr, models.TokenMagicLink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ti.GetAccess()
+}
+
+// countEmails replaces the mailer for the test and gives the number of emails sent
+func countEmails(t *testing.T) func() int {
+	var mu sync.Mutex
+	sent := 0
+	sendEmail = func(*generates.JWTAccessGenerate, *models.PublicUserInfo, emailmanager.EmailType) error {
+		mu.Lock()
+		defer mu.Unlock()
+		sent++
+		return nil
+	}
+	t.Cleanup(func() {
+		sendEmail = emailmanager.SendEmail
+	})
+	return func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return sent
+	}
+}
+
+func TestMagicLinkLoginUser(t *testing.T) {
+	stores := testutil.NewStores(t)
+	accessGenerate := testutil.NewAccessGenerate(t)
+	user := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+
+	token := newMagicLinkToken(t, accessGenerate, user)
+	ti, _, err := MagicLinkLoginUser(stores.User, stores.RefreshToken, stores.Revocation, accessGenerate, token, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ti.GetAccess() == "" || ti.GetRefresh() == "" {
+		t.Fatalf("expected an access and a refresh token, got %+v", ti)
+	}
+
+	if _, _, err = MagicLinkLoginUser(stores.User, stores.RefreshToken, stores.Revocation, accessGenerate, token, false); err != errors.ErrInvalidGrant {
+		t.Fatalf("expected the used magic link to be rejected, got %v", err)
+	}
+}
+
+func TestMagicLinkLoginUserChangedEmail(t *testing.T) {
+	stores := testutil.NewStores(t)
+	accessGenerate := testutil.NewAccessGenerate(t)
+	user := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+
+	token := newMagicLinkToken(t, accessGenerate, user)
+	user.Email = "john@example.org"
+	if err := stores.User.UpdateUser(user); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := MagicLinkLoginUser(stores.User, stores.RefreshToken, stores.Revocation, accessGenerate, token, false); err != errors.ErrInvalidGrant {
+		t.Fatalf("expected the magic link mailed to the former email to be rejected, got %v", err)
+	}
+}
+
+func TestRequestMagicLink(t *testing.T) {
+	stores := testutil.NewStores(t)
+	accessGenerate := testutil.NewAccessGenerate(t)
+	user := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+	sent := countEmails(t)
+
+	// Concurrent requests get a single link
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = RequestMagicLink(stores.User, accessGenerate, user.Email)
+		}()
+	}
+	wg.Wait()
+	if n := sent(); n != 1 {
+		t.Fatalf("expected a single magic link to be mailed, got %d", n)
+	}
+	if err := RequestMagicLink(stores.User, accessGenerate, user.Email); err != errors.ErrTooManyRequests {
+		t.Fatalf("expected a request within the interval to be refused, got %v", err)
+	}
+
+	// The requests older than the interval but within the window count towards the limit
+	now := time.Now()
+	addRequests := func(user *models.UserCredentials, oldest time.Time) {
+		t.Helper()
+		for i := 0; i < MagicLinkLimit; i++ {
+			at := oldest.Add(time.Duration(i) * MagicLinkInterval)
+			if err := stores.User.AddMagicLinkRequest(user.UID, at, at, at, MagicLinkLimit); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	limited := testutil.NewUser(t, stores.User, "jroe", models.RoleUser)
+	addRequests(limited, now.Add(-MagicLinkWindow+time.Minute))
+	if err := RequestMagicLink(stores.User, accessGenerate, limited.Email); err != errors.ErrTooManyRequests {
+		t.Fatalf("expected a request beyond the limit to be refused, got %v", err)
+	}
+
+	other := testutil.NewUser(t, stores.User, "jsmith", models.RoleUser)
+	addRequests(other, now.Add(-MagicLinkWindow-time.Minute))
+	if err := RequestMagicLink(stores.User, accessGenerate, other.Email); err != nil {
+		t.Fatalf("expected a request once the oldest left the window to be accepted, got %v", err)
+	}
+	if n := sent(); n != 2 {
+		t.Fatalf("expected 2 magic links to be mailed, got %d", n)
+	}
+	stored, err := stores.User.GetUser(bson.M{"uid": other.UID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.MagicLinkRequests) != MagicLinkLimit {
+		t.Fatalf("expected the last %d requests to be kept, got %d", MagicLinkLimit, len(stored.MagicLinkRequests))
+	}
+}
+
+func TestRequestMagicLinkSocialUser(t *testing.T) {
+	stores := testutil.NewStores(t)
+	accessGenerate := testutil.NewAccessGenerate(t)
+	user := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+	user.Kind = models.GithubAuth
+	if err := stores.User.UpdateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	sent := countEmails(t)
+
+	if err := RequestMagicLink(stores.User, accessGenerate, user.Email); err != errors.ErrInvalidUser {
+		t.Fatalf("expected no magic link for a social user, got %v", err)
+	}
+	if n := sent(); n != 0 {
+		t.Fatalf("expected no magic link to be mailed, got %d", n)
+	}
+}
@@ -0,0 +1,169 @@
+package loginmanager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mayadata-io/kubera-auth/manager/jwtmanager"
+	"github.com/mayadata-io/kubera-auth/manager/lockoutmanager"
+	"github.com/mayadata-io/kubera-auth/manager/mfamanager"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/generates"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/testutil"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/totp"
+)
+
+const clientIP = "192.0.2.1"
+
+// newMFAUser gives a user enrolled to MFA, along with its recovery codes. The enrollment is confirmed with the code of
+// the previous time step, so that the code of the current time step is accepted once.
+func newMFAUser(t *testing.T, stores *testutil.Stores, username string) (*models.UserCredentials, []string) {
+	t.Helper()
+	user := testutil.NewUser(t, stores.User, username, models.RoleUser)
+	enrollment, err := mfamanager.StartEnrollment(stores.User, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := totp.Code(enrollment.Secret, totp.Counter(time.Now())-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recoveryCodes, err := mfamanager.ConfirmEnrollment(stores.User, user, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user, recoveryCodes
+}
+
+// newChallenge gives the MFA challenge token of the user authenticated by its first factor
+func newChallenge(t *testing.T, stores *testutil.Stores, accessGenerate *generates.JWTAccessGenerate, user *models.UserCredentials) string {
+	t.Helper()
+	tgr := &jwtmanager.TokenGenerateRequest{UserInfo: user.GetPublicInfo()}
+	token, challenge, err := loginOrChallenge(stores.User, stores.RefreshToken, accessGenerate, tgr, user, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != nil || challenge == nil {
+		t.Fatal("expected an MFA challenge instead of a login token")
+	}
+	return challenge.MFAToken
+}
+
+func mfaLogin(stores *testutil.Stores, accessGenerate *generates.JWTAccessGenerate, mfaToken, code string) (*models.Token, error) {
+	return MFALoginUser(stores.User, stores.RefreshToken, stores.Revocation, stores.LoginAttempt, accessGenerate, mfaToken, code, clientIP)
+}
+
+func TestMFALoginUserChallengeIsSingleUse(t *testing.T) {
+	stores := testutil.NewStores(t)
+	accessGenerate := testutil.NewAccessGenerate(t)
+	user, recoveryCodes := newMFAUser(t, stores, "jdoe")
+	mfaToken := newChallenge(t, stores, accessGenerate, user)
+
+	token, err := mfaLogin(stores, accessGenerate, mfaToken, recoveryCodes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = jwtmanager.ParseLoginToken(stores.User, stores.Revocation, accessGenerate, token.GetAccess(), ""); err != nil {
+		t.Fatalf("expected a login token, got %v", err)
+	}
+	if _, err = mfaLogin(stores, accessGenerate, mfaToken, recoveryCodes[1]); err != errors.ErrInvalidGrant {
+		t.Fatalf("expected a used challenge to be rejected, got %v", err)
+	}
+	if _, err = CompleteMFALogin(stores.User, stores.RefreshToken, stores.Revocation, accessGenerate, user, mfaToken); err != errors.ErrInvalidGrant {
+		t.Fatalf("expected a used challenge not to complete another login, got %v", err)
+	}
+}
+
+func TestMFALoginUserCodesAreSingleUse(t *testing.T) {
+	stores := testutil.NewStores(t)
+	accessGenerate := testutil.NewAccessGenerate(t)
+	user, recoveryCodes := newMFAUser(t, stores, "jdoe")
+	code, err := totp.Code(user.MFA.Secret, totp.Counter(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, code := range map[string]string{"totp": code, "recovery": recoveryCodes[0]} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := mfaLogin(stores, accessGenerate, newChallenge(t, stores, accessGenerate, user), code); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := mfaLogin(stores, accessGenerate, newChallenge(t, stores, accessGenerate, user), code); err != errors.ErrInvalidMFACode {
+				t.Fatalf("expected a replayed code to be rejected, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMFALoginUserRevokesChallengeAfterWrongCodes(t *testing.T) {
+	stores := testutil.NewStores(t)
+	accessGenerate := testutil.NewAccessGenerate(t)
+	user, recoveryCodes := newMFAUser(t, stores, "jdoe")
+	mfaToken := newChallenge(t, stores, accessGenerate, user)
+
+	for i := 0; i < lockoutmanager.ChallengeThreshold; i++ {
+		if _, err := mfaLogin(stores, accessGenerate, mfaToken, "000000"); err != errors.ErrInvalidMFACode {
+			t.Fatalf("expected a wrong code to be rejected, got %v", err)
+		}
+	}
+	if _, err := mfaLogin(stores, accessGenerate, mfaToken, recoveryCodes[0]); err != errors.ErrInvalidGrant {
+		t.Fatalf("expected the challenge to be revoked, got %v", err)
+	}
+}
+
+func TestMFALoginUserLocksUserAfterWrongCodes(t *testing.T) {
+	stores := testutil.NewStores(t)
+	accessGenerate := testutil.NewAccessGenerate(t)
+	user, recoveryCodes := newMFAUser(t, stores, "jdoe")
+
+	// The wrong codes are counted across the challenges of the user
+	for i := 0; i < lockoutmanager.UserThreshold; i++ {
+		if _, err := mfaLogin(stores, accessGenerate, newChallenge(t, stores, accessGenerate, user), "000000"); err != errors.ErrInvalidMFACode {
+			t.Fatalf("expected a wrong code to be rejected, got %v", err)
+		}
+	}
+	if _, err := mfaLogin(stores, accessGenerate, newChallenge(t, stores, accessGenerate, user), recoveryCodes[0]); err != errors.ErrTooManyRequests {
+		t.Fatalf("expected the user to be locked, got %v", err)
+	}
+}
+
+// socialLogin logs in the user with the account of the provider and exchanges the login code the portal is given
+func socialLogin(t *testing.T, stores *testutil.Stores, accessGenerate *generates.JWTAccessGenerate, user *models.UserCredentials, enforceAdminMFA bool) (*models.Token, *models.MFAChallenge) {
+	t.Helper()
+	code, err := SocialLoginUser(stores.User, stores.LoginCode, user, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, challenge, err := LoginCodeLoginUser(stores.User, stores.RefreshToken, stores.LoginCode, accessGenerate, code, enforceAdminMFA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token, challenge
+}
+
+func TestSocialLoginUserChallengesMFAUsers(t *testing.T) {
+	stores := testutil.NewStores(t)
+	accessGenerate := testutil.NewAccessGenerate(t)
+	user, _ := newMFAUser(t, stores, "jdoe")
+	err := stores.User.AddIdentity(user.UID, models.Identity{Provider: models.GithubAuth, SocialAuthID: "42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, challenge := socialLogin(t, stores, accessGenerate, newSocialUser(models.GithubAuth, "42", user.Email, models.RoleUser), false)
+	if token != nil || challenge == nil || !challenge.Enrolled {
+		t.Fatalf("expected an MFA challenge instead of a login token, got %+v and %+v", token, challenge)
+	}
+
+	// The admins which are not enrolled yet have to enroll when MFA is enforced for them
+	admin := testutil.NewUser(t, stores.User, "admin", models.RoleAdmin)
+	err = stores.User.AddIdentity(admin.UID, models.Identity{Provider: models.GithubAuth, SocialAuthID: "43"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, challenge = socialLogin(t, stores, accessGenerate, newSocialUser(models.GithubAuth, "43", admin.Email, models.RoleUser), true)
+	if token != nil || challenge == nil || challenge.Enrolled {
+		t.Fatalf("expected an MFA enrollment challenge, got %+v and %+v", token, challenge)
+	}
+}
@@ -0,0 +1,41 @@
+package loginmanager
+
+import (
+	log "github.com/golang/glog"
+
+	"github.com/mayadata-io/kubera-auth/manager/jwtmanager"
+	"github.com/mayadata-io/kubera-auth/manager/lockoutmanager"
+	"github.com/mayadata-io/kubera-auth/manager/serviceaccountmanager"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/generates"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/store"
+)
+
+// ClientCredentialsLoginUser authenticates a service account by its client id and secret and issues a short-lived
+// login token bearing its role, restricted to the scope if any. No refresh token is issued, the service account
+// authenticates again once its token expires. The failed authentications lock the client ip for a while.
+func ClientCredentialsLoginUser(userStore *store.UserStore, loginAttemptStore *store.LoginAttemptStore, accessGenerate *generates.JWTAccessGenerate, clientID, secret, scope, clientIP string) (*models.Token, error) {
+	err := lockoutmanager.CheckClient(loginAttemptStore, lockoutmanager.ClientCredentialsScope, clientIP)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceAccount, err := serviceaccountmanager.Authenticate(userStore, clientID, secret)
+	if err == errors.ErrInvalidClient {
+		// The authentication has failed in any case, the error of the record is only logged
+		if recordErr := lockoutmanager.RecordClientFailure(loginAttemptStore, lockoutmanager.ClientCredentialsScope, clientIP); recordErr != nil {
+			log.Errorln("Error recording the failed authentication of the client ", recordErr)
+		}
+		return nil, err
+	} else if err != nil {
+		return nil, err
+	}
+
+	tgr := &jwtmanager.TokenGenerateRequest{
+		UserInfo:       serviceAccount.GetPublicInfo(),
+		AccessTokenExp: serviceaccountmanager.TokenExp,
+		Scope:          scope,
+	}
+	return jwtmanager.GenerateAuthToken(accessGenerate, tgr, models.TokenLogin)
+}
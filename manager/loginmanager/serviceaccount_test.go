@@ -0,0 +1,38 @@
+package loginmanager
+
+import (
+	"testing"
+
+	"github.com/mayadata-io/kubera-auth/manager/jwtmanager"
+	"github.com/mayadata-io/kubera-auth/manager/serviceaccountmanager"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/testutil"
+)
+
+func TestClientCredentialsTokenScope(t *testing.T) {
+	stores := testutil.NewStores(t)
+	accessGenerate := testutil.NewAccessGenerate(t)
+	admin := testutil.NewUser(t, stores.User, "admin", models.RoleAdmin)
+	serviceAccount, secret, err := serviceaccountmanager.CreateServiceAccount(stores.User, admin, "ci", models.RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, scope := range []string{"", models.ScopeUsersRead} {
+		ti, err := ClientCredentialsLoginUser(stores.User, stores.LoginAttempt, accessGenerate, serviceAccount.ClientID, secret, scope, "10.0.0.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ti.GetRefresh() != "" {
+			t.Errorf("scope %q: expected no refresh token to be issued", scope)
+		}
+		if _, err = jwtmanager.ParseLoginToken(stores.User, stores.Revocation, accessGenerate, ti.GetAccess(), models.ScopeUsersRead); err != nil {
+			t.Errorf("scope %q: expected the token to be accepted on a route of its scope, got %v", scope, err)
+		}
+		// The routes without a scope manage the login of a person, such as its password or its devices
+		if _, err = jwtmanager.ParseLoginToken(stores.User, stores.Revocation, accessGenerate, ti.GetAccess(), ""); err != errors.ErrInsufficientScope {
+			t.Errorf("scope %q: expected the token to be rejected on a route without scope, got %v", scope, err)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package mfamanager
+
+import (
+	"crypto/subtle"
+	"strings"
+	"time"
+
+	"github.com/globalsign/mgo"
+
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/store"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/digest"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/random"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/totp"
+)
+
+const (
+	// ChallengeExp is the time within which the user has to complete the login with a code
+	ChallengeExp = time.Minute * 5
+	// Issuer names kubera in the authenticator apps
+	Issuer = "Kubera"
+	// recoveryCodeCount is the number of recovery codes generated on enrollment
+	recoveryCodeCount = 10
+	// recoveryCodeLength is the number of random bytes in a recovery code
+	recoveryCodeLength = 10
+)
+
+// The methods with which a user can complete the login
+const (
+	MethodTOTP     = "totp"
+	MethodWebAuthn = "webauthn"
+)
+
+// IsRequired checks if the user has to complete the login with a second factor, either because the user
+// enrolled to MFA or because it is enforced for the admins
+func IsRequired(user *models.UserCredentials, enforceAdminMFA bool) bool {
+	return IsEnrolled(user) || (enforceAdminMFA && user.Role == models.RoleAdmin)
+}
+
+// IsEnrolled checks if the user has any second factor, either an authenticator app or a webauthn credential
+func IsEnrolled(user *models.UserCredentials) bool {
+	return len(Methods(user)) > 0
+}
+
+// Methods gives the second factors of the user
+func Methods(user *models.UserCredentials) []string {
+	methods := []string{}
+	if isEnabled(user) {
+		methods = append(methods, MethodTOTP)
+	}
+	if len(user.WebAuthnCredentials) > 0 {
+		methods = append(methods, MethodWebAuthn)
+	}
+	return methods
+}
+
+func isEnabled(user *models.UserCredentials) bool {
+	return user.MFA != nil && user.MFA.Enabled
+}
+
+// StartEnrollment generates a new secret for the user, the enrollment is pending till it is confirmed with a code
+func StartEnrollment(userStore *store.UserStore, user *models.UserCredentials) (*models.MFAEnrollment, error) {
+	if isEnabled(user) {
+		return nil, errors.ErrMFAEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	user.MFA = &models.MFA{
+		Secret: secret,
+	}
+	err = userStore.SetMFA(user.UID, user.MFA)
+	if err != nil {
+		return nil, err
+	}
+
+	account := user.Email
+	if account == "" {
+		account = user.UserName
+	}
+	return &models.MFAEnrollment{
+		Secret: secret,
+		URI:    totp.URI(Issuer, account, secret),
+	}, nil
+}
+
+// ConfirmEnrollment enables MFA once the user proves with a code that the secret has been added to an authenticator
+// app. The recovery codes are given only once, only their hashes are stored.
+func ConfirmEnrollment(userStore *store.UserStore, user *models.UserCredentials, code string) ([]string, error) {
+	if isEnabled(user) {
+		return nil, errors.ErrMFAEnabled
+	} else if user.MFA == nil {
+		return nil, errors.ErrMFANotEnabled
+	}
+
+	counter, ok := totp.Validate(user.MFA.Secret, code, time.Now())
+	if !ok {
+		return nil, errors.ErrInvalidMFACode
+	}
+
+	recoveryCodes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	enabledAt := time.Now()
+	user.MFA.Enabled = true
+	user.MFA.EnabledAt = &enabledAt
+	user.MFA.LastCounter = counter
+	user.MFA.RecoveryCodeHashes = hashes
+	return recoveryCodes, userStore.SetMFA(user.UID, user.MFA)
+}
+
+// Verify checks either a code of the authenticator app or one of the recovery codes of the user,
+// neither can be used again. The codes are marked as used atomically, so that concurrent logins
+// can't both accept the same code.
+func Verify(userStore *store.UserStore, user *models.UserCredentials, code string) error {
+	if !isEnabled(user) {
+		return errors.ErrMFANotEnabled
+	}
+
+	code = strings.TrimSpace(code)
+	if counter, ok := totp.Validate(user.MFA.Secret, code, time.Now()); ok {
+		if counter <= user.MFA.LastCounter {
+			return errors.ErrInvalidMFACode
+		}
+		err := userStore.UseMFACounter(user.UID, counter)
+		if err == mgo.ErrNotFound {
+			return errors.ErrInvalidMFACode
+		} else if err != nil {
+			return err
+		}
+		user.MFA.LastCounter = counter
+		return nil
+	}
+
+	hash := digest.SHA256(code)
+	for i, recoveryCodeHash := range user.MFA.RecoveryCodeHashes {
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(recoveryCodeHash)) == 1 {
+			err := userStore.UseRecoveryCode(user.UID, recoveryCodeHash)
+			if err == mgo.ErrNotFound {
+				return errors.ErrInvalidMFACode
+			} else if err != nil {
+				return err
+			}
+			user.MFA.RecoveryCodeHashes = append(user.MFA.RecoveryCodeHashes[:i], user.MFA.RecoveryCodeHashes[i+1:]...)
+			return nil
+		}
+	}
+	return errors.ErrInvalidMFACode
+}
+
+// Disable removes the MFA of the user after verifying a code, the admins can't disable it while it is enforced
+func Disable(userStore *store.UserStore, user *models.UserCredentials, code string, enforceAdminMFA bool) error {
+	if enforceAdminMFA && user.Role == models.RoleAdmin {
+		return errors.ErrMFAEnforced
+	}
+
+	err := Verify(userStore, user, code)
+	if err != nil {
+		return err
+	}
+	user.MFA = nil
+	return userStore.SetMFA(user.UID, nil)
+}
+
+func generateRecoveryCodes() ([]string, []string, error) {
+	recoveryCodes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range recoveryCodes {
+		recoveryCode, err := random.GetSecureRandomString(recoveryCodeLength)
+		if err != nil {
+			return nil, nil, err
+		}
+		recoveryCodes[i] = recoveryCode
+		hashes[i] = digest.SHA256(recoveryCode)
+	}
+	return recoveryCodes, hashes, nil
+}
@@ -0,0 +1,54 @@
+package mfamanager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mayadata-io/kubera-auth/manager/usermanager"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/testutil"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/totp"
+)
+
+func TestVerifyRejectsConcurrentReplay(t *testing.T) {
+	stores := testutil.NewStores(t)
+	user := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+	enrollment, err := StartEnrollment(stores.User, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := totp.Code(enrollment.Secret, totp.Counter(time.Now())-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recoveryCodes, err := ConfirmEnrollment(stores.User, user, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err = totp.Code(enrollment.Secret, totp.Counter(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, code := range map[string]string{"totp": code, "recovery": recoveryCodes[0]} {
+		t.Run(name, func(t *testing.T) {
+			// Both logins have read the user before either of them accepted the code
+			first, err := usermanager.GetUserByUID(stores.User, user.UID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			second, err := usermanager.GetUserByUID(stores.User, user.UID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = Verify(stores.User, first, code); err != nil {
+				t.Fatal(err)
+			}
+			if err = Verify(stores.User, second, code); err != errors.ErrInvalidMFACode {
+				t.Fatalf("expected the code to be accepted only once, got %v", err)
+			}
+		})
+	}
+}
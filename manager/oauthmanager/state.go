@@ -0,0 +1,99 @@
+package oauthmanager
+
+import (
+	"crypto/subtle"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/globalsign/mgo"
+
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/store"
+	"github.com/mayadata-io/kubera-auth/pkg/types"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/digest"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/random"
+)
+
+const (
+	// StateExp is the time within which the user has to complete the login with the provider
+	StateExp = time.Minute * 10
+	// stateLength and codeVerifierLength are the number of random bytes in a state and a PKCE code verifier
+	stateLength        = 32
+	codeVerifierLength = 32
+)
+
+// CreateState starts a social login with the provider and gives the random state, which has to be
+// sent to the provider as well as kept in a cookie of the browser. A non empty linkUserUID links the
+// account of the provider to that user instead.
+func CreateState(oauthStateStore *store.OAuthStateStore, provider models.AuthType, redirectTo, linkUserUID string) (string, *models.OAuthState, error) {
+	state, err := random.GetSecureRandomString(stateLength)
+	if err != nil {
+		return "", nil, err
+	}
+	codeVerifier, err := random.GetSecureRandomString(codeVerifierLength)
+	if err != nil {
+		return "", nil, err
+	}
+
+	createdAt := time.Now()
+	oauthState := &models.OAuthState{
+		StateHash:    digest.SHA256(state),
+		Provider:     provider,
+		CodeVerifier: codeVerifier,
+		RedirectTo:   redirectTo,
+		LinkUserUID:  linkUserUID,
+		CreatedAt:    createdAt,
+		ExpiresAt:    createdAt.Add(StateExp),
+	}
+	return state, oauthState, oauthStateStore.Set(oauthState)
+}
+
+// ConsumeState validates the state returned by the provider against the state kept in the cookie
+// of the browser which started the login. A state can be consumed only once.
+func ConsumeState(oauthStateStore *store.OAuthStateStore, state, cookieState string) (*models.OAuthState, error) {
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(cookieState)) != 1 {
+		return nil, errors.ErrInvalidState
+	}
+
+	oauthState, err := oauthStateStore.Take(digest.SHA256(state))
+	if err == mgo.ErrNotFound {
+		return nil, errors.ErrInvalidState
+	} else if err != nil {
+		return nil, err
+	}
+
+	if oauthState.ExpiresAt.Before(time.Now()) {
+		return nil, errors.ErrInvalidState
+	}
+	return oauthState, nil
+}
+
+// ValidateRedirectTo checks that the user can be sent to redirectTo after login. It has to be either a path
+// of the portal or an url having the same origin as the portal or one of the allowed urls.
+func ValidateRedirectTo(allowedURLs []string, redirectTo string) error {
+	if redirectTo == "" {
+		return nil
+	}
+
+	u, err := url.Parse(redirectTo)
+	if err != nil {
+		return errors.ErrInvalidRedirectURI
+	}
+	if !u.IsAbs() {
+		// Protocol relative urls such as `//evil.com` are not paths of the portal
+		if u.Host != "" || !strings.HasPrefix(u.Path, "/") || strings.HasPrefix(redirectTo, "//") || strings.Contains(redirectTo, "\\") {
+			return errors.ErrInvalidRedirectURI
+		}
+		return nil
+	}
+
+	for _, allowedURL := range append([]string{types.PortalURL}, allowedURLs...) {
+		allowed, err := url.Parse(allowedURL)
+		if err == nil && allowed.Scheme == u.Scheme && allowed.Host == u.Host {
+			return nil
+		}
+	}
+	return errors.ErrInvalidRedirectURI
+}
@@ -0,0 +1,75 @@
+package oauthmanager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/testutil"
+	"github.com/mayadata-io/kubera-auth/pkg/types"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/digest"
+)
+
+func TestConsumeState(t *testing.T) {
+	stores := testutil.NewStores(t)
+	state, _, err := CreateState(stores.OAuthState, models.GithubAuth, "/dashboard", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ConsumeState(stores.OAuthState, state, "another-state"); err != errors.ErrInvalidState {
+		t.Fatalf("expected a state differing from the cookie to be rejected, got %v", err)
+	}
+	oauthState, err := ConsumeState(stores.OAuthState, state, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oauthState.Provider != models.GithubAuth || oauthState.RedirectTo != "/dashboard" || oauthState.CodeVerifier == "" {
+		t.Fatalf("unexpected state %+v", oauthState)
+	}
+	if _, err := ConsumeState(stores.OAuthState, state, state); err != errors.ErrInvalidState {
+		t.Fatalf("expected a consumed state to be rejected, got %v", err)
+	}
+}
+
+func TestConsumeExpiredState(t *testing.T) {
+	stores := testutil.NewStores(t)
+	createdAt := time.Now().Add(-StateExp * 2)
+	err := stores.OAuthState.Set(&models.OAuthState{
+		StateHash: digest.SHA256("expired-state"),
+		Provider:  models.GithubAuth,
+		CreatedAt: createdAt,
+		ExpiresAt: createdAt.Add(StateExp),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ConsumeState(stores.OAuthState, "expired-state", "expired-state"); err != errors.ErrInvalidState {
+		t.Fatalf("expected an expired state to be rejected, got %v", err)
+	}
+}
+
+func TestValidateRedirectTo(t *testing.T) {
+	previousPortalURL := types.PortalURL
+	types.PortalURL = "https://kubera.example.org"
+	defer func() { types.PortalURL = previousPortalURL }()
+
+	allowedURLs := []string{"https://docs.example.org"}
+	for redirectTo, valid := range map[string]bool{
+		"":                                  true,
+		"/dashboard?tab=clusters":           true,
+		"https://kubera.example.org/a":      true,
+		"https://docs.example.org/guide":    true,
+		"http://kubera.example.org/a":       false,
+		"https://evil.example.org/a":        false,
+		"//evil.example.org/a":              false,
+		"/\\evil.example.org":               false,
+		"dashboard":                         false,
+		"javascript:alert(document.cookie)": false,
+	} {
+		if err := ValidateRedirectTo(allowedURLs, redirectTo); (err == nil) != valid {
+			t.Errorf("ValidateRedirectTo(%q) = %v, expected valid to be %v", redirectTo, err, valid)
+		}
+	}
+}
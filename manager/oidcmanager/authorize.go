@@ -0,0 +1,228 @@
+package oidcmanager
+
+import (
+	"crypto/subtle"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/globalsign/mgo"
+
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/store"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/digest"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/random"
+)
+
+const (
+	// ScopeOpenID is required in every OpenID Connect request
+	ScopeOpenID = "openid"
+	// ScopeProfile gives access to the name, username and picture of the user
+	ScopeProfile = "profile"
+	// ScopeEmail gives access to the email of the user
+	ScopeEmail = "email"
+	// ScopeOfflineAccess is accepted for the clients which explicitly ask for a refresh token
+	ScopeOfflineAccess = "offline_access"
+
+	// CodeChallengeMethodS256 is the only PKCE method supported, plain challenges are rejected
+	CodeChallengeMethodS256 = "S256"
+
+	// ResponseTypeCode is the only response type supported, as only the authorization code flow is supported
+	ResponseTypeCode = "code"
+
+	// authorizationRequestExp is the time within which the user has to login and the client has to redeem the code
+	authorizationRequestExp = time.Minute * 10
+	// requestIDLength and codeLength are the number of random bytes in a request id and an authorization code
+	requestIDLength = 32
+	codeLength      = 32
+)
+
+// SupportedScopes are the scopes which may be requested by the clients
+var SupportedScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail, ScopeOfflineAccess}
+
+// AuthorizationError is an error which has to be sent back to the redirect uri of the client
+type AuthorizationError struct {
+	Err         error
+	RedirectURI string
+	State       string
+}
+
+func (e *AuthorizationError) Error() string {
+	return e.Err.Error()
+}
+
+// RedirectURL gives the redirect uri of the client along with the error
+func (e *AuthorizationError) RedirectURL() string {
+	values := url.Values{}
+	values.Set("error", e.Err.Error())
+	if description, ok := errors.Descriptions[e.Err]; ok {
+		values.Set("error_description", description)
+	}
+	if e.State != "" {
+		values.Set("state", e.State)
+	}
+	return addQuery(e.RedirectURI, values)
+}
+
+// CreateAuthorizationRequest validates the authorization request of a client and stores it while the user logs in.
+// Errors which can't be sent to the client, as the client or its redirect uri are invalid, are returned as is,
+// all the other errors are returned as *AuthorizationError.
+func CreateAuthorizationRequest(clientStore *store.ClientStore, authorizationRequestStore *store.AuthorizationRequestStore, params url.Values) (*models.AuthorizationRequest, error) {
+	client, err := GetClient(clientStore, params.Get("client_id"))
+	if err != nil {
+		return nil, err
+	}
+	redirectURI := params.Get("redirect_uri")
+	if !hasRedirectURI(client, redirectURI) {
+		return nil, errors.ErrInvalidRedirectURI
+	}
+
+	authErr := &AuthorizationError{RedirectURI: redirectURI, State: params.Get("state")}
+	if params.Get("response_type") != ResponseTypeCode {
+		authErr.Err = errors.ErrUnsupportedResponseType
+		return nil, authErr
+	}
+
+	scope, ok := filterScope(params.Get("scope"))
+	if !ok {
+		authErr.Err = errors.ErrInvalidScope
+		return nil, authErr
+	}
+
+	codeChallenge := params.Get("code_challenge")
+	codeChallengeMethod := params.Get("code_challenge_method")
+	if codeChallenge == "" && client.Public {
+		authErr.Err = errors.ErrInvalidRequest
+		return nil, authErr
+	}
+	if codeChallenge != "" && codeChallengeMethod != CodeChallengeMethodS256 {
+		authErr.Err = errors.ErrInvalidRequest
+		return nil, authErr
+	}
+
+	requestID, err := random.GetSecureRandomString(requestIDLength)
+	if err != nil {
+		return nil, err
+	}
+
+	createdAt := time.Now()
+	request := &models.AuthorizationRequest{
+		RequestID:           requestID,
+		ClientID:            client.ClientID,
+		RedirectURI:         redirectURI,
+		Scope:               scope,
+		State:               params.Get("state"),
+		Nonce:               params.Get("nonce"),
+		CodeChallenge:       codeChallenge,
+		CodeChallengeMethod: codeChallengeMethod,
+		CreatedAt:           createdAt,
+		ExpiresAt:           createdAt.Add(authorizationRequestExp),
+	}
+	return request, authorizationRequestStore.Set(request)
+}
+
+// ApproveAuthorizationRequest issues an authorization code to the pending request for the logged in user
+// and gives the redirect uri of the client along with the code
+func ApproveAuthorizationRequest(authorizationRequestStore *store.AuthorizationRequestStore, requestID string, user *models.UserCredentials) (string, error) {
+	request, err := authorizationRequestStore.GetPending(requestID)
+	if err == mgo.ErrNotFound {
+		return "", errors.ErrInvalidRequest
+	} else if err != nil {
+		return "", err
+	}
+	if request.ExpiresAt.Before(time.Now()) {
+		return "", errors.ErrInvalidRequest
+	}
+
+	code, err := random.GetSecureRandomString(codeLength)
+	if err != nil {
+		return "", err
+	}
+
+	authTime := time.Now()
+	request.UserUID = user.UID
+	request.AuthTime = &authTime
+	request.CodeHash = digest.SHA256(code)
+	request.ExpiresAt = authTime.Add(authorizationRequestExp)
+	err = authorizationRequestStore.Update(request)
+	if err != nil {
+		return "", err
+	}
+
+	values := url.Values{}
+	values.Set("code", code)
+	if request.State != "" {
+		values.Set("state", request.State)
+	}
+	return addQuery(request.RedirectURI, values), nil
+}
+
+// RedeemCode consumes an authorization code of the client, a code can be redeemed only once
+func RedeemCode(authorizationRequestStore *store.AuthorizationRequestStore, client *models.Client, code, redirectURI, codeVerifier string) (*models.AuthorizationRequest, error) {
+	if code == "" {
+		return nil, errors.ErrInvalidRequest
+	}
+
+	request, err := authorizationRequestStore.TakeByCodeHash(digest.SHA256(code))
+	if err == mgo.ErrNotFound {
+		return nil, errors.ErrInvalidGrant
+	} else if err != nil {
+		return nil, err
+	}
+
+	if request.ExpiresAt.Before(time.Now()) || request.ClientID != client.ClientID || request.RedirectURI != redirectURI {
+		return nil, errors.ErrInvalidGrant
+	}
+	if request.CodeChallenge != "" && !verifyCodeChallenge(request.CodeChallenge, codeVerifier) {
+		return nil, errors.ErrInvalidGrant
+	}
+	return request, nil
+}
+
+// verifyCodeChallenge verifies the PKCE code verifier against the S256 challenge
+func verifyCodeChallenge(challenge, verifier string) bool {
+	if verifier == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(digest.S256(verifier)), []byte(challenge)) == 1
+}
+
+// filterScope drops the unknown scopes, the scope must contain openid
+func filterScope(scope string) (string, bool) {
+	var scopes []string
+	for _, s := range strings.Fields(scope) {
+		if contains(SupportedScopes, s) && !contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return strings.Join(scopes, " "), contains(scopes, ScopeOpenID)
+}
+
+// hasScope checks if the space separated scope contains the given scope
+func hasScope(scope, s string) bool {
+	return contains(strings.Fields(scope), s)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// addQuery adds the values to the query of the uri, keeping the query it already has
+func addQuery(uri string, values url.Values) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	query := u.Query()
+	for key := range values {
+		query.Set(key, values.Get(key))
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
@@ -0,0 +1,166 @@
+package oidcmanager
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/generates"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/testutil"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/digest"
+)
+
+const (
+	redirectURI  = "https://app.example.org/callback"
+	codeVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+func newClient(t *testing.T, stores *testutil.Stores, public bool) *models.Client {
+	t.Helper()
+	client := &models.Client{Name: "app", RedirectURIs: []string{redirectURI}, Public: public}
+	if _, err := CreateClient(stores.Client, client); err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// newCode gives an authorization code of the client approved by the user, for the given PKCE challenge
+func newCode(t *testing.T, stores *testutil.Stores, client *models.Client, user *models.UserCredentials, codeChallenge string) string {
+	t.Helper()
+	params := url.Values{}
+	params.Set("client_id", client.ClientID)
+	params.Set("redirect_uri", redirectURI)
+	params.Set("response_type", ResponseTypeCode)
+	params.Set("scope", "openid email unknown")
+	params.Set("state", "af0ifjsldkj")
+	params.Set("nonce", "n-0S6_WzA2Mj")
+	if codeChallenge != "" {
+		params.Set("code_challenge", codeChallenge)
+		params.Set("code_challenge_method", CodeChallengeMethodS256)
+	}
+	request, err := CreateAuthorizationRequest(stores.Client, stores.AuthorizationRequest, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	location, err := ApproveAuthorizationRequest(stores.AuthorizationRequest, request.RequestID, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Query().Get("state") != "af0ifjsldkj" {
+		t.Fatalf("expected the state to be sent back to the client, got %s", location)
+	}
+	return u.Query().Get("code")
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	stores := testutil.NewStores(t)
+	accessGenerate := testutil.NewAccessGenerate(t)
+	user := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+	client := newClient(t, stores, true)
+	code := newCode(t, stores, client, user, digest.S256(codeVerifier))
+
+	request, err := RedeemCode(stores.AuthorizationRequest, client, code, redirectURI, codeVerifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.Scope != "openid email" {
+		t.Fatalf("expected the unknown scopes to be dropped, got %q", request.Scope)
+	}
+	token, err := ExchangeCode(stores.User, stores.RefreshToken, accessGenerate, testutil.Issuer, client, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := &generates.IDTokenClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(token.IDToken, claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != user.UID || claims.Audience != client.ClientID || claims.Nonce != "n-0S6_WzA2Mj" || claims.Email != user.Email {
+		t.Fatalf("unexpected id token claims %+v", claims)
+	}
+	if token.GetRefresh() == "" {
+		t.Fatal("expected a refresh token")
+	}
+}
+
+func TestRedeemCodeOnce(t *testing.T) {
+	stores := testutil.NewStores(t)
+	user := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+	client := newClient(t, stores, false)
+	code := newCode(t, stores, client, user, "")
+
+	if _, err := RedeemCode(stores.AuthorizationRequest, client, code, redirectURI, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RedeemCode(stores.AuthorizationRequest, client, code, redirectURI, ""); err != errors.ErrInvalidGrant {
+		t.Fatalf("expected a redeemed code to be rejected, got %v", err)
+	}
+}
+
+func TestRedeemCodeWithWrongVerifier(t *testing.T) {
+	stores := testutil.NewStores(t)
+	user := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+	client := newClient(t, stores, true)
+
+	for name, verifier := range map[string]string{
+		"missing": "",
+		"wrong":   "another-verifier-of-the-same-length-as-the-real-one",
+	} {
+		t.Run(name, func(t *testing.T) {
+			code := newCode(t, stores, client, user, digest.S256(codeVerifier))
+			if _, err := RedeemCode(stores.AuthorizationRequest, client, code, redirectURI, verifier); err != errors.ErrInvalidGrant {
+				t.Fatalf("expected the code to be rejected, got %v", err)
+			}
+			// The code is consumed by a failed attempt, it can't be guessed further
+			if _, err := RedeemCode(stores.AuthorizationRequest, client, code, redirectURI, codeVerifier); err != errors.ErrInvalidGrant {
+				t.Fatalf("expected the code to be consumed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRedeemCodeOfAnotherClient(t *testing.T) {
+	stores := testutil.NewStores(t)
+	user := testutil.NewUser(t, stores.User, "jdoe", models.RoleUser)
+	client := newClient(t, stores, false)
+	otherClient := newClient(t, stores, false)
+
+	code := newCode(t, stores, client, user, "")
+	if _, err := RedeemCode(stores.AuthorizationRequest, otherClient, code, redirectURI, ""); err != errors.ErrInvalidGrant {
+		t.Fatalf("expected the code of another client to be rejected, got %v", err)
+	}
+	code = newCode(t, stores, client, user, "")
+	if _, err := RedeemCode(stores.AuthorizationRequest, client, code, redirectURI+"/other", ""); err != errors.ErrInvalidGrant {
+		t.Fatalf("expected a code redeemed for another redirect uri to be rejected, got %v", err)
+	}
+}
+
+func TestCreateAuthorizationRequestRequiresPKCEForPublicClients(t *testing.T) {
+	stores := testutil.NewStores(t)
+	client := newClient(t, stores, true)
+
+	params := url.Values{}
+	params.Set("client_id", client.ClientID)
+	params.Set("redirect_uri", redirectURI)
+	params.Set("response_type", ResponseTypeCode)
+	params.Set("scope", ScopeOpenID)
+	_, err := CreateAuthorizationRequest(stores.Client, stores.AuthorizationRequest, params)
+	if authErr, ok := err.(*AuthorizationError); !ok || authErr.Err != errors.ErrInvalidRequest {
+		t.Fatalf("expected a request without code challenge to be rejected, got %v", err)
+	}
+
+	params.Set("code_challenge", codeVerifier)
+	params.Set("code_challenge_method", "plain")
+	_, err = CreateAuthorizationRequest(stores.Client, stores.AuthorizationRequest, params)
+	if authErr, ok := err.(*AuthorizationError); !ok || authErr.Err != errors.ErrInvalidRequest {
+		t.Fatalf("expected a plain code challenge to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package oidcmanager
+
+import (
+	"crypto/subtle"
+	"net/url"
+
+	"github.com/globalsign/mgo"
+
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/store"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/digest"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/random"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/uuid"
+)
+
+// clientSecretLength is the number of random bytes in a client secret
+const clientSecretLength = 32
+
+// CreateClient registers a new client and returns its secret, which is empty for public clients
+func CreateClient(clientStore *store.ClientStore, client *models.Client) (string, error) {
+	if client.Name == "" || len(client.RedirectURIs) == 0 {
+		return "", errors.ErrInvalidRequest
+	}
+	for _, redirectURI := range client.RedirectURIs {
+		u, err := url.Parse(redirectURI)
+		if err != nil || !u.IsAbs() || u.Fragment != "" {
+			return "", errors.ErrInvalidRedirectURI
+		}
+	}
+
+	client.ClientID = uuid.Must(uuid.NewRandom()).String()
+	secret := ""
+	if !client.Public {
+		var err error
+		secret, err = random.GetSecureRandomString(clientSecretLength)
+		if err != nil {
+			return "", err
+		}
+		client.SecretHash = digest.SHA256(secret)
+	}
+	return secret, clientStore.Set(client)
+}
+
+// GetClient gets the client having the given client id
+func GetClient(clientStore *store.ClientStore, clientID string) (*models.Client, error) {
+	client, err := clientStore.Get(clientID)
+	if err == mgo.ErrNotFound {
+		return nil, errors.ErrInvalidClient
+	}
+	return client, err
+}
+
+// GetAllClients gets all the registered clients
+func GetAllClients(clientStore *store.ClientStore) ([]*models.Client, error) {
+	return clientStore.GetAll()
+}
+
+// DeleteClient removes a registered client
+func DeleteClient(clientStore *store.ClientStore, clientID string) error {
+	err := clientStore.Remove(clientID)
+	if err == mgo.ErrNotFound {
+		return errors.ErrInvalidClient
+	}
+	return err
+}
+
+// AuthenticateClient authenticates a client by its secret, public clients only identify themselves
+func AuthenticateClient(clientStore *store.ClientStore, clientID, secret string) (*models.Client, error) {
+	if clientID == "" {
+		return nil, errors.ErrInvalidClient
+	}
+	client, err := GetClient(clientStore, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	if client.Public {
+		if secret != "" {
+			return nil, errors.ErrInvalidClient
+		}
+		return client, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(digest.SHA256(secret)), []byte(client.SecretHash)) != 1 {
+		return nil, errors.ErrInvalidClient
+	}
+	return client, nil
+}
+
+// hasRedirectURI checks if the redirect uri is registered for the client, uris are compared exactly
+func hasRedirectURI(client *models.Client, redirectURI string) bool {
+	for _, uri := range client.RedirectURIs {
+		if uri == redirectURI {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package oidcmanager
+
+import (
+	"time"
+
+	"github.com/mayadata-io/kubera-auth/manager/jwtmanager"
+	"github.com/mayadata-io/kubera-auth/manager/usermanager"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/generates"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/store"
+)
+
+// ExchangeCode issues the tokens of the user who approved the authorization request of the code
+func ExchangeCode(userStore *store.UserStore, refreshTokenStore *store.RefreshTokenStore, accessGenerate *generates.JWTAccessGenerate, issuer string, client *models.Client, request *models.AuthorizationRequest) (*models.Token, error) {
+	user, err := getActiveUser(userStore, request.UserUID)
+	if err != nil {
+		return nil, err
+	}
+	return issueTokens(refreshTokenStore, accessGenerate, issuer, user, client, request.Scope, request.Nonce, request.AuthTime, "")
+}
+
+// RefreshToken exchanges a refresh token of the client for new tokens and rotates the refresh token
+func RefreshToken(userStore *store.UserStore, refreshTokenStore *store.RefreshTokenStore, accessGenerate *generates.JWTAccessGenerate, issuer string, client *models.Client, refresh string) (*models.Token, error) {
+	if refresh == "" {
+		return nil, errors.ErrInvalidRequest
+	}
+	storedToken, err := jwtmanager.UseRefreshToken(refreshTokenStore, refresh, client.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := getActiveUser(userStore, storedToken.UserUID)
+	if err != nil {
+		return nil, err
+	}
+	return issueTokens(refreshTokenStore, accessGenerate, issuer, user, client, storedToken.Scope, "", nil, storedToken.FamilyID)
+}
+
+// UserInfo gives the claims of the user which are released for the scope
+func UserInfo(user *models.UserCredentials, scope string) map[string]interface{} {
+	claims := &generates.IDTokenClaims{}
+	setUserClaims(claims, user, scope)
+
+	info := map[string]interface{}{
+		"sub": user.UID,
+	}
+	if claims.Name != "" {
+		info["name"] = claims.Name
+	}
+	if claims.PreferredUsername != "" {
+		info["preferred_username"] = claims.PreferredUsername
+	}
+	if claims.Picture != "" {
+		info["picture"] = claims.Picture
+	}
+	if claims.Email != "" {
+		info["email"] = claims.Email
+		info["email_verified"] = *claims.EmailVerified
+	}
+	return info
+}
+
+// issueTokens issues an access token meant for the audiences of the client, an id token and a refresh token of the
+// given family. The refresh token is only stored once the other tokens have been signed, so that a failure doesn't
+// leave a token nobody holds.
+func issueTokens(refreshTokenStore *store.RefreshTokenStore, accessGenerate *generates.JWTAccessGenerate, issuer string, user *models.UserCredentials, client *models.Client, scope, nonce string, authTime *time.Time, familyID string) (*models.Token, error) {
+	tgr := &jwtmanager.TokenGenerateRequest{
+		UserInfo: user.GetPublicInfo(),
+		ClientID: client.ClientID,
+		Audience: client.Audiences,
+		Scope:    scope,
+	}
+	ti, err := jwtmanager.GenerateAuthToken(accessGenerate, tgr, models.TokenLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &generates.IDTokenClaims{
+		Nonce: nonce,
+	}
+	claims.Issuer = issuer
+	claims.Subject = user.UID
+	claims.Audience = client.ClientID
+	claims.IssuedAt = ti.GetAccessCreateAt().Unix()
+	claims.ExpiresAt = ti.GetAccessCreateAt().Add(ti.GetAccessExpiresIn()).Unix()
+	if authTime != nil {
+		claims.AuthTime = authTime.Unix()
+	}
+	setUserClaims(claims, user, scope)
+
+	idToken, err := accessGenerate.IDToken(claims)
+	if err != nil {
+		return nil, err
+	}
+	ti.SetIDToken(idToken)
+
+	err = jwtmanager.GenerateRefreshToken(refreshTokenStore, tgr, ti, familyID)
+	if err != nil {
+		return nil, err
+	}
+	return ti, nil
+}
+
+// setUserClaims sets the claims of the user which are released for the scope
+func setUserClaims(claims *generates.IDTokenClaims, user *models.UserCredentials, scope string) {
+	if hasScope(scope, ScopeProfile) {
+		claims.Name = user.Name
+		claims.PreferredUsername = user.UserName
+		claims.Picture = user.Photo
+	}
+	if hasScope(scope, ScopeEmail) && user.Email != "" {
+		// Only a verified email is stored in `email`, the unverified one is kept in `unverified_email`
+		verified := true
+		claims.Email = user.Email
+		claims.EmailVerified = &verified
+	}
+}
+
+// getActiveUser gets the user for whom the tokens are issued, removed users can't get new tokens
+func getActiveUser(userStore *store.UserStore, uid string) (*models.UserCredentials, error) {
+	user, err := usermanager.GetUserByUID(userStore, uid)
+	if err == errors.ErrInvalidUser {
+		return nil, errors.ErrInvalidGrant
+	} else if err != nil {
+		return nil, err
+	}
+	if user.State == models.StateRemoved {
+		return nil, errors.ErrInvalidGrant
+	}
+	return user, nil
+}
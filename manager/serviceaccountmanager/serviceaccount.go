@@ -0,0 +1,170 @@
+// Package serviceaccountmanager manages the service accounts, the non-human principals with which the pipelines and
+// the other services call the API. A service account is stored along with the users so that its tokens are
+// validated like theirs, it authenticates with its uid as client id and a client secret.
+package serviceaccountmanager
+
+import (
+	"crypto/subtle"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/mayadata-io/kubera-auth/manager/usermanager"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/store"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/digest"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/random"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/uuid"
+)
+
+const (
+	// TokenExp is the lifetime of the tokens of the service accounts, which get no refresh token
+	TokenExp = time.Minute * 10
+	// DefaultSecretOverlap is the time for which the previous secrets stay valid after a rotation
+	DefaultSecretOverlap = time.Hour * 24
+	// MaxSecretOverlap is the longest time for which the previous secrets can stay valid after a rotation
+	MaxSecretOverlap = time.Hour * 24 * 30
+	// secretLength is the number of random bytes in a client secret
+	secretLength = 32
+)
+
+// CreateServiceAccount creates a service account of the role owned by the admin, the client secret is returned only
+// here. The name of the service account is unique among the usernames.
+func CreateServiceAccount(userStore *store.UserStore, owner *models.UserCredentials, name string, role models.Role) (*models.ServiceAccount, string, error) {
+	if role == "" {
+		role = models.RoleUser
+	}
+	if name == "" || (role != models.RoleAdmin && role != models.RoleUser) {
+		return nil, "", errors.ErrInvalidRequest
+	}
+
+	_, err := usermanager.GetUserByUserName(userStore, name)
+	if err == nil {
+		return nil, "", errors.ErrUserExists
+	} else if err != errors.ErrInvalidUser {
+		return nil, "", err
+	}
+
+	secret, clientSecret, err := newSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	serviceAccount := &models.UserCredentials{
+		UID:           uuid.Must(uuid.NewRandom()).String(),
+		UserName:      name,
+		Name:          name,
+		Kind:          models.ServiceAccountAuth,
+		Role:          role,
+		State:         models.StateActive,
+		OwnerUID:      owner.UID,
+		ClientSecrets: []models.ClientSecret{*clientSecret},
+	}
+	if err = userStore.Set(serviceAccount); err != nil {
+		return nil, "", err
+	}
+	return serviceAccount.GetServiceAccountInfo(), secret, nil
+}
+
+// GetServiceAccounts lists all the service accounts
+func GetServiceAccounts(userStore *store.UserStore) ([]*models.ServiceAccount, error) {
+	users, err := userStore.GetUsers(bson.M{"kind": models.ServiceAccountAuth})
+	if err != nil {
+		return nil, err
+	}
+
+	serviceAccounts := []*models.ServiceAccount{}
+	for _, user := range users {
+		serviceAccounts = append(serviceAccounts, user.GetServiceAccountInfo())
+	}
+	return serviceAccounts, nil
+}
+
+// RotateSecret adds a new client secret to the service account, the secret is returned only here. The previous
+// secrets stay valid for the overlap so that the clients can switch to the new secret without downtime, a zero
+// overlap revokes them at once. The secrets which have expired already are dropped.
+func RotateSecret(userStore *store.UserStore, clientID string, overlap time.Duration) (*models.ServiceAccount, string, error) {
+	if overlap < 0 || overlap > MaxSecretOverlap {
+		return nil, "", errors.ErrInvalidRequest
+	}
+	serviceAccount, err := getServiceAccount(userStore, clientID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(overlap)
+	var secrets []models.ClientSecret
+	for _, clientSecret := range serviceAccount.ClientSecrets {
+		if clientSecret.Expired(now) {
+			continue
+		}
+		if clientSecret.ExpiresAt == nil || clientSecret.ExpiresAt.After(expiresAt) {
+			clientSecret.ExpiresAt = &expiresAt
+		}
+		if overlap > 0 {
+			secrets = append(secrets, clientSecret)
+		}
+	}
+
+	secret, clientSecret, err := newSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	secrets = append(secrets, *clientSecret)
+	if err = userStore.UpdateClientSecrets(serviceAccount.UID, secrets); err != nil {
+		return nil, "", err
+	}
+	serviceAccount.ClientSecrets = secrets
+	return serviceAccount.GetServiceAccountInfo(), secret, nil
+}
+
+// DeleteServiceAccount removes the service account, its tokens are rejected from then on as their user is gone
+func DeleteServiceAccount(userStore *store.UserStore, clientID string) error {
+	err := userStore.RemoveServiceAccount(clientID)
+	if err == mgo.ErrNotFound {
+		return errors.ErrInvalidUser
+	}
+	return err
+}
+
+// Authenticate authenticates a service account by its client id and any of its unexpired secrets
+func Authenticate(userStore *store.UserStore, clientID, secret string) (*models.UserCredentials, error) {
+	if clientID == "" || secret == "" {
+		return nil, errors.ErrInvalidClient
+	}
+	serviceAccount, err := getServiceAccount(userStore, clientID)
+	if err == errors.ErrInvalidUser {
+		return nil, errors.ErrInvalidClient
+	} else if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	hash := []byte(digest.SHA256(secret))
+	for _, clientSecret := range serviceAccount.ClientSecrets {
+		if !clientSecret.Expired(now) && subtle.ConstantTimeCompare(hash, []byte(clientSecret.Hash)) == 1 {
+			return serviceAccount, nil
+		}
+	}
+	return nil, errors.ErrInvalidClient
+}
+
+// getServiceAccount gets the service account having the given client id
+func getServiceAccount(userStore *store.UserStore, clientID string) (*models.UserCredentials, error) {
+	return usermanager.GetUser(userStore, bson.M{"uid": clientID, "kind": models.ServiceAccountAuth})
+}
+
+// newSecret generates a client secret, giving the secret along with its stored form
+func newSecret() (string, *models.ClientSecret, error) {
+	secret, err := random.GetSecureRandomString(secretLength)
+	if err != nil {
+		return "", nil, err
+	}
+	return secret, &models.ClientSecret{
+		ID:        uuid.Must(uuid.NewRandom()).String(),
+		Hash:      digest.SHA256(secret),
+		CreatedAt: time.Now(),
+	}, nil
+}
@@ -0,0 +1,108 @@
+package serviceaccountmanager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/testutil"
+)
+
+// authenticates checks which of the secrets authenticate the service account
+func authenticates(t *testing.T, stores *testutil.Stores, clientID string, secrets map[string]bool) {
+	t.Helper()
+	for secret, valid := range secrets {
+		_, err := Authenticate(stores.User, clientID, secret)
+		if valid && err != nil {
+			t.Errorf("expected the secret %.8s... to be valid, got %v", secret, err)
+		} else if !valid && err != errors.ErrInvalidClient {
+			t.Errorf("expected the secret %.8s... to be rejected, got %v", secret, err)
+		}
+	}
+}
+
+func TestRotateSecret(t *testing.T) {
+	stores := testutil.NewStores(t)
+	admin := testutil.NewUser(t, stores.User, "admin", models.RoleAdmin)
+	serviceAccount, firstSecret, err := CreateServiceAccount(stores.User, admin, "ci", models.RoleUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientID := serviceAccount.ClientID
+
+	for _, overlap := range []time.Duration{-time.Second, MaxSecretOverlap + time.Second} {
+		if _, _, err = RotateSecret(stores.User, clientID, overlap); err != errors.ErrInvalidRequest {
+			t.Errorf("overlap %v: expected %v, got %v", overlap, errors.ErrInvalidRequest, err)
+		}
+	}
+
+	// Both secrets are valid during the overlap
+	serviceAccount, secondSecret, err := RotateSecret(stores.User, clientID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(serviceAccount.Secrets) != 2 || serviceAccount.Secrets[0].ExpiresAt == nil || serviceAccount.Secrets[1].ExpiresAt != nil {
+		t.Fatalf("expected the previous secret to expire and the new one not to, got %+v", serviceAccount.Secrets)
+	}
+	authenticates(t, stores, clientID, map[string]bool{firstSecret: true, secondSecret: true})
+
+	// A shorter overlap brings forward the expiry of the previous secrets, which are dropped once expired
+	_, thirdSecret, err := RotateSecret(stores.User, clientID, time.Millisecond*100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticates(t, stores, clientID, map[string]bool{firstSecret: true, secondSecret: true, thirdSecret: true})
+	time.Sleep(time.Millisecond * 200)
+	authenticates(t, stores, clientID, map[string]bool{firstSecret: false, secondSecret: false, thirdSecret: true})
+
+	serviceAccount, fourthSecret, err := RotateSecret(stores.User, clientID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(serviceAccount.Secrets) != 2 {
+		t.Errorf("expected the expired secrets to be dropped, got %+v", serviceAccount.Secrets)
+	}
+	authenticates(t, stores, clientID, map[string]bool{thirdSecret: true, fourthSecret: true})
+
+	// No overlap revokes the previous secrets at once
+	_, fifthSecret, err := RotateSecret(stores.User, clientID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticates(t, stores, clientID, map[string]bool{thirdSecret: false, fourthSecret: false, fifthSecret: true})
+}
+
+func TestAuthenticate(t *testing.T) {
+	stores := testutil.NewStores(t)
+	admin := testutil.NewUser(t, stores.User, "admin", models.RoleAdmin)
+	serviceAccount, secret, err := CreateServiceAccount(stores.User, admin, "ci", models.RoleUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name     string
+		clientID string
+		secret   string
+	}{
+		{name: "no secret", clientID: serviceAccount.ClientID, secret: ""},
+		{name: "wrong secret", clientID: serviceAccount.ClientID, secret: secret + "x"},
+		{name: "unknown client", clientID: "unknown", secret: secret},
+		{name: "user", clientID: admin.UID, secret: secret},
+	} {
+		if _, err = Authenticate(stores.User, test.clientID, test.secret); err != errors.ErrInvalidClient {
+			t.Errorf("%s: expected %v, got %v", test.name, errors.ErrInvalidClient, err)
+		}
+	}
+
+	if err = DeleteServiceAccount(stores.User, admin.UID); err != errors.ErrInvalidUser {
+		t.Errorf("expected a user not to be removed as a service account, got %v", err)
+	}
+	if err = DeleteServiceAccount(stores.User, serviceAccount.ClientID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Authenticate(stores.User, serviceAccount.ClientID, secret); err != errors.ErrInvalidClient {
+		t.Errorf("expected the removed service account to be rejected, got %v", err)
+	}
+}
@@ -4,12 +4,12 @@ import (
 	"time"
 
 	"github.com/globalsign/mgo/bson"
-	"golang.org/x/crypto/bcrypt"
 
 	"github.com/mayadata-io/kubera-auth/pkg/errors"
 	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/passwordhash"
+	"github.com/mayadata-io/kubera-auth/pkg/passwordpolicy"
 	"github.com/mayadata-io/kubera-auth/pkg/store"
-	"github.com/mayadata-io/kubera-auth/pkg/types"
 	"github.com/mayadata-io/kubera-auth/pkg/utils/uuid"
 )
 
@@ -19,7 +19,8 @@ import (
 // `isSignup` is a bool value used to detect whether this user creation is being
 // done via a local auth signup form or through an admin and will accordingly set
 // the values for the user to be created.
-func CreateUser(userStore *store.UserStore, user *models.UserCredentials, isSignup bool) (*models.PublicUserInfo, error) {
+// The password has to meet the `policy`, which is nil only for the default user.
+func CreateUser(userStore *store.UserStore, hasher *passwordhash.Hasher, policy *passwordpolicy.Policy, user *models.UserCredentials, isSignup bool) (*models.PublicUserInfo, error) {
 	exists, err := IsUserExists(userStore, user)
 	if err != nil {
 		return nil, err
@@ -27,7 +28,12 @@ func CreateUser(userStore *store.UserStore, user *models.UserCredentials, isSign
 		return nil, errors.ErrUserExists
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), types.PasswordEncryptionCost)
+	err = validatePassword(policy, user, user.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := hasher.Hash(user.Password)
 	if err != nil {
 		return nil, err
 	}
@@ -37,7 +43,7 @@ func CreateUser(userStore *store.UserStore, user *models.UserCredentials, isSign
 		newUser = &models.UserCredentials{
 			UID:             uuid.Must(uuid.NewRandom()).String(),
 			UserName:        user.UserName,
-			Password:        string(hashedPassword),
+			Password:        hashedPassword,
 			Name:            user.Name,
 			UnverifiedEmail: user.UserName,
 			Kind:            models.LocalAuth,
@@ -49,7 +55,7 @@ func CreateUser(userStore *store.UserStore, user *models.UserCredentials, isSign
 		newUser = &models.UserCredentials{
 			UID:             uuid.Must(uuid.NewRandom()).String(),
 			UserName:        user.UserName,
-			Password:        string(hashedPassword),
+			Password:        hashedPassword,
 			Name:            user.Name,
 			UnverifiedEmail: user.UnverifiedEmail,
 			Kind:            models.LocalAuth,
@@ -94,6 +100,9 @@ func CreateSocialUser(userStore *store.UserStore, user *models.UserCredentials)
 		time.Sleep(time.Millisecond * 100)
 	}
 	user.UID = uuid.Must(uuid.NewRandom()).String()
+	// The account of the provider is the first identity of the user
+	user.Identities = []models.Identity{NewIdentity(user)}
+	user.SocialAuthID = ""
 
 	return userStore.Set(user)
 }
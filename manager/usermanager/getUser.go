@@ -32,9 +32,9 @@ func GetUser(userStore *store.UserStore, query bson.M) (user *models.UserCredent
 	return
 }
 
-// GetAllUsers get the user information
+// GetAllUsers get the user information, the service accounts are not users and are left out
 func GetAllUsers(userStore *store.UserStore) ([]*models.PublicUserInfo, error) {
-	users, err := userStore.GetAllUsers()
+	users, err := userStore.GetUsers(bson.M{"kind": bson.M{"$ne": models.ServiceAccountAuth}})
 	if err != nil {
 		return nil, err
 	}
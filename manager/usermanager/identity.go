@@ -0,0 +1,110 @@
+package usermanager
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/store"
+)
+
+// NewIdentity gives the identity of the user returned by a social login provider
+func NewIdentity(user *models.UserCredentials) models.Identity {
+	linkedAt := time.Now()
+	return models.Identity{
+		Provider:     user.Kind,
+		SocialAuthID: user.SocialAuthID,
+		Email:        user.Email,
+		LinkedAt:     &linkedAt,
+	}
+}
+
+// GetUserByIdentity get the user to which the account of the provider is linked
+func GetUserByIdentity(userStore *store.UserStore, provider models.AuthType, socialAuthID string) (*models.UserCredentials, error) {
+	query := bson.M{"identities": bson.M{"$elemMatch": bson.M{"provider": provider, "social_auth_id": socialAuthID}}}
+	return GetUser(userStore, query)
+}
+
+// LinkIdentity links the account of the provider to the user. A user can have a single account of each provider
+// and an account can't be linked to more than one user.
+func LinkIdentity(userStore *store.UserStore, uid string, identity models.Identity) error {
+	linkedUser, err := GetUserByIdentity(userStore, identity.Provider, identity.SocialAuthID)
+	if err == nil {
+		if linkedUser.UID != uid {
+			return errors.ErrIdentityLinked
+		}
+		// The identity is already linked to the user
+		return nil
+	} else if err != errors.ErrInvalidUser {
+		return err
+	}
+
+	err = userStore.AddIdentity(uid, identity)
+	if err == mgo.ErrNotFound {
+		// Either the user does not exist or it has another identity of the provider
+		if _, err = GetUserByUID(userStore, uid); err != nil {
+			return err
+		}
+		return errors.ErrProviderLinked
+	} else if mgo.IsDup(err) {
+		return errors.ErrIdentityLinked
+	}
+	return err
+}
+
+// UnlinkIdentity removes the identity of the provider from the user. The last identity of a user
+// without a password can't be removed, as the user would not be able to login anymore.
+func UnlinkIdentity(userStore *store.UserStore, uid string, provider models.AuthType) (*models.PublicUserInfo, error) {
+	user, err := GetUserByUID(userStore, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	var identities []models.Identity
+	for _, identity := range user.Identities {
+		if identity.Provider != provider {
+			identities = append(identities, identity)
+		}
+	}
+	if len(identities) == len(user.Identities) {
+		return nil, errors.ErrInvalidRequest
+	}
+	if len(identities) == 0 && user.Kind != models.LocalAuth {
+		return nil, errors.ErrLastIdentity
+	}
+
+	user.Identities = identities
+	if user.Kind == provider {
+		// The kind of a social user is the provider of one of its identities
+		user.Kind = identities[0].Provider
+	}
+	err = userStore.UpdateUser(user)
+	return user.GetPublicInfo(), err
+}
+
+// MigrateIdentities moves the single social login of the users stored before the identities were introduced
+// into their identities
+func MigrateIdentities(userStore *store.UserStore) error {
+	users, err := userStore.GetUsers(bson.M{"social_auth_id": bson.M{"$exists": true, "$ne": ""}})
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		identity := models.Identity{
+			Provider:     user.Kind,
+			SocialAuthID: user.SocialAuthID,
+			Email:        user.Email,
+			LinkedAt:     user.CreatedAt,
+		}
+		user.Identities = append(user.Identities, identity)
+		user.SocialAuthID = ""
+		if err = userStore.UpdateUser(user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package usermanager
+
+import (
+	"fmt"
+
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/passwordhash"
+	"github.com/mayadata-io/kubera-auth/pkg/passwordpolicy"
+)
+
+// validatePassword checks the new password of the user against the policy, the passwords are not checked
+// without a policy such as for the default user configured by the admin
+func validatePassword(policy *passwordpolicy.Policy, user *models.UserCredentials, password string) error {
+	if policy == nil {
+		return nil
+	}
+	return policy.Validate(password, user.UserName, user.Name, user.Email, user.UnverifiedEmail)
+}
+
+// ValidateNewPassword checks the new password of the user against the policy and the history of its passwords
+func ValidateNewPassword(policy *passwordpolicy.Policy, user *models.UserCredentials, password string) error {
+	err := validatePassword(policy, user, password)
+	if err != nil {
+		return err
+	}
+	return checkPasswordHistory(policy, user, password)
+}
+
+// checkPasswordHistory rejects the current password and the previous passwords of the user kept in the history
+func checkPasswordHistory(policy *passwordpolicy.Policy, user *models.UserCredentials, password string) error {
+	if policy == nil || policy.HistorySize == 0 {
+		return nil
+	}
+	hashes := append([]string{user.Password}, user.PasswordHistory...)
+	if len(hashes) > policy.HistorySize {
+		hashes = hashes[:policy.HistorySize]
+	}
+	for _, hash := range hashes {
+		if matched, _ := passwordhash.Verify(hash, password); matched {
+			return passwordpolicy.NewViolationError(passwordpolicy.ViolationReused,
+				fmt.Sprintf("The password must not be one of the last %d passwords", policy.HistorySize))
+		}
+	}
+	return nil
+}
+
+// rememberPassword moves the current password of the user to its history before it is replaced,
+// the history keeps the passwords which can't be reused besides the new one
+func rememberPassword(policy *passwordpolicy.Policy, user *models.UserCredentials) {
+	if policy == nil {
+		return
+	}
+	if policy.HistorySize <= 1 || user.Password == "" {
+		user.PasswordHistory = nil
+		return
+	}
+	history := append([]string{user.Password}, user.PasswordHistory...)
+	if len(history) > policy.HistorySize-1 {
+		history = history[:policy.HistorySize-1]
+	}
+	user.PasswordHistory = history
+}
@@ -2,11 +2,10 @@ package usermanager
 
 import (
 	"github.com/globalsign/mgo/bson"
-	"golang.org/x/crypto/bcrypt"
-
 	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/passwordhash"
+	"github.com/mayadata-io/kubera-auth/pkg/passwordpolicy"
 	"github.com/mayadata-io/kubera-auth/pkg/store"
-	"github.com/mayadata-io/kubera-auth/pkg/types"
 )
 
 // UpdateUserDetails updates the user information
@@ -41,8 +40,8 @@ func UpdateUserDetails(userStore *store.UserStore, user *models.UserCredentials)
 	return user.GetPublicInfo(), err
 }
 
-// UpdatePassword sets the new user password
-func UpdatePassword(userStore *store.UserStore, newPassword, userID string) (*models.PublicUserInfo, error) {
+// UpdatePassword sets the new user password, which has to meet the policy
+func UpdatePassword(userStore *store.UserStore, hasher *passwordhash.Hasher, policy *passwordpolicy.Policy, newPassword, userID string) (*models.PublicUserInfo, error) {
 	var storedUser *models.UserCredentials
 	var err error
 
@@ -51,11 +50,17 @@ func UpdatePassword(userStore *store.UserStore, newPassword, userID string) (*mo
 		return nil, err
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), types.PasswordEncryptionCost)
+	err = ValidateNewPassword(policy, storedUser, newPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := hasher.Hash(newPassword)
 	if err != nil {
 		return nil, err
 	}
-	storedUser.Password = string(hashedPassword)
+	rememberPassword(policy, storedUser)
+	storedUser.Password = hashedPassword
 
 	if storedUser.State == models.StateCreated {
 		storedUser.State = models.StateActive
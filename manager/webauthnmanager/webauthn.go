@@ -0,0 +1,210 @@
+package webauthnmanager
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	log "github.com/golang/glog"
+
+	"github.com/mayadata-io/kubera-auth/manager/usermanager"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/store"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/random"
+	"github.com/mayadata-io/kubera-auth/pkg/webauthn"
+)
+
+const (
+	// SessionExp is the time within which the user has to complete a ceremony with the authenticator
+	SessionExp = time.Minute * 5
+	// challengeLength is the number of random bytes in a challenge
+	challengeLength = 32
+)
+
+// StartRegistration starts the registration of a new credential for the user
+func StartRegistration(sessionStore *store.WebAuthnSessionStore, rp *webauthn.RelyingParty, user *models.UserCredentials) (string, *webauthn.CreationOptions, error) {
+	session, err := createSession(sessionStore, models.WebAuthnRegistration, user.UID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var exclude []string
+	for _, credential := range user.WebAuthnCredentials {
+		exclude = append(exclude, credential.ID)
+	}
+	name := user.UserName
+	if name == "" {
+		name = user.Email
+	}
+	options := rp.CreationOptions(session.Challenge, []byte(user.UID), name, user.Name, exclude, SessionExp)
+	return session.ID.Hex(), options, nil
+}
+
+// FinishRegistration verifies the response of the authenticator and adds the new credential to the user
+func FinishRegistration(userStore *store.UserStore, sessionStore *store.WebAuthnSessionStore, rp *webauthn.RelyingParty, user *models.UserCredentials, sessionID, name string, response *webauthn.CredentialResponse) (*models.WebAuthnCredential, error) {
+	session, err := takeSession(sessionStore, sessionID, models.WebAuthnRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if session.UserUID != user.UID {
+		return nil, errors.ErrInvalidState
+	}
+
+	clientDataJSON, attestationObject, err := response.Attestation()
+	if err != nil {
+		return nil, errors.ErrInvalidWebAuthnResponse
+	}
+	credential, err := rp.VerifyRegistration(clientDataJSON, attestationObject, session.Challenge, false)
+	if err != nil {
+		log.Errorln("Error verifying the webauthn registration ", err)
+		return nil, errors.ErrInvalidWebAuthnResponse
+	}
+
+	createdAt := time.Now()
+	storedCredential := models.WebAuthnCredential{
+		ID:        webauthn.EncodeID(credential.ID),
+		Name:      name,
+		PublicKey: credential.PublicKey,
+		Algorithm: credential.Algorithm,
+		SignCount: credential.SignCount,
+		AAGUID:    credential.AAGUID,
+		CreatedAt: &createdAt,
+	}
+	err = userStore.AddWebAuthnCredential(user.UID, storedCredential)
+	if err == mgo.ErrNotFound || mgo.IsDup(err) {
+		// The credential is already registered, either for this or for another user
+		return nil, errors.ErrInvalidWebAuthnResponse
+	} else if err != nil {
+		return nil, err
+	}
+	user.WebAuthnCredentials = append(user.WebAuthnCredentials, storedCredential)
+	return &storedCredential, nil
+}
+
+// StartLogin starts the authentication of a user. The credentials of the user are allowed when the user completes
+// the login with a second factor, otherwise any discoverable credential is allowed for a passwordless login.
+func StartLogin(sessionStore *store.WebAuthnSessionStore, rp *webauthn.RelyingParty, user *models.UserCredentials) (string, *webauthn.RequestOptions, error) {
+	userUID := ""
+	var allow []string
+	if user != nil {
+		userUID = user.UID
+		for _, credential := range user.WebAuthnCredentials {
+			allow = append(allow, credential.ID)
+		}
+	}
+
+	session, err := createSession(sessionStore, models.WebAuthnAuthentication, userUID)
+	if err != nil {
+		return "", nil, err
+	}
+	// A passwordless login is a single factor, which has to verify the user
+	userVerification := webauthn.UserVerificationRequired
+	if user != nil {
+		userVerification = webauthn.UserVerificationPreferred
+	}
+	return session.ID.Hex(), rp.RequestOptions(session.Challenge, allow, userVerification, SessionExp), nil
+}
+
+// FinishLogin verifies the response of the authenticator and gives the user of the credential along with
+// the session, whose UserUID is set when the credential is used as a second factor
+func FinishLogin(userStore *store.UserStore, sessionStore *store.WebAuthnSessionStore, rp *webauthn.RelyingParty, sessionID string, response *webauthn.CredentialResponse) (*models.UserCredentials, *models.WebAuthnSession, error) {
+	session, err := takeSession(sessionStore, sessionID, models.WebAuthnAuthentication)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := usermanager.GetUser(userStore, bson.M{"webauthn_credentials.id": response.ID})
+	if err == errors.ErrInvalidUser {
+		return nil, nil, errors.ErrInvalidWebAuthnResponse
+	} else if err != nil {
+		return nil, nil, err
+	}
+	if session.UserUID != "" && session.UserUID != user.UID {
+		return nil, nil, errors.ErrInvalidWebAuthnResponse
+	}
+
+	assertion, err := response.Assertion()
+	if err != nil {
+		return nil, nil, errors.ErrInvalidWebAuthnResponse
+	}
+	for i := range user.WebAuthnCredentials {
+		storedCredential := &user.WebAuthnCredentials[i]
+		if storedCredential.ID != response.ID {
+			continue
+		}
+
+		credential := &webauthn.Credential{
+			PublicKey: storedCredential.PublicKey,
+			Algorithm: storedCredential.Algorithm,
+			SignCount: storedCredential.SignCount,
+		}
+		signCount, err := rp.VerifyAssertion(assertion, session.Challenge, credential, session.UserUID == "")
+		if err != nil {
+			log.Errorln("Error verifying the webauthn assertion of user uid: ", user.UID, err)
+			return nil, nil, errors.ErrInvalidWebAuthnResponse
+		}
+
+		lastUsedAt := time.Now()
+		storedCredential.SignCount = signCount
+		storedCredential.LastUsedAt = &lastUsedAt
+		return user, session, userStore.UseWebAuthnCredential(user.UID, storedCredential.ID, signCount, lastUsedAt)
+	}
+	return nil, nil, errors.ErrInvalidWebAuthnResponse
+}
+
+// RemoveCredential removes the credential of the user. The last second factor of an admin can't be removed
+// while MFA is enforced for the admins.
+func RemoveCredential(userStore *store.UserStore, user *models.UserCredentials, credentialID string, enforceAdminMFA bool) error {
+	var credentials []models.WebAuthnCredential
+	for _, credential := range user.WebAuthnCredentials {
+		if credential.ID != credentialID {
+			credentials = append(credentials, credential)
+		}
+	}
+	if len(credentials) == len(user.WebAuthnCredentials) {
+		return errors.ErrInvalidRequest
+	}
+	if len(credentials) == 0 && enforceAdminMFA && user.Role == models.RoleAdmin && (user.MFA == nil || !user.MFA.Enabled) {
+		return errors.ErrMFAEnforced
+	}
+
+	user.WebAuthnCredentials = credentials
+	return userStore.RemoveWebAuthnCredential(user.UID, credentialID)
+}
+
+func createSession(sessionStore *store.WebAuthnSessionStore, ceremony models.WebAuthnCeremony, userUID string) (*models.WebAuthnSession, error) {
+	challenge, err := random.GetSecureRandomString(challengeLength)
+	if err != nil {
+		return nil, err
+	}
+
+	createdAt := time.Now()
+	session := &models.WebAuthnSession{
+		ID:        bson.NewObjectId(),
+		Ceremony:  ceremony,
+		Challenge: challenge,
+		UserUID:   userUID,
+		CreatedAt: createdAt,
+		ExpiresAt: createdAt.Add(SessionExp),
+	}
+	return session, sessionStore.Set(session)
+}
+
+// takeSession consumes the pending session of the ceremony, a challenge can be used only once
+func takeSession(sessionStore *store.WebAuthnSessionStore, sessionID string, ceremony models.WebAuthnCeremony) (*models.WebAuthnSession, error) {
+	if !bson.IsObjectIdHex(sessionID) {
+		return nil, errors.ErrInvalidState
+	}
+
+	session, err := sessionStore.Take(bson.ObjectIdHex(sessionID))
+	if err == mgo.ErrNotFound {
+		return nil, errors.ErrInvalidState
+	} else if err != nil {
+		return nil, err
+	}
+	if session.Ceremony != ceremony || session.ExpiresAt.Before(time.Now()) {
+		return nil, errors.ErrInvalidState
+	}
+	return session, nil
+}
@@ -7,4 +7,9 @@ var (
 	ErrInvalidRedirectURI = errors.New("invalid redirect uri")
 	ErrInvalidAccessToken = errors.New("invalid access token")
 	ErrExpiredAccessToken = errors.New("expired access token")
+	ErrRevokedAccessToken = errors.New("revoked access token")
+	ErrInvalidState       = errors.New("invalid or expired oauth state")
+	// ErrSymmetricIDToken is returned when an id token would be signed with a HMAC key,
+	// which the clients can't verify without knowing the secret
+	ErrSymmetricIDToken = errors.New("id tokens require an asymmetric JWT_SIGNING_ALGORITHM")
 )
@@ -39,30 +39,117 @@ func (r *Response) SetHeader(key, value string) {
 
 // https://tools.ietf.org/html/rfc6749#section-5.2
 var (
-	ErrInvalidRequest         = errors.New("invalid_request")
-	ErrServerError            = errors.New("server_error")
-	ErrTemporarilyUnavailable = errors.New("temporarily_unavailable")
-	ErrInvalidUser            = errors.New("invalid_user")
-	ErrInvalidPassword        = errors.New("invalid_password")
-	ErrUserExists             = errors.New("User already exists")
+	ErrInvalidRequest          = errors.New("invalid_request")
+	ErrServerError             = errors.New("server_error")
+	ErrTemporarilyUnavailable  = errors.New("temporarily_unavailable")
+	ErrInvalidUser             = errors.New("invalid_user")
+	ErrInvalidPassword         = errors.New("invalid_password")
+	ErrUserExists              = errors.New("User already exists")
+	ErrInvalidGrant            = errors.New("invalid_grant")
+	ErrUnsupportedGrantType    = errors.New("unsupported_grant_type")
+	ErrInvalidClient           = errors.New("invalid_client")
+	ErrUnauthorizedClient      = errors.New("unauthorized_client")
+	ErrAccessDenied            = errors.New("access_denied")
+	ErrUnsupportedResponseType = errors.New("unsupported_response_type")
+	ErrInvalidScope            = errors.New("invalid_scope")
+	ErrMembershipRequired      = errors.New("membership_required")
+	ErrDomainNotAllowed        = errors.New("domain_not_allowed")
+	ErrEmailNotVerified        = errors.New("email_not_verified")
+	ErrIdentityLinked          = errors.New("identity_already_linked")
+	ErrProviderLinked          = errors.New("provider_already_linked")
+	ErrLastIdentity            = errors.New("last_identity")
+	ErrInvalidMFACode          = errors.New("invalid_mfa_code")
+	ErrMFAEnabled              = errors.New("mfa_already_enabled")
+	ErrMFANotEnabled           = errors.New("mfa_not_enabled")
+	ErrMFAEnforced             = errors.New("mfa_enforced")
+	ErrTooManyRequests         = errors.New("too_many_requests")
+	ErrInvalidWebAuthnResponse = errors.New("invalid_webauthn_response")
+	ErrAccountLocked           = errors.New("account_locked")
+	ErrMagicLinkDisabled       = errors.New("magic_link_disabled")
+	ErrPasswordPolicy          = errors.New("password_policy_violation")
+	ErrInsufficientScope       = errors.New("insufficient_scope")
+	ErrTokenNotFound           = errors.New("token_not_found")
+	ErrAuthorizationPending    = errors.New("authorization_pending")
+	ErrSlowDown                = errors.New("slow_down")
+	ErrExpiredToken            = errors.New("expired_token")
 )
 
 // Descriptions error description
 var Descriptions = map[error]string{
-	ErrInvalidRequest:         "The request is missing a required parameter, includes an invalid parameter value, includes a parameter more than once, or is otherwise malformed",
-	ErrServerError:            "The authorization server encountered an unexpected condition that prevented it from fulfilling the request",
-	ErrTemporarilyUnavailable: "The authorization server is currently unable to handle the request due to a temporary overloading or maintenance of the server",
-	ErrInvalidUser:            "User does not exist",
-	ErrInvalidPassword:        "User authentication failed",
-	ErrUserExists:             "This username is already assigned to another user",
+	ErrInvalidRequest:          "The request is missing a required parameter, includes an invalid parameter value, includes a parameter more than once, or is otherwise malformed",
+	ErrServerError:             "The authorization server encountered an unexpected condition that prevented it from fulfilling the request",
+	ErrTemporarilyUnavailable:  "The authorization server is currently unable to handle the request due to a temporary overloading or maintenance of the server",
+	ErrInvalidUser:             "User does not exist",
+	ErrInvalidPassword:         "User authentication failed",
+	ErrUserExists:              "This username is already assigned to another user",
+	ErrInvalidGrant:            "The provided authorization grant or refresh token is invalid, expired, revoked or was issued to another client",
+	ErrUnsupportedGrantType:    "The authorization grant type is not supported by the authorization server",
+	ErrInvalidClient:           "Client authentication failed",
+	ErrUnauthorizedClient:      "The client is not authorized to request an authorization code using this method",
+	ErrAccessDenied:            "The resource owner or authorization server denied the request",
+	ErrUnsupportedResponseType: "The authorization server does not support obtaining an authorization code using this method",
+	ErrInvalidScope:            "The requested scope is invalid, unknown, or malformed",
+	ErrInvalidRedirectURI:      "The redirect uri is missing or is not registered for the client",
+	ErrSymmetricIDToken:        "The OpenID Connect provider mode requires an asymmetric JWT_SIGNING_ALGORITHM such as ES256 or RS256",
+	ErrMembershipRequired:      "The user is not a member of any of the organizations or teams allowed to login with this provider",
+	ErrInvalidState:            "The login was not started by this browser or has expired, please try again",
+	ErrDomainNotAllowed:        "The domain of the account is not allowed to login with this provider",
+	ErrEmailNotVerified:        "The email of the account is not verified by the provider",
+	ErrIdentityLinked:          "The account of the provider is already linked to another user",
+	ErrProviderLinked:          "Another account of this provider is already linked to the user",
+	ErrLastIdentity:            "The identity can't be unlinked as it is the only way left for the user to login",
+	ErrInvalidMFACode:          "The code is invalid, expired or has already been used",
+	ErrMFAEnabled:              "Multi-factor authentication is already enabled for the user",
+	ErrMFANotEnabled:           "Multi-factor authentication is not enabled for the user",
+	ErrMFAEnforced:             "Multi-factor authentication is enforced for the admins and can't be disabled",
+	ErrTooManyRequests:         "Too many requests, try again later",
+	ErrInvalidWebAuthnResponse: "The response of the authenticator could not be verified",
+	ErrAccountLocked:           "The login is temporarily locked after too many failed attempts, try again later",
+	ErrMagicLinkDisabled:       "The login with an email link is disabled",
+	ErrPasswordPolicy:          "The password does not meet the password policy",
+	ErrInsufficientScope:       "The scope of the token does not allow this request",
+	ErrTokenNotFound:           "The personal access token does not exist",
+	ErrAuthorizationPending:    "The user has not yet approved the device, keep polling",
+	ErrSlowDown:                "The device is polling too fast, the polling interval has been increased by 5 seconds",
+	ErrExpiredToken:            "The device code has expired, start a new login",
 }
 
 // StatusCodes response error HTTP status code
 var StatusCodes = map[error]int{
-	ErrInvalidRequest:         400,
-	ErrServerError:            500,
-	ErrTemporarilyUnavailable: 503,
-	ErrInvalidUser:            401,
-	ErrInvalidPassword:        401,
-	ErrUserExists:             401,
+	ErrInvalidRequest:          400,
+	ErrServerError:             500,
+	ErrTemporarilyUnavailable:  503,
+	ErrInvalidUser:             401,
+	ErrInvalidPassword:         401,
+	ErrUserExists:              401,
+	ErrInvalidGrant:            400,
+	ErrUnsupportedGrantType:    400,
+	ErrInvalidClient:           401,
+	ErrUnauthorizedClient:      400,
+	ErrAccessDenied:            403,
+	ErrUnsupportedResponseType: 400,
+	ErrInvalidScope:            400,
+	ErrInvalidRedirectURI:      400,
+	ErrSymmetricIDToken:        501,
+	ErrMembershipRequired:      403,
+	ErrInvalidState:            400,
+	ErrDomainNotAllowed:        403,
+	ErrEmailNotVerified:        403,
+	ErrIdentityLinked:          409,
+	ErrProviderLinked:          409,
+	ErrLastIdentity:            400,
+	ErrInvalidMFACode:          401,
+	ErrMFAEnabled:              409,
+	ErrMFANotEnabled:           400,
+	ErrMFAEnforced:             403,
+	ErrTooManyRequests:         429,
+	ErrInvalidWebAuthnResponse: 401,
+	ErrAccountLocked:           429,
+	ErrMagicLinkDisabled:       403,
+	ErrPasswordPolicy:          400,
+	ErrInsufficientScope:       403,
+	ErrTokenNotFound:           404,
+	ErrAuthorizationPending:    400,
+	ErrSlowDown:                400,
+	ErrExpiredToken:            400,
 }
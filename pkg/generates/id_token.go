@@ -0,0 +1,40 @@
+package generates
+
+import (
+	"github.com/dgrijalva/jwt-go"
+
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+)
+
+// IDTokenClaims are the claims of an OpenID Connect id token
+type IDTokenClaims struct {
+	Nonce             string `json:"nonce,omitempty"`
+	AuthTime          int64  `json:"auth_time,omitempty"`
+	Name              string `json:"name,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Picture           string `json:"picture,omitempty"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     *bool  `json:"email_verified,omitempty"`
+	jwt.StandardClaims
+}
+
+// IDToken signs the claims of an id token with the active key
+func (a *JWTAccessGenerate) IDToken(claims *IDTokenClaims) (string, error) {
+	if a.IDTokenSigningAlgorithm() == "" {
+		return "", errors.ErrSymmetricIDToken
+	}
+	return a.sign(claims)
+}
+
+// IDTokenSigningAlgorithm gives the algorithm with which id tokens are signed,
+// it is empty when the active key can't sign id tokens
+func (a *JWTAccessGenerate) IDTokenSigningAlgorithm() string {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	key := a.keyring.Active()
+	if isHs(key.method) {
+		return ""
+	}
+	return key.Algorithm
+}
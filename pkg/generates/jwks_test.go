@@ -0,0 +1,27 @@
+package generates
+
+import (
+	"testing"
+)
+
+func TestThumbprint(t *testing.T) {
+	// The example of RFC 7638 section 3.1
+	jwk := &JSONWebKey{
+		Kty: "RSA",
+		E:   "AQAB",
+		N: "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMst" +
+			"n64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5ha" +
+			"jrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+	}
+	expected := "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"
+	if got := jwk.Thumbprint(); got != expected {
+		t.Errorf("Expected: %v, Got: %v", expected, got)
+	}
+}
+
+func TestPadBytes(t *testing.T) {
+	padded := padBytes([]byte{1, 2}, 4)
+	if len(padded) != 4 || padded[0] != 0 || padded[1] != 0 || padded[2] != 1 || padded[3] != 2 {
+		t.Errorf("Expected: %v, Got: %v", []byte{0, 0, 1, 2}, padded)
+	}
+}
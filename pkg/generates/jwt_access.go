@@ -1,23 +1,18 @@
 package generates
 
 import (
-	"context"
-	errs "errors"
+	"encoding/json"
 	"fmt"
 	"os"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/globalsign/mgo/bson"
 	log "github.com/golang/glog"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/mayadata-io/kubera-auth/pkg/errors"
-	"github.com/mayadata-io/kubera-auth/pkg/k8s"
 	"github.com/mayadata-io/kubera-auth/pkg/models"
-	"github.com/mayadata-io/kubera-auth/pkg/types"
-	"github.com/mayadata-io/kubera-auth/pkg/utils/random"
 )
 
 // JWTAccessClaims jwt claims
@@ -29,25 +24,76 @@ type JWTAccessClaims struct {
 	Email    string           `json:"email,omitempty"`
 	Name     string           `json:"name,omitempty"`
 	Type     models.TokenType `json:"type"`
+	// Scope restricts the routes on which the token can be used, see models.HasScope
+	Scope string `json:"scope,omitempty"`
+	// ClientID is the OpenID Connect client to which the token was issued, it is empty for the portal
+	ClientID string `json:"client_id,omitempty"`
+	// Audience replaces the audience of the standard claims, which can't hold several audiences
+	Audience Audience `json:"aud,omitempty"`
 	jwt.StandardClaims
 }
 
+// Audience are the services for which a token is meant, a single audience is encoded as a string
+type Audience []string
+
+// Contains checks if the service is an audience of the token
+func (a Audience) Contains(audience string) bool {
+	for _, aud := range a {
+		if aud == audience {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalJSON encodes a single audience as a string and several audiences as an array
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+// UnmarshalJSON decodes the audience given either as a string or as an array
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var audience string
+	if err := json.Unmarshal(data, &audience); err == nil {
+		*a = Audience{audience}
+		return nil
+	}
+	var audiences []string
+	if err := json.Unmarshal(data, &audiences); err != nil {
+		return err
+	}
+	*a = audiences
+	return nil
+}
+
 func init() {
 	if os.Getenv("CONFIGMAP_NAME") == "" {
 		log.Fatal("Environment variable CONFIGMAP_NAME is not set")
 	}
 }
 
-// NewJWTAccessGenerate create to generate the jwt access token instance
-func NewJWTAccessGenerate(method jwt.SigningMethod) *JWTAccessGenerate {
-	key, err := initializeSecret()
-	if err != nil {
-		log.Fatal(err)
+// NewJWTAccessGenerate create to generate the jwt access token instance.
+// New tokens are signed with the active key of the keyring, a key retired by a rotation is
+// kept for verifying the tokens for the given retention. The tokens are issued by the issuer
+// for the audience, which is the audience accepted on parsing.
+func NewJWTAccessGenerate(method jwt.SigningMethod, retention time.Duration, issuer, audience string) *JWTAccessGenerate {
+	if !isHs(method) && !isEs(method) && !isRsOrPS(method) && !isEdDSA(method) {
+		log.Fatal("Unsupported signing method: ", method.Alg())
 	}
-	return &JWTAccessGenerate{
-		SignedKey:    []byte(key),
+
+	a := &JWTAccessGenerate{
 		SignedMethod: method,
+		KeyRetention: retention,
+		Issuer:       issuer,
+		Audience:     audience,
+	}
+	if err := a.initializeKeyring(); err != nil {
+		log.Fatal(err)
 	}
+	return a
 }
 
 // GenerateBasic provide the basis of the generated token data
@@ -55,35 +101,69 @@ type GenerateBasic struct {
 	UserInfo  *models.PublicUserInfo
 	CreateAt  *time.Time
 	TokenInfo *models.Token
+	// ClientID, Audience and Scope are set for the login tokens issued to OpenID Connect clients
+	ClientID string
+	Audience []string
+	Scope    string
 }
 
 // JWTAccessGenerate generate the jwt access token
 type JWTAccessGenerate struct {
-	SignedKey    []byte
+	// SignedMethod is the signing method for which new keys are generated
 	SignedMethod jwt.SigningMethod
-}
+	// KeyRetention is the time for which a retired key is kept for verification
+	KeyRetention time.Duration
+	// Issuer is the issuer of the tokens, the tokens of other issuers are rejected
+	Issuer string
+	// Audience is the audience of kubera-auth, which is an audience of all the tokens it issues and accepts
+	Audience string
+	// LoginAudiences are the other services, such as the Kubera microservices, which accept the login tokens of the portal
+	LoginAudiences []string
 
-func initializeSecret() (string, error) {
-	if k8s.ClientSet == nil {
-		return "", errors.New("ClientSet not found")
-	}
+	lock       sync.RWMutex
+	keyring    *Keyring
+	reloadedAt time.Time
+	// reloadLock collapses the concurrent reloads triggered by unknown keys into one
+	reloadLock sync.Mutex
+}
 
-	cm, err := k8s.ClientSet.CoreV1().ConfigMaps(types.DefaultNamespace).Get(context.TODO(), types.DefaultConfigMap, metav1.GetOptions{})
+// initializeKeyring reads the keyring from the secret, creating it if required. If the configured
+// signing method differs from the one of the active key, the active key is rotated.
+func (a *JWTAccessGenerate) initializeKeyring() error {
+	keyring, err := updateKeyring(func(keyring *Keyring) (bool, error) {
+		if keyring.Active() == nil {
+			return true, keyring.seed(a.SignedMethod)
+		}
+		if keyring.Active().Algorithm != a.SignedMethod.Alg() {
+			return true, keyring.rotate(a.SignedMethod)
+		}
+		return keyring.prune(a.KeyRetention), nil
+	})
 	if err != nil {
-		return "", err
-	}
-	if cm.Data[types.JWTSecretString] != "" {
-		return cm.Data[types.JWTSecretString], nil
+		return err
 	}
+	a.setKeyring(keyring)
+	return nil
+}
 
-	secret := random.GetRandomString(10)
-	cm.Data[types.JWTSecretString] = secret
-	_, err = k8s.ClientSet.CoreV1().ConfigMaps(types.DefaultNamespace).Update(context.TODO(), cm, metav1.UpdateOptions{})
-	return secret, err
+func (a *JWTAccessGenerate) setKeyring(keyring *Keyring) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.keyring = keyring
+	a.reloadedAt = time.Now()
 }
 
-// Token based on the UUID generated token
+// Token based on the UUID generated token. The login tokens are meant for the login audiences, or for the audiences
+// of the client to which they are issued, the other tokens are only meant for kubera-auth.
 func (a *JWTAccessGenerate) Token(data *GenerateBasic) (string, error) {
+	audience := Audience{a.Audience}
+	if data.TokenInfo.Type == models.TokenLogin && data.ClientID == "" {
+		audience = append(audience, a.LoginAudiences...)
+	} else if data.TokenInfo.Type == models.TokenLogin {
+		audience = append(audience, data.Audience...)
+	}
+
+	now := time.Now().Unix()
 	claims := &JWTAccessClaims{
 		ID:       data.UserInfo.ID,
 		UID:      data.UserInfo.UID,
@@ -92,76 +172,116 @@ func (a *JWTAccessGenerate) Token(data *GenerateBasic) (string, error) {
 		Email:    data.UserInfo.Email,
 		Name:     data.UserInfo.Name,
 		Type:     data.TokenInfo.Type,
+		Scope:    data.Scope,
+		ClientID: data.ClientID,
+		Audience: audience,
 		StandardClaims: jwt.StandardClaims{
-			IssuedAt:  time.Now().Unix(),
+			Id:        data.TokenInfo.GetID(),
+			Issuer:    a.Issuer,
+			Subject:   data.UserInfo.UID,
+			IssuedAt:  now,
+			NotBefore: now,
 			ExpiresAt: data.TokenInfo.GetAccessCreateAt().Add(data.TokenInfo.GetAccessExpiresIn()).Unix(),
 		},
 	}
-	token := jwt.NewWithClaims(a.SignedMethod, claims)
-	var key interface{}
-	if a.isEs() {
-		v, err := jwt.ParseECPrivateKeyFromPEM(a.SignedKey)
-		if err != nil {
-			return "", err
-		}
-		key = v
-	} else if a.isRsOrPS() {
-		v, err := jwt.ParseRSAPrivateKeyFromPEM(a.SignedKey)
-		if err != nil {
-			return "", err
-		}
-		key = v
-	} else if a.isHs() {
-		key = a.SignedKey
-	} else {
-		return "", errs.New("unsupported sign method")
-	}
+	return a.sign(claims)
+}
 
-	access, err := token.SignedString(key)
+// sign signs the claims with the active key of the keyring
+func (a *JWTAccessGenerate) sign(claims jwt.Claims) (string, error) {
+	a.lock.RLock()
+	key := a.keyring.Active()
+	a.lock.RUnlock()
+
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.ID
+
+	access, err := token.SignedString(key.signKey)
 	if err != nil {
 		return "", err
 	}
 	return access, nil
 }
 
-func (a *JWTAccessGenerate) isEs() bool {
-	return strings.HasPrefix(a.SignedMethod.Alg(), "ES")
-}
+// JWKS gives the public keys which can be used to verify the tokens,
+// the keys of HMAC signing methods are never published
+func (a *JWTAccessGenerate) JWKS() (*JSONWebKeySet, error) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
 
-func (a *JWTAccessGenerate) isRsOrPS() bool {
-	isRs := strings.HasPrefix(a.SignedMethod.Alg(), "RS")
-	isPs := strings.HasPrefix(a.SignedMethod.Alg(), "PS")
-	return isRs || isPs
+	set := &JSONWebKeySet{
+		Keys: []*JSONWebKey{},
+	}
+	for _, key := range a.keyring.Keys {
+		jwk, err := key.JWK()
+		if err != nil {
+			return nil, err
+		} else if jwk != nil {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set, nil
 }
 
-func (a *JWTAccessGenerate) isHs() bool {
-	return strings.HasPrefix(a.SignedMethod.Alg(), "HS")
+// Keys gives the non secret information about the keys of the keyring
+func (a *JWTAccessGenerate) Keys() []*KeyInfo {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	return a.keyring.Info()
 }
 
-// Parse parses a UserName from a token
-func (a *JWTAccessGenerate) Parse(tokenString string) (*models.UserCredentials, error) {
-	token, err := a.parseToken(tokenString)
+// Parse parses the user from a token of the given type, the tokens of the other types are rejected
+func (a *JWTAccessGenerate) Parse(tokenString string, tokenType models.TokenType) (*models.UserCredentials, error) {
+	claims, err := a.ParseClaims(tokenString)
 	if err != nil {
 		return nil, err
 	}
+	if claims.Type != tokenType {
+		return nil, errors.ErrInvalidAccessToken
+	}
 
 	var user *models.UserCredentials = new(models.UserCredentials)
+	user.Role = claims.Role
+	user.UID = claims.UID
+	user.ID = claims.ID
+	return user, nil
+}
 
-	if claims, ok := token.Claims.(*JWTAccessClaims); ok && token.Valid {
-		user.Role = claims.Role
-		user.UID = claims.UID
-		user.ID = claims.ID
-		return user, nil
+// ParseClaims validates a token and returns all of its claims. Besides the signature and the lifetime of the token,
+// including its nbf, the token has to be issued by the issuer for the audience of kubera-auth.
+func (a *JWTAccessGenerate) ParseClaims(tokenString string) (*JWTAccessClaims, error) {
+	token, err := a.parseToken(tokenString)
+	if err != nil {
+		return nil, err
 	}
-	return nil, errors.ErrInvalidAccessToken
+
+	claims, ok := token.Claims.(*JWTAccessClaims)
+	if !ok || !token.Valid || claims.Issuer != a.Issuer || !claims.Audience.Contains(a.Audience) {
+		return nil, errors.ErrInvalidAccessToken
+	}
+	return claims, nil
+}
+
+// HasScope checks if the token meets the required scope, see models.HasScope
+func (c *JWTAccessClaims) HasScope(scope string) bool {
+	return models.HasScope(c.Scope, scope)
 }
 
 func (a *JWTAccessGenerate) parseToken(tokenString string) (*jwt.Token, error) {
 	return jwt.ParseWithClaims(tokenString, &JWTAccessClaims{}, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			kid = legacyKeyID
+		}
+
+		key := a.verificationKey(kid)
+		if key == nil {
+			return nil, fmt.Errorf("Unknown signing key: %v", kid)
+		}
 		// Validating the signing method
-		if ok := token.Method.Alg() == a.SignedMethod.Alg(); !ok {
+		if ok := token.Method.Alg() == key.Algorithm; !ok {
 			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
 		}
-		return a.SignedKey, nil
+		return key.verifyKey, nil
 	})
 }
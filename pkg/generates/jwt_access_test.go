@@ -0,0 +1,128 @@
+package generates
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+)
+
+const (
+	testIssuer   = "https://kubera.example.org/api/auth"
+	testAudience = "kubera-auth"
+)
+
+// newTestGenerate gives a generator signing with a keyring of its own, which doesn't need Kubernetes
+func newTestGenerate(t *testing.T) *JWTAccessGenerate {
+	t.Helper()
+	keyring := &Keyring{}
+	if err := keyring.rotate(jwt.SigningMethodES256); err != nil {
+		t.Fatal(err)
+	}
+	return &JWTAccessGenerate{
+		SignedMethod:   jwt.SigningMethodES256,
+		Issuer:         testIssuer,
+		Audience:       testAudience,
+		LoginAudiences: []string{"kubera"},
+		keyring:        keyring,
+	}
+}
+
+func newTestClaims() *JWTAccessClaims {
+	now := time.Now()
+	return &JWTAccessClaims{
+		UID:      "uid",
+		Type:     models.TokenLogin,
+		Audience: Audience{testAudience},
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    testIssuer,
+			Subject:   "uid",
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			ExpiresAt: now.Add(time.Hour).Unix(),
+		},
+	}
+}
+
+func TestParseClaims(t *testing.T) {
+	a := newTestGenerate(t)
+
+	for _, test := range []struct {
+		name   string
+		modify func(claims *JWTAccessClaims)
+		valid  bool
+	}{
+		{"valid", func(*JWTAccessClaims) {}, true},
+		{"several audiences", func(claims *JWTAccessClaims) { claims.Audience = Audience{"kubera", testAudience} }, true},
+		{"wrong issuer", func(claims *JWTAccessClaims) { claims.Issuer = "https://other.example.org" }, false},
+		{"no issuer", func(claims *JWTAccessClaims) { claims.Issuer = "" }, false},
+		{"foreign audience", func(claims *JWTAccessClaims) { claims.Audience = Audience{"kubera"} }, false},
+		{"no audience", func(claims *JWTAccessClaims) { claims.Audience = nil }, false},
+		{"future nbf", func(claims *JWTAccessClaims) { claims.NotBefore = time.Now().Add(time.Hour).Unix() }, false},
+		{"expired", func(claims *JWTAccessClaims) { claims.ExpiresAt = time.Now().Add(-time.Minute).Unix() }, false},
+	} {
+		claims := newTestClaims()
+		test.modify(claims)
+		token, err := a.sign(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = a.ParseClaims(token)
+		if test.valid && err != nil {
+			t.Errorf("%s: expected the token to be accepted, got %v", test.name, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("%s: expected the token to be rejected", test.name)
+		}
+	}
+}
+
+func TestTokenAudience(t *testing.T) {
+	a := newTestGenerate(t)
+	createAt := time.Now()
+	userInfo := &models.PublicUserInfo{UID: "uid"}
+
+	for _, test := range []struct {
+		name      string
+		tokenType models.TokenType
+		clientID  string
+		audience  []string
+		expected  Audience
+	}{
+		{"portal login", models.TokenLogin, "", nil, Audience{testAudience, "kubera"}},
+		{"client login", models.TokenLogin, "client", []string{"api"}, Audience{testAudience, "api"}},
+		{"password reset", models.TokenPasswordReset, "", nil, Audience{testAudience}},
+	} {
+		ti := models.NewToken(test.tokenType)
+		ti.SetAccessCreateAt(createAt)
+		ti.SetAccessExpiresIn(time.Hour)
+		token, err := a.Token(&GenerateBasic{
+			UserInfo:  userInfo,
+			CreateAt:  &createAt,
+			TokenInfo: ti,
+			ClientID:  test.clientID,
+			Audience:  test.audience,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		claims, err := a.ParseClaims(token)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if len(claims.Audience) != len(test.expected) {
+			t.Fatalf("%s: expected the audience %v, got %v", test.name, test.expected, claims.Audience)
+		}
+		for _, audience := range test.expected {
+			if !claims.Audience.Contains(audience) {
+				t.Errorf("%s: expected the audience %v, got %v", test.name, test.expected, claims.Audience)
+			}
+		}
+		if claims.Issuer != testIssuer {
+			t.Errorf("%s: expected the issuer %s, got %s", test.name, testIssuer, claims.Issuer)
+		}
+	}
+}
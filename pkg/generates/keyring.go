@@ -0,0 +1,325 @@
+package generates
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	log "github.com/golang/glog"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/k8s"
+	"github.com/mayadata-io/kubera-auth/pkg/types"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/random"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/uuid"
+)
+
+// legacyKeyID identifies the shared secret which was used before the keyring existed,
+// the tokens signed with it don't carry a `kid` header
+const legacyKeyID = "legacy"
+
+// SigningKey is a key of the keyring
+type SigningKey struct {
+	ID        string `json:"kid"`
+	Algorithm string `json:"alg"`
+	// Key is the shared secret for HMAC signing methods, else the PEM encoded private key
+	Key       string    `json:"key"`
+	CreatedAt time.Time `json:"created_at"`
+	// RetiredAt is the time since when the key is not used for signing anymore,
+	// it is kept for verifying the tokens signed with it till they expire
+	RetiredAt *time.Time `json:"retired_at,omitempty"`
+
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// Keyring holds the keys which are accepted for verifying the tokens,
+// only the active key is used for signing new tokens
+type Keyring struct {
+	ActiveKeyID string        `json:"active_kid"`
+	Keys        []*SigningKey `json:"keys"`
+}
+
+// KeyInfo is the non secret information about a key of the keyring
+type KeyInfo struct {
+	ID        string     `json:"kid"`
+	Algorithm string     `json:"alg"`
+	CreatedAt time.Time  `json:"created_at"`
+	RetiredAt *time.Time `json:"retired_at,omitempty"`
+	Active    bool       `json:"active"`
+}
+
+// newSigningKey generates a new key for the signing method
+func newSigningKey(method jwt.SigningMethod) (*SigningKey, error) {
+	key := &SigningKey{
+		Algorithm: method.Alg(),
+		CreatedAt: time.Now(),
+	}
+
+	if isHs(method) {
+		secret, err := random.GetSecureRandomString(hmacSecretLength)
+		if err != nil {
+			return nil, err
+		}
+		key.ID = uuid.Must(uuid.NewRandom()).String()
+		key.Key = secret
+		return key, key.init()
+	}
+
+	signer, err := generateKey(method)
+	if err != nil {
+		return nil, err
+	}
+	pemKey, err := encodePrivateKey(signer)
+	if err != nil {
+		return nil, err
+	}
+	key.Key = string(pemKey)
+	if err = key.init(); err != nil {
+		return nil, err
+	}
+
+	// The thumbprint of an asymmetric key is used as its key id
+	jwk, err := key.JWK()
+	if err != nil {
+		return nil, err
+	}
+	key.ID = jwk.Kid
+	return key, nil
+}
+
+// init parses the key material so that it can be used for signing and verification
+func (k *SigningKey) init() error {
+	k.method = jwt.GetSigningMethod(k.Algorithm)
+	if k.method == nil {
+		return fmt.Errorf("unsupported signing method %s of key %s", k.Algorithm, k.ID)
+	}
+
+	if isHs(k.method) {
+		k.signKey = []byte(k.Key)
+		k.verifyKey = k.signKey
+		return nil
+	}
+
+	signer, err := parsePrivateKey([]byte(k.Key))
+	if err != nil {
+		return err
+	}
+	if err = validateKey(k.method, signer); err != nil {
+		return err
+	}
+	k.signKey = signer
+	k.verifyKey = signer.Public()
+	return nil
+}
+
+// JWK gives the public JWK of the key, nil for shared secrets
+func (k *SigningKey) JWK() (*JSONWebKey, error) {
+	if isHs(k.method) {
+		return nil, nil
+	}
+	return newJSONWebKey(k.verifyKey, k.Algorithm)
+}
+
+// Active gives the key used for signing the new tokens
+func (kr *Keyring) Active() *SigningKey {
+	return kr.Get(kr.ActiveKeyID)
+}
+
+// Get gives the key having the given key id
+func (kr *Keyring) Get(kid string) *SigningKey {
+	for _, key := range kr.Keys {
+		if key.ID == kid {
+			return key
+		}
+	}
+	return nil
+}
+
+// Info gives the non secret information about the keys
+func (kr *Keyring) Info() []*KeyInfo {
+	keys := make([]*KeyInfo, 0, len(kr.Keys))
+	for _, key := range kr.Keys {
+		keys = append(keys, &KeyInfo{
+			ID:        key.ID,
+			Algorithm: key.Algorithm,
+			CreatedAt: key.CreatedAt,
+			RetiredAt: key.RetiredAt,
+			Active:    key.ID == kr.ActiveKeyID,
+		})
+	}
+	return keys
+}
+
+// add adds a key to the keyring and makes it the active key, the previously active key is retired
+func (kr *Keyring) add(key *SigningKey) {
+	if active := kr.Active(); active != nil {
+		retiredAt := time.Now()
+		active.RetiredAt = &retiredAt
+	}
+	kr.Keys = append(kr.Keys, key)
+	kr.ActiveKeyID = key.ID
+}
+
+// rotate generates a new active key for the signing method
+func (kr *Keyring) rotate(method jwt.SigningMethod) error {
+	key, err := newSigningKey(method)
+	if err != nil {
+		return err
+	}
+	kr.add(key)
+	log.Infoln("Rotated the signing key, new key id: ", key.ID)
+	return nil
+}
+
+// prune removes the keys which have been retired for longer than the retention, returns whether any key was removed
+func (kr *Keyring) prune(retention time.Duration) bool {
+	keys := make([]*SigningKey, 0, len(kr.Keys))
+	for _, key := range kr.Keys {
+		if !key.expired(retention) {
+			keys = append(keys, key)
+		}
+	}
+	pruned := len(keys) != len(kr.Keys)
+	kr.Keys = keys
+	return pruned
+}
+
+// hasExpiredKeys checks whether prune would remove any key
+func (kr *Keyring) hasExpiredKeys(retention time.Duration) bool {
+	for _, key := range kr.Keys {
+		if key.expired(retention) {
+			return true
+		}
+	}
+	return false
+}
+
+// expired checks whether the key has been retired for longer than the retention
+func (k *SigningKey) expired(retention time.Duration) bool {
+	return k.RetiredAt != nil && time.Since(*k.RetiredAt) >= retention
+}
+
+// seed adds the key which was used before the keyring existed, or a new one if there was none
+func (kr *Keyring) seed(method jwt.SigningMethod) error {
+	legacy, err := legacyKey(method)
+	if err != nil {
+		return err
+	} else if legacy == nil {
+		return kr.rotate(method)
+	}
+	kr.add(legacy)
+	return nil
+}
+
+// legacyKey gives the key which was used before the keyring existed, nil if there was none
+func legacyKey(method jwt.SigningMethod) (*SigningKey, error) {
+	key := &SigningKey{
+		Algorithm: method.Alg(),
+		CreatedAt: time.Now(),
+	}
+
+	if isHs(method) {
+		secret, err := legacySecret()
+		if err != nil || secret == "" {
+			return nil, err
+		}
+		key.ID = legacyKeyID
+		key.Key = secret
+		return key, key.init()
+	}
+
+	secret, err := getSecret()
+	if err != nil {
+		return nil, err
+	}
+	pemKey := secret.Data[types.JWTSigningKeyString]
+	if len(pemKey) == 0 {
+		return nil, nil
+	}
+	key.Key = string(pemKey)
+	if err = key.init(); err != nil {
+		return nil, err
+	}
+	jwk, err := key.JWK()
+	if err != nil {
+		return nil, err
+	}
+	key.ID = jwk.Kid
+	return key, nil
+}
+
+// loadKeyring reads the keyring from the secret
+func loadKeyring() (*Keyring, error) {
+	secret, err := getSecret()
+	if err != nil {
+		return nil, err
+	}
+	return decodeKeyring(secret.Data[types.JWTKeyringString])
+}
+
+// updateKeyring applies the update on the keyring stored in the secret and persists the keyring if
+// the update reports a change. The secret is updated with optimistic concurrency so that concurrent
+// updates from multiple replicas never overwrite each other, the update is retried on conflicts.
+func updateKeyring(update func(keyring *Keyring) (bool, error)) (*Keyring, error) {
+	var keyring *Keyring
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		secret, err := getSecret()
+		if err != nil {
+			return err
+		}
+		keyring, err = decodeKeyring(secret.Data[types.JWTKeyringString])
+		if err != nil {
+			return err
+		}
+
+		changed, err := update(keyring)
+		if err != nil || !changed {
+			return err
+		}
+
+		data, err := json.Marshal(keyring)
+		if err != nil {
+			return err
+		}
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		secret.Data[types.JWTKeyringString] = data
+		_, err = k8s.ClientSet.CoreV1().Secrets(types.DefaultNamespace).Update(context.TODO(), secret, metav1.UpdateOptions{})
+		return err
+	})
+	return keyring, err
+}
+
+func decodeKeyring(data []byte) (*Keyring, error) {
+	keyring := &Keyring{}
+	if len(data) == 0 {
+		return keyring, nil
+	}
+	if err := json.Unmarshal(data, keyring); err != nil {
+		return nil, err
+	}
+	for _, key := range keyring.Keys {
+		if err := key.init(); err != nil {
+			return nil, err
+		}
+	}
+	return keyring, nil
+}
+
+func getSecret() (*corev1.Secret, error) {
+	if k8s.ClientSet == nil {
+		return nil, errors.New("ClientSet not found")
+	}
+	if types.Credentials == "" {
+		return nil, errors.New("Environment variable SECRET_NAME is not set")
+	}
+	return k8s.ClientSet.CoreV1().Secrets(types.DefaultNamespace).Get(context.TODO(), types.Credentials, metav1.GetOptions{})
+}
@@ -0,0 +1,146 @@
+package generates
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/k8s"
+	"github.com/mayadata-io/kubera-auth/pkg/types"
+)
+
+const (
+	rsaKeySize        = 2048
+	hmacSecretLength  = 64
+	privateKeyPEMType = "PRIVATE KEY"
+)
+
+func isEs(method jwt.SigningMethod) bool {
+	return strings.HasPrefix(method.Alg(), "ES")
+}
+
+func isRsOrPS(method jwt.SigningMethod) bool {
+	isRs := strings.HasPrefix(method.Alg(), "RS")
+	isPs := strings.HasPrefix(method.Alg(), "PS")
+	return isRs || isPs
+}
+
+func isHs(method jwt.SigningMethod) bool {
+	return strings.HasPrefix(method.Alg(), "HS")
+}
+
+func isEdDSA(method jwt.SigningMethod) bool {
+	return method.Alg() == SigningMethodEdDSA.Alg()
+}
+
+// legacySecret gets the shared secret which was used for signing the tokens before the keyring existed
+func legacySecret() (string, error) {
+	if k8s.ClientSet == nil {
+		return "", errors.New("ClientSet not found")
+	}
+
+	cm, err := k8s.ClientSet.CoreV1().ConfigMaps(types.DefaultNamespace).Get(context.TODO(), types.DefaultConfigMap, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	return cm.Data[types.JWTSecretString], nil
+}
+
+// generateKey generates a new private key for the signing method
+func generateKey(method jwt.SigningMethod) (crypto.Signer, error) {
+	switch {
+	case isRsOrPS(method):
+		return rsa.GenerateKey(rand.Reader, rsaKeySize)
+	case isEs(method):
+		curve, err := curveOf(method)
+		if err != nil {
+			return nil, err
+		}
+		return ecdsa.GenerateKey(curve, rand.Reader)
+	case isEdDSA(method):
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	}
+	return nil, fmt.Errorf("unable to generate a key for signing method %s", method.Alg())
+}
+
+// validateKey checks whether the key can be used with the signing method
+func validateKey(method jwt.SigningMethod, key crypto.Signer) error {
+	valid := false
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		valid = isRsOrPS(method)
+	case *ecdsa.PrivateKey:
+		curve, err := curveOf(method)
+		valid = err == nil && k.Curve == curve
+	case ed25519.PrivateKey:
+		valid = isEdDSA(method)
+	}
+
+	if !valid {
+		return fmt.Errorf("signing key of type %T can not be used with signing method %s", key, method.Alg())
+	}
+	return nil
+}
+
+// curveOf returns the elliptic curve which has to be used with an ES signing method
+func curveOf(method jwt.SigningMethod) (elliptic.Curve, error) {
+	switch method.Alg() {
+	case jwt.SigningMethodES256.Alg():
+		return elliptic.P256(), nil
+	case jwt.SigningMethodES384.Alg():
+		return elliptic.P384(), nil
+	case jwt.SigningMethodES512.Alg():
+		return elliptic.P521(), nil
+	}
+	return nil, fmt.Errorf("no elliptic curve for signing method %s", method.Alg())
+}
+
+// parsePrivateKey parses a PEM encoded PKCS #1, SEC 1 or PKCS #8 private key
+func parsePrivateKey(pemKey []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(pemKey)
+	if block == nil {
+		return nil, errors.New("signing key is not PEM encoded")
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported signing key of type %T", key)
+	}
+	return signer, nil
+}
+
+// encodePrivateKey encodes the private key in PEM encoded PKCS #8 form
+func encodePrivateKey(key crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: privateKeyPEMType, Bytes: der}), nil
+}
@@ -0,0 +1,120 @@
+package generates
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+)
+
+// newGenerate gives a generator whose keyring holds a new key of the signing method
+func newGenerate(t *testing.T, method jwt.SigningMethod) *JWTAccessGenerate {
+	t.Helper()
+	keyring := &Keyring{}
+	if err := keyring.rotate(method); err != nil {
+		t.Fatal(err)
+	}
+	return &JWTAccessGenerate{SignedMethod: method, keyring: keyring}
+}
+
+func newTokenData() *GenerateBasic {
+	ti := models.NewToken(models.TokenLogin)
+	ti.SetAccessCreateAt(time.Now())
+	ti.SetAccessExpiresIn(time.Hour)
+	return &GenerateBasic{
+		UserInfo:  &models.PublicUserInfo{UID: "user-uid"},
+		TokenInfo: ti,
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	methods := []jwt.SigningMethod{
+		jwt.SigningMethodRS256,
+		jwt.SigningMethodPS256,
+		jwt.SigningMethodES256,
+		jwt.SigningMethodES384,
+		jwt.SigningMethodES512,
+		SigningMethodEdDSA,
+	}
+	for _, method := range methods {
+		t.Run(method.Alg(), func(t *testing.T) {
+			a := newGenerate(t, method)
+			access, err := a.Token(newTokenData())
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err := a.ParseClaims(access)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.UID != "user-uid" {
+				t.Errorf("Expected: %v, Got: %v", "user-uid", claims.UID)
+			}
+
+			// The key persisted in the secret can be used once parsed back
+			key, err := parsePrivateKey([]byte(a.keyring.Active().Key))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = validateKey(method, key); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestParseRejectsOtherAlgorithm(t *testing.T) {
+	a := newGenerate(t, jwt.SigningMethodRS256)
+	key := a.keyring.Active()
+	claims := &JWTAccessClaims{UID: "user-uid"}
+
+	// Signed with the right key, but not with the algorithm of the key
+	ps := jwt.NewWithClaims(jwt.SigningMethodPS256, claims)
+	ps.Header["kid"] = key.ID
+	access, err := ps.SignedString(key.signKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = a.ParseClaims(access); err == nil {
+		t.Error("expected a token of another algorithm than the one of the key to be rejected")
+	}
+
+	// The public key must not be usable as the secret of a HMAC signature
+	der, err := x509.MarshalPKIXPublicKey(key.verifyKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	hs.Header["kid"] = key.ID
+	access, err = hs.SignedString(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = a.ParseClaims(access); err == nil {
+		t.Error("expected a token signed with the public key as HMAC secret to be rejected")
+	}
+}
+
+func TestValidateKey(t *testing.T) {
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = validateKey(jwt.SigningMethodES256, p384); err == nil {
+		t.Error("expected a key of another curve to be rejected")
+	}
+
+	rsaKey, err := generateKey(jwt.SigningMethodRS256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = validateKey(jwt.SigningMethodES256, rsaKey); err == nil {
+		t.Error("expected a RSA key to be rejected for an ES signing method")
+	}
+}
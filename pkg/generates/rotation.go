@@ -0,0 +1,119 @@
+package generates
+
+import (
+	"time"
+
+	log "github.com/golang/glog"
+
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+)
+
+const (
+	// keyringSyncInterval is the interval at which the keyring is synced with the secret
+	keyringSyncInterval = time.Minute
+	// keyringReloadInterval limits how often a token signed with an unknown key triggers a reload
+	keyringReloadInterval = time.Second * 10
+)
+
+// verificationKey gives the key having the given key id. The keyring is reloaded from the secret if
+// the key is not known, as the key might have been added by a rotation on another replica. The reloads
+// are attempted at most once per interval, whether they succeed or not.
+func (a *JWTAccessGenerate) verificationKey(kid string) *SigningKey {
+	if key, reload := a.lookupKey(kid); key != nil || !reload {
+		return key
+	}
+
+	a.reloadLock.Lock()
+	defer a.reloadLock.Unlock()
+	// The keyring might have been reloaded while waiting for the lock
+	if key, reload := a.lookupKey(kid); key != nil || !reload {
+		return key
+	}
+
+	a.lock.Lock()
+	a.reloadedAt = time.Now()
+	a.lock.Unlock()
+	if err := a.Reload(); err != nil {
+		log.Errorln("Error reloading the keyring", err)
+		return nil
+	}
+
+	key, _ := a.lookupKey(kid)
+	return key
+}
+
+// lookupKey gives the key having the given key id, and whether the keyring can be reloaded to find it
+func (a *JWTAccessGenerate) lookupKey(kid string) (*SigningKey, bool) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	return a.keyring.Get(kid), time.Since(a.reloadedAt) >= keyringReloadInterval
+}
+
+// Reload reads the keyring from the secret
+func (a *JWTAccessGenerate) Reload() error {
+	keyring, err := loadKeyring()
+	if err != nil {
+		return err
+	}
+	if keyring.Active() == nil {
+		return errors.New("keyring has no active key")
+	}
+	a.setKeyring(keyring)
+	return nil
+}
+
+// Rotate generates a new active key, the previously active key is retired
+func (a *JWTAccessGenerate) Rotate() ([]*KeyInfo, error) {
+	keyring, err := updateKeyring(func(keyring *Keyring) (bool, error) {
+		keyring.prune(a.KeyRetention)
+		return true, keyring.rotate(a.SignedMethod)
+	})
+	if err != nil {
+		return nil, err
+	}
+	a.setKeyring(keyring)
+	return keyring.Info(), nil
+}
+
+// StartKeyRotation keeps the keyring in sync with the secret in background, removing the retired keys
+// once their retention is over. If the interval is not zero the active key is rotated whenever it
+// gets older than the interval.
+func (a *JWTAccessGenerate) StartKeyRotation(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(keyringSyncInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := a.syncKeyring(interval); err != nil {
+				log.Errorln("Error syncing the keyring", err)
+			}
+		}
+	}()
+}
+
+func (a *JWTAccessGenerate) syncKeyring(interval time.Duration) error {
+	if err := a.Reload(); err != nil {
+		return err
+	}
+
+	a.lock.RLock()
+	rotate := interval > 0 && time.Since(a.keyring.Active().CreatedAt) >= interval
+	prune := a.keyring.hasExpiredKeys(a.KeyRetention)
+	a.lock.RUnlock()
+	if !rotate && !prune {
+		return nil
+	}
+
+	// The conditions are checked again on the stored keyring, another replica might have updated it meanwhile
+	keyring, err := updateKeyring(func(keyring *Keyring) (bool, error) {
+		pruned := keyring.prune(a.KeyRetention)
+		if interval > 0 && time.Since(keyring.Active().CreatedAt) >= interval {
+			return true, keyring.rotate(a.SignedMethod)
+		}
+		return pruned, nil
+	})
+	if err != nil {
+		return err
+	}
+	a.setKeyring(keyring)
+	return nil
+}
@@ -0,0 +1,94 @@
+package generates
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/mayadata-io/kubera-auth/pkg/k8s"
+	"github.com/mayadata-io/kubera-auth/pkg/types"
+)
+
+// newFailingReloads gives a generator whose keyring can't be reloaded, along with the number of reloads
+func newFailingReloads(t *testing.T) (*JWTAccessGenerate, *int32) {
+	var reloads int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&reloads, 1)
+		// Slow enough for the concurrent lookups to pile up on the reload
+		time.Sleep(time.Millisecond * 50)
+		http.Error(w, "unavailable", http.StatusInternalServerError)
+	}))
+	clientSet, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	previousClientSet, previousNamespace, previousCredentials := k8s.ClientSet, types.DefaultNamespace, types.Credentials
+	k8s.ClientSet, types.DefaultNamespace, types.Credentials = clientSet, "kubera", "kubera-auth-test"
+	t.Cleanup(func() {
+		k8s.ClientSet, types.DefaultNamespace, types.Credentials = previousClientSet, previousNamespace, previousCredentials
+		server.Close()
+	})
+
+	keyring := &Keyring{}
+	if err := keyring.rotate(jwt.SigningMethodES256); err != nil {
+		t.Fatal(err)
+	}
+	return &JWTAccessGenerate{SignedMethod: jwt.SigningMethodES256, keyring: keyring}, &reloads
+}
+
+func TestVerificationKeyCollapsesReloads(t *testing.T) {
+	a, reloads := newFailingReloads(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if key := a.verificationKey("unknown"); key != nil {
+				t.Error("expected no key for an unknown key id")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(reloads); n != 1 {
+		t.Fatalf("expected the concurrent lookups to reload the keyring once, got %d reloads", n)
+	}
+}
+
+func TestVerificationKeyThrottlesFailedReloads(t *testing.T) {
+	a, reloads := newFailingReloads(t)
+
+	for i := 0; i < 5; i++ {
+		if key := a.verificationKey("unknown"); key != nil {
+			t.Fatal("expected no key for an unknown key id")
+		}
+	}
+	if n := atomic.LoadInt32(reloads); n != 1 {
+		t.Fatalf("expected a failed reload to be throttled, got %d reloads", n)
+	}
+
+	// Once the interval is over the keyring is reloaded again
+	a.lock.Lock()
+	a.reloadedAt = time.Now().Add(-keyringReloadInterval)
+	a.lock.Unlock()
+	a.verificationKey("unknown")
+	if n := atomic.LoadInt32(reloads); n != 2 {
+		t.Fatalf("expected a reload once the interval is over, got %d reloads", n)
+	}
+
+	// The known keys never trigger a reload
+	if key := a.verificationKey(a.keyring.Active().ID); key == nil {
+		t.Fatal("expected the active key to be found")
+	}
+	if n := atomic.LoadInt32(reloads); n != 2 {
+		t.Fatalf("expected no reload for a known key, got %d reloads", n)
+	}
+}
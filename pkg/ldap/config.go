@@ -0,0 +1,91 @@
+package ldap
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mayadata-io/kubera-auth/pkg/types"
+)
+
+// Config is the configuration of the LDAP directory
+type Config struct {
+	// URL is the address of the directory, either ldap://host:389 or ldaps://host:636
+	URL string
+	// StartTLS upgrades a plain ldap connection to TLS
+	StartTLS           bool
+	InsecureSkipVerify bool
+	// BindDN and BindPassword are the service account with which the users are looked up,
+	// the users are looked up anonymously without it
+	BindDN       string
+	BindPassword string
+	// BaseDN is the subtree under which the users are looked up
+	BaseDN string
+	// UserAttribute holds the username of the users, such as uid or sAMAccountName for Active Directory
+	UserAttribute  string
+	EmailAttribute string
+	NameAttribute  string
+	// GroupAttribute lists the groups of which the user is a member, as given by the memberOf overlay
+	GroupAttribute string
+	// AllowedGroups restricts the login to the members of these groups, members of AdminGroups get the admin role.
+	// A group is either its DN or the value of its first RDN, such as its common name.
+	AllowedGroups []string
+	AdminGroups   []string
+	Timeout       time.Duration
+}
+
+// NewConfig returns the LDAP config
+func NewConfig() *Config {
+	startTLS, _ := strconv.ParseBool(os.Getenv(types.LDAP_START_TLS))
+	insecureSkipVerify, _ := strconv.ParseBool(os.Getenv(types.LDAP_INSECURE_SKIP_VERIFY))
+	return &Config{
+		URL:                os.Getenv(types.LDAP_URL),
+		StartTLS:           startTLS,
+		InsecureSkipVerify: insecureSkipVerify,
+		BindDN:             os.Getenv(types.LDAP_BIND_DN),
+		BindPassword:       os.Getenv(types.LDAP_BIND_PASSWORD),
+		BaseDN:             os.Getenv(types.LDAP_BASE_DN),
+		UserAttribute:      getEnv(types.LDAP_USER_ATTRIBUTE, "uid"),
+		EmailAttribute:     getEnv(types.LDAP_EMAIL_ATTRIBUTE, "mail"),
+		NameAttribute:      getEnv(types.LDAP_NAME_ATTRIBUTE, "displayName"),
+		GroupAttribute:     getEnv(types.LDAP_GROUP_ATTRIBUTE, "memberOf"),
+		AllowedGroups:      splitList(os.Getenv(types.LDAP_ALLOWED_GROUPS)),
+		AdminGroups:        splitList(os.Getenv(types.LDAP_ADMIN_GROUPS)),
+		Timeout:            time.Second * 10,
+	}
+}
+
+// IsMember checks if the entry is a member of any of the groups
+func (cfg *Config) IsMember(entry *Entry, groups []string) bool {
+	for _, memberOf := range entry.GetAll(cfg.GroupAttribute) {
+		commonName := memberOf
+		if rdn := strings.SplitN(memberOf, ",", 2)[0]; strings.Contains(rdn, "=") {
+			commonName = strings.TrimSpace(strings.SplitN(rdn, "=", 2)[1])
+		}
+		for _, group := range groups {
+			if strings.EqualFold(group, memberOf) || strings.EqualFold(group, commonName) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func getEnv(name, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// splitList splits a semicolon separated list, as the DNs of the groups contain commas
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ";") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,333 @@
+// ldap authenticates the users against an LDAP directory, such as OpenLDAP or Active Directory, with simple binds.
+// Only the operations needed for the login are implemented: bind, search of a user by an attribute and StartTLS.
+package ldap
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// The errors of the authentication which are caused by the user rather than by the directory
+var (
+	ErrInvalidCredentials = errors.New("ldap: invalid credentials")
+	ErrUserNotFound       = errors.New("ldap: user not found")
+)
+
+// The protocol operations, https://tools.ietf.org/html/rfc4511#section-4.2
+const (
+	opBindRequest       ber.Tag = 0
+	opBindResponse      ber.Tag = 1
+	opUnbindRequest     ber.Tag = 2
+	opSearchRequest     ber.Tag = 3
+	opSearchResultEntry ber.Tag = 4
+	opSearchResultDone  ber.Tag = 5
+	opSearchResultRef   ber.Tag = 19
+	opExtendedRequest   ber.Tag = 23
+	opExtendedResponse  ber.Tag = 24
+)
+
+const (
+	protocolVersion = 3
+	startTLSOID     = "1.3.6.1.4.1.1466.20037"
+
+	resultSuccess            = 0
+	resultInvalidCredentials = 49
+
+	scopeWholeSubtree   = 2
+	derefNever          = 0
+	filterEqualityMatch = 3
+)
+
+// Entry is an entry of the directory along with the attributes which have been requested
+type Entry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+// Get gives the first value of the attribute, the names of the attributes are case insensitive
+func (e *Entry) Get(name string) string {
+	if values := e.GetAll(name); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// GetAll gives all the values of the attribute
+func (e *Entry) GetAll(name string) []string {
+	for attribute, values := range e.Attributes {
+		if strings.EqualFold(attribute, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+// Authenticate looks up the user with the service account of the config and binds as the user with the password,
+// the entry of the user is given with the mapped attributes
+func (cfg *Config) Authenticate(username, password string) (*Entry, error) {
+	// An empty password would be an unauthenticated bind, which the directories accept for any user
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := cfg.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.close()
+
+	if cfg.BindDN != "" {
+		if err = conn.bind(cfg.BindDN, cfg.BindPassword); err != nil {
+			return nil, fmt.Errorf("ldap: bind of the service account failed: %v", err)
+		}
+	}
+
+	attributes := []string{cfg.UserAttribute, cfg.EmailAttribute, cfg.NameAttribute, cfg.GroupAttribute}
+	entries, err := conn.search(cfg.BaseDN, cfg.UserAttribute, username, attributes)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrUserNotFound
+	} else if len(entries) > 1 {
+		return nil, fmt.Errorf("ldap: %d entries found for the user %s", len(entries), username)
+	}
+
+	if err = conn.bind(entries[0].DN, password); err != nil {
+		return nil, err
+	}
+	return entries[0], nil
+}
+
+type conn struct {
+	net.Conn
+	messageID int64
+}
+
+// dial connects to the directory, either with ldaps or with ldap optionally upgraded with StartTLS
+func (cfg *Config) dial() (*conn, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		ServerName: u.Hostname(),
+		// nolint: gosec
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+
+	var netConn net.Conn
+	switch u.Scheme {
+	case "ldap":
+		netConn, err = dialer.Dial("tcp", hostPort(u, "389"))
+	case "ldaps":
+		netConn, err = tls.DialWithDialer(dialer, "tcp", hostPort(u, "636"), tlsConfig)
+	default:
+		return nil, fmt.Errorf("ldap: unsupported url scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &conn{Conn: netConn}
+	if err = c.SetDeadline(time.Now().Add(cfg.Timeout)); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	if u.Scheme == "ldap" && cfg.StartTLS {
+		if err = c.startTLS(tlsConfig); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func hostPort(u *url.URL, defaultPort string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), defaultPort)
+}
+
+func (c *conn) startTLS(tlsConfig *tls.Config) error {
+	request := ber.Encode(ber.ClassApplication, ber.TypeConstructed, opExtendedRequest, nil, "Extended Request")
+	request.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, startTLSOID, "Request Name"))
+	response, err := c.roundTrip(request, opExtendedResponse)
+	if err != nil {
+		return err
+	}
+	if err = checkResult(response); err != nil {
+		return err
+	}
+
+	tlsConn := tls.Client(c.Conn, tlsConfig)
+	if err = tlsConn.Handshake(); err != nil {
+		return err
+	}
+	c.Conn = tlsConn
+	return nil
+}
+
+func (c *conn) bind(dn, password string) error {
+	request := ber.Encode(ber.ClassApplication, ber.TypeConstructed, opBindRequest, nil, "Bind Request")
+	request.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, protocolVersion, "Version"))
+	request.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, "Name"))
+	request.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, password, "Simple"))
+
+	response, err := c.roundTrip(request, opBindResponse)
+	if err != nil {
+		return err
+	}
+	return checkResult(response)
+}
+
+// search searches the subtree of the base for the entries whose attribute equals the value
+func (c *conn) search(baseDN, attribute, value string, attributes []string) ([]*Entry, error) {
+	request := ber.Encode(ber.ClassApplication, ber.TypeConstructed, opSearchRequest, nil, "Search Request")
+	request.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, baseDN, "Base DN"))
+	request.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, scopeWholeSubtree, "Scope"))
+	request.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, derefNever, "Deref Aliases"))
+	request.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, 2, "Size Limit"))
+	request.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, 0, "Time Limit"))
+	request.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, false, "Types Only"))
+	// The filter is encoded rather than parsed from a string, the value doesn't need to be escaped
+	filter := ber.Encode(ber.ClassContext, ber.TypeConstructed, filterEqualityMatch, nil, "Equality Match")
+	filter.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, attribute, "Attribute"))
+	filter.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+	request.AppendChild(filter)
+	attributeList := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for _, attribute := range attributes {
+		if attribute != "" {
+			attributeList.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, attribute, "Attribute"))
+		}
+	}
+	request.AppendChild(attributeList)
+
+	messageID, err := c.send(request)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Entry
+	for {
+		response, err := c.receive(messageID)
+		if err != nil {
+			return nil, err
+		}
+		switch response.Tag {
+		case opSearchResultEntry:
+			entry, err := parseEntry(response)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		case opSearchResultRef:
+			// The referrals to other directories are not followed
+		case opSearchResultDone:
+			return entries, checkResult(response)
+		default:
+			return nil, fmt.Errorf("ldap: unexpected response %d to the search", response.Tag)
+		}
+	}
+}
+
+func (c *conn) close() {
+	request := ber.Encode(ber.ClassApplication, ber.TypePrimitive, opUnbindRequest, nil, "Unbind Request")
+	_, _ = c.send(request)
+	c.Close()
+}
+
+// roundTrip sends the request and reads the single response of the expected operation
+func (c *conn) roundTrip(request *ber.Packet, responseOp ber.Tag) (*ber.Packet, error) {
+	messageID, err := c.send(request)
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.receive(messageID)
+	if err != nil {
+		return nil, err
+	}
+	if response.Tag != responseOp {
+		return nil, fmt.Errorf("ldap: unexpected response %d", response.Tag)
+	}
+	return response, nil
+}
+
+func (c *conn) send(request *ber.Packet) (int64, error) {
+	c.messageID++
+	message := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Message")
+	message.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, c.messageID, "Message ID"))
+	message.AppendChild(request)
+	_, err := c.Write(message.Bytes())
+	return c.messageID, err
+}
+
+// receive reads the next message of the request and gives its protocol operation
+func (c *conn) receive(messageID int64) (*ber.Packet, error) {
+	message, err := ber.ReadPacket(c)
+	if err != nil {
+		return nil, err
+	}
+	if len(message.Children) < 2 {
+		return nil, fmt.Errorf("ldap: invalid message")
+	}
+	if id, ok := message.Children[0].Value.(int64); !ok || id != messageID {
+		return nil, fmt.Errorf("ldap: unexpected message id")
+	}
+	response := message.Children[1]
+	if response.ClassType != ber.ClassApplication {
+		return nil, fmt.Errorf("ldap: invalid protocol operation")
+	}
+	return response, nil
+}
+
+// checkResult checks the LDAPResult of the response
+func checkResult(response *ber.Packet) error {
+	if len(response.Children) < 3 {
+		return fmt.Errorf("ldap: invalid result")
+	}
+	code, _ := response.Children[0].Value.(int64)
+	switch code {
+	case resultSuccess:
+		return nil
+	case resultInvalidCredentials:
+		return ErrInvalidCredentials
+	}
+	return fmt.Errorf("ldap: result code %d: %s", code, stringValue(response.Children[2]))
+}
+
+func parseEntry(response *ber.Packet) (*Entry, error) {
+	if len(response.Children) < 2 {
+		return nil, fmt.Errorf("ldap: invalid search result entry")
+	}
+	entry := &Entry{
+		DN:         stringValue(response.Children[0]),
+		Attributes: map[string][]string{},
+	}
+	for _, attribute := range response.Children[1].Children {
+		if len(attribute.Children) < 2 {
+			return nil, fmt.Errorf("ldap: invalid attribute of %s", entry.DN)
+		}
+		name := stringValue(attribute.Children[0])
+		for _, value := range attribute.Children[1].Children {
+			entry.Attributes[name] = append(entry.Attributes[name], stringValue(value))
+		}
+	}
+	return entry, nil
+}
+
+func stringValue(packet *ber.Packet) string {
+	if value, ok := packet.Value.(string); ok {
+		return value
+	}
+	return packet.Data.String()
+}
@@ -0,0 +1,197 @@
+package ldap
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// fakeDirectory is an in-process stand-in of an LDAP directory, it answers the binds and the equality searches
+type fakeDirectory struct {
+	listener  net.Listener
+	passwords map[string]string
+	entries   []*Entry
+}
+
+func newFakeDirectory(t *testing.T, passwords map[string]string, entries []*Entry) *fakeDirectory {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	directory := &fakeDirectory{listener: listener, passwords: passwords, entries: entries}
+	go directory.serve()
+	return directory
+}
+
+func (d *fakeDirectory) URL() string {
+	return "ldap://" + d.listener.Addr().String()
+}
+
+func (d *fakeDirectory) Close() {
+	d.listener.Close()
+}
+
+func (d *fakeDirectory) serve() {
+	for {
+		c, err := d.listener.Accept()
+		if err != nil {
+			return
+		}
+		go d.handle(c)
+	}
+}
+
+func (d *fakeDirectory) handle(c net.Conn) {
+	defer c.Close()
+	for {
+		message, err := ber.ReadPacket(c)
+		if err != nil {
+			return
+		}
+		messageID := message.Children[0].Value.(int64)
+		request := message.Children[1]
+
+		switch request.Tag {
+		case opBindRequest:
+			dn := stringValue(request.Children[1])
+			password := request.Children[2].Data.String()
+			code := resultInvalidCredentials
+			if expected, ok := d.passwords[dn]; ok && expected == password {
+				code = resultSuccess
+			}
+			writeResult(c, messageID, opBindResponse, code)
+		case opSearchRequest:
+			filter := request.Children[6]
+			attribute, value := stringValue(filter.Children[0]), stringValue(filter.Children[1])
+			for _, entry := range d.entries {
+				if strings.EqualFold(entry.Get(attribute), value) {
+					writeEntry(c, messageID, entry)
+				}
+			}
+			writeResult(c, messageID, opSearchResultDone, resultSuccess)
+		case opUnbindRequest:
+			return
+		}
+	}
+}
+
+func writeMessage(c net.Conn, messageID int64, response *ber.Packet) {
+	message := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Message")
+	message.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "Message ID"))
+	message.AppendChild(response)
+	_, _ = c.Write(message.Bytes())
+}
+
+func writeResult(c net.Conn, messageID int64, op ber.Tag, code int) {
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, op, nil, "Result")
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "Result Code"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	writeMessage(c, messageID, response)
+}
+
+func writeEntry(c net.Conn, messageID int64, entry *Entry) {
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, opSearchResultEntry, nil, "Search Result Entry")
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.DN, "Object Name"))
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for name, values := range entry.Attributes {
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+		}
+		attribute.AppendChild(set)
+		attributes.AppendChild(attribute)
+	}
+	response.AppendChild(attributes)
+	writeMessage(c, messageID, response)
+}
+
+func TestAuthenticate(t *testing.T) {
+	jdoe := &Entry{
+		DN: "uid=jdoe,ou=people,dc=example,dc=org",
+		Attributes: map[string][]string{
+			"uid":         {"jdoe"},
+			"mail":        {"jdoe@example.org"},
+			"displayName": {"John Doe"},
+			"memberOf":    {"cn=Admins,ou=groups,dc=example,dc=org", "cn=developers,ou=groups,dc=example,dc=org"},
+		},
+	}
+	directory := newFakeDirectory(t, map[string]string{
+		"cn=kubera,dc=example,dc=org": "service",
+		jdoe.DN:                       "secret",
+	}, []*Entry{jdoe})
+	defer directory.Close()
+
+	config := &Config{
+		URL:            directory.URL(),
+		BindDN:         "cn=kubera,dc=example,dc=org",
+		BindPassword:   "service",
+		BaseDN:         "dc=example,dc=org",
+		UserAttribute:  "uid",
+		EmailAttribute: "mail",
+		NameAttribute:  "displayName",
+		GroupAttribute: "memberOf",
+		Timeout:        time.Second * 5,
+	}
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		err      error
+	}{
+		{name: "valid credentials", username: "jdoe", password: "secret"},
+		{name: "wrong password", username: "jdoe", password: "wrong", err: ErrInvalidCredentials},
+		{name: "empty password", username: "jdoe", password: "", err: ErrInvalidCredentials},
+		{name: "unknown user", username: "alice", password: "secret", err: ErrUserNotFound},
+		{name: "filter characters", username: "*)(uid=*", password: "secret", err: ErrUserNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entry, err := config.Authenticate(test.username, test.password)
+			if err != test.err {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if entry.DN != jdoe.DN || entry.Get("mail") != "jdoe@example.org" || entry.Get("displayname") != "John Doe" {
+				t.Errorf("unexpected entry %+v", entry)
+			}
+		})
+	}
+
+	serviceConfig := *config
+	serviceConfig.BindPassword = "wrong"
+	if _, err := serviceConfig.Authenticate("jdoe", "secret"); err == nil || err == ErrInvalidCredentials {
+		t.Errorf("expected the bind of the service account to fail, got %v", err)
+	}
+}
+
+func TestIsMember(t *testing.T) {
+	config := &Config{GroupAttribute: "memberOf"}
+	entry := &Entry{Attributes: map[string][]string{
+		"memberOf": {"cn=Admins,ou=groups,dc=example,dc=org"},
+	}}
+
+	tests := []struct {
+		groups   []string
+		isMember bool
+	}{
+		{groups: []string{"admins"}, isMember: true},
+		{groups: []string{"CN=admins,OU=groups,DC=example,DC=org"}, isMember: true},
+		{groups: []string{"developers", "Admins"}, isMember: true},
+		{groups: []string{"developers"}, isMember: false},
+		{groups: nil, isMember: false},
+	}
+	for _, test := range tests {
+		if isMember := config.IsMember(entry, test.groups); isMember != test.isMember {
+			t.Errorf("expected membership of %v to be %v", test.groups, test.isMember)
+		}
+	}
+}
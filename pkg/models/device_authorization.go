@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+// DeviceAuthorizationStatus is the state of a device authorization
+type DeviceAuthorizationStatus string
+
+const (
+	// DeviceAuthorizationPending means the user has not entered the user code yet
+	DeviceAuthorizationPending DeviceAuthorizationStatus = "pending"
+	// DeviceAuthorizationApproved means the user has approved the device, which gets a login token on its next poll
+	DeviceAuthorizationApproved DeviceAuthorizationStatus = "approved"
+	// DeviceAuthorizationDenied means the user has denied the device
+	DeviceAuthorizationDenied DeviceAuthorizationStatus = "denied"
+)
+
+// DeviceAuthorization is a login of a device without a browser, such as the CLI, as described in RFC 8628. The device
+// polls with the device code, of which only the hash is persisted, while the user logs in on another device and
+// approves the login with the user code.
+type DeviceAuthorization struct {
+	ID             bson.ObjectId             `bson:"_id,omitempty"`
+	DeviceCodeHash string                    `bson:"device_code_hash"`
+	UserCode       string                    `bson:"user_code"`
+	Scope          string                    `bson:"scope,omitempty"`
+	Status         DeviceAuthorizationStatus `bson:"status"`
+	UserUID        string                    `bson:"uid,omitempty"`
+	// Interval is the minimum time in seconds between two polls, it grows each time the device polls too fast
+	Interval     int        `bson:"interval"`
+	LastPolledAt *time.Time `bson:"last_polled_at,omitempty"`
+	CreatedAt    time.Time  `bson:"created_at"`
+	ExpiresAt    time.Time  `bson:"expires_at"`
+}
+
+const (
+	// DeviceCodeGrant exchanges the device code of an approved device authorization for a token
+	DeviceCodeGrant GrantType = "urn:ietf:params:oauth:grant-type:device_code"
+)
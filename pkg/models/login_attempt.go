@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+)
+
+// LoginAttempt counts the recent failed attempts of a client, such as the failed logins from a client ip.
+// The attempts are forgotten once they expire.
+type LoginAttempt struct {
+	// Key identifies the client along with the kind of the attempts, such as `login:ip:10.0.0.1`
+	Key         string     `bson:"key"`
+	Failures    int        `bson:"failures"`
+	LockedUntil *time.Time `bson:"locked_until,omitempty"`
+	ExpiresAt   time.Time  `bson:"expires_at"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+// LoginCode is the stored form of the one-time code the portal is redirected with after a social login,
+// which the portal exchanges for the tokens of the user. Only the hash of the code is persisted.
+type LoginCode struct {
+	ID        bson.ObjectId `bson:"_id,omitempty"`
+	Hash      string        `bson:"hash"`
+	UserUID   string        `bson:"uid"`
+	ExpiresAt time.Time     `bson:"expires_at"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+// MFA is the time based one time password enrollment of a user
+type MFA struct {
+	// Secret is the shared secret of the authenticator app, the enrollment is pending till it is Enabled
+	Secret  string `bson:"secret"`
+	Enabled bool   `bson:"enabled"`
+	// LastCounter is the time step of the last accepted code, so that a code can't be used twice
+	LastCounter int64 `bson:"last_counter,omitempty"`
+	// RecoveryCodeHashes are the hashes of the unused one time recovery codes
+	RecoveryCodeHashes []string   `bson:"recovery_code_hashes,omitempty"`
+	EnabledAt          *time.Time `bson:"enabled_at,omitempty"`
+}
+
+// MFAChallenge is given on login, instead of a login token, to the users which have to complete
+// the login with a code of their authenticator app or with a recovery code
+type MFAChallenge struct {
+	MFAToken  string `json:"mfa_token"`
+	ExpiresIn int64  `json:"expires_in"`
+	// Enrolled is false for the users which have to enroll since MFA is enforced for them
+	Enrolled bool `json:"mfa_enrolled"`
+	// Methods are the second factors with which the user can complete the login
+	Methods []string `json:"mfa_methods"`
+}
+
+// MFAEnrollment is the secret of a pending enrollment, to be added to an authenticator app
+type MFAEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
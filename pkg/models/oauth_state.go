@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+// OAuthState is the server side record of a social login started by a browser. The random state
+// is sent to the provider and kept in a cookie of the browser, only its hash is stored.
+type OAuthState struct {
+	ID        bson.ObjectId `bson:"_id,omitempty"`
+	StateHash string        `bson:"state_hash"`
+	Provider  AuthType      `bson:"provider"`
+	// CodeVerifier is the PKCE verifier sent along with the code to the provider
+	CodeVerifier string `bson:"code_verifier"`
+	// RedirectTo is the allowlisted url to which the portal sends the user after login
+	RedirectTo string `bson:"redirect_to,omitempty"`
+	// LinkUserUID is the user to which the account of the provider is linked, instead of logging in with it
+	LinkUserUID string    `bson:"link_user_uid,omitempty"`
+	CreatedAt   time.Time `bson:"created_at"`
+	ExpiresAt   time.Time `bson:"expires_at"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+// Client is a relying party which is registered to login its users with kubera-auth as OpenID provider
+type Client struct {
+	ID       bson.ObjectId `bson:"_id,omitempty" json:"-"`
+	ClientID string        `bson:"client_id" json:"client_id"`
+	// SecretHash is the digest of the client secret, the secret itself is shown only once on creation
+	SecretHash   string   `bson:"secret_hash,omitempty" json:"-"`
+	Name         string   `bson:"name" json:"name"`
+	RedirectURIs []string `bson:"redirect_uris" json:"redirect_uris"`
+	// Public clients, such as single page or native applications, can't keep a secret and must use PKCE
+	Public    bool       `bson:"public" json:"public"`
+	CreatedAt *time.Time `bson:"created_at,omitempty" json:"created_at"`
+	// Audiences are the services for which the access tokens issued to the client are meant, besides kubera-auth
+	Audiences []string `bson:"audiences,omitempty" json:"audiences,omitempty"`
+}
+
+// AuthorizationRequest is a request of a client for an authorization code. It is pending while
+// the user logs in, once approved it carries the hash of the issued code till the code is redeemed.
+type AuthorizationRequest struct {
+	ID                  bson.ObjectId `bson:"_id,omitempty"`
+	RequestID           string        `bson:"request_id"`
+	ClientID            string        `bson:"client_id"`
+	RedirectURI         string        `bson:"redirect_uri"`
+	Scope               string        `bson:"scope"`
+	State               string        `bson:"state,omitempty"`
+	Nonce               string        `bson:"nonce,omitempty"`
+	CodeChallenge       string        `bson:"code_challenge,omitempty"`
+	CodeChallengeMethod string        `bson:"code_challenge_method,omitempty"`
+	UserUID             string        `bson:"uid,omitempty"`
+	AuthTime            *time.Time    `bson:"auth_time,omitempty"`
+	CodeHash            string        `bson:"code_hash,omitempty"`
+	CreatedAt           time.Time     `bson:"created_at"`
+	ExpiresAt           time.Time     `bson:"expires_at"`
+}
+
+const (
+	// AuthorizationCodeGrant exchanges an authorization code for a token
+	AuthorizationCodeGrant GrantType = "authorization_code"
+)
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+// PersonalAccessToken is a long lived token which a user creates for the CLI or automation. It is restricted to
+// a scope and expires, only the hash of the token handed out to the user is persisted.
+type PersonalAccessToken struct {
+	ID bson.ObjectId `bson:"_id,omitempty" json:"-"`
+	// TokenID identifies the token when it is listed or revoked, it is not the token itself
+	TokenID   string    `bson:"token_id" json:"id"`
+	Hash      string    `bson:"hash" json:"-"`
+	UserUID   string    `bson:"uid" json:"-"`
+	Name      string    `bson:"name" json:"name"`
+	Scope     string    `bson:"scope" json:"scope"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	ExpiresAt time.Time `bson:"expires_at" json:"expires_at"`
+	// LastUsedAt and LastUsedIP are recorded on every authenticated request made with the token
+	LastUsedAt *time.Time `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+	LastUsedIP string     `bson:"last_used_ip,omitempty" json:"last_used_ip,omitempty"`
+}
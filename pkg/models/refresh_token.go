@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+// RefreshToken is the stored form of an opaque refresh token. Only the hash of
+// the token handed out to the client is persisted.
+// Every refresh token belongs to a family which is started by a login, each
+// rotation adds a new token to the same family.
+type RefreshToken struct {
+	ID       bson.ObjectId `bson:"_id,omitempty"`
+	Hash     string        `bson:"hash"`
+	FamilyID string        `bson:"family_id"`
+	UserUID  string        `bson:"uid"`
+	// ClientID is the OpenID Connect client to which the token was issued, it is empty for the portal
+	ClientID  string     `bson:"client_id,omitempty"`
+	Scope     string     `bson:"scope,omitempty"`
+	CreatedAt time.Time  `bson:"created_at"`
+	ExpiresAt time.Time  `bson:"expires_at"`
+	UsedAt    *time.Time `bson:"used_at,omitempty"`
+}
+
+// GrantType defines the way in which a client obtains a token
+type GrantType string
+
+const (
+	// PasswordGrant exchanges the username and password of a local user for a token
+	PasswordGrant GrantType = "password"
+	// RefreshTokenGrant exchanges a refresh token for a new token
+	RefreshTokenGrant GrantType = "refresh_token"
+	// LoginCodeGrant exchanges the one-time code given to the portal after a social login for a token
+	LoginCodeGrant GrantType = "login_code"
+	// MFAGrant exchanges an MFA challenge token along with a code of the user for a token
+	MFAGrant GrantType = "mfa"
+	// LDAPGrant exchanges the username and password of a user of the LDAP directory for a token
+	LDAPGrant GrantType = "ldap"
+	// ClientCredentialsGrant exchanges the client id and secret of a service account for a token
+	ClientCredentialsGrant GrantType = "client_credentials"
+)
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// RevokedToken is an entry of the revocation list, a token whose `jti` claim
+// is found in this list is rejected even if its signature and expiry are valid
+type RevokedToken struct {
+	JTI       string    `bson:"jti"`
+	UserUID   string    `bson:"uid,omitempty"`
+	RevokedAt time.Time `bson:"revoked_at"`
+	// ExpiresAt is the expiry of the revoked token, there is no need to keep
+	// the entry once the token would be rejected for being expired anyway
+	ExpiresAt time.Time `bson:"expires_at"`
+}
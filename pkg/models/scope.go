@@ -0,0 +1,33 @@
+package models
+
+import "strings"
+
+// The scopes of the API which can be requested on login. A login token without scope can be used on all the
+// routes allowed to its user, a token with a scope only on the routes requiring one of its scopes.
+const (
+	ScopeUsersRead    = "users:read"
+	ScopeUsersWrite   = "users:write"
+	ScopeConfigRead   = "config:read"
+	ScopeConfigWrite  = "config:write"
+	ScopeClientsRead  = "clients:read"
+	ScopeClientsWrite = "clients:write"
+)
+
+// APIScopes are the scopes which can be requested on login
+var APIScopes = []string{ScopeUsersRead, ScopeUsersWrite, ScopeConfigRead, ScopeConfigWrite, ScopeClientsRead, ScopeClientsWrite}
+
+// HasScope checks if a token of the space separated scope meets the required scope, a token without scope meets
+// every scope while an empty required scope is only met by a token without scope
+func HasScope(scope, required string) bool {
+	if scope == "" {
+		return true
+	} else if required == "" {
+		return false
+	}
+	for _, s := range strings.Fields(scope) {
+		if s == required {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,24 @@
+package models
+
+import "testing"
+
+func TestHasScope(t *testing.T) {
+	for _, test := range []struct {
+		scope    string
+		required string
+		expected bool
+	}{
+		{"", "", true},
+		{"", ScopeUsersWrite, true},
+		{ScopeUsersRead, "", false},
+		{ScopeUsersRead, ScopeUsersRead, true},
+		{ScopeUsersRead, ScopeUsersWrite, false},
+		{ScopeUsersRead + " " + ScopeConfigRead, ScopeConfigRead, true},
+		{ScopeUsersRead + "  " + ScopeConfigRead, ScopeConfigWrite, false},
+		{"users", ScopeUsersRead, false},
+	} {
+		if actual := HasScope(test.scope, test.required); actual != test.expected {
+			t.Errorf("HasScope(%q, %q): expected %v, got %v", test.scope, test.required, test.expected, actual)
+		}
+	}
+}
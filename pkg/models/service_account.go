@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+)
+
+// ClientSecret is a secret of a service account, only its hash is persisted. A service account has several secrets
+// while a rotation is in progress, the previous secrets expire once the clients have switched to the new one.
+type ClientSecret struct {
+	ID        string    `bson:"id" json:"id"`
+	Hash      string    `bson:"hash" json:"-"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	// ExpiresAt is set on the previous secrets by a rotation
+	ExpiresAt *time.Time `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+}
+
+// Expired checks if the secret can't be used anymore
+func (s *ClientSecret) Expired(now time.Time) bool {
+	return s.ExpiresAt != nil && !now.Before(*s.ExpiresAt)
+}
+
+// ServiceAccount displays the information of a service account, its client id is its uid
+type ServiceAccount struct {
+	ClientID  string         `json:"client_id"`
+	Name      string         `json:"name"`
+	Role      Role           `json:"role"`
+	OwnerUID  string         `json:"owner_uid"`
+	CreatedAt *time.Time     `json:"created_at"`
+	Secrets   []ClientSecret `json:"secrets"`
+}
+
+// GetServiceAccountInfo fetches the ServiceAccount from the stored service account
+func (u *UserCredentials) GetServiceAccountInfo() *ServiceAccount {
+	secrets := u.ClientSecrets
+	if secrets == nil {
+		secrets = []ClientSecret{}
+	}
+	return &ServiceAccount{
+		ClientID:  u.UID,
+		Name:      u.Name,
+		Role:      u.Role,
+		OwnerUID:  u.OwnerUID,
+		CreatedAt: u.CreatedAt,
+		Secrets:   secrets,
+	}
+}
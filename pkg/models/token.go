@@ -17,16 +17,42 @@ type TokenType string
 var (
 	// TokenLogin will be used for login purposes
 	TokenLogin TokenType = "Login"
-	// TokenEmail will be used as authenticity for the link in emails
-	TokenEmail TokenType = "Email"
+	// TokenEmailVerification is mailed to a user to verify its email, it can be used only once
+	TokenEmailVerification TokenType = "EmailVerification"
+	// TokenPasswordReset is mailed to a user who forgot its password, it can be used only once to set a new password
+	TokenPasswordReset TokenType = "PasswordReset"
+	// TokenMFA is the challenge given after the password of a user with MFA, it can only be used to complete the login
+	TokenMFA TokenType = "MFA"
+	// TokenMagicLink is mailed to a user to sign in without a password, it can only be exchanged once for a login token
+	TokenMagicLink TokenType = "MagicLink"
+	// TokenPersonalAccess is the type under which the personal access tokens are introspected, they aren't JWTs
+	TokenPersonalAccess TokenType = "PersonalAccess"
 )
 
 // Token token model
 type Token struct {
+	// ID is the unique identifier of the access token, used as its `jti` claim
+	ID              string        `bson:"ID"`
 	Access          string        `bson:"Access"`
 	AccessCreateAt  time.Time     `bson:"AccessCreateAt"`
 	AccessExpiresIn time.Duration `bson:"AccessExpiresIn"`
 	Type            TokenType     `bson:"type"`
+	// Refresh is the opaque refresh token issued alongside the access token, if any
+	Refresh          string        `bson:"Refresh"`
+	RefreshCreateAt  time.Time     `bson:"RefreshCreateAt"`
+	RefreshExpiresIn time.Duration `bson:"RefreshExpiresIn"`
+	// IDToken is the OpenID Connect id token issued to a client, if any
+	IDToken string `bson:"IDToken"`
+}
+
+// GetID the unique identifier of the access Token
+func (t *Token) GetID() string {
+	return t.ID
+}
+
+// SetID the unique identifier of the access Token
+func (t *Token) SetID(id string) {
+	t.ID = id
 }
 
 // GetAccess access Token
@@ -58,3 +84,43 @@ func (t *Token) GetAccessExpiresIn() time.Duration {
 func (t *Token) SetAccessExpiresIn(exp time.Duration) {
 	t.AccessExpiresIn = exp
 }
+
+// GetRefresh refresh Token
+func (t *Token) GetRefresh() string {
+	return t.Refresh
+}
+
+// SetRefresh refresh Token
+func (t *Token) SetRefresh(refresh string) {
+	t.Refresh = refresh
+}
+
+// GetRefreshCreateAt create Time
+func (t *Token) GetRefreshCreateAt() time.Time {
+	return t.RefreshCreateAt
+}
+
+// SetRefreshCreateAt create Time
+func (t *Token) SetRefreshCreateAt(createAt time.Time) {
+	t.RefreshCreateAt = createAt
+}
+
+// GetRefreshExpiresIn the lifetime in seconds of the refresh token
+func (t *Token) GetRefreshExpiresIn() time.Duration {
+	return t.RefreshExpiresIn
+}
+
+// SetRefreshExpiresIn the lifetime in seconds of the refresh token
+func (t *Token) SetRefreshExpiresIn(exp time.Duration) {
+	t.RefreshExpiresIn = exp
+}
+
+// GetIDToken the OpenID Connect id Token
+func (t *Token) GetIDToken() string {
+	return t.IDToken
+}
+
+// SetIDToken the OpenID Connect id Token
+func (t *Token) SetIDToken(idToken string) {
+	t.IDToken = idToken
+}
@@ -31,12 +31,40 @@ type UserCredentials struct {
 	Role            Role            `bson:"role,omitempty" json:"role"`
 	LoggedIn        bool            `bson:"logged_in,omitempty" json:"logged_in"`
 	SocialAuthID    string          `bson:"social_auth_id,omitempty" json:"social_auth_id"`
+	Identities      []Identity      `bson:"identities,omitempty" json:"identities"`
 	CreatedAt       *time.Time      `bson:"created_at,omitempty" json:"created_at"`
 	UpdatedAt       *time.Time      `bson:"updated_at,omitempty" json:"updated_at"`
 	RemovedAt       *time.Time      `bson:"removed_at,omitempty" json:"removed_at"`
 	State           State           `bson:"state,omitempty" json:"state"`
 	OnBoardingState OnBoardingState `bson:"onboarding_state,omitempty" json:"onboarding_state"`
 	Photo           string          `bson:"pictureUrl,omitempty" json:"pictureUrl"`
+	// TokensRevokedAt invalidates all the tokens of the user which were issued till this time
+	TokensRevokedAt *time.Time `bson:"tokens_revoked_at,omitempty" json:"-"`
+	MFA             *MFA       `bson:"mfa,omitempty" json:"-"`
+	// MagicLinkRequests are the times at which the recent sign-in links were mailed to the user
+	MagicLinkRequests []time.Time `bson:"magic_link_requests,omitempty" json:"-"`
+	// WebAuthnCredentials are the passkeys and security keys of the user, either used as second factor or for passwordless logins
+	WebAuthnCredentials []WebAuthnCredential `bson:"webauthn_credentials,omitempty" json:"-"`
+	// FailedLogins counts the consecutive failed logins of the user, too many of them lock the login till LockedUntil
+	FailedLogins int        `bson:"failed_logins,omitempty" json:"-"`
+	LockedUntil  *time.Time `bson:"locked_until,omitempty" json:"-"`
+	// PasswordHistory are the hashes of the previous passwords of the user, the most recent first
+	PasswordHistory []string `bson:"password_history,omitempty" json:"-"`
+	// OwnerUID is the admin who created the service account, it is only set for the service accounts
+	OwnerUID string `bson:"owner_uid,omitempty" json:"owner_uid,omitempty"`
+	// ClientSecrets are the secrets with which the service account authenticates, see ServiceAccountAuth
+	ClientSecrets []ClientSecret `bson:"client_secrets,omitempty" json:"-"`
+}
+
+// Identity is an account of a social login provider with which the user can login, a user can
+// have one identity of each provider. The SocialAuthID of a user returned by a provider on login
+// gives its identity, the stored users keep the ids of all their providers in Identities.
+type Identity struct {
+	Provider     AuthType `bson:"provider" json:"provider"`
+	SocialAuthID string   `bson:"social_auth_id" json:"social_auth_id"`
+	// Email is the verified email of the account given by the provider when it was linked
+	Email    string     `bson:"email,omitempty" json:"email,omitempty"`
+	LinkedAt *time.Time `bson:"linked_at,omitempty" json:"linked_at,omitempty"`
 }
 
 //AuthType determines the type of authentication opted by the user for login
@@ -51,6 +79,21 @@ const (
 
 	// GoogleAuth authenticates via Google OAuth
 	GoogleAuth AuthType = "google"
+
+	// GitlabAuth authenticates via GitLab OAuth, either on gitlab.com or on a self-managed instance
+	GitlabAuth AuthType = "gitlab"
+
+	// BitbucketAuth authenticates via Bitbucket OAuth
+	BitbucketAuth AuthType = "bitbucket"
+
+	// LDAPAuth authenticates with the username and password of the user in an LDAP directory, such as Active Directory
+	LDAPAuth AuthType = "ldap"
+
+	// SAMLAuth authenticates via a SAML 2.0 identity provider, such as Okta, Azure AD or ADFS
+	SAMLAuth AuthType = "saml"
+
+	// ServiceAccountAuth is the kind of the service accounts, which authenticate with a client id and secret
+	ServiceAccountAuth AuthType = "service_account"
 )
 
 // Role states the role of the user in the portal
@@ -104,9 +147,12 @@ type PublicUserInfo struct {
 	UpdatedAt       *time.Time      `json:"updated_at"`
 	RemovedAt       *time.Time      `json:"removed_at"`
 	SocialAuthID    string          `json:"social_auth_id,omitempty"`
+	Identities      []Identity      `json:"identities,omitempty"`
 	State           State           `json:"state"`
 	OnBoardingState OnBoardingState `json:"onboarding_state"`
 	Photo           string          `json:"pictureUrl,omitempty"`
+	MFAEnabled      bool            `json:"mfa_enabled"`
+	Locked          bool            `json:"locked"`
 }
 
 //State is the current state of the database entry of the user
@@ -134,6 +180,7 @@ func (u *UserCredentials) GetPublicInfo() *PublicUserInfo {
 		ID:              u.ID,
 		UID:             u.UID,
 		Kind:            u.Kind,
+		Identities:      u.Identities,
 		Role:            u.Role,
 		LoggedIn:        u.LoggedIn,
 		CreatedAt:       u.CreatedAt,
@@ -142,5 +189,12 @@ func (u *UserCredentials) GetPublicInfo() *PublicUserInfo {
 		State:           u.State,
 		OnBoardingState: u.OnBoardingState,
 		Photo:           u.Photo,
+		MFAEnabled:      u.MFA != nil && u.MFA.Enabled,
+		Locked:          u.IsLocked(),
 	}
 }
+
+// IsLocked tells if the login of the user is locked after too many failed logins
+func (u *UserCredentials) IsLocked() bool {
+	return u.LockedUntil != nil && time.Now().Before(*u.LockedUntil)
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+// WebAuthnCredential is a WebAuthn credential, such as a passkey or a security key, registered by a user
+type WebAuthnCredential struct {
+	// ID is the base64url encoded id of the credential
+	ID   string `bson:"id" json:"id"`
+	Name string `bson:"name,omitempty" json:"name"`
+	// PublicKey is the PKIX encoded public key of the credential
+	PublicKey []byte `bson:"public_key" json:"-"`
+	Algorithm int64  `bson:"algorithm" json:"-"`
+	// SignCount is the signature counter of the authenticator, which has to increase with every login
+	SignCount  uint32     `bson:"sign_count" json:"-"`
+	AAGUID     []byte     `bson:"aaguid,omitempty" json:"-"`
+	CreatedAt  *time.Time `bson:"created_at,omitempty" json:"created_at"`
+	LastUsedAt *time.Time `bson:"last_used_at,omitempty" json:"last_used_at"`
+}
+
+// WebAuthnCeremony is the kind of a WebAuthn ceremony
+type WebAuthnCeremony string
+
+const (
+	// WebAuthnRegistration registers a new credential for a user
+	WebAuthnRegistration WebAuthnCeremony = "registration"
+	// WebAuthnAuthentication logs in a user with a credential
+	WebAuthnAuthentication WebAuthnCeremony = "authentication"
+)
+
+// WebAuthnSession is the server side record of a pending WebAuthn ceremony along with its challenge
+type WebAuthnSession struct {
+	ID        bson.ObjectId    `bson:"_id,omitempty"`
+	Ceremony  WebAuthnCeremony `bson:"ceremony"`
+	Challenge string           `bson:"challenge"`
+	// UserUID is the user registering a credential or completing the login with a second factor,
+	// it is empty for the passwordless logins
+	UserUID   string    `bson:"user_uid,omitempty"`
+	CreatedAt time.Time `bson:"created_at"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
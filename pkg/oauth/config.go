@@ -1,12 +1,14 @@
 package oauth
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
+	"strings"
 
+	"github.com/mayadata-io/kubera-auth/pkg/models"
 	"github.com/mayadata-io/kubera-auth/pkg/types"
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/github"
-	"golang.org/x/oauth2/google"
 )
 
 //SocialAuthConfig is the type for github and gmail config
@@ -14,26 +16,183 @@ type SocialAuthConfig struct {
 	oauth2.Config
 }
 
-//NewGithubConfig returns the github config
-func NewGithubConfig() SocialAuthConfig {
+// ProviderConfig is the configuration of a social login provider
+type ProviderConfig struct {
+	// Name identifies the provider, it is stored as the kind of the users who login with it
+	Name        models.AuthType `json:"name"`
+	DisplayName string          `json:"display_name"`
+	// Issuer is the url from which the OpenID Connect providers are discovered
+	Issuer       string   `json:"issuer,omitempty"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	RedirectURL  string   `json:"redirect_url,omitempty"`
+	Scopes       []string `json:"scopes,omitempty"`
+	// AllowedGroups restricts the login to the members of these groups of the provider, such as
+	// github organizations (`org`) and teams (`org/team-slug`). Members of AdminGroups get the admin role.
+	AllowedGroups []string `json:"allowed_groups,omitempty"`
+	AdminGroups   []string `json:"admin_groups,omitempty"`
+	// AllowedDomains restricts the login to the accounts of these hosted domains, such as google workspaces
+	AllowedDomains []string `json:"allowed_domains,omitempty"`
+}
+
+// SocialAuthConfig gives the oauth2 config of the provider for the given endpoint
+func (cfg *ProviderConfig) SocialAuthConfig(endpoint oauth2.Endpoint) SocialAuthConfig {
 	return SocialAuthConfig{
 		Config: oauth2.Config{
-			ClientID:     os.Getenv(types.GITHUB_CLIENT_ID),
-			ClientSecret: os.Getenv(types.GITHUB_CLIENT_SECRET),
-			Scopes:       []string{"read:user", "user:email"},
-			Endpoint:     github.Endpoint,
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			Scopes:       cfg.Scopes,
+			Endpoint:     endpoint,
+			RedirectURL:  cfg.RedirectURL,
 		},
 	}
 }
 
-func NewGoogleConfig() SocialAuthConfig {
-	return SocialAuthConfig{
-		Config: oauth2.Config{
-			ClientID:     os.Getenv(types.GOOGLE_CLIENT_ID),
-			ClientSecret: os.Getenv(types.GOOGLE_CLIENT_SECRET),
-			Scopes:       []string{"email", "profile", "openid"},
-			Endpoint:     google.Endpoint,
-			RedirectURL:  os.Getenv(types.GOOGLE_REDIRECT_URL),
+//NewGithubConfig returns the github config
+func NewGithubConfig() *ProviderConfig {
+	config := &ProviderConfig{
+		Name:          models.GithubAuth,
+		DisplayName:   "GitHub",
+		ClientID:      os.Getenv(types.GITHUB_CLIENT_ID),
+		ClientSecret:  os.Getenv(types.GITHUB_CLIENT_SECRET),
+		Scopes:        []string{"read:user", "user:email"},
+		AllowedGroups: splitList(os.Getenv(types.GITHUB_ALLOWED_GROUPS)),
+		AdminGroups:   splitList(os.Getenv(types.GITHUB_ADMIN_GROUPS)),
+	}
+	// The private organization and team memberships can only be read with read:org
+	if len(config.AllowedGroups) > 0 || len(config.AdminGroups) > 0 {
+		config.Scopes = append(config.Scopes, "read:org")
+	}
+	return config
+}
+
+// NewGoogleConfig returns the google config
+func NewGoogleConfig() *ProviderConfig {
+	return &ProviderConfig{
+		Name:           models.GoogleAuth,
+		DisplayName:    "Google",
+		ClientID:       os.Getenv(types.GOOGLE_CLIENT_ID),
+		ClientSecret:   os.Getenv(types.GOOGLE_CLIENT_SECRET),
+		Scopes:         []string{"email", "profile", "openid"},
+		RedirectURL:    os.Getenv(types.GOOGLE_REDIRECT_URL),
+		AllowedDomains: splitList(os.Getenv(types.GOOGLE_ALLOWED_DOMAINS)),
+	}
+}
+
+// NewGitlabConfig returns the gitlab config, the issuer is the base url of the gitlab instance
+func NewGitlabConfig(defaultRedirectURL string) *ProviderConfig {
+	return &ProviderConfig{
+		Name:         models.GitlabAuth,
+		DisplayName:  "GitLab",
+		Issuer:       os.Getenv(types.GITLAB_BASE_URL),
+		ClientID:     os.Getenv(types.GITLAB_CLIENT_ID),
+		ClientSecret: os.Getenv(types.GITLAB_CLIENT_SECRET),
+		Scopes:       []string{"read_user"},
+		RedirectURL:  defaultRedirectURL,
+	}
+}
+
+// NewBitbucketConfig returns the bitbucket config
+func NewBitbucketConfig() *ProviderConfig {
+	return &ProviderConfig{
+		Name:         models.BitbucketAuth,
+		DisplayName:  "Bitbucket",
+		ClientID:     os.Getenv(types.BITBUCKET_CLIENT_ID),
+		ClientSecret: os.Getenv(types.BITBUCKET_CLIENT_SECRET),
+		Scopes:       []string{"account", "email"},
+	}
+}
+
+// SAMLConfig is the configuration of the SAML 2.0 identity provider, for which kubera is the service provider.
+// The redirect url of the provider is the assertion consumer service.
+type SAMLConfig struct {
+	ProviderConfig
+	// EntityID identifies kubera to the identity provider, it defaults to the url of the metadata of kubera
+	EntityID string
+	// IDPMetadataURL is the url of the metadata of the identity provider, unless the metadata is given in IDPMetadata
+	IDPMetadataURL string
+	IDPMetadata    string
+	// The attributes of the assertions from which the user is created, the groups are mapped as AllowedGroups and AdminGroups
+	EmailAttribute  string
+	NameAttribute   string
+	GroupsAttribute string
+}
+
+// NewSAMLConfig returns the SAML config, the entity id defaults to the url of the metadata of kubera
+func NewSAMLConfig(metadataURL, acsURL string) *SAMLConfig {
+	config := &SAMLConfig{
+		ProviderConfig: ProviderConfig{
+			Name:          models.SAMLAuth,
+			DisplayName:   os.Getenv(types.SAML_DISPLAY_NAME),
+			RedirectURL:   acsURL,
+			AllowedGroups: splitList(os.Getenv(types.SAML_ALLOWED_GROUPS)),
+			AdminGroups:   splitList(os.Getenv(types.SAML_ADMIN_GROUPS)),
 		},
+		EntityID:        os.Getenv(types.SAML_ENTITY_ID),
+		IDPMetadataURL:  os.Getenv(types.SAML_IDP_METADATA_URL),
+		IDPMetadata:     os.Getenv(types.SAML_IDP_METADATA),
+		EmailAttribute:  os.Getenv(types.SAML_EMAIL_ATTRIBUTE),
+		NameAttribute:   os.Getenv(types.SAML_NAME_ATTRIBUTE),
+		GroupsAttribute: os.Getenv(types.SAML_GROUPS_ATTRIBUTE),
+	}
+	if config.DisplayName == "" {
+		config.DisplayName = "SAML"
+	}
+	if config.EntityID == "" {
+		config.EntityID = metadataURL
+	}
+	if config.EmailAttribute == "" {
+		config.EmailAttribute = "email"
+	}
+	if config.NameAttribute == "" {
+		config.NameAttribute = "name"
+	}
+	if config.GroupsAttribute == "" {
+		config.GroupsAttribute = "groups"
+	}
+	return config
+}
+
+// NewOIDCConfigs returns the configs of the generic OpenID Connect providers, which are
+// given as a JSON list of provider configs. The redirect url defaults to defaultRedirectURL.
+func NewOIDCConfigs(defaultRedirectURL string) ([]*ProviderConfig, error) {
+	var configs []*ProviderConfig
+	value := os.Getenv(types.OIDC_PROVIDERS)
+	if value == "" {
+		return configs, nil
+	}
+	if err := json.Unmarshal([]byte(value), &configs); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", types.OIDC_PROVIDERS, err)
+	}
+
+	for _, cfg := range configs {
+		switch cfg.Name {
+		case "", models.LocalAuth, models.GithubAuth, models.GoogleAuth, models.GitlabAuth, models.BitbucketAuth, models.LDAPAuth, models.SAMLAuth, models.ServiceAccountAuth:
+			return nil, fmt.Errorf("invalid name %q of the provider in %s", cfg.Name, types.OIDC_PROVIDERS)
+		}
+		if cfg.Issuer == "" || cfg.ClientID == "" {
+			return nil, fmt.Errorf("issuer and client_id of the provider %s are required in %s", cfg.Name, types.OIDC_PROVIDERS)
+		}
+		if cfg.DisplayName == "" {
+			cfg.DisplayName = string(cfg.Name)
+		}
+		if cfg.RedirectURL == "" {
+			cfg.RedirectURL = defaultRedirectURL
+		}
+		if len(cfg.Scopes) == 0 {
+			cfg.Scopes = []string{"openid", "email", "profile"}
+		}
+	}
+	return configs, nil
+}
+
+// splitList splits a comma separated list, dropping the empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
 }
@@ -0,0 +1,99 @@
+package oauth
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+)
+
+// Provider is a social login provider with which the users can login into kubera
+type Provider interface {
+	// Config gives the configuration with which the provider was created
+	Config() *ProviderConfig
+	// AuthCodeURL gives the url of the login page of the provider
+	AuthCodeURL(ctx context.Context, state, codeVerifier string) (string, error)
+	// Exchange exchanges the code sent by the provider to the callback for the user who logged in
+	Exchange(ctx context.Context, code, codeVerifier string) (*models.UserCredentials, error)
+}
+
+// ProviderInfo is the public information about a provider which the portal needs to offer it for login
+type ProviderInfo struct {
+	Name        models.AuthType `json:"name"`
+	DisplayName string          `json:"display_name"`
+	Enabled     bool            `json:"enabled"`
+}
+
+// Registry holds the social login providers, a provider can be enabled or disabled at runtime
+type Registry struct {
+	lock      sync.RWMutex
+	providers map[models.AuthType]Provider
+	enabled   map[models.AuthType]bool
+}
+
+// NewRegistry creates an empty registry of providers
+func NewRegistry() *Registry {
+	return &Registry{
+		providers: map[models.AuthType]Provider{},
+		enabled:   map[models.AuthType]bool{},
+	}
+}
+
+// Register adds the provider to the registry, replacing any provider having the same name
+func (r *Registry) Register(provider Provider, enabled bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.providers[provider.Config().Name] = provider
+	r.enabled[provider.Config().Name] = enabled
+}
+
+// Get gets the provider having the given name, disabled providers are not returned
+func (r *Registry) Get(name models.AuthType) (Provider, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	provider, ok := r.providers[name]
+	return provider, ok && r.enabled[name]
+}
+
+// Lookup gets the provider having the given name whether it is enabled or not
+func (r *Registry) Lookup(name models.AuthType) (Provider, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	provider, ok := r.providers[name]
+	return provider, ok
+}
+
+// Enabled tells if the provider having the given name is registered and enabled
+func (r *Registry) Enabled(name models.AuthType) bool {
+	_, ok := r.Get(name)
+	return ok
+}
+
+// SetEnabled enables or disables the provider having the given name
+func (r *Registry) SetEnabled(name models.AuthType, enabled bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.providers[name]; ok {
+		r.enabled[name] = enabled
+	}
+}
+
+// Providers gives the information about all the registered providers sorted by name
+func (r *Registry) Providers() []*ProviderInfo {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	infos := []*ProviderInfo{}
+	for name, provider := range r.providers {
+		infos = append(infos, &ProviderInfo{
+			Name:        name,
+			DisplayName: provider.Config().DisplayName,
+			Enabled:     r.enabled[name],
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
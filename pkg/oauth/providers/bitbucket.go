@@ -0,0 +1,119 @@
+package providers
+
+import (
+	"context"
+	"time"
+
+	log "github.com/golang/glog"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/oauth"
+	"golang.org/x/oauth2"
+	bitbucketEndpoint "golang.org/x/oauth2/bitbucket"
+)
+
+const (
+	bitbucketAPIURL = "https://api.bitbucket.org/2.0"
+)
+
+// BitbucketProvider logs in the users with their bitbucket account
+type BitbucketProvider struct {
+	config     *oauth.ProviderConfig
+	authConfig oauth.SocialAuthConfig
+	apiURL     string
+}
+
+type bitbucketUser struct {
+	AccountID   string `json:"account_id"`
+	UUID        string `json:"uuid"`
+	DisplayName string `json:"display_name"`
+	Nickname    string `json:"nickname"`
+	Links       struct {
+		Avatar struct {
+			Href string `json:"href"`
+		} `json:"avatar"`
+	} `json:"links"`
+}
+
+type bitbucketEmails struct {
+	Values []struct {
+		Email       string `json:"email"`
+		IsPrimary   bool   `json:"is_primary"`
+		IsConfirmed bool   `json:"is_confirmed"`
+	} `json:"values"`
+}
+
+// NewBitbucketProvider creates the bitbucket provider
+func NewBitbucketProvider(config *oauth.ProviderConfig) *BitbucketProvider {
+	return &BitbucketProvider{
+		config:     config,
+		authConfig: config.SocialAuthConfig(bitbucketEndpoint.Endpoint),
+		apiURL:     bitbucketAPIURL,
+	}
+}
+
+// Config gives the configuration of the provider
+func (p *BitbucketProvider) Config() *oauth.ProviderConfig {
+	return p.config
+}
+
+// AuthCodeURL gives the url of the bitbucket login page
+func (p *BitbucketProvider) AuthCodeURL(ctx context.Context, state, codeVerifier string) (string, error) {
+	return p.authConfig.AuthCodeURLWithPKCE(state, codeVerifier), nil
+}
+
+// Exchange gives the details of the user fetched as from bitbucket
+func (p *BitbucketProvider) Exchange(ctx context.Context, code, codeVerifier string) (*models.UserCredentials, error) {
+	token, err := p.authConfig.GetToken(ctx, code, codeVerifier)
+	if err != nil {
+		log.Errorln("Error getting token from bitbucket", err)
+		return nil, err
+	}
+
+	user, err := p.getBitbucketUser(ctx, token)
+	if err != nil {
+		log.Errorln("Error getting user from bitbucket", err)
+		return nil, err
+	}
+	return user, nil
+}
+
+// getBitbucketUser returns the user information from the token
+func (p *BitbucketProvider) getBitbucketUser(ctx context.Context, token *oauth2.Token) (*models.UserCredentials, error) {
+	tc := p.authConfig.Client(ctx, token)
+
+	var bUser bitbucketUser
+	if err := getJSON(tc, p.apiURL+"/user", &bUser); err != nil {
+		return nil, err
+	}
+	var emails bitbucketEmails
+	if err := getJSON(tc, p.apiURL+"/user/emails", &emails); err != nil {
+		return nil, err
+	}
+
+	currTime := time.Now()
+	user := models.UserCredentials{
+		Name:         bUser.DisplayName,
+		Kind:         models.BitbucketAuth,
+		Role:         models.RoleUser,
+		State:        models.StateActive,
+		LoggedIn:     true,
+		SocialAuthID: bUser.AccountID,
+		CreatedAt:    &currTime,
+		Photo:        bUser.Links.Avatar.Href,
+	}
+	if user.SocialAuthID == "" {
+		user.SocialAuthID = bUser.UUID
+	}
+	if user.Name == "" {
+		user.Name = bUser.Nickname
+	}
+
+	for _, email := range emails.Values {
+		if email.IsPrimary && email.IsConfirmed {
+			user.Email = email.Email
+			user.OnBoardingState = models.BoardingStateEmailVerified
+			break
+		}
+	}
+	return &user, nil
+}
@@ -0,0 +1,51 @@
+package providers
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/oauth2"
+
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/oauth"
+)
+
+func TestBitbucketProvider(t *testing.T) {
+	server := newFakeProviderServer(t, "/site/oauth2/access_token", map[string]interface{}{
+		"/2.0/user": map[string]interface{}{
+			"account_id":   "557058:c0b72ad0",
+			"uuid":         "{d301aafa-d676-4ee0-88be-962be7417567}",
+			"display_name": "John Doe",
+			"links": map[string]interface{}{
+				"avatar": map[string]interface{}{"href": "https://example.com/jdoe.png"},
+			},
+		},
+		"/2.0/user/emails": map[string]interface{}{
+			"values": []map[string]interface{}{
+				{"email": "unconfirmed@example.com", "is_primary": false, "is_confirmed": false},
+				{"email": "jdoe@example.com", "is_primary": true, "is_confirmed": true},
+			},
+		},
+	})
+	defer server.Close()
+
+	config := &oauth.ProviderConfig{
+		Name:     models.BitbucketAuth,
+		ClientID: "client",
+	}
+	provider := NewBitbucketProvider(config)
+	provider.authConfig = config.SocialAuthConfig(oauth2.Endpoint{
+		AuthURL:  server.URL + "/site/oauth2/authorize",
+		TokenURL: server.URL + "/site/oauth2/access_token",
+	})
+	provider.apiURL = server.URL + "/2.0"
+
+	user, err := provider.Exchange(context.Background(), "code", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Kind != models.BitbucketAuth || user.SocialAuthID != "557058:c0b72ad0" || user.Name != "John Doe" ||
+		user.Email != "jdoe@example.com" || user.OnBoardingState != models.BoardingStateEmailVerified {
+		t.Errorf("Unexpected user: %+v", user)
+	}
+}
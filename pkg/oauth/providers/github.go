@@ -1,23 +1,64 @@
 package providers
 
 import (
+	"context"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/gin-gonic/gin"
 	log "github.com/golang/glog"
 	"github.com/google/go-github/github"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
 	"github.com/mayadata-io/kubera-auth/pkg/models"
-	controller "github.com/mayadata-io/kubera-auth/versionedController/v1"
+	"github.com/mayadata-io/kubera-auth/pkg/oauth"
 	"golang.org/x/oauth2"
+	githubEndpoint "golang.org/x/oauth2/github"
 )
 
+const (
+	githubAPIURL = "https://api.github.com/"
+)
+
+// GithubProvider logs in the users with their github account
+type GithubProvider struct {
+	config     *oauth.ProviderConfig
+	authConfig oauth.SocialAuthConfig
+	apiURL     string
+}
+
+// NewGithubProvider creates the github provider
+func NewGithubProvider(config *oauth.ProviderConfig) *GithubProvider {
+	return &GithubProvider{
+		config:     config,
+		authConfig: config.SocialAuthConfig(githubEndpoint.Endpoint),
+		apiURL:     githubAPIURL,
+	}
+}
+
+// Config gives the configuration of the provider
+func (p *GithubProvider) Config() *oauth.ProviderConfig {
+	return p.config
+}
+
+// AuthCodeURL gives the url of the github login page
+func (p *GithubProvider) AuthCodeURL(ctx context.Context, state, codeVerifier string) (string, error) {
+	return p.authConfig.AuthCodeURLWithPKCE(state, codeVerifier), nil
+}
+
+// newClient gives a client of the github api authenticated with the token
+func (p *GithubProvider) newClient(ctx context.Context, token *oauth2.Token) (*github.Client, error) {
+	client := github.NewClient(oauth2.NewClient(ctx, oauth2.StaticTokenSource(token)))
+	baseURL, err := url.Parse(p.apiURL)
+	if err != nil {
+		return nil, err
+	}
+	client.BaseURL = baseURL
+	return client, nil
+}
+
 // getUserFromToken Returns the user information from the token
-func getGitHubUser(c *gin.Context, token *oauth2.Token) (*models.UserCredentials, error) {
-	ctx := c.Request.Context()
-	ts := oauth2.StaticTokenSource(token)
-	tc := oauth2.NewClient(ctx, ts)
-	client := github.NewClient(tc)
+func getGitHubUser(ctx context.Context, client *github.Client) (*models.UserCredentials, error) {
 
 	githubUser, _, err := client.Users.Get(ctx, "")
 	if err != nil {
@@ -42,9 +83,13 @@ func getGitHubUser(c *gin.Context, token *oauth2.Token) (*models.UserCredentials
 	}
 
 	for _, githubUserEmail := range githubUserEmails {
-		if githubUserEmail.Primary != nil && githubUserEmail.Email != nil {
-			user.Email = githubUserEmail.GetEmail()
-			user.OnBoardingState = models.BoardingStateEmailVerified
+		if githubUserEmail.GetPrimary() && githubUserEmail.Email != nil {
+			if githubUserEmail.GetVerified() {
+				user.Email = githubUserEmail.GetEmail()
+				user.OnBoardingState = models.BoardingStateEmailVerified
+			} else {
+				user.UnverifiedEmail = githubUserEmail.GetEmail()
+			}
 			break
 		}
 	}
@@ -52,19 +97,87 @@ func getGitHubUser(c *gin.Context, token *oauth2.Token) (*models.UserCredentials
 	return &user, err
 }
 
-// GetGithubUser gives the details of the user fetched as from github
-func GetGithubUser(c *gin.Context) (*models.UserCredentials, error) {
-	token, err := controller.Server.GithubConfig.GetToken(c)
+// Exchange gives the details of the user fetched as from github
+func (p *GithubProvider) Exchange(ctx context.Context, code, codeVerifier string) (*models.UserCredentials, error) {
+	token, err := p.authConfig.GetToken(ctx, code, codeVerifier)
 	if err != nil {
 		log.Errorln("Error getting token from github", err)
 		return nil, err
 	}
 
-	githubUser, err := getGitHubUser(c, token)
+	client, err := p.newClient(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	githubUser, err := getGitHubUser(ctx, client)
 	if err != nil {
 		log.Errorln("Error getting user from github", err)
 		return nil, err
 	}
 
+	if len(p.config.AllowedGroups) == 0 && len(p.config.AdminGroups) == 0 {
+		return githubUser, nil
+	}
+
+	memberships, err := getGitHubMemberships(ctx, client)
+	if err != nil {
+		log.Errorln("Error getting memberships from github", err)
+		return nil, err
+	}
+	if len(p.config.AllowedGroups) > 0 && !hasGroup(memberships, p.config.AllowedGroups) {
+		return nil, errors.ErrMembershipRequired
+	}
+	if hasGroup(memberships, p.config.AdminGroups) {
+		githubUser.Role = models.RoleAdmin
+	}
+
 	return githubUser, nil
 }
+
+// getGitHubMemberships gives the organizations (`org`) and teams (`org/team-slug`) of the user
+func getGitHubMemberships(ctx context.Context, client *github.Client) (map[string]bool, error) {
+	memberships := map[string]bool{}
+
+	opt := &github.ListOptions{PerPage: 100}
+	for {
+		orgs, resp, err := client.Organizations.List(ctx, "", opt)
+		if err != nil {
+			return nil, err
+		}
+		for _, org := range orgs {
+			memberships[strings.ToLower(org.GetLogin())] = true
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	opt = &github.ListOptions{PerPage: 100}
+	for {
+		teams, resp, err := client.Teams.ListUserTeams(ctx, opt)
+		if err != nil {
+			return nil, err
+		}
+		for _, team := range teams {
+			org := strings.ToLower(team.GetOrganization().GetLogin())
+			memberships[org] = true
+			memberships[org+"/"+strings.ToLower(team.GetSlug())] = true
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return memberships, nil
+}
+
+// hasGroup checks if any of the groups is one of the memberships
+func hasGroup(memberships map[string]bool, groups []string) bool {
+	for _, group := range groups {
+		if memberships[strings.ToLower(group)] {
+			return true
+		}
+	}
+	return false
+}
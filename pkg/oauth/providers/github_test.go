@@ -0,0 +1,104 @@
+package providers
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/oauth2"
+
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/oauth"
+)
+
+func newFakeGithubProvider(t *testing.T, allowedGroups, adminGroups []string) *GithubProvider {
+	server := newFakeProviderServer(t, "/login/oauth/access_token", map[string]interface{}{
+		"/user": map[string]interface{}{
+			"id":         42,
+			"login":      "jdoe",
+			"name":       "John Doe",
+			"avatar_url": "https://example.com/jdoe.png",
+		},
+		"/user/emails": []map[string]interface{}{
+			{"email": "other@example.com", "primary": false, "verified": true},
+			{"email": "jdoe@example.com", "primary": true, "verified": true},
+		},
+		"/user/orgs": []map[string]interface{}{
+			{"login": "MayaData-io"},
+		},
+		"/user/teams": []map[string]interface{}{
+			{"slug": "platform-admins", "organization": map[string]interface{}{"login": "kubera"}},
+		},
+	})
+	t.Cleanup(server.Close)
+
+	config := &oauth.ProviderConfig{
+		Name:          models.GithubAuth,
+		ClientID:      "client",
+		AllowedGroups: allowedGroups,
+		AdminGroups:   adminGroups,
+	}
+	provider := NewGithubProvider(config)
+	provider.authConfig = config.SocialAuthConfig(oauth2.Endpoint{
+		AuthURL:  server.URL + "/login/oauth/authorize",
+		TokenURL: server.URL + "/login/oauth/access_token",
+	})
+	provider.apiURL = server.URL + "/"
+	return provider
+}
+
+func TestGithubProvider(t *testing.T) {
+	provider := newFakeGithubProvider(t, nil, nil)
+
+	user, err := provider.Exchange(context.Background(), "code", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Kind != models.GithubAuth || user.SocialAuthID != "42" || user.Name != "John Doe" ||
+		user.Email != "jdoe@example.com" || user.Role != models.RoleUser {
+		t.Errorf("Unexpected user: %+v", user)
+	}
+}
+
+func TestGithubProviderMemberships(t *testing.T) {
+	tests := map[string]struct {
+		allowedGroups []string
+		adminGroups   []string
+		err           error
+		role          models.Role
+	}{
+		"member of an allowed organization": {
+			allowedGroups: []string{"mayadata-io"},
+			role:          models.RoleUser,
+		},
+		"member of an allowed team": {
+			allowedGroups: []string{"kubera/platform-admins"},
+			role:          models.RoleUser,
+		},
+		"not a member of the allowed groups": {
+			allowedGroups: []string{"other-org", "kubera/other-team"},
+			err:           errors.ErrMembershipRequired,
+		},
+		"member of an admin team": {
+			allowedGroups: []string{"mayadata-io"},
+			adminGroups:   []string{"Kubera/Platform-Admins"},
+			role:          models.RoleAdmin,
+		},
+		"not a member of the admin teams": {
+			adminGroups: []string{"kubera/other-team"},
+			role:        models.RoleUser,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			provider := newFakeGithubProvider(t, test.allowedGroups, test.adminGroups)
+			user, err := provider.Exchange(context.Background(), "code", "verifier")
+			if err != test.err {
+				t.Fatalf("Expected error %v, got %v", test.err, err)
+			}
+			if err == nil && user.Role != test.role {
+				t.Errorf("Expected role %v, got %v", test.role, user.Role)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package providers
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/golang/glog"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/oauth"
+	"golang.org/x/oauth2"
+)
+
+// DefaultGitlabBaseURL is the base url of gitlab.com, self-managed instances have their own base url
+const DefaultGitlabBaseURL = "https://gitlab.com"
+
+// GitlabProvider logs in the users with their account on gitlab.com or on a self-managed gitlab instance
+type GitlabProvider struct {
+	config     *oauth.ProviderConfig
+	authConfig oauth.SocialAuthConfig
+	apiURL     string
+}
+
+type gitlabUser struct {
+	ID        int64  `json:"id"`
+	Username  string `json:"username"`
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	AvatarURL string `json:"avatar_url"`
+	// ConfirmedAt is set once the user has confirmed the email
+	ConfirmedAt *time.Time `json:"confirmed_at"`
+}
+
+// NewGitlabProvider creates the gitlab provider for the instance at the issuer of the config
+func NewGitlabProvider(config *oauth.ProviderConfig) *GitlabProvider {
+	baseURL := strings.TrimSuffix(config.Issuer, "/")
+	if baseURL == "" {
+		baseURL = DefaultGitlabBaseURL
+	}
+	return &GitlabProvider{
+		config: config,
+		authConfig: config.SocialAuthConfig(oauth2.Endpoint{
+			AuthURL:  baseURL + "/oauth/authorize",
+			TokenURL: baseURL + "/oauth/token",
+		}),
+		apiURL: baseURL + "/api/v4",
+	}
+}
+
+// Config gives the configuration of the provider
+func (p *GitlabProvider) Config() *oauth.ProviderConfig {
+	return p.config
+}
+
+// AuthCodeURL gives the url of the gitlab login page
+func (p *GitlabProvider) AuthCodeURL(ctx context.Context, state, codeVerifier string) (string, error) {
+	return p.authConfig.AuthCodeURLWithPKCE(state, codeVerifier), nil
+}
+
+// Exchange gives the details of the user fetched as from gitlab
+func (p *GitlabProvider) Exchange(ctx context.Context, code, codeVerifier string) (*models.UserCredentials, error) {
+	token, err := p.authConfig.GetToken(ctx, code, codeVerifier)
+	if err != nil {
+		log.Errorln("Error getting token from gitlab", err)
+		return nil, err
+	}
+
+	user, err := p.getGitlabUser(ctx, token)
+	if err != nil {
+		log.Errorln("Error getting user from gitlab", err)
+		return nil, err
+	}
+	return user, nil
+}
+
+// getGitlabUser returns the user information from the token
+func (p *GitlabProvider) getGitlabUser(ctx context.Context, token *oauth2.Token) (*models.UserCredentials, error) {
+	tc := p.authConfig.Client(ctx, token)
+
+	var gUser gitlabUser
+	if err := getJSON(tc, p.apiURL+"/user", &gUser); err != nil {
+		return nil, err
+	}
+
+	currTime := time.Now()
+	user := models.UserCredentials{
+		Name:         gUser.Name,
+		Kind:         models.GitlabAuth,
+		Role:         models.RoleUser,
+		State:        models.StateActive,
+		LoggedIn:     true,
+		SocialAuthID: strconv.FormatInt(gUser.ID, 10),
+		CreatedAt:    &currTime,
+		Photo:        gUser.AvatarURL,
+	}
+	if user.Name == "" {
+		user.Name = gUser.Username
+	}
+	// The email of the user is only verified once confirmed, which gitlab instances may not require
+	if gUser.Email != "" && gUser.ConfirmedAt != nil {
+		user.Email = gUser.Email
+		user.OnBoardingState = models.BoardingStateEmailVerified
+	} else if gUser.Email != "" {
+		user.UnverifiedEmail = gUser.Email
+	}
+	return &user, nil
+}
@@ -0,0 +1,114 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/oauth"
+)
+
+// newFakeProviderServer fakes the token endpoint of a provider along with its user api
+func newFakeProviderServer(t *testing.T, tokenPath string, api map[string]interface{}) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc(tokenPath, func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		if r.Form.Get("code") != "code" || r.Form.Get("code_verifier") != "verifier" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "token",
+			"token_type":   "bearer",
+		})
+	})
+	for path, response := range api {
+		response := response
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(response)
+		})
+	}
+	return httptest.NewServer(mux)
+}
+
+func TestGitlabProvider(t *testing.T) {
+	server := newFakeProviderServer(t, "/oauth/token", map[string]interface{}{
+		"/api/v4/user": map[string]interface{}{
+			"id":           42,
+			"username":     "jdoe",
+			"name":         "John Doe",
+			"email":        "jdoe@example.com",
+			"avatar_url":   "https://example.com/jdoe.png",
+			"confirmed_at": "2020-03-04T10:12:13.000Z",
+		},
+	})
+	defer server.Close()
+
+	provider := NewGitlabProvider(&oauth.ProviderConfig{
+		Name:     models.GitlabAuth,
+		Issuer:   server.URL + "/",
+		ClientID: "client",
+	})
+
+	authCodeURL, err := provider.AuthCodeURL(context.Background(), "state", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(authCodeURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Path != "/oauth/authorize" || u.Query().Get("state") != "state" || u.Query().Get("code_challenge_method") != "S256" {
+		t.Errorf("Unexpected auth code url: %v", authCodeURL)
+	}
+
+	user, err := provider.Exchange(context.Background(), "code", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Kind != models.GitlabAuth || user.SocialAuthID != "42" || user.Name != "John Doe" ||
+		user.Email != "jdoe@example.com" || user.Photo != "https://example.com/jdoe.png" {
+		t.Errorf("Unexpected user: %+v", user)
+	}
+
+	if _, err = provider.Exchange(context.Background(), "code", "wrong-verifier"); err == nil {
+		t.Error("Expected an error for a wrong code verifier")
+	}
+}
+
+func TestGitlabProviderUnconfirmedEmail(t *testing.T) {
+	server := newFakeProviderServer(t, "/oauth/token", map[string]interface{}{
+		"/api/v4/user": map[string]interface{}{
+			"id":           42,
+			"username":     "jdoe",
+			"email":        "jdoe@example.com",
+			"confirmed_at": nil,
+		},
+	})
+	defer server.Close()
+
+	provider := NewGitlabProvider(&oauth.ProviderConfig{
+		Name:     models.GitlabAuth,
+		Issuer:   server.URL,
+		ClientID: "client",
+	})
+	user, err := provider.Exchange(context.Background(), "code", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "" || user.UnverifiedEmail != "jdoe@example.com" || user.Name != "jdoe" {
+		t.Errorf("Unexpected user: %+v", user)
+	}
+}
@@ -1,14 +1,15 @@
 package providers
 
 import (
-	"encoding/json"
-	"net/http"
+	"context"
+	"strings"
 
-	"github.com/gin-gonic/gin"
 	log "github.com/golang/glog"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
 	"github.com/mayadata-io/kubera-auth/pkg/models"
-	controller "github.com/mayadata-io/kubera-auth/versionedController/v1"
+	"github.com/mayadata-io/kubera-auth/pkg/oauth"
 	"golang.org/x/oauth2"
+	googleEndpoint "golang.org/x/oauth2/google"
 )
 
 const (
@@ -35,21 +36,58 @@ type account struct {
 	VerifiedEmail bool `json:"verified_email"`
 }
 
+// GoogleProvider logs in the users with their google account
+type GoogleProvider struct {
+	config      *oauth.ProviderConfig
+	authConfig  oauth.SocialAuthConfig
+	userInfoURL string
+}
+
+// NewGoogleProvider creates the google provider
+func NewGoogleProvider(config *oauth.ProviderConfig) *GoogleProvider {
+	return &GoogleProvider{
+		config:      config,
+		authConfig:  config.SocialAuthConfig(googleEndpoint.Endpoint),
+		userInfoURL: userInfo,
+	}
+}
+
+// Config gives the configuration of the provider
+func (p *GoogleProvider) Config() *oauth.ProviderConfig {
+	return p.config
+}
+
+// AuthCodeURL gives the url of the google login page
+func (p *GoogleProvider) AuthCodeURL(ctx context.Context, state, codeVerifier string) (string, error) {
+	opts := []oauth2.AuthCodeOption{oauth2.SetAuthURLParam("include_granted_scopes", "true"), oauth2.AccessTypeOnline}
+	// hd only optimizes the login page for the domain, the domain is enforced on the callback.
+	// With several domains google is asked for any workspace account.
+	switch len(p.config.AllowedDomains) {
+	case 0:
+	case 1:
+		opts = append(opts, oauth2.SetAuthURLParam("hd", p.config.AllowedDomains[0]))
+	default:
+		opts = append(opts, oauth2.SetAuthURLParam("hd", "*"))
+	}
+	return p.authConfig.AuthCodeURLWithPKCE(state, codeVerifier, opts...), nil
+}
+
 // getGoogleUser gets the user model based on the structure
-func getGoogleUser(c *gin.Context, token *oauth2.Token) (*models.UserCredentials, error) {
-	ctx := c.Request.Context()
+func (p *GoogleProvider) getGoogleUser(ctx context.Context, token *oauth2.Token) (*models.UserCredentials, error) {
 	// use oauth2.ReuseTokenSource when access_type is set to offline, refreshing every hour
 	ts := oauth2.StaticTokenSource(token)
 	tc := oauth2.NewClient(ctx, ts)
-	resp, err := tc.Get(userInfo)
-	if err != nil {
+	var gUser account
+	if err := getJSON(tc, p.userInfoURL, &gUser); err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-	var gUser account
-	if resp.StatusCode == http.StatusOK {
-		_ = json.NewDecoder(resp.Body).Decode(&gUser)
+	if !gUser.VerifiedEmail {
+		return nil, errors.ErrEmailNotVerified
 	}
+	if len(p.config.AllowedDomains) > 0 && !hasDomain(p.config.AllowedDomains, gUser.Hd) {
+		return nil, errors.ErrDomainNotAllowed
+	}
+
 	user := models.UserCredentials{
 		Name:            gUser.Name,
 		Kind:            models.GoogleAuth,
@@ -64,14 +102,28 @@ func getGoogleUser(c *gin.Context, token *oauth2.Token) (*models.UserCredentials
 	return &user, nil
 }
 
-// GetGoogleUser gives the details of the user fetched as from google
-func GetGoogleUser(c *gin.Context) (*models.UserCredentials, error) {
-	token, err := controller.Server.GoogleConfig.GetToken(c)
+// hasDomain checks if the hosted domain of the user is one of the domains,
+// users without a hosted domain are personal google accounts
+func hasDomain(domains []string, hd string) bool {
+	if hd == "" {
+		return false
+	}
+	for _, domain := range domains {
+		if strings.EqualFold(domain, hd) {
+			return true
+		}
+	}
+	return false
+}
+
+// Exchange gives the details of the user fetched as from google
+func (p *GoogleProvider) Exchange(ctx context.Context, code, codeVerifier string) (*models.UserCredentials, error) {
+	token, err := p.authConfig.GetToken(ctx, code, codeVerifier)
 	if err != nil {
 		log.Errorln("Error getting token from Google", err)
 		return nil, err
 	}
-	user, err := getGoogleUser(c, token)
+	user, err := p.getGoogleUser(ctx, token)
 	if err != nil {
 		log.Errorln("Error getting user from Google", err)
 		return nil, err
@@ -0,0 +1,81 @@
+package providers
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"golang.org/x/oauth2"
+
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/oauth"
+)
+
+func TestGoogleProvider(t *testing.T) {
+	tests := []struct {
+		name    string
+		account map[string]interface{}
+		err     error
+	}{
+		{
+			name:    "workspace account",
+			account: map[string]interface{}{"id": "42", "email": "jdoe@example.com", "hd": "example.com", "verified_email": true},
+		},
+		{
+			name:    "other workspace",
+			account: map[string]interface{}{"id": "42", "email": "jdoe@example.org", "hd": "example.org", "verified_email": true},
+			err:     errors.ErrDomainNotAllowed,
+		},
+		{
+			name:    "personal account",
+			account: map[string]interface{}{"id": "42", "email": "jdoe@gmail.com", "verified_email": true},
+			err:     errors.ErrDomainNotAllowed,
+		},
+		{
+			name:    "unverified email",
+			account: map[string]interface{}{"id": "42", "email": "jdoe@example.com", "hd": "example.com", "verified_email": false},
+			err:     errors.ErrEmailNotVerified,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newFakeProviderServer(t, "/token", map[string]interface{}{"/userinfo": test.account})
+			defer server.Close()
+
+			config := &oauth.ProviderConfig{
+				Name:           models.GoogleAuth,
+				ClientID:       "client",
+				AllowedDomains: []string{"Example.com"},
+			}
+			provider := NewGoogleProvider(config)
+			provider.authConfig = config.SocialAuthConfig(oauth2.Endpoint{
+				AuthURL:  server.URL + "/auth",
+				TokenURL: server.URL + "/token",
+			})
+			provider.userInfoURL = server.URL + "/userinfo"
+
+			user, err := provider.Exchange(context.Background(), "code", "verifier")
+			if err != test.err {
+				t.Fatalf("Expected error %v, got %v", test.err, err)
+			}
+			if err == nil && (user.Email != "jdoe@example.com" || user.SocialAuthID != "42") {
+				t.Errorf("Unexpected user: %+v", user)
+			}
+		})
+	}
+
+	provider := NewGoogleProvider(&oauth.ProviderConfig{Name: models.GoogleAuth, AllowedDomains: []string{"example.com"}})
+	authCodeURL, err := provider.AuthCodeURL(context.Background(), "state", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(authCodeURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Query().Get("hd") != "example.com" {
+		t.Errorf("Expected the hd hint in %v", authCodeURL)
+	}
+}
@@ -0,0 +1,20 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// getJSON decodes the JSON response of a GET request
+func getJSON(client *http.Client, url string, v interface{}) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("request to %s failed with status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
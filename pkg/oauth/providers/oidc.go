@@ -0,0 +1,219 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	log "github.com/golang/glog"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/oauth"
+	"golang.org/x/oauth2"
+)
+
+// discoveryPath is the path of the OpenID Connect provider metadata relative to the issuer
+const discoveryPath = "/.well-known/openid-configuration"
+
+// OIDCProvider logs in the users with any OpenID Connect provider such as Keycloak, Dex, Okta or Azure AD.
+// The endpoints of the provider are discovered from its issuer on first use.
+type OIDCProvider struct {
+	config     *oauth.ProviderConfig
+	httpClient *http.Client
+
+	lock      sync.Mutex
+	discovery *oidcDiscovery
+}
+
+// oidcDiscovery is the part of the provider metadata which is needed for login
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+// oidcClaims are the standard claims of the user from which the user is created
+type oidcClaims struct {
+	Subject           string      `json:"sub"`
+	Name              string      `json:"name"`
+	PreferredUsername string      `json:"preferred_username"`
+	Email             string      `json:"email"`
+	EmailVerified     interface{} `json:"email_verified"`
+	Picture           string      `json:"picture"`
+}
+
+// NewOIDCProvider creates a generic OpenID Connect provider
+func NewOIDCProvider(config *oauth.ProviderConfig) *OIDCProvider {
+	return &OIDCProvider{
+		config:     config,
+		httpClient: &http.Client{Timeout: time.Second * 10},
+	}
+}
+
+// Config gives the configuration of the provider
+func (p *OIDCProvider) Config() *oauth.ProviderConfig {
+	return p.config
+}
+
+// AuthCodeURL gives the url of the login page of the provider
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, codeVerifier string) (string, error) {
+	authConfig, _, err := p.authConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+	return authConfig.AuthCodeURLWithPKCE(state, codeVerifier), nil
+}
+
+// Exchange gives the details of the user fetched as from the provider
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier string) (*models.UserCredentials, error) {
+	authConfig, discovery, err := p.authConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.httpClient)
+	token, err := authConfig.GetToken(ctx, code, codeVerifier)
+	if err != nil {
+		log.Errorln("Error getting token from ", p.config.Name, err)
+		return nil, err
+	}
+
+	claims, err := p.getClaims(ctx, authConfig, discovery, token)
+	if err != nil {
+		log.Errorln("Error getting user from ", p.config.Name, err)
+		return nil, err
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("no subject in the claims of %s", p.config.Name)
+	}
+	return p.newUser(claims), nil
+}
+
+// getClaims gets the claims of the user from the userinfo endpoint, falling back to the id token.
+// As the id token is received directly from the token endpoint, TLS validates its issuer instead of its signature.
+func (p *OIDCProvider) getClaims(ctx context.Context, authConfig oauth.SocialAuthConfig, discovery *oidcDiscovery, token *oauth2.Token) (*oidcClaims, error) {
+	claims := &oidcClaims{}
+	if discovery.UserInfoEndpoint != "" {
+		resp, err := authConfig.Client(ctx, token).Get(discovery.UserInfoEndpoint)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("userinfo request failed with status %d", resp.StatusCode)
+		}
+		return claims, json.NewDecoder(resp.Body).Decode(claims)
+	}
+
+	idToken, _ := token.Extra("id_token").(string)
+	if idToken == "" {
+		return nil, fmt.Errorf("neither userinfo endpoint nor id token is available")
+	}
+	mapClaims := jwt.MapClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(idToken, mapClaims); err != nil {
+		return nil, err
+	}
+	if !mapClaims.VerifyIssuer(discovery.Issuer, true) || !mapClaims.VerifyAudience(p.config.ClientID, true) ||
+		!mapClaims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, fmt.Errorf("invalid id token")
+	}
+
+	data, err := json.Marshal(mapClaims)
+	if err != nil {
+		return nil, err
+	}
+	return claims, json.Unmarshal(data, claims)
+}
+
+// newUser maps the claims to the user, the email is taken as verified only if the provider says so
+func (p *OIDCProvider) newUser(claims *oidcClaims) *models.UserCredentials {
+	currTime := time.Now()
+	user := &models.UserCredentials{
+		Name:            claims.Name,
+		Kind:            p.config.Name,
+		Role:            models.RoleUser,
+		State:           models.StateActive,
+		LoggedIn:        true,
+		SocialAuthID:    claims.Subject,
+		CreatedAt:       &currTime,
+		Photo:           claims.Picture,
+		OnBoardingState: models.BoardingStateUnverifiedAndComplete,
+	}
+	if user.Name == "" {
+		user.Name = claims.PreferredUsername
+	}
+
+	if isTrue(claims.EmailVerified) {
+		user.Email = claims.Email
+		user.OnBoardingState = models.BoardingStateEmailVerified
+	} else {
+		user.UnverifiedEmail = claims.Email
+	}
+	return user
+}
+
+// authConfig gives the oauth2 config for the discovered endpoints of the provider
+func (p *OIDCProvider) authConfig(ctx context.Context) (oauth.SocialAuthConfig, *oidcDiscovery, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return oauth.SocialAuthConfig{}, nil, err
+	}
+	endpoint := oauth2.Endpoint{
+		AuthURL:  discovery.AuthorizationEndpoint,
+		TokenURL: discovery.TokenEndpoint,
+	}
+	return p.config.SocialAuthConfig(endpoint), discovery, nil
+}
+
+// discover fetches the provider metadata from the issuer, it is fetched again till it is fetched successfully
+func (p *OIDCProvider) discover(ctx context.Context) (*oidcDiscovery, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(p.config.Issuer, "/")+discoveryPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("discovery of %s failed with status %d", p.config.Name, resp.StatusCode)
+	}
+
+	discovery := &oidcDiscovery{}
+	if err = json.NewDecoder(resp.Body).Decode(discovery); err != nil {
+		return nil, err
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" {
+		return nil, fmt.Errorf("discovery of %s is missing the authorization or token endpoint", p.config.Name)
+	}
+	if discovery.Issuer == "" {
+		discovery.Issuer = p.config.Issuer
+	}
+	p.discovery = discovery
+	return discovery, nil
+}
+
+// isTrue reads a boolean claim, some providers send booleans as strings
+func isTrue(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	}
+	return false
+}
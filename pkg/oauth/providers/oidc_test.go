@@ -0,0 +1,209 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/oauth"
+)
+
+// newFakeOIDCServer fakes an OpenID Connect provider whose token endpoint responds with the id token.
+// The userinfo endpoint is only discovered if userInfo is given.
+func newFakeOIDCServer(t *testing.T, idToken func(issuer string) string, userInfo map[string]interface{}) *httptest.Server {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	mux.HandleFunc(discoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		discovery := map[string]interface{}{
+			"issuer":                 server.URL,
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+		}
+		if userInfo != nil {
+			discovery["userinfo_endpoint"] = server.URL + "/userinfo"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(discovery)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		if r.Form.Get("code") != "code" || r.Form.Get("code_verifier") != "verifier" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "token",
+			"token_type":   "bearer",
+			"id_token":     idToken(server.URL),
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(userInfo)
+	})
+	return server
+}
+
+// newIDToken signs the claims with a key of the test, the provider doesn't verify the signature
+func newIDToken(t *testing.T, claims jwt.MapClaims) string {
+	idToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return idToken
+}
+
+func newTestOIDCProvider(issuer string) *OIDCProvider {
+	return NewOIDCProvider(&oauth.ProviderConfig{
+		Name:     "keycloak",
+		Issuer:   issuer + "/",
+		ClientID: "client",
+	})
+}
+
+func TestOIDCProviderUserInfo(t *testing.T) {
+	server := newFakeOIDCServer(t, func(string) string { return "" }, map[string]interface{}{
+		"sub":            "42",
+		"name":           "John Doe",
+		"email":          "jdoe@example.com",
+		"email_verified": true,
+		"picture":        "https://example.com/jdoe.png",
+	})
+	defer server.Close()
+	provider := newTestOIDCProvider(server.URL)
+
+	authCodeURL, err := provider.AuthCodeURL(context.Background(), "state", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(authCodeURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Path != "/authorize" || u.Query().Get("state") != "state" || u.Query().Get("code_challenge_method") != "S256" {
+		t.Errorf("Unexpected auth code url: %v", authCodeURL)
+	}
+
+	user, err := provider.Exchange(context.Background(), "code", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Kind != "keycloak" || user.SocialAuthID != "42" || user.Name != "John Doe" ||
+		user.Email != "jdoe@example.com" || user.OnBoardingState != models.BoardingStateEmailVerified {
+		t.Errorf("Unexpected user: %+v", user)
+	}
+}
+
+func TestOIDCProviderEmailVerified(t *testing.T) {
+	tests := []struct {
+		name          string
+		emailVerified interface{}
+		verified      bool
+	}{
+		{name: "true", emailVerified: true, verified: true},
+		{name: "true as string", emailVerified: "true", verified: true},
+		{name: "false", emailVerified: false},
+		{name: "false as string", emailVerified: "false"},
+		{name: "missing", emailVerified: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userInfo := map[string]interface{}{
+				"sub":                "42",
+				"preferred_username": "jdoe",
+				"email":              "jdoe@example.com",
+			}
+			if tt.emailVerified != nil {
+				userInfo["email_verified"] = tt.emailVerified
+			}
+			server := newFakeOIDCServer(t, func(string) string { return "" }, userInfo)
+			defer server.Close()
+
+			user, err := newTestOIDCProvider(server.URL).Exchange(context.Background(), "code", "verifier")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.verified && (user.Email != "jdoe@example.com" || user.UnverifiedEmail != "") {
+				t.Errorf("Expected a verified email, got: %+v", user)
+			}
+			if !tt.verified && (user.Email != "" || user.UnverifiedEmail != "jdoe@example.com") {
+				t.Errorf("Expected an unverified email, got: %+v", user)
+			}
+			if user.Name != "jdoe" {
+				t.Errorf("Expected the preferred username as name, got: %v", user.Name)
+			}
+		})
+	}
+}
+
+func TestOIDCProviderIDTokenFallback(t *testing.T) {
+	validClaims := func(issuer string) jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":            issuer,
+			"aud":            "client",
+			"sub":            "42",
+			"exp":            time.Now().Add(time.Minute).Unix(),
+			"email":          "jdoe@example.com",
+			"email_verified": true,
+		}
+	}
+	tests := []struct {
+		name   string
+		claims func(issuer string) jwt.MapClaims
+		valid  bool
+	}{
+		{name: "valid", claims: validClaims, valid: true},
+		{name: "wrong issuer", claims: func(issuer string) jwt.MapClaims {
+			claims := validClaims(issuer)
+			claims["iss"] = "https://attacker.example.com"
+			return claims
+		}},
+		{name: "foreign audience", claims: func(issuer string) jwt.MapClaims {
+			claims := validClaims(issuer)
+			claims["aud"] = "another-client"
+			return claims
+		}},
+		{name: "expired", claims: func(issuer string) jwt.MapClaims {
+			claims := validClaims(issuer)
+			claims["exp"] = time.Now().Add(-time.Minute).Unix()
+			return claims
+		}},
+		{name: "without expiry", claims: func(issuer string) jwt.MapClaims {
+			claims := validClaims(issuer)
+			delete(claims, "exp")
+			return claims
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeOIDCServer(t, func(issuer string) string { return newIDToken(t, tt.claims(issuer)) }, nil)
+			defer server.Close()
+
+			user, err := newTestOIDCProvider(server.URL).Exchange(context.Background(), "code", "verifier")
+			if tt.valid {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if user.SocialAuthID != "42" || user.Email != "jdoe@example.com" {
+					t.Errorf("Unexpected user: %+v", user)
+				}
+			} else if err == nil {
+				t.Errorf("Expected the id token to be rejected, got user: %+v", user)
+			}
+		})
+	}
+}
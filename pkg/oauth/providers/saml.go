@@ -0,0 +1,177 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/golang/glog"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/oauth"
+	"github.com/mayadata-io/kubera-auth/pkg/saml"
+)
+
+// SAMLProvider logs in the users with a SAML 2.0 identity provider such as Okta, Azure AD or ADFS. The identity
+// provider posts its response to the assertion consumer service, the response is exchanged for the user like a code.
+// The metadata of the identity provider is loaded on first use.
+type SAMLProvider struct {
+	config     *oauth.SAMLConfig
+	httpClient *http.Client
+
+	lock            sync.Mutex
+	serviceProvider *saml.ServiceProvider
+}
+
+// NewSAMLProvider creates the SAML provider
+func NewSAMLProvider(config *oauth.SAMLConfig) *SAMLProvider {
+	return &SAMLProvider{
+		config:     config,
+		httpClient: &http.Client{Timeout: time.Second * 10},
+	}
+}
+
+// Config gives the configuration of the provider
+func (p *SAMLProvider) Config() *oauth.ProviderConfig {
+	return &p.config.ProviderConfig
+}
+
+// Metadata gives the metadata of kubera as service provider, which is registered with the identity provider
+func (p *SAMLProvider) Metadata() ([]byte, error) {
+	sp := &saml.ServiceProvider{
+		EntityID: p.config.EntityID,
+		ACSURL:   p.config.RedirectURL,
+	}
+	return sp.Metadata()
+}
+
+// AuthCodeURL gives the url of the single sign on service of the identity provider along with an AuthnRequest
+func (p *SAMLProvider) AuthCodeURL(ctx context.Context, state, codeVerifier string) (string, error) {
+	sp, err := p.getServiceProvider(ctx)
+	if err != nil {
+		return "", err
+	}
+	return sp.AuthnRequestURL(samlRequestID(codeVerifier), state)
+}
+
+// Exchange validates the SAMLResponse posted by the identity provider and gives the user of its assertion
+func (p *SAMLProvider) Exchange(ctx context.Context, code, codeVerifier string) (*models.UserCredentials, error) {
+	sp, err := p.getServiceProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	assertion, err := sp.ParseResponse(code, samlRequestID(codeVerifier))
+	if statusErr, ok := err.(*saml.StatusError); ok {
+		// The user couldn't be authenticated or didn't consent to the login
+		log.Errorln("Error status of the response of ", p.config.Name, statusErr.Code)
+		return nil, errors.ErrAccessDenied
+	} else if err != nil {
+		log.Errorln("Error validating the response of ", p.config.Name, err)
+		return nil, err
+	}
+
+	user := p.newUser(assertion)
+	if len(p.config.AllowedGroups) == 0 && len(p.config.AdminGroups) == 0 {
+		return user, nil
+	}
+
+	memberships := map[string]bool{}
+	for _, group := range assertion.GetAll(p.config.GroupsAttribute) {
+		memberships[strings.ToLower(group)] = true
+	}
+	if len(p.config.AllowedGroups) > 0 && !hasGroup(memberships, p.config.AllowedGroups) {
+		return nil, errors.ErrMembershipRequired
+	}
+	if hasGroup(memberships, p.config.AdminGroups) {
+		user.Role = models.RoleAdmin
+	}
+	return user, nil
+}
+
+// newUser maps the attributes of the assertion onto a user, the name id is the id of the user with the provider
+func (p *SAMLProvider) newUser(assertion *saml.Assertion) *models.UserCredentials {
+	email := assertion.Get(p.config.EmailAttribute)
+	if email == "" && strings.Contains(assertion.NameID, "@") {
+		// The name id is often the email of the user
+		email = assertion.NameID
+	}
+	name := assertion.Get(p.config.NameAttribute)
+	if name == "" {
+		name = assertion.NameID
+	}
+
+	currTime := time.Now()
+	user := &models.UserCredentials{
+		Name:         name,
+		Kind:         p.config.Name,
+		Role:         models.RoleUser,
+		State:        models.StateActive,
+		LoggedIn:     true,
+		SocialAuthID: assertion.NameID,
+		CreatedAt:    &currTime,
+	}
+	// The emails of the identity provider are managed by its administrators, they are considered as verified
+	if email != "" {
+		user.Email = email
+		user.OnBoardingState = models.BoardingStateEmailVerified
+	}
+	return user
+}
+
+// getServiceProvider gives kubera as service provider of the identity provider, whose metadata is loaded once
+func (p *SAMLProvider) getServiceProvider(ctx context.Context) (*saml.ServiceProvider, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.serviceProvider != nil {
+		return p.serviceProvider, nil
+	}
+
+	metadata := []byte(p.config.IDPMetadata)
+	if len(metadata) == 0 {
+		if p.config.IDPMetadataURL == "" {
+			return nil, fmt.Errorf("the metadata of the identity provider of %s is not configured", p.config.Name)
+		}
+		var err error
+		if metadata, err = p.fetchMetadata(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	idp, err := saml.ParseMetadata(metadata)
+	if err != nil {
+		return nil, err
+	}
+	p.serviceProvider = &saml.ServiceProvider{
+		EntityID: p.config.EntityID,
+		ACSURL:   p.config.RedirectURL,
+		IDP:      idp,
+	}
+	return p.serviceProvider, nil
+}
+
+func (p *SAMLProvider) fetchMetadata(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.config.IDPMetadataURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("request of the metadata of %s failed with status %d", p.config.Name, resp.StatusCode)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// samlRequestID gives the id of the AuthnRequest of a login, the id is derived from the code verifier of its state
+// to match the response of the identity provider with the login which requested it
+func samlRequestID(codeVerifier string) string {
+	return "_" + codeVerifier
+}
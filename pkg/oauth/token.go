@@ -1,17 +1,27 @@
 package oauth
 
 import (
+	"context"
 	"errors"
 
-	"github.com/gin-gonic/gin"
 	"golang.org/x/oauth2"
+
+	"github.com/mayadata-io/kubera-auth/pkg/utils/digest"
 )
 
-//GetToken gets the temp code for oauth and exchanges this code with github in order to get auth token
-func (config SocialAuthConfig) GetToken(c *gin.Context) (*oauth2.Token, error) {
-	code := c.Query("code")
+//GetToken exchanges the temp code for oauth with the provider in order to get auth token
+func (config SocialAuthConfig) GetToken(ctx context.Context, code, codeVerifier string) (*oauth2.Token, error) {
 	if code == "" {
 		return nil, errors.New("Code not found")
 	}
-	return config.Exchange(c.Request.Context(), code)
+	return config.Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", codeVerifier))
+}
+
+// AuthCodeURLWithPKCE gives the login url of the provider with the state and the S256 challenge of the PKCE code verifier
+func (config SocialAuthConfig) AuthCodeURLWithPKCE(state, codeVerifier string, opts ...oauth2.AuthCodeOption) string {
+	opts = append(opts,
+		oauth2.SetAuthURLParam("code_challenge", digest.S256(codeVerifier)),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	)
+	return config.AuthCodeURL(state, opts...)
 }
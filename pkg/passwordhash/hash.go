@@ -0,0 +1,179 @@
+// Package passwordhash hashes the passwords of the local users. The hashes are self-describing, argon2id hashes are
+// PHC strings such as $argon2id$v=19$m=19456,t=2,p=1$<salt>$<key> and bcrypt hashes are the usual $2a$<cost>$...
+// strings, so that the hashes of any algorithm and parameters can be verified after the configuration has changed.
+package passwordhash
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	log "github.com/golang/glog"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/mayadata-io/kubera-auth/pkg/types"
+)
+
+// Algorithm is a password hashing algorithm
+type Algorithm string
+
+const (
+	Bcrypt   Algorithm = "bcrypt"
+	Argon2id Algorithm = "argon2id"
+)
+
+const argon2idPrefix = "$argon2id$"
+
+// ErrUnknownHash is returned for the hashes of neither bcrypt nor argon2id, or whose parameters can't be read
+var ErrUnknownHash = errors.New("passwordhash: unknown hash format")
+
+// Argon2Params are the parameters of argon2id, the memory is in KiB
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// Hasher hashes the new passwords with the configured algorithm and parameters
+type Hasher struct {
+	Algorithm  Algorithm
+	BcryptCost int
+	Argon2     Argon2Params
+}
+
+// NewHasher returns the hasher configured by the environment, the passwords are hashed with argon2id by default
+// with the parameters recommended by OWASP
+func NewHasher() *Hasher {
+	hasher := &Hasher{
+		Algorithm:  Algorithm(getEnv(types.PASSWORD_HASH_ALGORITHM, string(Argon2id))),
+		BcryptCost: parseIntEnv(types.PASSWORD_BCRYPT_COST, 12),
+		Argon2: Argon2Params{
+			Memory:      uint32(parseIntEnv(types.PASSWORD_ARGON2_MEMORY, 19*1024)),
+			Iterations:  uint32(parseIntEnv(types.PASSWORD_ARGON2_ITERATIONS, 2)),
+			Parallelism: uint8(parseIntEnv(types.PASSWORD_ARGON2_PARALLELISM, 1)),
+			SaltLength:  16,
+			KeyLength:   32,
+		},
+	}
+	switch hasher.Algorithm {
+	case Bcrypt:
+		if hasher.BcryptCost < bcrypt.MinCost || hasher.BcryptCost > bcrypt.MaxCost {
+			log.Fatal("Invalid ", types.PASSWORD_BCRYPT_COST, " ", hasher.BcryptCost)
+		}
+	case Argon2id:
+		if hasher.Argon2.Iterations == 0 || hasher.Argon2.Parallelism == 0 || hasher.Argon2.Memory < 8*uint32(hasher.Argon2.Parallelism) {
+			log.Fatal("Invalid argon2id parameters ", hasher.Argon2)
+		}
+	default:
+		log.Fatal("Unsupported ", types.PASSWORD_HASH_ALGORITHM, " ", hasher.Algorithm)
+	}
+	return hasher
+}
+
+// Hash hashes the password with the configured algorithm and parameters
+func (h *Hasher) Hash(password string) (string, error) {
+	if h.Algorithm == Bcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.BcryptCost)
+		return string(hash), err
+	}
+
+	salt := make([]byte, h.Argon2.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Argon2.Iterations, h.Argon2.Memory, h.Argon2.Parallelism, h.Argon2.KeyLength)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version,
+		h.Argon2.Memory, h.Argon2.Iterations, h.Argon2.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// NeedsRehash checks if the hash was made with another algorithm or other parameters than the configured ones,
+// such a hash is replaced once the password is known on login
+func (h *Hasher) NeedsRehash(encodedHash string) bool {
+	if h.Algorithm == Bcrypt {
+		cost, err := bcrypt.Cost([]byte(encodedHash))
+		return err != nil || cost != h.BcryptCost
+	}
+
+	params, _, key, err := decodeArgon2id(encodedHash)
+	if err != nil {
+		return true
+	}
+	return params.Memory != h.Argon2.Memory || params.Iterations != h.Argon2.Iterations ||
+		params.Parallelism != h.Argon2.Parallelism || uint32(len(key)) != h.Argon2.KeyLength
+}
+
+// Verify compares the password with the hash of either algorithm, an error is only returned for an invalid hash
+func Verify(encodedHash, password string) (bool, error) {
+	if strings.HasPrefix(encodedHash, argon2idPrefix) {
+		params, salt, key, err := decodeArgon2id(encodedHash)
+		if err != nil {
+			return false, err
+		}
+		otherKey := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(key, otherKey) == 1, nil
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(encodedHash), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	} else if err != nil {
+		return false, ErrUnknownHash
+	}
+	return true, nil
+}
+
+// decodeArgon2id reads the parameters, the salt and the key of an argon2id PHC string
+func decodeArgon2id(encodedHash string) (*Argon2Params, []byte, []byte, error) {
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 6 || "$"+parts[1]+"$" != argon2idPrefix {
+		return nil, nil, nil, ErrUnknownHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, ErrUnknownHash
+	}
+	params := &Argon2Params{}
+	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil || params.Iterations == 0 || params.Parallelism == 0 {
+		return nil, nil, nil, ErrUnknownHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, ErrUnknownHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return nil, nil, nil, ErrUnknownHash
+	}
+	params.SaltLength, params.KeyLength = uint32(len(salt)), uint32(len(key))
+	return params, salt, key, nil
+}
+
+func getEnv(name, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func parseIntEnv(name string, defaultValue int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil || i < 0 {
+		log.Fatal("Error parsing ", name, " ", value)
+	}
+	return i
+}
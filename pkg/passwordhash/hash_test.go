@@ -0,0 +1,75 @@
+package passwordhash
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestHashers() map[string]*Hasher {
+	return map[string]*Hasher{
+		"argon2id": {
+			Algorithm: Argon2id,
+			Argon2:    Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+		},
+		"bcrypt": {Algorithm: Bcrypt, BcryptCost: bcrypt.MinCost},
+	}
+}
+
+func TestHashAndVerify(t *testing.T) {
+	for name, hasher := range newTestHashers() {
+		t.Run(name, func(t *testing.T) {
+			hash, err := hasher.Hash("correct horse")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hasher.Algorithm == Argon2id && !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+				t.Fatalf("unexpected hash %s", hash)
+			}
+			if matched, err := Verify(hash, "correct horse"); err != nil || !matched {
+				t.Fatalf("expected the password to match, got %v %v", matched, err)
+			}
+			if matched, err := Verify(hash, "wrong horse"); err != nil || matched {
+				t.Fatalf("expected the password not to match, got %v %v", matched, err)
+			}
+			if hasher.NeedsRehash(hash) {
+				t.Fatal("expected the hash not to need a rehash")
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	hashers := newTestHashers()
+	argon2Hash, err := hashers["argon2id"].Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bcryptHash, err := hashers["bcrypt"].Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !hashers["argon2id"].NeedsRehash(bcryptHash) || !hashers["bcrypt"].NeedsRehash(argon2Hash) {
+		t.Error("expected the hashes of another algorithm to need a rehash")
+	}
+	stronger := *hashers["argon2id"]
+	stronger.Argon2.Iterations = 2
+	if !stronger.NeedsRehash(argon2Hash) {
+		t.Error("expected the argon2id hash with other parameters to need a rehash")
+	}
+	costlier := *hashers["bcrypt"]
+	costlier.BcryptCost++
+	if !costlier.NeedsRehash(bcryptHash) {
+		t.Error("expected the bcrypt hash with another cost to need a rehash")
+	}
+}
+
+func TestVerifyInvalidHash(t *testing.T) {
+	for _, hash := range []string{"", "plain", "$argon2id$v=19$m=64,t=1$c2FsdA$a2V5", "$argon2id$v=16$m=64,t=1,p=1$c2FsdA$a2V5"} {
+		if _, err := Verify(hash, "correct horse"); err != ErrUnknownHash {
+			t.Errorf("expected ErrUnknownHash for %q, got %v", hash, err)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+// Package passwordpolicy validates the passwords of the local users against the configured policy
+package passwordpolicy
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	log "github.com/golang/glog"
+
+	"github.com/mayadata-io/kubera-auth/pkg/types"
+)
+
+// The codes of the violations, which the portal can translate
+const (
+	ViolationMinLength = "min_length"
+	ViolationUppercase = "uppercase"
+	ViolationLowercase = "lowercase"
+	ViolationDigit     = "digit"
+	ViolationSymbol    = "symbol"
+	ViolationUserInfo  = "user_info"
+	ViolationCommon    = "common"
+	ViolationReused    = "reused"
+)
+
+// minUserInfoLength is the length from which a part of the username, name or email is not allowed in the password,
+// the shorter parts would reject too many passwords by chance
+const minUserInfoLength = 4
+
+// Violation is a rule of the policy which the password doesn't meet
+type Violation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ViolationError is returned for a password which violates the policy, it lists all the violations at once
+type ViolationError struct {
+	Violations []Violation
+}
+
+func (e *ViolationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.Message
+	}
+	return "password policy violated: " + strings.Join(messages, ", ")
+}
+
+// NewViolationError gives the error of a single violation
+func NewViolationError(code, message string) *ViolationError {
+	return &ViolationError{Violations: []Violation{{Code: code, Message: message}}}
+}
+
+// Policy is the set of rules which the passwords of the local users have to meet
+type Policy struct {
+	MinLength        int
+	RequireUppercase bool
+	RequireLowercase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	// DisallowUserInfo rejects the passwords containing the username, the name or the email of the user
+	DisallowUserInfo bool
+	// HistorySize is the number of the last passwords of a user, including the current one, which can't be reused
+	HistorySize int
+	// commonPasswords are the lower cased passwords of the breached or common password list
+	commonPasswords map[string]bool
+}
+
+// NewPolicy returns the password policy configured by the environment, the passwords need 8 characters by default
+func NewPolicy() *Policy {
+	policy := &Policy{
+		MinLength:        parseIntEnv(types.PASSWORD_MIN_LENGTH, 8),
+		RequireUppercase: parseBoolEnv(types.PASSWORD_REQUIRE_UPPERCASE, false),
+		RequireLowercase: parseBoolEnv(types.PASSWORD_REQUIRE_LOWERCASE, false),
+		RequireDigit:     parseBoolEnv(types.PASSWORD_REQUIRE_DIGIT, false),
+		RequireSymbol:    parseBoolEnv(types.PASSWORD_REQUIRE_SYMBOL, false),
+		DisallowUserInfo: parseBoolEnv(types.PASSWORD_DISALLOW_USER_INFO, true),
+		HistorySize:      parseIntEnv(types.PASSWORD_HISTORY_SIZE, 3),
+	}
+	if path := os.Getenv(types.PASSWORD_COMMON_LIST_FILE); path != "" {
+		if err := policy.LoadCommonPasswords(path); err != nil {
+			log.Fatal("Error loading the common passwords ", err)
+		}
+	}
+	return policy
+}
+
+// LoadCommonPasswords loads the list of the breached or common passwords which are rejected, the file has a
+// password per line, the empty lines and the lines starting with # are skipped
+func (p *Policy) LoadCommonPasswords(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	commonPasswords := map[string]bool{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		commonPasswords[strings.ToLower(line)] = true
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+	p.commonPasswords = commonPasswords
+	log.Infoln("Loaded", len(commonPasswords), "common passwords from", path)
+	return nil
+}
+
+// Validate checks the password against the rules of the policy, userInfo are the username, the name and the emails
+// of the user which the password may not contain. It returns a *ViolationError listing all the violated rules.
+func (p *Policy) Validate(password string, userInfo ...string) error {
+	var violations []Violation
+	if len([]rune(password)) < p.MinLength {
+		violations = append(violations, Violation{
+			Code:    ViolationMinLength,
+			Message: fmt.Sprintf("The password must be at least %d characters long", p.MinLength),
+		})
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	if p.RequireUppercase && !hasUpper {
+		violations = append(violations, Violation{Code: ViolationUppercase, Message: "The password must contain an uppercase letter"})
+	}
+	if p.RequireLowercase && !hasLower {
+		violations = append(violations, Violation{Code: ViolationLowercase, Message: "The password must contain a lowercase letter"})
+	}
+	if p.RequireDigit && !hasDigit {
+		violations = append(violations, Violation{Code: ViolationDigit, Message: "The password must contain a digit"})
+	}
+	if p.RequireSymbol && !hasSymbol {
+		violations = append(violations, Violation{Code: ViolationSymbol, Message: "The password must contain a symbol"})
+	}
+
+	lowerPassword := strings.ToLower(password)
+	if p.DisallowUserInfo && containsUserInfo(lowerPassword, userInfo) {
+		violations = append(violations, Violation{
+			Code:    ViolationUserInfo,
+			Message: "The password must not contain the username, the name or the email",
+		})
+	}
+	if p.commonPasswords[lowerPassword] {
+		violations = append(violations, Violation{
+			Code:    ViolationCommon,
+			Message: "The password is too common or is known to be breached",
+		})
+	}
+
+	if len(violations) > 0 {
+		return &ViolationError{Violations: violations}
+	}
+	return nil
+}
+
+// containsUserInfo checks if the password contains any of the user info or of its words, such as the parts of an email
+func containsUserInfo(lowerPassword string, userInfo []string) bool {
+	for _, info := range userInfo {
+		info = strings.ToLower(info)
+		words := strings.FieldsFunc(info, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, word := range append(words, info) {
+			if len([]rune(word)) >= minUserInfoLength && strings.Contains(lowerPassword, word) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func parseIntEnv(name string, defaultValue int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil || i < 0 {
+		log.Fatal("Error parsing ", name, " ", value)
+	}
+	return i
+}
+
+func parseBoolEnv(name string, defaultValue bool) bool {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Fatal("Error parsing ", name, err)
+	}
+	return b
+}
@@ -0,0 +1,70 @@
+package passwordpolicy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func violationCodes(err error) []string {
+	if err == nil {
+		return nil
+	}
+	var codes []string
+	for _, violation := range err.(*ViolationError).Violations {
+		codes = append(codes, violation.Code)
+	}
+	return codes
+}
+
+func TestValidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "passwordpolicy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	listFile := filepath.Join(dir, "common.txt")
+	if err = ioutil.WriteFile(listFile, []byte("# common passwords\n\nPassword1!\nletmein123\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	policy := &Policy{
+		MinLength:        10,
+		RequireUppercase: true,
+		RequireLowercase: true,
+		RequireDigit:     true,
+		RequireSymbol:    true,
+		DisallowUserInfo: true,
+	}
+	if err = policy.LoadCommonPasswords(listFile); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		password string
+		codes    []string
+	}{
+		{password: "Correct-Horse-7", codes: nil},
+		{password: "Sh0rt!", codes: []string{ViolationMinLength}},
+		{password: "lowercase-only", codes: []string{ViolationUppercase, ViolationDigit}},
+		{password: "UPPERCASE 123", codes: []string{ViolationLowercase}},
+		{password: "NoSymbols1234", codes: []string{ViolationSymbol}},
+		{password: "Hello-Jdoe-42", codes: []string{ViolationUserInfo}},
+		{password: "Example.org-42", codes: []string{ViolationUserInfo}},
+		{password: "PASSWORD1!", codes: []string{ViolationLowercase, ViolationCommon}},
+	}
+	for _, test := range tests {
+		t.Run(test.password, func(t *testing.T) {
+			codes := violationCodes(policy.Validate(test.password, "jdoe", "John Doe", "jdoe@example.org"))
+			if len(codes) != len(test.codes) {
+				t.Fatalf("expected the violations %v, got %v", test.codes, codes)
+			}
+			for i := range codes {
+				if codes[i] != test.codes[i] {
+					t.Fatalf("expected the violations %v, got %v", test.codes, codes)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package saml
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// IdentityProvider is the part of the metadata of the identity provider which is needed for login
+type IdentityProvider struct {
+	EntityID string
+	// SSOURL is the url of the single sign on service with the HTTP-Redirect binding
+	SSOURL string
+	// Certificates are the certificates with which the identity provider signs its responses
+	Certificates []*x509.Certificate
+}
+
+type entityDescriptor struct {
+	EntityID          string             `xml:"entityID,attr"`
+	IDPSSODescriptors []idpSSODescriptor `xml:"IDPSSODescriptor"`
+	// EntityDescriptors are the entities of an EntitiesDescriptor, which some identity providers publish
+	EntityDescriptors []entityDescriptor `xml:"EntityDescriptor"`
+}
+
+type idpSSODescriptor struct {
+	KeyDescriptors       []keyDescriptor `xml:"KeyDescriptor"`
+	SingleSignOnServices []endpoint      `xml:"SingleSignOnService"`
+}
+
+type keyDescriptor struct {
+	Use          string   `xml:"use,attr"`
+	Certificates []string `xml:"KeyInfo>X509Data>X509Certificate"`
+}
+
+type endpoint struct {
+	Binding  string `xml:"Binding,attr"`
+	Location string `xml:"Location,attr"`
+}
+
+// ParseMetadata parses the metadata of an identity provider, either an EntityDescriptor or an EntitiesDescriptor
+// holding the EntityDescriptor of the identity provider
+func ParseMetadata(data []byte) (*IdentityProvider, error) {
+	var root entityDescriptor
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("saml: invalid metadata: %v", err)
+	}
+
+	entities := append([]entityDescriptor{root}, root.EntityDescriptors...)
+	for _, entity := range entities {
+		if len(entity.IDPSSODescriptors) > 0 {
+			return newIdentityProvider(entity.EntityID, entity.IDPSSODescriptors[0])
+		}
+	}
+	return nil, fmt.Errorf("saml: no identity provider in the metadata")
+}
+
+func newIdentityProvider(entityID string, descriptor idpSSODescriptor) (*IdentityProvider, error) {
+	idp := &IdentityProvider{EntityID: entityID}
+	if idp.EntityID == "" {
+		return nil, fmt.Errorf("saml: no entity id in the metadata")
+	}
+
+	for _, service := range descriptor.SingleSignOnServices {
+		if service.Binding == HTTPRedirectBinding {
+			idp.SSOURL = service.Location
+			break
+		}
+	}
+	if idp.SSOURL == "" {
+		return nil, fmt.Errorf("saml: no single sign on service with the HTTP-Redirect binding in the metadata")
+	}
+
+	for _, key := range descriptor.KeyDescriptors {
+		if key.Use != "" && key.Use != "signing" {
+			continue
+		}
+		for _, encoded := range key.Certificates {
+			// The certificates are base64 encoded DER, usually wrapped over several lines
+			der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(encoded), ""))
+			if err != nil {
+				return nil, fmt.Errorf("saml: invalid certificate in the metadata: %v", err)
+			}
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, fmt.Errorf("saml: invalid certificate in the metadata: %v", err)
+			}
+			idp.Certificates = append(idp.Certificates, cert)
+		}
+	}
+	if len(idp.Certificates) == 0 {
+		return nil, fmt.Errorf("saml: no signing certificate in the metadata")
+	}
+	return idp, nil
+}
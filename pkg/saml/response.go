@@ -0,0 +1,243 @@
+package saml
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/russellhaering/goxmldsig/etreeutils"
+)
+
+// ErrEncryptedAssertion is returned for the responses with an encrypted assertion, which are not supported
+var ErrEncryptedAssertion = errors.New("saml: encrypted assertions are not supported")
+
+// StatusError is returned for the responses whose status is not success, such as when the user couldn't be
+// authenticated by the identity provider
+type StatusError struct {
+	Code string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("saml: the status of the response is %s", e.Code)
+}
+
+// Assertion is the subject of a validated assertion along with its attributes
+type Assertion struct {
+	NameID string
+	// Attributes are keyed by both their name and their friendly name
+	Attributes map[string][]string
+}
+
+// Get gives the first value of the attribute, the names of the attributes are case insensitive
+func (a *Assertion) Get(name string) string {
+	if values := a.GetAll(name); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// GetAll gives all the values of the attribute
+func (a *Assertion) GetAll(name string) []string {
+	for attribute, values := range a.Attributes {
+		if strings.EqualFold(attribute, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+// ParseResponse validates the base64 encoded response posted to the assertion consumer service for the
+// AuthnRequest having the request id. Either the response or its assertion has to be signed by the identity
+// provider, only the signed elements are read.
+func (sp *ServiceProvider) ParseResponse(encodedResponse, requestID string) (*Assertion, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(encodedResponse), ""))
+	if err != nil {
+		return nil, fmt.Errorf("saml: invalid encoding of the response: %v", err)
+	}
+	doc := etree.NewDocument()
+	if err = doc.ReadFromBytes(data); err != nil {
+		return nil, fmt.Errorf("saml: invalid response: %v", err)
+	}
+	response := doc.Root()
+	if response == nil || response.Tag != "Response" || response.NamespaceURI() != protocolNamespace {
+		return nil, fmt.Errorf("saml: invalid response")
+	}
+
+	if status := response.FindElement("./Status/StatusCode"); status == nil || status.SelectAttrValue("Value", "") != statusSuccess {
+		code := ""
+		if status != nil {
+			code = status.SelectAttrValue("Value", "")
+		}
+		return nil, &StatusError{Code: code}
+	}
+	if response.FindElement("./EncryptedAssertion") != nil {
+		return nil, ErrEncryptedAssertion
+	}
+
+	validationContext := dsig.NewDefaultValidationContext(&dsig.MemoryX509CertificateStore{
+		Roots: sp.IDP.Certificates,
+	})
+	responseSigned := response.FindElement("./Signature") != nil
+	if responseSigned {
+		if response, err = validationContext.Validate(response); err != nil {
+			return nil, fmt.Errorf("saml: invalid signature of the response: %v", err)
+		}
+	}
+
+	assertions := response.SelectElements("Assertion")
+	if len(assertions) != 1 {
+		return nil, fmt.Errorf("saml: %d assertions found in the response", len(assertions))
+	}
+	assertion := assertions[0]
+	if assertion.FindElement("./Signature") != nil {
+		// The namespaces declared by the response are needed to canonicalize the assertion
+		nsContext, err := etreeutils.NSBuildParentContext(assertion)
+		if err != nil {
+			return nil, err
+		}
+		if assertion, err = etreeutils.NSDetatch(nsContext, assertion); err != nil {
+			return nil, err
+		}
+		if assertion, err = validationContext.Validate(assertion); err != nil {
+			return nil, fmt.Errorf("saml: invalid signature of the assertion: %v", err)
+		}
+	} else if !responseSigned {
+		return nil, fmt.Errorf("saml: neither the response nor the assertion is signed")
+	}
+
+	if err = sp.validateResponse(response, requestID); err != nil {
+		return nil, err
+	}
+	if err = sp.validateAssertion(assertion, requestID, time.Now()); err != nil {
+		return nil, err
+	}
+	return parseAssertion(assertion)
+}
+
+// validateResponse validates the optional attributes of the response, which are compared when given
+func (sp *ServiceProvider) validateResponse(response *etree.Element, requestID string) error {
+	if response.SelectAttrValue("Version", "") != "2.0" {
+		return fmt.Errorf("saml: unsupported version of the response")
+	}
+	if destination := response.SelectAttrValue("Destination", ""); destination != "" && destination != sp.ACSURL {
+		return fmt.Errorf("saml: the destination %s of the response is not the assertion consumer service", destination)
+	}
+	if inResponseTo := response.SelectAttrValue("InResponseTo", ""); inResponseTo != "" && inResponseTo != requestID {
+		return fmt.Errorf("saml: the response is not for the request")
+	}
+	if issuer := response.SelectElement("Issuer"); issuer != nil && strings.TrimSpace(issuer.Text()) != sp.IDP.EntityID {
+		return fmt.Errorf("saml: the issuer of the response is not the identity provider")
+	}
+	return nil
+}
+
+// validateAssertion validates the issuer, the subject confirmation and the conditions of the assertion,
+// a bearer assertion can only be used by the service provider for its request till it expires
+func (sp *ServiceProvider) validateAssertion(assertion *etree.Element, requestID string, now time.Time) error {
+	if assertion.Tag != "Assertion" || assertion.NamespaceURI() != assertionNamespace {
+		return fmt.Errorf("saml: invalid assertion")
+	}
+	if issuer := assertion.SelectElement("Issuer"); issuer == nil || strings.TrimSpace(issuer.Text()) != sp.IDP.EntityID {
+		return fmt.Errorf("saml: the issuer of the assertion is not the identity provider")
+	}
+
+	confirmed := false
+	for _, confirmation := range assertion.FindElements("./Subject/SubjectConfirmation") {
+		if confirmation.SelectAttrValue("Method", "") != bearerConfirmation {
+			continue
+		}
+		data := confirmation.SelectElement("SubjectConfirmationData")
+		if data == nil {
+			continue
+		}
+		notOnOrAfter, err := parseTime(data, "NotOnOrAfter")
+		if err != nil || notOnOrAfter == nil {
+			continue
+		}
+		if data.SelectAttrValue("Recipient", "") == sp.ACSURL && data.SelectAttrValue("InResponseTo", "") == requestID &&
+			now.Before(notOnOrAfter.Add(MaxClockSkew)) {
+			confirmed = true
+			break
+		}
+	}
+	if !confirmed {
+		return fmt.Errorf("saml: the subject of the assertion is not confirmed for the request")
+	}
+
+	conditions := assertion.SelectElement("Conditions")
+	if conditions == nil {
+		return fmt.Errorf("saml: no conditions in the assertion")
+	}
+	notBefore, err := parseTime(conditions, "NotBefore")
+	if err != nil {
+		return err
+	}
+	if notBefore != nil && now.Add(MaxClockSkew).Before(*notBefore) {
+		return fmt.Errorf("saml: the assertion is not yet valid")
+	}
+	notOnOrAfter, err := parseTime(conditions, "NotOnOrAfter")
+	if err != nil {
+		return err
+	}
+	if notOnOrAfter != nil && !now.Before(notOnOrAfter.Add(MaxClockSkew)) {
+		return fmt.Errorf("saml: the assertion has expired")
+	}
+
+	// Each audience restriction has to be met, a restriction is met when any of its audiences is the service provider
+	restrictions := conditions.SelectElements("AudienceRestriction")
+	if len(restrictions) == 0 {
+		return fmt.Errorf("saml: no audience restriction in the assertion")
+	}
+	for _, restriction := range restrictions {
+		allowed := false
+		for _, audience := range restriction.SelectElements("Audience") {
+			if strings.TrimSpace(audience.Text()) == sp.EntityID {
+				allowed = true
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("saml: the service provider is not an audience of the assertion")
+		}
+	}
+	return nil
+}
+
+func parseAssertion(assertion *etree.Element) (*Assertion, error) {
+	nameID := assertion.FindElement("./Subject/NameID")
+	if nameID == nil || strings.TrimSpace(nameID.Text()) == "" {
+		return nil, fmt.Errorf("saml: no name id in the subject of the assertion")
+	}
+
+	result := &Assertion{
+		NameID:     strings.TrimSpace(nameID.Text()),
+		Attributes: map[string][]string{},
+	}
+	for _, attribute := range assertion.FindElements("./AttributeStatement/Attribute") {
+		var values []string
+		for _, value := range attribute.SelectElements("AttributeValue") {
+			values = append(values, strings.TrimSpace(value.Text()))
+		}
+		for _, name := range []string{attribute.SelectAttrValue("Name", ""), attribute.SelectAttrValue("FriendlyName", "")} {
+			if name != "" {
+				result.Attributes[name] = append(result.Attributes[name], values...)
+			}
+		}
+	}
+	return result, nil
+}
+
+func parseTime(el *etree.Element, attribute string) (*time.Time, error) {
+	value := el.SelectAttrValue(attribute, "")
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("saml: invalid %s: %v", attribute, err)
+	}
+	return &t, nil
+}
@@ -0,0 +1,142 @@
+// saml implements the service provider side of the SAML 2.0 web browser SSO profile: the metadata of the
+// service provider, the AuthnRequest sent with the HTTP-Redirect binding and the validation of the signed
+// responses received with the HTTP-POST binding. Encrypted assertions are not supported.
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"encoding/xml"
+	"net/url"
+	"time"
+)
+
+// The namespaces and bindings of the SAML 2.0 core and bindings specifications
+const (
+	protocolNamespace  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	assertionNamespace = "urn:oasis:names:tc:SAML:2.0:assertion"
+
+	// HTTPRedirectBinding sends the message in the query of a redirect
+	HTTPRedirectBinding = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	// HTTPPostBinding sends the message in a form posted by the browser
+	HTTPPostBinding = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+
+	statusSuccess      = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	bearerConfirmation = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+)
+
+// MaxClockSkew is the difference tolerated between the clocks of the identity provider and kubera
+const MaxClockSkew = time.Minute * 3
+
+// ServiceProvider is kubera as the service provider of an identity provider
+type ServiceProvider struct {
+	// EntityID identifies kubera to the identity provider, it is the audience of the assertions
+	EntityID string
+	// ACSURL is the url of the assertion consumer service to which the responses are posted
+	ACSURL string
+	IDP    *IdentityProvider
+}
+
+type spMetadata struct {
+	XMLName         xml.Name        `xml:"urn:oasis:names:tc:SAML:2.0:metadata EntityDescriptor"`
+	EntityID        string          `xml:"entityID,attr"`
+	SPSSODescriptor spSSODescriptor `xml:"SPSSODescriptor"`
+}
+
+type spSSODescriptor struct {
+	AuthnRequestsSigned        bool              `xml:"AuthnRequestsSigned,attr"`
+	WantAssertionsSigned       bool              `xml:"WantAssertionsSigned,attr"`
+	ProtocolSupportEnumeration string            `xml:"protocolSupportEnumeration,attr"`
+	AssertionConsumerServices  []indexedEndpoint `xml:"AssertionConsumerService"`
+}
+
+type indexedEndpoint struct {
+	Binding   string `xml:"Binding,attr"`
+	Location  string `xml:"Location,attr"`
+	Index     int    `xml:"index,attr"`
+	IsDefault bool   `xml:"isDefault,attr"`
+}
+
+type authnRequest struct {
+	XMLName                     xml.Name     `xml:"urn:oasis:names:tc:SAML:2.0:protocol AuthnRequest"`
+	ID                          string       `xml:"ID,attr"`
+	Version                     string       `xml:"Version,attr"`
+	IssueInstant                string       `xml:"IssueInstant,attr"`
+	Destination                 string       `xml:"Destination,attr"`
+	AssertionConsumerServiceURL string       `xml:"AssertionConsumerServiceURL,attr"`
+	ProtocolBinding             string       `xml:"ProtocolBinding,attr"`
+	Issuer                      issuer       `xml:"urn:oasis:names:tc:SAML:2.0:assertion Issuer"`
+	NameIDPolicy                nameIDPolicy `xml:"NameIDPolicy"`
+}
+
+type issuer struct {
+	Value string `xml:",chardata"`
+}
+
+type nameIDPolicy struct {
+	AllowCreate bool `xml:"AllowCreate,attr"`
+}
+
+// Metadata gives the metadata of the service provider which is registered with the identity provider
+func (sp *ServiceProvider) Metadata() ([]byte, error) {
+	metadata := spMetadata{
+		EntityID: sp.EntityID,
+		SPSSODescriptor: spSSODescriptor{
+			WantAssertionsSigned:       true,
+			ProtocolSupportEnumeration: protocolNamespace,
+			AssertionConsumerServices: []indexedEndpoint{
+				{Binding: HTTPPostBinding, Location: sp.ACSURL, Index: 1, IsDefault: true},
+			},
+		},
+	}
+	data, err := xml.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// AuthnRequestURL gives the url of the single sign on service of the identity provider with an AuthnRequest,
+// the identity provider posts its response with the relay state back to the assertion consumer service
+func (sp *ServiceProvider) AuthnRequestURL(requestID, relayState string) (string, error) {
+	request := authnRequest{
+		ID:                          requestID,
+		Version:                     "2.0",
+		IssueInstant:                time.Now().UTC().Format(time.RFC3339),
+		Destination:                 sp.IDP.SSOURL,
+		AssertionConsumerServiceURL: sp.ACSURL,
+		ProtocolBinding:             HTTPPostBinding,
+		Issuer:                      issuer{Value: sp.EntityID},
+		NameIDPolicy:                nameIDPolicy{AllowCreate: true},
+	}
+	data, err := xml.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+
+	// The HTTP-Redirect binding deflates the message before encoding it
+	var buf bytes.Buffer
+	writer, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err = writer.Write(data); err != nil {
+		return "", err
+	}
+	if err = writer.Close(); err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(sp.IDP.SSOURL)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set("SAMLRequest", base64.StdEncoding.EncodeToString(buf.Bytes()))
+	if relayState != "" {
+		query.Set("RelayState", relayState)
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
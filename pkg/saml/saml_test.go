@@ -0,0 +1,224 @@
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+const (
+	testIDPEntityID = "https://idp.example.org/metadata"
+	testRequestID   = "_request"
+)
+
+func newTestServiceProvider(t *testing.T, keyStore dsig.X509KeyStore) *ServiceProvider {
+	_, cert, err := keyStore.GetKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadata := `<EntitiesDescriptor xmlns="urn:oasis:names:tc:SAML:2.0:metadata">
+  <EntityDescriptor entityID="` + testIDPEntityID + `">
+    <IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+      <KeyDescriptor use="signing">
+        <KeyInfo xmlns="http://www.w3.org/2000/09/xmldsig#">
+          <X509Data><X509Certificate>
+            ` + base64.StdEncoding.EncodeToString(cert) + `
+          </X509Certificate></X509Data>
+        </KeyInfo>
+      </KeyDescriptor>
+      <SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="https://idp.example.org/sso/post"/>
+      <SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://idp.example.org/sso?tenant=kubera"/>
+    </IDPSSODescriptor>
+  </EntityDescriptor>
+</EntitiesDescriptor>`
+	idp, err := ParseMetadata([]byte(metadata))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idp.EntityID != testIDPEntityID || idp.SSOURL != "https://idp.example.org/sso?tenant=kubera" || len(idp.Certificates) != 1 {
+		t.Fatalf("unexpected identity provider %+v", idp)
+	}
+	return &ServiceProvider{
+		EntityID: "https://kubera.example.org/v1/saml/metadata",
+		ACSURL:   "https://kubera.example.org/v1/saml/acs",
+		IDP:      idp,
+	}
+}
+
+// testResponse describes the response which the identity provider would post to the assertion consumer service
+type testResponse struct {
+	audience      string
+	inResponseTo  string
+	notOnOrAfter  time.Time
+	signResponse  bool
+	signAssertion bool
+}
+
+func (r testResponse) encode(t *testing.T, keyStore dsig.X509KeyStore, sp *ServiceProvider) string {
+	now := time.Now().UTC()
+	doc := etree.NewDocument()
+	response := doc.CreateElement("samlp:Response")
+	response.CreateAttr("xmlns:samlp", protocolNamespace)
+	response.CreateAttr("xmlns:saml", assertionNamespace)
+	response.CreateAttr("ID", "_response")
+	response.CreateAttr("Version", "2.0")
+	response.CreateAttr("Destination", sp.ACSURL)
+	response.CreateAttr("InResponseTo", r.inResponseTo)
+	response.CreateElement("saml:Issuer").SetText(testIDPEntityID)
+	response.CreateElement("samlp:Status").CreateElement("samlp:StatusCode").CreateAttr("Value", statusSuccess)
+
+	assertion := response.CreateElement("saml:Assertion")
+	// The identity providers declare the namespace on the assertion as well, to sign it on its own
+	assertion.CreateAttr("xmlns:saml", assertionNamespace)
+	assertion.CreateAttr("ID", "_assertion")
+	assertion.CreateAttr("Version", "2.0")
+	assertion.CreateElement("saml:Issuer").SetText(testIDPEntityID)
+	subject := assertion.CreateElement("saml:Subject")
+	subject.CreateElement("saml:NameID").SetText("jdoe")
+	confirmation := subject.CreateElement("saml:SubjectConfirmation")
+	confirmation.CreateAttr("Method", bearerConfirmation)
+	data := confirmation.CreateElement("saml:SubjectConfirmationData")
+	data.CreateAttr("InResponseTo", r.inResponseTo)
+	data.CreateAttr("Recipient", sp.ACSURL)
+	data.CreateAttr("NotOnOrAfter", r.notOnOrAfter.Format(time.RFC3339))
+	conditions := assertion.CreateElement("saml:Conditions")
+	conditions.CreateAttr("NotBefore", now.Add(-time.Minute).Format(time.RFC3339))
+	conditions.CreateAttr("NotOnOrAfter", r.notOnOrAfter.Format(time.RFC3339))
+	conditions.CreateElement("saml:AudienceRestriction").CreateElement("saml:Audience").SetText(r.audience)
+	attribute := assertion.CreateElement("saml:AttributeStatement").CreateElement("saml:Attribute")
+	attribute.CreateAttr("Name", "urn:oid:0.9.2342.19200300.100.1.3")
+	attribute.CreateAttr("FriendlyName", "mail")
+	attribute.CreateElement("saml:AttributeValue").SetText("jdoe@example.org")
+
+	signingContext := dsig.NewDefaultSigningContext(keyStore)
+	signingContext.Canonicalizer = dsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("")
+	if r.signAssertion {
+		signed, err := signingContext.SignEnveloped(assertion)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.RemoveChild(assertion)
+		response.AddChild(signed)
+	}
+	if r.signResponse {
+		signed, err := signingContext.SignEnveloped(response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc.SetRoot(signed)
+	}
+
+	encoded, err := doc.WriteToString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString([]byte(encoded))
+}
+
+func TestAuthnRequestURL(t *testing.T) {
+	sp := newTestServiceProvider(t, dsig.RandomKeyStoreForTest())
+	authnRequestURL, err := sp.AuthnRequestURL(testRequestID, "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse(authnRequestURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Host != "idp.example.org" || u.Query().Get("tenant") != "kubera" || u.Query().Get("RelayState") != "state" {
+		t.Fatalf("unexpected url %s", authnRequestURL)
+	}
+	deflated, err := base64.StdEncoding.DecodeString(u.Query().Get("SAMLRequest"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request, err := ioutil.ReadAll(flate.NewReader(bytes.NewReader(deflated)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{`ID="_request"`, `AssertionConsumerServiceURL="` + sp.ACSURL + `"`, sp.EntityID} {
+		if !strings.Contains(string(request), expected) {
+			t.Errorf("expected %s in the request %s", expected, request)
+		}
+	}
+}
+
+func TestParseResponse(t *testing.T) {
+	keyStore := dsig.RandomKeyStoreForTest()
+	sp := newTestServiceProvider(t, keyStore)
+	valid := testResponse{
+		audience:      sp.EntityID,
+		inResponseTo:  testRequestID,
+		notOnOrAfter:  time.Now().Add(time.Minute * 5),
+		signAssertion: true,
+	}
+
+	tests := []struct {
+		name     string
+		response func(t *testing.T) string
+		valid    bool
+	}{
+		{name: "signed assertion", valid: true, response: func(t *testing.T) string {
+			return valid.encode(t, keyStore, sp)
+		}},
+		{name: "signed response", valid: true, response: func(t *testing.T) string {
+			response := valid
+			response.signAssertion, response.signResponse = false, true
+			return response.encode(t, keyStore, sp)
+		}},
+		{name: "unsigned", response: func(t *testing.T) string {
+			response := valid
+			response.signAssertion = false
+			return response.encode(t, keyStore, sp)
+		}},
+		{name: "signed by another key", response: func(t *testing.T) string {
+			return valid.encode(t, dsig.RandomKeyStoreForTest(), sp)
+		}},
+		{name: "tampered", response: func(t *testing.T) string {
+			data, _ := base64.StdEncoding.DecodeString(valid.encode(t, keyStore, sp))
+			tampered := strings.Replace(string(data), "jdoe@example.org", "admin@example.org", 1)
+			return base64.StdEncoding.EncodeToString([]byte(tampered))
+		}},
+		{name: "another audience", response: func(t *testing.T) string {
+			response := valid
+			response.audience = "https://another.example.org"
+			return response.encode(t, keyStore, sp)
+		}},
+		{name: "another request", response: func(t *testing.T) string {
+			response := valid
+			response.inResponseTo = "_another"
+			return response.encode(t, keyStore, sp)
+		}},
+		{name: "expired", response: func(t *testing.T) string {
+			response := valid
+			response.notOnOrAfter = time.Now().Add(-time.Minute * 10)
+			return response.encode(t, keyStore, sp)
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assertion, err := sp.ParseResponse(test.response(t), testRequestID)
+			if !test.valid {
+				if err == nil {
+					t.Fatal("expected the response to be rejected")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if assertion.NameID != "jdoe" || assertion.Get("MAIL") != "jdoe@example.org" || assertion.Get("urn:oid:0.9.2342.19200300.100.1.3") != "jdoe@example.org" {
+				t.Errorf("unexpected assertion %+v", assertion)
+			}
+		})
+	}
+}
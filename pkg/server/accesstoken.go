@@ -0,0 +1,69 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mayadata-io/kubera-auth/manager/accesstokenmanager"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/types"
+)
+
+// GetPersonalAccessTokensRequest lists the personal access tokens of the user
+func (s *Server) GetPersonalAccessTokensRequest(c *gin.Context) {
+	jwtUser, exists := c.Get(types.JWTUserCredentialsKey)
+	if !exists {
+		s.errorResponse(c, errors.ErrInvalidAccessToken)
+		return
+	}
+	jwtUserCredentials := jwtUser.(*models.UserCredentials)
+
+	tokens, err := accesstokenmanager.GetTokens(s.personalAccessTokenStore, jwtUserCredentials)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	s.successResponse(c, tokens)
+}
+
+// CreatePersonalAccessTokenRequest creates a personal access token of the user. The token is part of this
+// response only.
+func (s *Server) CreatePersonalAccessTokenRequest(c *gin.Context, name, scope string, expiryDays int) {
+	jwtUser, exists := c.Get(types.JWTUserCredentialsKey)
+	if !exists {
+		s.errorResponse(c, errors.ErrInvalidAccessToken)
+		return
+	}
+	jwtUserCredentials := jwtUser.(*models.UserCredentials)
+
+	accessToken, token, err := accesstokenmanager.CreateToken(s.personalAccessTokenStore, jwtUserCredentials, name, scope, expiryDays)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	s.successResponse(c, struct {
+		*models.PersonalAccessToken
+		Token string `json:"token"`
+	}{accessToken, token})
+}
+
+// DeletePersonalAccessTokenRequest revokes a personal access token of the user
+func (s *Server) DeletePersonalAccessTokenRequest(c *gin.Context, tokenID string) {
+	jwtUser, exists := c.Get(types.JWTUserCredentialsKey)
+	if !exists {
+		s.errorResponse(c, errors.ErrInvalidAccessToken)
+		return
+	}
+	jwtUserCredentials := jwtUser.(*models.UserCredentials)
+
+	err := accesstokenmanager.RevokeToken(s.personalAccessTokenStore, jwtUserCredentials, tokenID)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Token revoked successfully",
+	})
+}
@@ -2,58 +2,151 @@ package server
 
 import (
 	"log"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/dgrijalva/jwt-go"
+
+	"github.com/mayadata-io/kubera-auth/pkg/models"
 	"github.com/mayadata-io/kubera-auth/pkg/types"
 )
 
 // Config configuration parameters
 type Config struct {
-	TokenType         string // token type
-	DisableLocalAuth  bool
-	DisableGithubAuth bool
-	DisableGoogleAuth bool
+	TokenType     string // token type
+	SigningMethod jwt.SigningMethod
+	// KeyRotationInterval is the age after which the signing key is rotated, 0 disables the scheduled rotation
+	KeyRotationInterval  time.Duration
+	DisableLocalAuth     bool
+	DisableGithubAuth    bool
+	DisableGoogleAuth    bool
+	DisableGitlabAuth    bool
+	DisableBitbucketAuth bool
+	DisableMagicLinkAuth bool
+	DisableLDAPAuth      bool
+	DisableSAMLAuth      bool
+	// EnforceAdminMFA requires the admins to login with multi-factor authentication
+	EnforceAdminMFA bool
+	// Issuer is the public url of kubera-auth, used as issuer of the access tokens and the OpenID Connect id tokens
+	Issuer string
+	// Audience is the audience of kubera-auth itself, only the tokens meant for it are accepted
+	Audience string
+	// LoginAudiences are the services accepting the login tokens of the portal
+	LoginAudiences []string
+	// AllowedRedirectURLs are the urls, besides the portal, to which the user may be sent after a social login
+	AllowedRedirectURLs []string
+	// EmailLinkProviders are the providers trusted to verify the emails, a new account of these providers is linked
+	// to the user owning its email. The accounts of the other providers have to be linked explicitly by the user.
+	EmailLinkProviders []models.AuthType
+	// WebAuthnRPID is the domain to which the webauthn credentials are bound, the host of the portal by default
+	WebAuthnRPID string
+	// WebAuthnOrigins are the origins from which the webauthn ceremonies are accepted, the portal by default
+	WebAuthnOrigins []string
 }
 
 // NewConfig create to configuration instance
 func NewConfig() *Config {
 	config := &Config{
-		TokenType: types.BEARER,
+		TokenType:     types.BEARER,
+		SigningMethod: jwt.SigningMethodHS512,
+		Issuer:        types.PortalURL + "/api/auth",
+		Audience:      "kubera-auth",
 	}
 	var err error
-	// TODO: Think of something to do away of repetitive code
-	disableGithubAuth := os.Getenv(types.DISABLE_GITHUBAUTH)
-	disableGoogleAuth := os.Getenv(types.DISABLE_GOOGLEAUTH)
-	disableLocalAuth := os.Getenv(types.DISABLE_LOCALAUTH)
-	if disableLocalAuth == "" {
-		// Will be enabled by default
-		config.DisableLocalAuth = false
-	} else {
-		config.DisableLocalAuth, err = strconv.ParseBool(disableLocalAuth)
-		if err != nil {
-			log.Fatal("Error parsing ", types.DISABLE_LOCALAUTH, err)
+	// Local auth will be enabled by default, the social logins will be disabled by default
+	config.DisableLocalAuth = parseBoolEnv(types.DISABLE_LOCALAUTH, false)
+	config.DisableGoogleAuth = parseBoolEnv(types.DISABLE_GOOGLEAUTH, true)
+	config.DisableGithubAuth = parseBoolEnv(types.DISABLE_GITHUBAUTH, true)
+	config.DisableGitlabAuth = parseBoolEnv(types.DISABLE_GITLABAUTH, true)
+	config.DisableBitbucketAuth = parseBoolEnv(types.DISABLE_BITBUCKETAUTH, true)
+	config.DisableMagicLinkAuth = parseBoolEnv(types.DISABLE_MAGICLINKAUTH, true)
+	config.DisableLDAPAuth = parseBoolEnv(types.DISABLE_LDAPAUTH, true)
+	config.DisableSAMLAuth = parseBoolEnv(types.DISABLE_SAMLAUTH, true)
+	config.EnforceAdminMFA = parseBoolEnv(types.ENFORCE_ADMIN_MFA, false)
+
+	if signingAlgorithm := os.Getenv(types.JWT_SIGNING_ALGORITHM); signingAlgorithm != "" {
+		config.SigningMethod = jwt.GetSigningMethod(signingAlgorithm)
+		if config.SigningMethod == nil {
+			log.Fatal("Unsupported ", types.JWT_SIGNING_ALGORITHM, " ", signingAlgorithm)
 		}
 	}
 
-	if disableGoogleAuth == "" {
-		// Will be disabled by default
-		config.DisableGoogleAuth = true
-	} else {
-		config.DisableGoogleAuth, err = strconv.ParseBool(disableGoogleAuth)
+	if keyRotationInterval := os.Getenv(types.JWT_KEY_ROTATION_INTERVAL); keyRotationInterval != "" {
+		config.KeyRotationInterval, err = time.ParseDuration(keyRotationInterval)
 		if err != nil {
-			log.Fatal("Error parsing ", types.DISABLE_GOOGLEAUTH, err)
+			log.Fatal("Error parsing ", types.JWT_KEY_ROTATION_INTERVAL, err)
 		}
 	}
 
-	if disableGithubAuth == "" {
-		// Will be disabled by default
-		config.DisableGithubAuth = true
-	} else {
-		config.DisableGithubAuth, err = strconv.ParseBool(disableGithubAuth)
-		if err != nil {
-			log.Fatal("Error parsing ", types.DISABLE_GITHUBAUTH, err)
+	if issuer := os.Getenv(types.ISSUER_URL); issuer != "" {
+		config.Issuer = strings.TrimSuffix(issuer, "/")
+	}
+
+	if audience := os.Getenv(types.JWT_AUDIENCE); audience != "" {
+		config.Audience = audience
+	}
+	loginAudiences := os.Getenv(types.JWT_LOGIN_AUDIENCES)
+	if loginAudiences == "" {
+		loginAudiences = "kubera"
+	}
+	for _, audience := range strings.Split(loginAudiences, ",") {
+		if audience = strings.TrimSpace(audience); audience != "" {
+			config.LoginAudiences = append(config.LoginAudiences, audience)
+		}
+	}
+
+	for _, allowedURL := range strings.Split(os.Getenv(types.ALLOWED_REDIRECT_URLS), ",") {
+		if allowedURL = strings.TrimSpace(allowedURL); allowedURL != "" {
+			config.AllowedRedirectURLs = append(config.AllowedRedirectURLs, allowedURL)
+		}
+	}
+
+	for _, provider := range strings.Split(os.Getenv(types.EMAIL_LINK_PROVIDERS), ",") {
+		if provider = strings.TrimSpace(provider); provider != "" {
+			config.EmailLinkProviders = append(config.EmailLinkProviders, models.AuthType(provider))
+		}
+	}
+
+	config.WebAuthnRPID = os.Getenv(types.WEBAUTHN_RP_ID)
+	if portalURL, err := url.Parse(types.PortalURL); err == nil {
+		if config.WebAuthnRPID == "" {
+			config.WebAuthnRPID = portalURL.Hostname()
+		}
+		config.WebAuthnOrigins = []string{portalURL.Scheme + "://" + portalURL.Host}
+	}
+	if origins := os.Getenv(types.WEBAUTHN_ORIGINS); origins != "" {
+		config.WebAuthnOrigins = nil
+		for _, origin := range strings.Split(origins, ",") {
+			if origin = strings.TrimSuffix(strings.TrimSpace(origin), "/"); origin != "" {
+				config.WebAuthnOrigins = append(config.WebAuthnOrigins, origin)
+			}
 		}
 	}
 	return config
 }
+
+// linksEmail checks if the accounts of the provider are linked to the user owning their email
+func (c *Config) linksEmail(provider models.AuthType) bool {
+	for _, p := range c.EmailLinkProviders {
+		if p == provider {
+			return true
+		}
+	}
+	return false
+}
+
+// parseBoolEnv parses the boolean environment variable, giving the default value if it is not set
+func parseBoolEnv(name string, defaultValue bool) bool {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Fatal("Error parsing ", name, err)
+	}
+	return b
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mayadata-io/kubera-auth/manager/devicemanager"
+	"github.com/mayadata-io/kubera-auth/manager/loginmanager"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/types"
+)
+
+// DeviceCodeRequest starts the login of a device such as the CLI, which shows the user code and the verification
+// uri to the user and then polls the token endpoint with the device code
+func (s *Server) DeviceCodeRequest(c *gin.Context, scope string) {
+	authorization, deviceCode, err := devicemanager.CreateDeviceAuthorization(s.deviceAuthorizationStore, scope)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+
+	userCode := devicemanager.FormatUserCode(authorization.UserCode)
+	verificationURI := s.Config.Issuer + "/v1/device"
+	values := url.Values{}
+	values.Set("user_code", userCode)
+	s.successResponse(c, gin.H{
+		"device_code":               deviceCode,
+		"user_code":                 userCode,
+		"verification_uri":          verificationURI,
+		"verification_uri_complete": verificationURI + "?" + values.Encode(),
+		"expires_in":                int64(devicemanager.DeviceCodeExp / time.Second),
+		"interval":                  authorization.Interval,
+	})
+}
+
+// DeviceVerificationRequest sends the user to the device page of the portal, where the user logs in and
+// approves or denies the device, see DecideDeviceRequest
+func (s *Server) DeviceVerificationRequest(c *gin.Context, userCode string) {
+	values := url.Values{}
+	if userCode != "" {
+		values.Set("user_code", userCode)
+	}
+	c.Redirect(http.StatusFound, types.PortalURL+"/device?"+values.Encode())
+}
+
+// DecideDeviceRequest approves the login of the device showing the user code for the user sending the request,
+// or denies it
+func (s *Server) DecideDeviceRequest(c *gin.Context, userCode string, approved bool) {
+	jwtUser, exists := c.Get(types.JWTUserCredentialsKey)
+	if !exists {
+		s.errorResponse(c, errors.ErrInvalidAccessToken)
+		return
+	}
+	jwtUserCredentials := jwtUser.(*models.UserCredentials)
+
+	authorization, err := devicemanager.DecideDeviceAuthorization(s.deviceAuthorizationStore, userCode, jwtUserCredentials, approved)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	s.successResponse(c, gin.H{
+		"status": authorization.Status,
+		"scope":  authorization.Scope,
+	})
+}
+
+// DeviceCodeLoginRequest gives the tokens of the device once its user has approved it
+func (s *Server) DeviceCodeLoginRequest(c *gin.Context, deviceCode string) {
+	tokenInfo, err := loginmanager.DeviceCodeLoginUser(s.userStore, s.refreshTokenStore, s.deviceAuthorizationStore, s.accessGenerate, deviceCode)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	s.successResponse(c, s.getTokenData(tokenInfo))
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/mayadata-io/kubera-auth/manager/usermanager"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/types"
+)
+
+// GetIdentitiesRequest lists the identities linked to the user
+func (s *Server) GetIdentitiesRequest(c *gin.Context) {
+	jwtUser, exists := c.Get(types.JWTUserCredentialsKey)
+	if !exists {
+		s.errorResponse(c, errors.ErrInvalidAccessToken)
+		return
+	}
+	jwtUserCredentials := jwtUser.(*models.UserCredentials)
+
+	identities := jwtUserCredentials.Identities
+	if identities == nil {
+		identities = []models.Identity{}
+	}
+	s.successResponse(c, identities)
+}
+
+// LinkIdentityRequest starts linking an account of the provider to the user. The response gives the url of
+// the login page of the provider, to which the browser has to navigate, the state cookie is set on the browser.
+func (s *Server) LinkIdentityRequest(c *gin.Context, authType models.AuthType, redirectTo string) {
+	jwtUser, exists := c.Get(types.JWTUserCredentialsKey)
+	if !exists {
+		s.errorResponse(c, errors.ErrInvalidAccessToken)
+		return
+	}
+	jwtUserCredentials := jwtUser.(*models.UserCredentials)
+
+	provider, ok := s.Providers.Get(authType)
+	if !ok {
+		s.errorResponse(c, errors.ErrInvalidRequest)
+		return
+	}
+
+	authCodeURL, err := s.startSocialLogin(c, provider, redirectTo, jwtUserCredentials.UID)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	s.successResponse(c, gin.H{
+		"url": authCodeURL,
+	})
+}
+
+// UnlinkIdentityRequest removes the identity of the provider from the user
+func (s *Server) UnlinkIdentityRequest(c *gin.Context, authType models.AuthType) {
+	jwtUser, exists := c.Get(types.JWTUserCredentialsKey)
+	if !exists {
+		s.errorResponse(c, errors.ErrInvalidAccessToken)
+		return
+	}
+	jwtUserCredentials := jwtUser.(*models.UserCredentials)
+
+	user, err := usermanager.UnlinkIdentity(s.userStore, jwtUserCredentials.UID, authType)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	s.successResponse(c, user)
+}
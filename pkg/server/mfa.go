@@ -0,0 +1,129 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mayadata-io/kubera-auth/manager/jwtmanager"
+	"github.com/mayadata-io/kubera-auth/manager/loginmanager"
+	"github.com/mayadata-io/kubera-auth/manager/mfamanager"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/types"
+)
+
+// getMFAUser gives the user enrolling to MFA, either logged in or holding the MFA challenge token which the
+// admins get on login while MFA is enforced but not yet enabled for them. The challenge token is given back.
+func (s *Server) getMFAUser(c *gin.Context) (*models.UserCredentials, string, error) {
+	if jwtUser, exists := c.Get(types.JWTUserCredentialsKey); exists {
+		return jwtUser.(*models.UserCredentials), "", nil
+	}
+
+	auth := c.Request.Header.Get(types.AuthHeaderKey)
+	if !strings.HasPrefix(auth, types.AuthHeaderPrefix) {
+		return nil, "", errors.ErrInvalidAccessToken
+	}
+	token := auth[len(types.AuthHeaderPrefix):]
+
+	if user, err := jwtmanager.ParseLoginToken(s.userStore, s.revocationStore, s.accessGenerate, token, ""); err == nil {
+		return user, "", nil
+	}
+	user, err := jwtmanager.ParseMFAToken(s.userStore, s.revocationStore, s.accessGenerate, token)
+	if err != nil {
+		return nil, "", err
+	}
+	return user, token, nil
+}
+
+// GetMFARequest gives the MFA status of the user
+func (s *Server) GetMFARequest(c *gin.Context) {
+	jwtUser, exists := c.Get(types.JWTUserCredentialsKey)
+	if !exists {
+		s.errorResponse(c, errors.ErrInvalidAccessToken)
+		return
+	}
+	jwtUserCredentials := jwtUser.(*models.UserCredentials)
+
+	status := gin.H{
+		"enabled":             false,
+		"enforced":            s.Config.EnforceAdminMFA && jwtUserCredentials.Role == models.RoleAdmin,
+		"recovery_codes_left": 0,
+		"methods":             mfamanager.Methods(jwtUserCredentials),
+	}
+	if jwtUserCredentials.MFA != nil && jwtUserCredentials.MFA.Enabled {
+		status["enabled"] = true
+		status["recovery_codes_left"] = len(jwtUserCredentials.MFA.RecoveryCodeHashes)
+	}
+	s.successResponse(c, status)
+}
+
+// StartMFAEnrollmentRequest generates the secret which the user has to add to an authenticator app
+func (s *Server) StartMFAEnrollmentRequest(c *gin.Context) {
+	user, mfaToken, err := s.getMFAUser(c)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	// The challenge token only proves the password, the users having a second factor can't enroll another one with it
+	if mfaToken != "" && mfamanager.IsEnrolled(user) {
+		s.errorResponse(c, errors.ErrMFAEnabled)
+		return
+	}
+
+	enrollment, err := mfamanager.StartEnrollment(s.userStore, user)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	s.successResponse(c, enrollment)
+}
+
+// ConfirmMFAEnrollmentRequest enables MFA for the user with a code of the authenticator app and gives the
+// recovery codes. The users enrolling with an MFA challenge token are logged in as well.
+func (s *Server) ConfirmMFAEnrollmentRequest(c *gin.Context, code string) {
+	user, mfaToken, err := s.getMFAUser(c)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	if mfaToken != "" && mfamanager.IsEnrolled(user) {
+		s.errorResponse(c, errors.ErrMFAEnabled)
+		return
+	}
+
+	recoveryCodes, err := mfamanager.ConfirmEnrollment(s.userStore, user, code)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+
+	data := map[string]interface{}{}
+	if mfaToken != "" {
+		tokenInfo, err := loginmanager.CompleteMFALogin(s.userStore, s.refreshTokenStore, s.revocationStore, s.accessGenerate, user, mfaToken)
+		if err != nil {
+			s.errorResponse(c, err)
+			return
+		}
+		data = s.getTokenData(tokenInfo)
+	}
+	data["recovery_codes"] = recoveryCodes
+	s.successResponse(c, data)
+}
+
+// DisableMFARequest disables MFA for the user with a code of the authenticator app or a recovery code
+func (s *Server) DisableMFARequest(c *gin.Context, code string) {
+	jwtUser, exists := c.Get(types.JWTUserCredentialsKey)
+	if !exists {
+		s.errorResponse(c, errors.ErrInvalidAccessToken)
+		return
+	}
+	jwtUserCredentials := jwtUser.(*models.UserCredentials)
+
+	err := mfamanager.Disable(s.userStore, jwtUserCredentials, code, s.Config.EnforceAdminMFA)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	s.successResponse(c, jwtUserCredentials.GetPublicInfo())
+}
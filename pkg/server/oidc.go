@@ -0,0 +1,296 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mayadata-io/kubera-auth/manager/accesstokenmanager"
+	"github.com/mayadata-io/kubera-auth/manager/jwtmanager"
+	"github.com/mayadata-io/kubera-auth/manager/oidcmanager"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/types"
+)
+
+// checkOIDCProvider checks whether kubera-auth can act as an OpenID Connect provider, which requires the id tokens
+// to be signed with an asymmetric key the clients can verify. No authorization is started or granted otherwise, so
+// that no code is ever redeemed for tokens which can't be issued.
+func (s *Server) checkOIDCProvider() error {
+	if s.accessGenerate.IDTokenSigningAlgorithm() == "" {
+		return errors.ErrSymmetricIDToken
+	}
+	return nil
+}
+
+// DiscoveryRequest responds with the OpenID Connect provider metadata
+func (s *Server) DiscoveryRequest(c *gin.Context) {
+	if err := s.checkOIDCProvider(); err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+
+	issuer := s.Config.Issuer
+	signingAlgorithms := []string{s.accessGenerate.IDTokenSigningAlgorithm()}
+
+	s.successResponse(c, gin.H{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/authorize",
+		"token_endpoint":                        issuer + "/token",
+		"userinfo_endpoint":                     issuer + "/userinfo",
+		"jwks_uri":                              issuer + "/.well-known/jwks.json",
+		"response_types_supported":              []string{oidcmanager.ResponseTypeCode},
+		"grant_types_supported":                 []models.GrantType{models.AuthorizationCodeGrant, models.RefreshTokenGrant},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": signingAlgorithms,
+		"scopes_supported":                      oidcmanager.SupportedScopes,
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":      []string{oidcmanager.CodeChallengeMethodS256},
+		"claims_supported":                      []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "name", "preferred_username", "picture", "email", "email_verified"},
+	})
+}
+
+// AuthorizeRequest validates the authorization request of a client and sends the user to the portal to login.
+// The portal approves the request once the user has logged in, see ApproveAuthorizationRequest.
+func (s *Server) AuthorizeRequest(c *gin.Context) {
+	if err := s.checkOIDCProvider(); err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+
+	request, err := oidcmanager.CreateAuthorizationRequest(s.clientStore, s.authorizationRequestStore, c.Request.URL.Query())
+	if authErr, ok := err.(*oidcmanager.AuthorizationError); ok {
+		c.Redirect(http.StatusFound, authErr.RedirectURL())
+		return
+	} else if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+
+	values := url.Values{}
+	values.Set("authorization_request", request.RequestID)
+	c.Redirect(http.StatusFound, types.PortalURL+"/login?"+values.Encode())
+}
+
+// ApproveAuthorizationRequest issues an authorization code for the logged in user and responds
+// with the redirect uri of the client, to which the portal has to send the user
+func (s *Server) ApproveAuthorizationRequest(c *gin.Context, requestID string) {
+	jwtUser, exists := c.Get(types.JWTUserCredentialsKey)
+	if !exists {
+		s.errorResponse(c, errors.ErrInvalidAccessToken)
+		return
+	}
+	jwtUserCredentials := jwtUser.(*models.UserCredentials)
+
+	if requestID == "" {
+		s.errorResponse(c, errors.ErrInvalidRequest)
+		return
+	}
+
+	redirectURI, err := oidcmanager.ApproveAuthorizationRequest(s.authorizationRequestStore, requestID, jwtUserCredentials)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	s.successResponse(c, gin.H{
+		"redirect_uri": redirectURI,
+	})
+}
+
+// OIDCTokenRequest is the token endpoint of the OpenID Connect clients, the client authenticates
+// either with HTTP basic authentication or with the client_id and client_secret form parameters
+func (s *Server) OIDCTokenRequest(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	if err := s.checkOIDCProvider(); err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+
+	clientID, secret, ok := c.Request.BasicAuth()
+	if ok {
+		// The credentials are form encoded before being sent with basic authentication
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID = c.PostForm("client_id")
+		secret = c.PostForm("client_secret")
+	}
+
+	client, err := oidcmanager.AuthenticateClient(s.clientStore, clientID, secret)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+
+	var tokenInfo *models.Token
+	switch models.GrantType(c.PostForm("grant_type")) {
+	case models.AuthorizationCodeGrant:
+		request, err := oidcmanager.RedeemCode(s.authorizationRequestStore, client, c.PostForm("code"), c.PostForm("redirect_uri"), c.PostForm("code_verifier"))
+		if err != nil {
+			s.errorResponse(c, err)
+			return
+		}
+		tokenInfo, err = oidcmanager.ExchangeCode(s.userStore, s.refreshTokenStore, s.accessGenerate, s.Config.Issuer, client, request)
+		if err != nil {
+			s.errorResponse(c, err)
+			return
+		}
+	case models.RefreshTokenGrant:
+		tokenInfo, err = oidcmanager.RefreshToken(s.userStore, s.refreshTokenStore, s.accessGenerate, s.Config.Issuer, client, c.PostForm("refresh_token"))
+		if err != nil {
+			s.errorResponse(c, err)
+			return
+		}
+	default:
+		s.errorResponse(c, errors.ErrUnsupportedGrantType)
+		return
+	}
+	s.successResponse(c, s.getTokenData(tokenInfo))
+}
+
+// UserInfoRequest responds with the claims of the user of the access token which are released for the scope
+// of the token, all the claims are released for the tokens of the portal
+func (s *Server) UserInfoRequest(c *gin.Context) {
+	jwtUser, exists := c.Get(types.JWTUserCredentialsKey)
+	if !exists {
+		s.errorResponse(c, errors.ErrInvalidAccessToken)
+		return
+	}
+	jwtUserCredentials := jwtUser.(*models.UserCredentials)
+
+	claims, err := s.accessGenerate.ParseClaims(c.GetString(types.AccessTokenKey))
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	scope := claims.Scope
+	if scope == "" {
+		scope = strings.Join(oidcmanager.SupportedScopes, " ")
+	}
+	s.successResponse(c, oidcmanager.UserInfo(jwtUserCredentials, scope))
+}
+
+// GetClientsRequest lists the registered OpenID Connect clients, request should be sent by admin
+func (s *Server) GetClientsRequest(c *gin.Context) {
+	jwtUser, exists := c.Get(types.JWTUserCredentialsKey)
+	if !exists {
+		s.errorResponse(c, errors.ErrInvalidAccessToken)
+		return
+	}
+	jwtUserCredentials := jwtUser.(*models.UserCredentials)
+
+	if jwtUserCredentials.Role != models.RoleAdmin {
+		s.errorResponse(c, errors.ErrInvalidUser)
+		return
+	}
+
+	clients, err := oidcmanager.GetAllClients(s.clientStore)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	s.successResponse(c, clients)
+}
+
+// CreateClientRequest registers an OpenID Connect client, request should be sent by admin.
+// The client secret is part of this response only.
+func (s *Server) CreateClientRequest(c *gin.Context, client *models.Client) {
+	jwtUser, exists := c.Get(types.JWTUserCredentialsKey)
+	if !exists {
+		s.errorResponse(c, errors.ErrInvalidAccessToken)
+		return
+	}
+	jwtUserCredentials := jwtUser.(*models.UserCredentials)
+
+	if jwtUserCredentials.Role != models.RoleAdmin {
+		s.errorResponse(c, errors.ErrInvalidUser)
+		return
+	}
+	if err := s.checkOIDCProvider(); err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+
+	secret, err := oidcmanager.CreateClient(s.clientStore, client)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	s.successResponse(c, struct {
+		*models.Client
+		ClientSecret string `json:"client_secret,omitempty"`
+	}{client, secret})
+}
+
+// DeleteClientRequest removes an OpenID Connect client, request should be sent by admin
+func (s *Server) DeleteClientRequest(c *gin.Context, clientID string) {
+	jwtUser, exists := c.Get(types.JWTUserCredentialsKey)
+	if !exists {
+		s.errorResponse(c, errors.ErrInvalidAccessToken)
+		return
+	}
+	jwtUserCredentials := jwtUser.(*models.UserCredentials)
+
+	if jwtUserCredentials.Role != models.RoleAdmin {
+		s.errorResponse(c, errors.ErrInvalidUser)
+		return
+	}
+
+	err := oidcmanager.DeleteClient(s.clientStore, clientID)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Client deleted successfully",
+	})
+}
+
+// IntrospectRequest tells a resource server whether the token, either a JWT or a personal access token, is active,
+// see RFC 7662.
+// The caller authenticates either as a confidential client with HTTP basic authentication
+// or with an access token of its own.
+func (s *Server) IntrospectRequest(c *gin.Context, token string) {
+	c.Header("Cache-Control", "no-store")
+
+	if clientID, secret, ok := c.Request.BasicAuth(); ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+		client, err := oidcmanager.AuthenticateClient(s.clientStore, clientID, secret)
+		if err != nil || client.Public {
+			s.errorResponse(c, errors.ErrInvalidClient)
+			return
+		}
+	} else {
+		auth := c.Request.Header.Get(types.AuthHeaderKey)
+		if !strings.HasPrefix(auth, types.AuthHeaderPrefix) {
+			s.errorResponse(c, errors.ErrInvalidClient)
+			return
+		}
+		if _, err := s.GetUserFromToken(auth[len(types.AuthHeaderPrefix):], "", c.ClientIP()); err != nil {
+			s.errorResponse(c, errors.ErrInvalidClient)
+			return
+		}
+	}
+
+	if token == "" {
+		s.errorResponse(c, errors.ErrInvalidRequest)
+		return
+	}
+
+	var introspection *jwtmanager.Introspection
+	var err error
+	if accesstokenmanager.IsPersonalAccessToken(token) {
+		introspection, err = accesstokenmanager.IntrospectToken(s.userStore, s.personalAccessTokenStore, token)
+	} else {
+		introspection, err = jwtmanager.IntrospectToken(s.userStore, s.revocationStore, s.accessGenerate, token)
+	}
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	s.successResponse(c, introspection)
+}
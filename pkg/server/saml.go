@@ -0,0 +1,75 @@
+package server
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/golang/glog"
+
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/oauth/providers"
+	"github.com/mayadata-io/kubera-auth/pkg/types"
+)
+
+// samlResubmittedField marks the responses which were posted once more by the browser from kubera
+const samlResubmittedField = "resubmitted"
+
+// samlResubmitTemplate posts the response of the identity provider once more, from the page of kubera
+var samlResubmitTemplate = template.Must(template.New("saml").Parse(`<!DOCTYPE html>
+<html>
+<body onload="document.forms[0].submit()">
+<form method="post">
+<input type="hidden" name="SAMLResponse" value="{{.SAMLResponse}}">
+<input type="hidden" name="RelayState" value="{{.RelayState}}">
+<input type="hidden" name="` + samlResubmittedField + `" value="true">
+<noscript><button type="submit">Continue</button></noscript>
+</form>
+</body>
+</html>`))
+
+// SAMLMetadataRequest responds with the metadata of kubera as service provider, which is registered with the
+// identity provider. The metadata is available while the SAML login is disabled, to set up the identity provider.
+func (s *Server) SAMLMetadataRequest(c *gin.Context) {
+	provider, ok := s.Providers.Lookup(models.SAMLAuth)
+	samlProvider, isSAML := provider.(*providers.SAMLProvider)
+	if !ok || !isSAML {
+		s.errorResponse(c, errors.ErrUnsupportedGrantType)
+		return
+	}
+
+	metadata, err := samlProvider.Metadata()
+	if err != nil {
+		log.Errorln("Error generating the metadata of the service provider ", err)
+		s.errorResponse(c, err)
+		return
+	}
+	c.Data(http.StatusOK, "application/samlmetadata+xml", metadata)
+}
+
+// SAMLCallbackRequest completes the login with the SAML identity provider, which posts its response to the assertion
+// consumer service along with the state as relay state. The browsers don't send the lax state cookie with the cross
+// site post of the identity provider, so the response is posted once more from kubera for the cookie to be sent.
+func (s *Server) SAMLCallbackRequest(c *gin.Context, urlString string) {
+	samlResponse := c.PostForm("SAMLResponse")
+	relayState := c.PostForm("RelayState")
+
+	if _, err := c.Cookie(types.OAuthStateCookie); err != nil && c.PostForm(samlResubmittedField) == "" {
+		var page bytes.Buffer
+		err = samlResubmitTemplate.Execute(&page, gin.H{
+			"SAMLResponse": samlResponse,
+			"RelayState":   relayState,
+		})
+		if err != nil {
+			s.socialLoginErrorRedirect(c, urlString, err)
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+		c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+		return
+	}
+
+	s.completeSocialLogin(c, urlString, relayState, samlResponse, "")
+}
@@ -1,27 +1,38 @@
 package server
 
 import (
+	"fmt"
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
 	"github.com/globalsign/mgo/bson"
 	log "github.com/golang/glog"
 	"github.com/imdario/mergo"
 
+	"github.com/mayadata-io/kubera-auth/manager/accesstokenmanager"
 	"github.com/mayadata-io/kubera-auth/manager/emailmanager"
 	"github.com/mayadata-io/kubera-auth/manager/jwtmanager"
+	"github.com/mayadata-io/kubera-auth/manager/lockoutmanager"
 	"github.com/mayadata-io/kubera-auth/manager/loginmanager"
+	"github.com/mayadata-io/kubera-auth/manager/mfamanager"
+	"github.com/mayadata-io/kubera-auth/manager/oauthmanager"
 	"github.com/mayadata-io/kubera-auth/manager/usermanager"
 	"github.com/mayadata-io/kubera-auth/pkg/errors"
 	"github.com/mayadata-io/kubera-auth/pkg/generates"
+	"github.com/mayadata-io/kubera-auth/pkg/ldap"
 	"github.com/mayadata-io/kubera-auth/pkg/models"
 	"github.com/mayadata-io/kubera-auth/pkg/oauth"
+	"github.com/mayadata-io/kubera-auth/pkg/oauth/providers"
+	"github.com/mayadata-io/kubera-auth/pkg/passwordhash"
+	"github.com/mayadata-io/kubera-auth/pkg/passwordpolicy"
 	"github.com/mayadata-io/kubera-auth/pkg/store"
 	"github.com/mayadata-io/kubera-auth/pkg/types"
+	"github.com/mayadata-io/kubera-auth/pkg/webauthn"
 )
 
 func init() {
@@ -33,24 +44,97 @@ func init() {
 // NewServer create authorization server
 func NewServer(cfg *Config) *Server {
 	userStoreCfg := store.NewConfig(types.DefaultDBServerURL, types.DefaultAuthDB)
+	accessGenerate := generates.NewJWTAccessGenerate(cfg.SigningMethod, jwtmanager.MaxTokenExp(), cfg.Issuer, cfg.Audience)
+	accessGenerate.LoginAudiences = cfg.LoginAudiences
 	srv := &Server{
 		Config:         cfg,
-		accessGenerate: generates.NewJWTAccessGenerate(jwt.SigningMethodHS512),
-		GithubConfig:   oauth.NewGithubConfig(),
-		GoogleConfig:   oauth.NewGoogleConfig(),
+		accessGenerate: accessGenerate,
+		Providers:      oauth.NewRegistry(),
+		Directory:      ldap.NewConfig(),
+		passwordPolicy: passwordpolicy.NewPolicy(),
+		PasswordHasher: passwordhash.NewHasher(),
+		relyingParty: &webauthn.RelyingParty{
+			ID:      cfg.WebAuthnRPID,
+			Name:    mfamanager.Issuer,
+			Origins: cfg.WebAuthnOrigins,
+		},
 	}
-	srv.MustUserStorage(store.NewUserStore(userStoreCfg, store.NewDefaultUserConfig()))
+	srv.registerProviders()
+
+	session, err := store.NewSession(userStoreCfg)
+	if err != nil {
+		panic(err)
+	}
+	srv.MustUserStorage(store.NewUserStoreWithSession(session, userStoreCfg.DB, store.NewDefaultUserConfig()))
+	srv.MustRefreshTokenStorage(store.NewRefreshTokenStoreWithSession(session, userStoreCfg.DB))
+	srv.MustLoginCodeStorage(store.NewLoginCodeStoreWithSession(session, userStoreCfg.DB))
+	srv.MustLoginAttemptStorage(store.NewLoginAttemptStoreWithSession(session, userStoreCfg.DB))
+	srv.MustRevocationStorage(store.NewRevocationStoreWithSession(session, userStoreCfg.DB))
+	srv.MustClientStorage(store.NewClientStoreWithSession(session, userStoreCfg.DB))
+	srv.MustAuthorizationRequestStorage(store.NewAuthorizationRequestStoreWithSession(session, userStoreCfg.DB))
+	srv.MustOAuthStateStorage(store.NewOAuthStateStoreWithSession(session, userStoreCfg.DB))
+	srv.MustWebAuthnSessionStorage(store.NewWebAuthnSessionStoreWithSession(session, userStoreCfg.DB))
+	srv.MustPersonalAccessTokenStorage(store.NewPersonalAccessTokenStoreWithSession(session, userStoreCfg.DB))
+	srv.MustDeviceAuthorizationStorage(store.NewDeviceAuthorizationStoreWithSession(session, userStoreCfg.DB))
+	srv.accessGenerate.StartKeyRotation(cfg.KeyRotationInterval)
 
 	return srv
 }
 
 // Server Provide authorization server
 type Server struct {
-	Config         *Config
-	GithubConfig   oauth.SocialAuthConfig
-	GoogleConfig   oauth.SocialAuthConfig
-	accessGenerate *generates.JWTAccessGenerate
-	userStore      *store.UserStore
+	Config                    *Config
+	Providers                 *oauth.Registry
+	Directory                 *ldap.Config
+	PasswordHasher            *passwordhash.Hasher
+	passwordPolicy            *passwordpolicy.Policy
+	accessGenerate            *generates.JWTAccessGenerate
+	userStore                 *store.UserStore
+	refreshTokenStore         *store.RefreshTokenStore
+	loginCodeStore            *store.LoginCodeStore
+	revocationStore           *store.RevocationStore
+	clientStore               *store.ClientStore
+	authorizationRequestStore *store.AuthorizationRequestStore
+	oauthStateStore           *store.OAuthStateStore
+	webAuthnSessionStore      *store.WebAuthnSessionStore
+	loginAttemptStore         *store.LoginAttemptStore
+	personalAccessTokenStore  *store.PersonalAccessTokenStore
+	deviceAuthorizationStore  *store.DeviceAuthorizationStore
+	relyingParty              *webauthn.RelyingParty
+	// policyLock guards the replacement of the password policy when it is configured
+	policyLock sync.RWMutex
+}
+
+// PasswordPolicy gives the current password policy, which is shared by the requests and must not be modified
+func (s *Server) PasswordPolicy() *passwordpolicy.Policy {
+	s.policyLock.RLock()
+	defer s.policyLock.RUnlock()
+	return s.passwordPolicy
+}
+
+// SetPasswordPolicy replaces the password policy, the requests in progress keep validating with the previous one
+func (s *Server) SetPasswordPolicy(policy *passwordpolicy.Policy) {
+	s.policyLock.Lock()
+	defer s.policyLock.Unlock()
+	s.passwordPolicy = policy
+}
+
+// registerProviders registers the built in social login providers along with the configured OpenID Connect providers
+func (s *Server) registerProviders() {
+	s.Providers.Register(providers.NewGithubProvider(oauth.NewGithubConfig()), !s.Config.DisableGithubAuth)
+	s.Providers.Register(providers.NewGoogleProvider(oauth.NewGoogleConfig()), !s.Config.DisableGoogleAuth)
+	s.Providers.Register(providers.NewGitlabProvider(oauth.NewGitlabConfig(s.Config.Issuer+"/v1/oauth")), !s.Config.DisableGitlabAuth)
+	s.Providers.Register(providers.NewBitbucketProvider(oauth.NewBitbucketConfig()), !s.Config.DisableBitbucketAuth)
+	samlConfig := oauth.NewSAMLConfig(s.Config.Issuer+"/v1/saml/metadata", s.Config.Issuer+"/v1/saml/acs")
+	s.Providers.Register(providers.NewSAMLProvider(samlConfig), !s.Config.DisableSAMLAuth)
+
+	oidcConfigs, err := oauth.NewOIDCConfigs(s.Config.Issuer + "/v1/oauth")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, oidcConfig := range oidcConfigs {
+		s.Providers.Register(providers.NewOIDCProvider(oidcConfig), true)
+	}
 }
 
 // MustUserStorage mandatory mapping the user store interface
@@ -59,13 +143,104 @@ func (s *Server) MustUserStorage(stor *store.UserStore, err error) {
 		panic(err)
 	}
 	s.userStore = stor
-	_, err = usermanager.CreateUser(stor, models.DefaultUser, false)
+	_, err = usermanager.CreateUser(stor, s.PasswordHasher, nil, models.DefaultUser, false)
 	if err != nil {
 		log.Infoln("Unable to create default user with error:", err)
 	}
+	err = usermanager.MigrateIdentities(stor)
+	if err != nil {
+		log.Errorln("Unable to migrate the social logins of the users to identities with error:", err)
+	}
+}
+
+// MustRefreshTokenStorage mandatory mapping the refresh token store interface
+func (s *Server) MustRefreshTokenStorage(stor *store.RefreshTokenStore, err error) {
+	if err != nil {
+		panic(err)
+	}
+	s.refreshTokenStore = stor
+}
+
+// MustLoginCodeStorage mandatory mapping the login code store interface
+func (s *Server) MustLoginCodeStorage(stor *store.LoginCodeStore, err error) {
+	if err != nil {
+		panic(err)
+	}
+	s.loginCodeStore = stor
+}
+
+// MustLoginAttemptStorage mandatory mapping the login attempt store interface
+func (s *Server) MustLoginAttemptStorage(stor *store.LoginAttemptStore, err error) {
+	if err != nil {
+		panic(err)
+	}
+	s.loginAttemptStore = stor
+}
+
+// MustRevocationStorage mandatory mapping the revocation store interface
+func (s *Server) MustRevocationStorage(stor *store.RevocationStore, err error) {
+	if err != nil {
+		panic(err)
+	}
+	s.revocationStore = stor
+}
+
+// MustClientStorage mandatory mapping the client store interface
+func (s *Server) MustClientStorage(stor *store.ClientStore, err error) {
+	if err != nil {
+		panic(err)
+	}
+	s.clientStore = stor
+}
+
+// MustAuthorizationRequestStorage mandatory mapping the authorization request store interface
+func (s *Server) MustAuthorizationRequestStorage(stor *store.AuthorizationRequestStore, err error) {
+	if err != nil {
+		panic(err)
+	}
+	s.authorizationRequestStore = stor
+}
+
+// MustOAuthStateStorage mandatory mapping the oauth state store interface
+func (s *Server) MustOAuthStateStorage(stor *store.OAuthStateStore, err error) {
+	if err != nil {
+		panic(err)
+	}
+	s.oauthStateStore = stor
+}
+
+// MustPersonalAccessTokenStorage mandatory mapping the personal access token store interface
+func (s *Server) MustPersonalAccessTokenStorage(stor *store.PersonalAccessTokenStore, err error) {
+	if err != nil {
+		panic(err)
+	}
+	s.personalAccessTokenStore = stor
+}
+
+// MustDeviceAuthorizationStorage mandatory mapping the device authorization store interface
+func (s *Server) MustDeviceAuthorizationStorage(stor *store.DeviceAuthorizationStore, err error) {
+	if err != nil {
+		panic(err)
+	}
+	s.deviceAuthorizationStore = stor
+}
+
+// MustWebAuthnSessionStorage mandatory mapping the webauthn session store interface
+func (s *Server) MustWebAuthnSessionStorage(stor *store.WebAuthnSessionStore, err error) {
+	if err != nil {
+		panic(err)
+	}
+	s.webAuthnSessionStore = stor
 }
 
 func (s *Server) errorResponse(c *gin.Context, err error) {
+	if violationErr, ok := err.(*passwordpolicy.ViolationError); ok {
+		// The violations are listed for the portal to show all of them at once
+		data, code, _ := s.getErrorData(errors.ErrPasswordPolicy)
+		data["violations"] = violationErr.Violations
+		c.JSON(code, data)
+		return
+	}
 	data, code, _ := s.getErrorData(err)
 	c.JSON(code, data)
 }
@@ -75,36 +250,269 @@ func (s *Server) successResponse(c *gin.Context, data interface{}) {
 }
 
 // LocalLoginRequest the local authentication request handling
-func (s *Server) LocalLoginRequest(c *gin.Context, username, password string) {
+func (s *Server) LocalLoginRequest(c *gin.Context, username, password, scope string) {
 	if username == "" || password == "" {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Username or password cannot be empty",
 		})
 		return
 	}
+	scope, err := jwtmanager.NormalizeScope(scope)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
 
-	tokenInfo, err := loginmanager.LocalLoginUser(s.userStore, s.accessGenerate, username, password)
+	tokenInfo, mfaChallenge, err := loginmanager.LocalLoginUser(s.userStore, s.refreshTokenStore, s.loginAttemptStore, s.accessGenerate, s.PasswordHasher, username, password, scope, c.ClientIP(), s.Config.EnforceAdminMFA)
 	if err != nil {
 		s.errorResponse(c, err)
 		return
+	} else if mfaChallenge != nil {
+		s.successResponse(c, mfaChallenge)
+		return
 	}
 	s.successResponse(c, s.getTokenData(tokenInfo))
 }
 
-// SocialLoginRequest logs in the user with github or gmail
-func (s *Server) SocialLoginRequest(c *gin.Context, user *models.UserCredentials, urlString string) {
-	values := url.Values{}
-	tokenInfo, err := loginmanager.SocialLoginUser(s.userStore, s.accessGenerate, user)
+// LDAPLoginRequest authenticates the user against the LDAP directory
+func (s *Server) LDAPLoginRequest(c *gin.Context, username, password, scope string) {
+	if s.Config.DisableLDAPAuth {
+		s.errorResponse(c, errors.ErrUnsupportedGrantType)
+		return
+	} else if username == "" || password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Username or password cannot be empty",
+		})
+		return
+	}
+	scope, err := jwtmanager.NormalizeScope(scope)
 	if err != nil {
-		log.Errorln("Error logging in ", err)
 		s.errorResponse(c, err)
 		return
 	}
 
-	values.Set("access_token", tokenInfo.GetAccess())
+	tokenInfo, mfaChallenge, err := loginmanager.LDAPLoginUser(s.userStore, s.refreshTokenStore, s.loginAttemptStore, s.accessGenerate, s.Directory, username, password, scope, c.ClientIP(), s.Config.EnforceAdminMFA)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	} else if mfaChallenge != nil {
+		s.successResponse(c, mfaChallenge)
+		return
+	}
+	s.successResponse(c, s.getTokenData(tokenInfo))
+}
+
+// MFALoginRequest completes the login of a user with the MFA challenge token and a code of the user
+func (s *Server) MFALoginRequest(c *gin.Context, mfaToken, code string) {
+	if mfaToken == "" || code == "" {
+		s.errorResponse(c, errors.ErrInvalidRequest)
+		return
+	}
+
+	tokenInfo, err := loginmanager.MFALoginUser(s.userStore, s.refreshTokenStore, s.revocationStore, s.loginAttemptStore, s.accessGenerate, mfaToken, code, c.ClientIP())
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	s.successResponse(c, s.getTokenData(tokenInfo))
+}
+
+// MagicLinkRequest mails a sign-in link to the user owning the verified email. The response doesn't tell whether
+// such a user exists.
+func (s *Server) MagicLinkRequest(c *gin.Context, email string) {
+	if s.Config.DisableMagicLinkAuth {
+		s.errorResponse(c, errors.ErrMagicLinkDisabled)
+		return
+	} else if email == "" {
+		s.errorResponse(c, errors.ErrInvalidRequest)
+		return
+	}
+
+	err := loginmanager.RequestMagicLink(s.userStore, s.accessGenerate, email)
+	if err == errors.ErrInvalidUser {
+		log.Infoln("Magic link requested for an unknown email")
+	} else if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Sign-in email sent if the email belongs to a user",
+	})
+}
+
+// MagicLinkLoginRequest exchanges the token of a magic link for a login token
+func (s *Server) MagicLinkLoginRequest(c *gin.Context, magicLinkToken string) {
+	if s.Config.DisableMagicLinkAuth {
+		s.errorResponse(c, errors.ErrMagicLinkDisabled)
+		return
+	} else if magicLinkToken == "" {
+		s.errorResponse(c, errors.ErrInvalidRequest)
+		return
+	}
+
+	tokenInfo, mfaChallenge, err := loginmanager.MagicLinkLoginUser(s.userStore, s.refreshTokenStore, s.revocationStore, s.accessGenerate, magicLinkToken, s.Config.EnforceAdminMFA)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	} else if mfaChallenge != nil {
+		s.successResponse(c, mfaChallenge)
+		return
+	}
+	s.successResponse(c, s.getTokenData(tokenInfo))
+}
+
+// RefreshTokenRequest exchanges a refresh token for a new pair of access and refresh token
+func (s *Server) RefreshTokenRequest(c *gin.Context, refresh string) {
+	if refresh == "" {
+		s.errorResponse(c, errors.ErrInvalidRequest)
+		return
+	}
+
+	tokenInfo, err := loginmanager.RefreshLoginUser(s.userStore, s.refreshTokenStore, s.accessGenerate, refresh)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	s.successResponse(c, s.getTokenData(tokenInfo))
+}
+
+// SocialLoginRedirect sends the user to the provider for login. The state of the login is stored and
+// kept in a short lived cookie of the browser, so that the callback can only be completed by this browser.
+func (s *Server) SocialLoginRedirect(c *gin.Context, provider oauth.Provider, redirectTo string) {
+	authCodeURL, err := s.startSocialLogin(c, provider, redirectTo, "")
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	c.Redirect(http.StatusFound, authCodeURL)
+}
+
+// startSocialLogin stores the state of the login along with its cookie and gives the url of the login page of the provider
+func (s *Server) startSocialLogin(c *gin.Context, provider oauth.Provider, redirectTo, linkUserUID string) (string, error) {
+	err := oauthmanager.ValidateRedirectTo(s.Config.AllowedRedirectURLs, redirectTo)
+	if err != nil {
+		return "", err
+	}
+
+	state, oauthState, err := oauthmanager.CreateState(s.oauthStateStore, provider.Config().Name, redirectTo, linkUserUID)
+	if err != nil {
+		return "", err
+	}
+
+	authCodeURL, err := provider.AuthCodeURL(c.Request.Context(), state, oauthState.CodeVerifier)
+	if err != nil {
+		return "", err
+	}
+
+	s.setOAuthStateCookie(c, state, int(oauthmanager.StateExp/time.Second))
+	return authCodeURL, nil
+}
+
+// validateOAuthState validates the state of the provider callback against the cookie of the browser
+func (s *Server) validateOAuthState(c *gin.Context, state string) (*models.OAuthState, error) {
+	cookieState, _ := c.Cookie(types.OAuthStateCookie)
+	// The state cookie is of no use anymore, whether the state is valid or not
+	s.setOAuthStateCookie(c, "", -1)
+	return oauthmanager.ConsumeState(s.oauthStateStore, state, cookieState)
+}
+
+func (s *Server) setOAuthStateCookie(c *gin.Context, value string, maxAge int) {
+	// Lax cookies are sent along with the top level redirect from the provider
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(types.OAuthStateCookie, value, maxAge, "/", "", strings.HasPrefix(types.PortalURL, "https://"), true)
+}
+
+// SocialLoginRequest completes the login with the provider which redirected the user to the callback.
+// The user is sent back to the portal either with a one-time login code or with the error which prevented
+// the login. The tokens never travel in the redirect, they would otherwise end up in the browser history and logs.
+func (s *Server) SocialLoginRequest(c *gin.Context, urlString string) {
+	s.completeSocialLogin(c, urlString, c.Query("state"), c.Query("code"), c.Query("error"))
+}
+
+// completeSocialLogin exchanges the code sent by the provider along with the state for the user, and logs in the user
+func (s *Server) completeSocialLogin(c *gin.Context, urlString, state, code, providerError string) {
+	oauthState, err := s.validateOAuthState(c, state)
+	if err != nil {
+		s.socialLoginErrorRedirect(c, urlString, err)
+		return
+	}
+
+	provider, ok := s.Providers.Get(oauthState.Provider)
+	if !ok {
+		s.socialLoginErrorRedirect(c, urlString, errors.ErrInvalidState)
+		return
+	}
+	if providerError != "" {
+		// The user didn't authorize kubera with the provider
+		s.socialLoginErrorRedirect(c, urlString, errors.ErrAccessDenied)
+		return
+	}
+
+	user, err := provider.Exchange(c.Request.Context(), code, oauthState.CodeVerifier)
+	if err != nil {
+		log.Errorln("Error getting user from ", oauthState.Provider, err)
+		s.socialLoginErrorRedirect(c, urlString, err)
+		return
+	}
+
+	if oauthState.LinkUserUID != "" {
+		// The user is logged in with the account once it is linked
+		err = usermanager.LinkIdentity(s.userStore, oauthState.LinkUserUID, usermanager.NewIdentity(user))
+		if err != nil {
+			log.Errorln("Error linking the identity of ", oauthState.Provider, err)
+			s.socialLoginErrorRedirect(c, urlString, err)
+			return
+		}
+	}
+
+	// The role of the user is kept in sync with the provider when the provider maps groups onto roles
+	syncRole := len(provider.Config().AdminGroups) > 0
+	linkEmail := s.Config.linksEmail(oauthState.Provider)
+	loginCode, err := loginmanager.SocialLoginUser(s.userStore, s.loginCodeStore, user, syncRole, linkEmail)
+	if err != nil {
+		log.Errorln("Error logging in ", err)
+		s.socialLoginErrorRedirect(c, urlString, err)
+		return
+	}
+
+	values := url.Values{}
+	values.Set("code", loginCode)
+	if oauthState.RedirectTo != "" {
+		values.Set("redirect_to", oauthState.RedirectTo)
+	}
+	c.Redirect(http.StatusFound, urlString+values.Encode())
+}
+
+// socialLoginErrorRedirect sends the user back to the portal with the error of the social login
+func (s *Server) socialLoginErrorRedirect(c *gin.Context, urlString string, err error) {
+	data, _, _ := s.getErrorData(err)
+	values := url.Values{}
+	for key, value := range data {
+		values.Set(key, fmt.Sprint(value))
+	}
 	c.Redirect(http.StatusFound, urlString+values.Encode())
 }
 
+// LoginCodeRequest exchanges the one-time login code of a social login for the tokens of the user,
+// or for an MFA challenge when the user has to complete the login with MFA
+func (s *Server) LoginCodeRequest(c *gin.Context, code string) {
+	if code == "" {
+		s.errorResponse(c, errors.ErrInvalidRequest)
+		return
+	}
+
+	tokenInfo, mfaChallenge, err := loginmanager.LoginCodeLoginUser(s.userStore, s.refreshTokenStore, s.loginCodeStore, s.accessGenerate, code, s.Config.EnforceAdminMFA)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	} else if mfaChallenge != nil {
+		// The portal completes the login with the challenge, as for the local logins
+		s.successResponse(c, mfaChallenge)
+		return
+	}
+	s.successResponse(c, s.getTokenData(tokenInfo))
+}
+
 // LogoutRequest the authorization request handling
 func (s *Server) LogoutRequest(c *gin.Context) {
 	jwtUser, exists := c.Get(types.JWTUserCredentialsKey)
@@ -114,7 +522,13 @@ func (s *Server) LogoutRequest(c *gin.Context) {
 	}
 	jwtUserCredentials := jwtUser.(*models.UserCredentials)
 
-	err := loginmanager.LogoutUser(s.userStore, jwtUserCredentials.ID)
+	err := jwtmanager.RevokeToken(s.revocationStore, s.accessGenerate, c.GetString(types.AccessTokenKey))
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+
+	err = loginmanager.LogoutUser(s.userStore, s.refreshTokenStore, jwtUserCredentials.ID)
 	if err != nil {
 		s.errorResponse(c, err)
 		return
@@ -125,6 +539,53 @@ func (s *Server) LogoutRequest(c *gin.Context) {
 	})
 }
 
+// RevokeUserTokensRequest revokes all the tokens of a user, request should be sent by admin
+func (s *Server) RevokeUserTokensRequest(c *gin.Context, userID string) {
+	jwtUser, exists := c.Get(types.JWTUserCredentialsKey)
+	if !exists {
+		s.errorResponse(c, errors.ErrInvalidAccessToken)
+		return
+	}
+	jwtUserCredentials := jwtUser.(*models.UserCredentials)
+
+	if jwtUserCredentials.Role != models.RoleAdmin {
+		s.errorResponse(c, errors.ErrInvalidUser)
+		return
+	}
+
+	err := jwtmanager.RevokeUserTokens(s.userStore, s.refreshTokenStore, userID)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Tokens revoked successfully",
+	})
+}
+
+// UnlockUserRequest unlocks the login of a particular user locked after too many failed logins, request should be sent by admin
+func (s *Server) UnlockUserRequest(c *gin.Context, userID string) {
+	jwtUser, exists := c.Get(types.JWTUserCredentialsKey)
+	if !exists {
+		s.errorResponse(c, errors.ErrInvalidAccessToken)
+		return
+	}
+	jwtUserCredentials := jwtUser.(*models.UserCredentials)
+
+	if jwtUserCredentials.Role != models.RoleAdmin {
+		s.errorResponse(c, errors.ErrInvalidUser)
+		return
+	}
+
+	userInfo, err := lockoutmanager.UnlockUser(s.userStore, userID)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	s.successResponse(c, userInfo)
+}
+
 // GetTokenData token data
 func (s *Server) getTokenData(ti *models.Token) map[string]interface{} {
 	data := map[string]interface{}{
@@ -132,6 +593,12 @@ func (s *Server) getTokenData(ti *models.Token) map[string]interface{} {
 		"token_type":   s.Config.TokenType,
 		"expires_in":   int64(ti.GetAccessExpiresIn() / time.Second),
 	}
+	if refresh := ti.GetRefresh(); refresh != "" {
+		data["refresh_token"] = refresh
+	}
+	if idToken := ti.GetIDToken(); idToken != "" {
+		data["id_token"] = idToken
+	}
 	return data
 }
 
@@ -195,13 +662,34 @@ func (s *Server) responseErrorHandler(re *errors.Response) {
 	log.Infoln("Response Error:", re.Error.Error())
 }
 
-// GetUserFromToken gets the user from token
-func (s *Server) GetUserFromToken(token string) (*models.UserCredentials, error) {
-	return jwtmanager.ParseToken(s.userStore, s.accessGenerate, token)
+// JWKSRequest responds with the public keys which can be used to verify the tokens
+func (s *Server) JWKSRequest(c *gin.Context) {
+	jwks, err := s.accessGenerate.JWKS()
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	s.successResponse(c, jwks)
 }
 
-// UpdatePasswordRequest validates the request
-func (s *Server) UpdatePasswordRequest(c *gin.Context, newPassword string) {
+// GetKeysRequest lists the keys of the signing keyring, request should be sent by admin
+func (s *Server) GetKeysRequest(c *gin.Context) {
+	jwtUser, exists := c.Get(types.JWTUserCredentialsKey)
+	if !exists {
+		s.errorResponse(c, errors.ErrInvalidAccessToken)
+		return
+	}
+	jwtUserCredentials := jwtUser.(*models.UserCredentials)
+
+	if jwtUserCredentials.Role != models.RoleAdmin {
+		s.errorResponse(c, errors.ErrInvalidUser)
+		return
+	}
+	s.successResponse(c, s.accessGenerate.Keys())
+}
+
+// RotateKeysRequest rotates the signing key, request should be sent by admin
+func (s *Server) RotateKeysRequest(c *gin.Context) {
 	jwtUser, exists := c.Get(types.JWTUserCredentialsKey)
 	if !exists {
 		s.errorResponse(c, errors.ErrInvalidAccessToken)
@@ -209,12 +697,82 @@ func (s *Server) UpdatePasswordRequest(c *gin.Context, newPassword string) {
 	}
 	jwtUserCredentials := jwtUser.(*models.UserCredentials)
 
+	if jwtUserCredentials.Role != models.RoleAdmin {
+		s.errorResponse(c, errors.ErrInvalidUser)
+		return
+	}
+
+	keys, err := s.accessGenerate.Rotate()
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	s.successResponse(c, keys)
+}
+
+// GetUserFromToken gets the user from token, either a login token or a personal access token whose use from the
+// client ip is recorded. The scope of the token has to meet the required scope.
+func (s *Server) GetUserFromToken(token, scope, clientIP string) (*models.UserCredentials, error) {
+	if accesstokenmanager.IsPersonalAccessToken(token) {
+		return accesstokenmanager.ParseToken(s.userStore, s.personalAccessTokenStore, token, scope, clientIP)
+	}
+	return jwtmanager.ParseLoginToken(s.userStore, s.revocationStore, s.accessGenerate, token, scope)
+}
+
+// getPasswordUser gives the user changing its password, either logged in or holding the token of a password
+// reset link. The reset token is given back to be consumed.
+func (s *Server) getPasswordUser(c *gin.Context) (*models.UserCredentials, string, error) {
+	auth := c.Request.Header.Get(types.AuthHeaderKey)
+	if !strings.HasPrefix(auth, types.AuthHeaderPrefix) {
+		return nil, "", errors.ErrInvalidAccessToken
+	}
+	token := auth[len(types.AuthHeaderPrefix):]
+
+	if user, err := jwtmanager.ParseLoginToken(s.userStore, s.revocationStore, s.accessGenerate, token, models.ScopeUsersWrite); err == nil {
+		return user, "", nil
+	} else if err == errors.ErrInsufficientScope {
+		return nil, "", err
+	}
+	user, err := jwtmanager.ParsePasswordResetToken(s.userStore, s.revocationStore, s.accessGenerate, token)
+	if err != nil {
+		return nil, "", err
+	}
+	return user, token, nil
+}
+
+// UpdatePasswordRequest validates the request, the password is changed either by the logged in user or
+// with a password reset link, which can be used only once
+func (s *Server) UpdatePasswordRequest(c *gin.Context, newPassword string) {
+	jwtUserCredentials, resetToken, err := s.getPasswordUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
 	if newPassword == "" {
 		c.JSON(http.StatusBadRequest, errors.ErrInvalidRequest)
 		return
 	}
 
-	updatedUserInfo, err := usermanager.UpdatePassword(s.userStore, newPassword, jwtUserCredentials.UserName)
+	if resetToken != "" {
+		// The link isn't used up by a password which would be rejected
+		err = usermanager.ValidateNewPassword(s.PasswordPolicy(), jwtUserCredentials, newPassword)
+		if err != nil {
+			s.errorResponse(c, err)
+			return
+		}
+		err = jwtmanager.ConsumeToken(s.revocationStore, s.accessGenerate, resetToken)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+
+	updatedUserInfo, err := usermanager.UpdatePassword(s.userStore, s.PasswordHasher, s.PasswordPolicy(), newPassword, jwtUserCredentials.UserName)
 	if err != nil {
 		s.errorResponse(c, err)
 		return
@@ -239,7 +797,7 @@ func (s *Server) ResetPasswordRequest(c *gin.Context, newPassword, userName stri
 	var updatedUserInfo *models.PublicUserInfo
 	var err error
 	if jwtUserCredentials.Role == models.RoleAdmin {
-		updatedUserInfo, err = usermanager.UpdatePassword(s.userStore, newPassword, userName)
+		updatedUserInfo, err = usermanager.UpdatePassword(s.userStore, s.PasswordHasher, s.PasswordPolicy(), newPassword, userName)
 		if err != nil {
 			s.errorResponse(c, err)
 			return
@@ -305,7 +863,7 @@ func (s *Server) CreateRequest(c *gin.Context, user *models.UserCredentials) {
 	var createdUserInfo *models.PublicUserInfo
 	var err error
 	if jwtUserCredentials.Role == models.RoleAdmin {
-		createdUserInfo, err = usermanager.CreateUser(s.userStore, user, false)
+		createdUserInfo, err = usermanager.CreateUser(s.userStore, s.PasswordHasher, s.PasswordPolicy(), user, false)
 		if err != nil {
 			s.errorResponse(c, err)
 			return
@@ -323,7 +881,7 @@ func (s *Server) SelfSignupUser(c *gin.Context, user *models.UserCredentials) {
 		return
 	}
 
-	createdUserInfo, err := usermanager.CreateUser(s.userStore, user, true)
+	createdUserInfo, err := usermanager.CreateUser(s.userStore, s.PasswordHasher, s.PasswordPolicy(), user, true)
 	if err != nil {
 		s.errorResponse(c, err)
 		return
@@ -335,10 +893,13 @@ func (s *Server) SelfSignupUser(c *gin.Context, user *models.UserCredentials) {
 		return
 	}
 
-	tokenInfo, err := loginmanager.LocalLoginUser(s.userStore, s.accessGenerate, user.UserName, user.Password)
+	tokenInfo, mfaChallenge, err := loginmanager.LocalLoginUser(s.userStore, s.refreshTokenStore, s.loginAttemptStore, s.accessGenerate, s.PasswordHasher, user.UserName, user.Password, "", c.ClientIP(), s.Config.EnforceAdminMFA)
 	if err != nil {
 		s.errorResponse(c, err)
 		return
+	} else if mfaChallenge != nil {
+		s.successResponse(c, mfaChallenge)
+		return
 	}
 	s.successResponse(c, s.getTokenData(tokenInfo))
 }
@@ -416,16 +977,18 @@ func (s *Server) SendVerificationLink(c *gin.Context, resend bool, unverifiedEma
 	s.successResponse(c, userInfo)
 }
 
-// VerifyEmail marks a user email as verified
-func (s *Server) VerifyEmail(c *gin.Context, redirectURL string) {
-	jwtUser, exists := c.Get(types.JWTUserCredentialsKey)
-	if !exists {
-		s.errorResponse(c, errors.ErrInvalidAccessToken)
-		// Redirecting user to UI if the user is not authorized
+// VerifyEmail marks a user email as verified with the token of a verification link, which can be used only once
+func (s *Server) VerifyEmail(c *gin.Context, token, redirectURL string) {
+	jwtUserCredentials, err := jwtmanager.ParseEmailVerificationToken(s.userStore, s.revocationStore, s.accessGenerate, token)
+	if err != nil {
+		log.Error("Error occurred while parsing jwt token error: " + err.Error())
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": err.Error(),
+		})
+		// Redirecting user back to UI if access token is not valid
 		c.Redirect(http.StatusPermanentRedirect, redirectURL)
 		return
 	}
-	jwtUserCredentials := jwtUser.(*models.UserCredentials)
 
 	if jwtUserCredentials.UnverifiedEmail != "" {
 		// Checking if the given email is already registered with some user
@@ -447,6 +1010,16 @@ func (s *Server) VerifyEmail(c *gin.Context, redirectURL string) {
 			return
 		}
 
+		err = jwtmanager.ConsumeToken(s.revocationStore, s.accessGenerate, token)
+		if err != nil {
+			log.Errorln("Error consuming the verification link of user uid: ", jwtUserCredentials.UID, err)
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": err.Error(),
+			})
+			c.Redirect(http.StatusPermanentRedirect, redirectURL)
+			return
+		}
+
 		jwtUserCredentials.Email = jwtUserCredentials.UnverifiedEmail
 		jwtUserCredentials.UnverifiedEmail = ""
 		if jwtUserCredentials.Kind == models.LocalAuth {
@@ -462,7 +1035,7 @@ func (s *Server) VerifyEmail(c *gin.Context, redirectURL string) {
 		return
 	}
 
-	_, err := usermanager.UpdateUserDetails(s.userStore, jwtUserCredentials)
+	_, err = usermanager.UpdateUserDetails(s.userStore, jwtUserCredentials)
 	if err != nil {
 		s.errorResponse(c, err)
 		// Redirecting user to UI if updating the database fails
@@ -499,7 +1072,23 @@ func (s *Server) RestoreEmail(c *gin.Context) {
 
 // ForgotPasswordRequest validates the request
 func (s *Server) ForgotPasswordRequest(c *gin.Context, email string) {
+	err := lockoutmanager.CheckClient(s.loginAttemptStore, lockoutmanager.PasswordResetScope, c.ClientIP())
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+
 	storedUser, err := usermanager.GetUserByUserName(s.userStore, email)
+	if err != nil {
+		// The requests for unknown users are guesses of the client
+		if rerr := lockoutmanager.RecordClientFailure(s.loginAttemptStore, lockoutmanager.PasswordResetScope, c.ClientIP()); rerr != nil {
+			log.Errorln("Error recording the failed password reset request of the client ", rerr)
+		}
+		s.errorResponse(c, err)
+		return
+	}
+
+	err = lockoutmanager.ThrottleUser(s.loginAttemptStore, lockoutmanager.PasswordResetScope, storedUser)
 	if err != nil {
 		s.errorResponse(c, err)
 		return
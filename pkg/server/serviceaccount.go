@@ -0,0 +1,129 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mayadata-io/kubera-auth/manager/jwtmanager"
+	"github.com/mayadata-io/kubera-auth/manager/loginmanager"
+	"github.com/mayadata-io/kubera-auth/manager/serviceaccountmanager"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/types"
+)
+
+// ClientCredentialsLoginRequest exchanges the client id and secret of a service account for a short-lived token
+func (s *Server) ClientCredentialsLoginRequest(c *gin.Context, clientID, secret, scope string) {
+	scope, err := jwtmanager.NormalizeScope(scope)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+
+	tokenInfo, err := loginmanager.ClientCredentialsLoginUser(s.userStore, s.loginAttemptStore, s.accessGenerate, clientID, secret, scope, c.ClientIP())
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	s.successResponse(c, s.getTokenData(tokenInfo))
+}
+
+// GetServiceAccountsRequest lists the service accounts, request should be sent by admin
+func (s *Server) GetServiceAccountsRequest(c *gin.Context) {
+	jwtUser, exists := c.Get(types.JWTUserCredentialsKey)
+	if !exists {
+		s.errorResponse(c, errors.ErrInvalidAccessToken)
+		return
+	}
+	jwtUserCredentials := jwtUser.(*models.UserCredentials)
+
+	if jwtUserCredentials.Role != models.RoleAdmin {
+		s.errorResponse(c, errors.ErrInvalidUser)
+		return
+	}
+
+	serviceAccounts, err := serviceaccountmanager.GetServiceAccounts(s.userStore)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	s.successResponse(c, serviceAccounts)
+}
+
+// CreateServiceAccountRequest creates a service account owned by the admin sending the request.
+// The client secret is part of this response only.
+func (s *Server) CreateServiceAccountRequest(c *gin.Context, name string, role models.Role) {
+	jwtUser, exists := c.Get(types.JWTUserCredentialsKey)
+	if !exists {
+		s.errorResponse(c, errors.ErrInvalidAccessToken)
+		return
+	}
+	jwtUserCredentials := jwtUser.(*models.UserCredentials)
+
+	if jwtUserCredentials.Role != models.RoleAdmin {
+		s.errorResponse(c, errors.ErrInvalidUser)
+		return
+	}
+
+	serviceAccount, secret, err := serviceaccountmanager.CreateServiceAccount(s.userStore, jwtUserCredentials, name, role)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	s.successResponse(c, struct {
+		*models.ServiceAccount
+		ClientSecret string `json:"client_secret"`
+	}{serviceAccount, secret})
+}
+
+// RotateServiceAccountSecretRequest adds a new client secret to the service account, the previous secrets stay
+// valid for the overlap. Request should be sent by admin, the new secret is part of this response only.
+func (s *Server) RotateServiceAccountSecretRequest(c *gin.Context, clientID string, overlap time.Duration) {
+	jwtUser, exists := c.Get(types.JWTUserCredentialsKey)
+	if !exists {
+		s.errorResponse(c, errors.ErrInvalidAccessToken)
+		return
+	}
+	jwtUserCredentials := jwtUser.(*models.UserCredentials)
+
+	if jwtUserCredentials.Role != models.RoleAdmin {
+		s.errorResponse(c, errors.ErrInvalidUser)
+		return
+	}
+
+	serviceAccount, secret, err := serviceaccountmanager.RotateSecret(s.userStore, clientID, overlap)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	s.successResponse(c, struct {
+		*models.ServiceAccount
+		ClientSecret string `json:"client_secret"`
+	}{serviceAccount, secret})
+}
+
+// DeleteServiceAccountRequest removes a service account, request should be sent by admin
+func (s *Server) DeleteServiceAccountRequest(c *gin.Context, clientID string) {
+	jwtUser, exists := c.Get(types.JWTUserCredentialsKey)
+	if !exists {
+		s.errorResponse(c, errors.ErrInvalidAccessToken)
+		return
+	}
+	jwtUserCredentials := jwtUser.(*models.UserCredentials)
+
+	if jwtUserCredentials.Role != models.RoleAdmin {
+		s.errorResponse(c, errors.ErrInvalidUser)
+		return
+	}
+
+	err := serviceaccountmanager.DeleteServiceAccount(s.userStore, clientID)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Service account deleted successfully",
+	})
+}
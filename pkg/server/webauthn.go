@@ -0,0 +1,180 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mayadata-io/kubera-auth/manager/jwtmanager"
+	"github.com/mayadata-io/kubera-auth/manager/loginmanager"
+	"github.com/mayadata-io/kubera-auth/manager/mfamanager"
+	"github.com/mayadata-io/kubera-auth/manager/webauthnmanager"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/types"
+	"github.com/mayadata-io/kubera-auth/pkg/webauthn"
+)
+
+// getMFAChallengeUser gives the user holding the MFA challenge token of the request, the user is nil
+// when the request has no token
+func (s *Server) getMFAChallengeUser(c *gin.Context) (*models.UserCredentials, string, error) {
+	auth := c.Request.Header.Get(types.AuthHeaderKey)
+	if auth == "" {
+		return nil, "", nil
+	} else if !strings.HasPrefix(auth, types.AuthHeaderPrefix) {
+		return nil, "", errors.ErrInvalidAccessToken
+	}
+	token := auth[len(types.AuthHeaderPrefix):]
+
+	user, err := jwtmanager.ParseMFAToken(s.userStore, s.revocationStore, s.accessGenerate, token)
+	if err != nil {
+		return nil, "", err
+	}
+	return user, token, nil
+}
+
+// GetWebAuthnCredentialsRequest gives the webauthn credentials registered by the user
+func (s *Server) GetWebAuthnCredentialsRequest(c *gin.Context) {
+	jwtUser, exists := c.Get(types.JWTUserCredentialsKey)
+	if !exists {
+		s.errorResponse(c, errors.ErrInvalidAccessToken)
+		return
+	}
+	jwtUserCredentials := jwtUser.(*models.UserCredentials)
+
+	credentials := jwtUserCredentials.WebAuthnCredentials
+	if credentials == nil {
+		credentials = []models.WebAuthnCredential{}
+	}
+	s.successResponse(c, credentials)
+}
+
+// StartWebAuthnRegistrationRequest gives the options with which the browser creates a new credential for the user
+func (s *Server) StartWebAuthnRegistrationRequest(c *gin.Context) {
+	user, mfaToken, err := s.getMFAUser(c)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	// The challenge token only proves the password, the users having a second factor can't enroll another one with it
+	if mfaToken != "" && mfamanager.IsEnrolled(user) {
+		s.errorResponse(c, errors.ErrMFAEnabled)
+		return
+	}
+
+	sessionID, options, err := webauthnmanager.StartRegistration(s.webAuthnSessionStore, s.relyingParty, user)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	s.successResponse(c, gin.H{
+		"session_id": sessionID,
+		"options":    options,
+	})
+}
+
+// FinishWebAuthnRegistrationRequest registers the credential created by the browser. The users registering
+// with an MFA challenge token are logged in as well.
+func (s *Server) FinishWebAuthnRegistrationRequest(c *gin.Context, sessionID, name string, response *webauthn.CredentialResponse) {
+	user, mfaToken, err := s.getMFAUser(c)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	if mfaToken != "" && mfamanager.IsEnrolled(user) {
+		s.errorResponse(c, errors.ErrMFAEnabled)
+		return
+	}
+
+	credential, err := webauthnmanager.FinishRegistration(s.userStore, s.webAuthnSessionStore, s.relyingParty, user, sessionID, name, response)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+
+	data := map[string]interface{}{}
+	if mfaToken != "" {
+		tokenInfo, err := loginmanager.CompleteMFALogin(s.userStore, s.refreshTokenStore, s.revocationStore, s.accessGenerate, user, mfaToken)
+		if err != nil {
+			s.errorResponse(c, err)
+			return
+		}
+		data = s.getTokenData(tokenInfo)
+	}
+	data["credential"] = credential
+	s.successResponse(c, data)
+}
+
+// StartWebAuthnLoginRequest gives the options with which the browser gets an assertion of a credential.
+// The credential completes the login as a second factor when the request holds an MFA challenge token,
+// otherwise the user logs in without a password.
+func (s *Server) StartWebAuthnLoginRequest(c *gin.Context) {
+	user, _, err := s.getMFAChallengeUser(c)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	if user != nil && len(user.WebAuthnCredentials) == 0 {
+		s.errorResponse(c, errors.ErrMFANotEnabled)
+		return
+	}
+
+	sessionID, options, err := webauthnmanager.StartLogin(s.webAuthnSessionStore, s.relyingParty, user)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	s.successResponse(c, gin.H{
+		"session_id": sessionID,
+		"options":    options,
+	})
+}
+
+// FinishWebAuthnLoginRequest verifies the assertion of the credential and logs in the user
+func (s *Server) FinishWebAuthnLoginRequest(c *gin.Context, sessionID string, response *webauthn.CredentialResponse) {
+	challengeUser, mfaToken, err := s.getMFAChallengeUser(c)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+
+	user, session, err := webauthnmanager.FinishLogin(s.userStore, s.webAuthnSessionStore, s.relyingParty, sessionID, response)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+
+	var tokenInfo *models.Token
+	if session.UserUID != "" {
+		// The second factor has to be completed by the user who proved the password
+		if challengeUser == nil || challengeUser.UID != session.UserUID {
+			s.errorResponse(c, errors.ErrInvalidAccessToken)
+			return
+		}
+		tokenInfo, err = loginmanager.CompleteMFALogin(s.userStore, s.refreshTokenStore, s.revocationStore, s.accessGenerate, user, mfaToken)
+	} else {
+		tokenInfo, err = loginmanager.PasswordlessLoginUser(s.userStore, s.refreshTokenStore, s.accessGenerate, user)
+	}
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	s.successResponse(c, s.getTokenData(tokenInfo))
+}
+
+// DeleteWebAuthnCredentialRequest removes the webauthn credential of the user
+func (s *Server) DeleteWebAuthnCredentialRequest(c *gin.Context, credentialID string) {
+	jwtUser, exists := c.Get(types.JWTUserCredentialsKey)
+	if !exists {
+		s.errorResponse(c, errors.ErrInvalidAccessToken)
+		return
+	}
+	jwtUserCredentials := jwtUser.(*models.UserCredentials)
+
+	err := webauthnmanager.RemoveCredential(s.userStore, jwtUserCredentials, credentialID, s.Config.EnforceAdminMFA)
+	if err != nil {
+		s.errorResponse(c, err)
+		return
+	}
+	s.successResponse(c, jwtUserCredentials.GetPublicInfo())
+}
@@ -0,0 +1,84 @@
+package store
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/types"
+)
+
+// AuthorizationRequestStore MongoDB storage for the authorization requests of the OpenID Connect clients
+type AuthorizationRequestStore struct {
+	mongoCollection
+}
+
+// NewAuthorizationRequestStoreWithSession create an authorization request store instance based on mongodb
+func NewAuthorizationRequestStoreWithSession(session *mgo.Session, dbName string) (*AuthorizationRequestStore, error) {
+	as := &AuthorizationRequestStore{
+		mongoCollection: mongoCollection{
+			dbName:  dbName,
+			cName:   types.DefaultAuthorizationRequestCollection,
+			session: session,
+		},
+	}
+
+	err := as.ensureIndexes(
+		mgo.Index{Key: []string{"request_id"}, Unique: true},
+		mgo.Index{Key: []string{"code_hash"}, Sparse: true},
+		// Mongo removes the requests by itself once they expire
+		mgo.Index{Key: []string{"expires_at"}, ExpireAfter: time.Second},
+	)
+	return as, err
+}
+
+// Set stores a new authorization request
+func (as *AuthorizationRequestStore) Set(request *models.AuthorizationRequest) (err error) {
+	as.cHandler(func(c *mgo.Collection) {
+		if cerr := c.Insert(request); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// GetPending gets the authorization request having the given request id for which no code was issued yet
+func (as *AuthorizationRequestStore) GetPending(requestID string) (request *models.AuthorizationRequest, err error) {
+	as.cHandler(func(c *mgo.Collection) {
+		request = new(models.AuthorizationRequest)
+		query := bson.M{"request_id": requestID, "code_hash": bson.M{"$exists": false}}
+		if cerr := c.Find(query).One(request); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// Update updates the authorization request
+func (as *AuthorizationRequestStore) Update(request *models.AuthorizationRequest) (err error) {
+	as.cHandler(func(c *mgo.Collection) {
+		if cerr := c.UpdateId(request.ID, request); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// TakeByCodeHash atomically removes and returns the authorization request of the given code,
+// so that an authorization code can be redeemed only once
+func (as *AuthorizationRequestStore) TakeByCodeHash(codeHash string) (request *models.AuthorizationRequest, err error) {
+	as.cHandler(func(c *mgo.Collection) {
+		request = new(models.AuthorizationRequest)
+		change := mgo.Change{Remove: true}
+		if _, cerr := c.Find(bson.M{"code_hash": codeHash}).Apply(change, request); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/types"
+)
+
+// DeviceAuthorizationStore MongoDB storage for the device authorizations
+type DeviceAuthorizationStore struct {
+	mongoCollection
+}
+
+// NewDeviceAuthorizationStoreWithSession create a device authorization store instance based on mongodb
+func NewDeviceAuthorizationStoreWithSession(session *mgo.Session, dbName string) (*DeviceAuthorizationStore, error) {
+	ds := &DeviceAuthorizationStore{
+		mongoCollection: mongoCollection{
+			dbName:  dbName,
+			cName:   types.DefaultDeviceAuthorizationCollection,
+			session: session,
+		},
+	}
+
+	err := ds.ensureIndexes(
+		mgo.Index{Key: []string{"device_code_hash"}, Unique: true},
+		mgo.Index{Key: []string{"user_code"}, Unique: true},
+		// Mongo removes the device authorizations by itself once they expire
+		mgo.Index{Key: []string{"expires_at"}, ExpireAfter: time.Second},
+	)
+	return ds, err
+}
+
+// Set stores a new device authorization
+func (ds *DeviceAuthorizationStore) Set(authorization *models.DeviceAuthorization) (err error) {
+	ds.cHandler(func(c *mgo.Collection) {
+		if cerr := c.Insert(authorization); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// Decide atomically approves or denies the pending device authorization having the given user code, the user is
+// recorded along with an approval. mgo.ErrNotFound is returned if there is no such authorization or it was decided
+// already.
+func (ds *DeviceAuthorizationStore) Decide(userCode string, status models.DeviceAuthorizationStatus, uid string) (authorization *models.DeviceAuthorization, err error) {
+	ds.cHandler(func(c *mgo.Collection) {
+		authorization = new(models.DeviceAuthorization)
+		set := bson.M{"status": status}
+		if uid != "" {
+			set["uid"] = uid
+		}
+		change := mgo.Change{Update: bson.M{"$set": set}, ReturnNew: true}
+		query := bson.M{"user_code": userCode, "status": models.DeviceAuthorizationPending}
+		if _, cerr := c.Find(query).Apply(change, authorization); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// Poll atomically records a poll of the device authorization having the given device code hash and returns the
+// authorization as it was before the poll
+func (ds *DeviceAuthorizationStore) Poll(deviceCodeHash string) (authorization *models.DeviceAuthorization, err error) {
+	ds.cHandler(func(c *mgo.Collection) {
+		authorization = new(models.DeviceAuthorization)
+		change := mgo.Change{Update: bson.M{"$set": bson.M{"last_polled_at": time.Now()}}}
+		if _, cerr := c.Find(bson.M{"device_code_hash": deviceCodeHash}).Apply(change, authorization); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// SlowDown increases the polling interval of the device authorization by the given seconds
+func (ds *DeviceAuthorizationStore) SlowDown(deviceCodeHash string, seconds int) (err error) {
+	ds.cHandler(func(c *mgo.Collection) {
+		update := bson.M{"$inc": bson.M{"interval": seconds}}
+		if cerr := c.Update(bson.M{"device_code_hash": deviceCodeHash}, update); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// Take atomically removes and returns the device authorization having the given device code hash and status,
+// so that a device authorization can be redeemed only once
+func (ds *DeviceAuthorizationStore) Take(deviceCodeHash string, status models.DeviceAuthorizationStatus) (authorization *models.DeviceAuthorization, err error) {
+	ds.cHandler(func(c *mgo.Collection) {
+		authorization = new(models.DeviceAuthorization)
+		change := mgo.Change{Remove: true}
+		query := bson.M{"device_code_hash": deviceCodeHash, "status": status}
+		if _, cerr := c.Find(query).Apply(change, authorization); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
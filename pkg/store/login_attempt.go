@@ -0,0 +1,89 @@
+package store
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/types"
+)
+
+// LoginAttemptStore MongoDB storage for the failed attempts of the clients
+type LoginAttemptStore struct {
+	mongoCollection
+}
+
+// NewLoginAttemptStoreWithSession create a login attempt store instance based on mongodb
+func NewLoginAttemptStoreWithSession(session *mgo.Session, dbName string) (*LoginAttemptStore, error) {
+	ls := &LoginAttemptStore{
+		mongoCollection: mongoCollection{
+			dbName:  dbName,
+			cName:   types.DefaultLoginAttemptCollection,
+			session: session,
+		},
+	}
+
+	err := ls.ensureIndexes(
+		mgo.Index{Key: []string{"key"}, Unique: true},
+		// Mongo forgets the attempts by itself once they expire
+		mgo.Index{Key: []string{"expires_at"}, ExpireAfter: time.Second},
+	)
+	return ls, err
+}
+
+// Get gives the attempts of the given key, nil when there are no recent attempts
+func (ls *LoginAttemptStore) Get(key string) (attempt *models.LoginAttempt, err error) {
+	ls.cHandler(func(c *mgo.Collection) {
+		attempt = new(models.LoginAttempt)
+		if cerr := c.Find(bson.M{"key": key}).One(attempt); cerr == mgo.ErrNotFound {
+			attempt = nil
+		} else if cerr != nil {
+			err = cerr
+		}
+	})
+	return
+}
+
+// Increment atomically counts a failed attempt of the given key and gives the updated attempts
+func (ls *LoginAttemptStore) Increment(key string, expiresAt time.Time) (attempt *models.LoginAttempt, err error) {
+	ls.cHandler(func(c *mgo.Collection) {
+		attempt = new(models.LoginAttempt)
+		change := mgo.Change{
+			Update: bson.M{
+				"$inc": bson.M{"failures": 1},
+				"$set": bson.M{"expires_at": expiresAt},
+			},
+			Upsert:    true,
+			ReturnNew: true,
+		}
+		if _, cerr := c.Find(bson.M{"key": key}).Apply(change, attempt); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// Lock locks the attempts of the given key till the given time
+func (ls *LoginAttemptStore) Lock(key string, lockedUntil time.Time) (err error) {
+	ls.cHandler(func(c *mgo.Collection) {
+		if cerr := c.Update(bson.M{"key": key}, bson.M{"$set": bson.M{"locked_until": lockedUntil}}); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// Remove forgets the attempts of the given key
+func (ls *LoginAttemptStore) Remove(key string) (err error) {
+	ls.cHandler(func(c *mgo.Collection) {
+		if cerr := c.Remove(bson.M{"key": key}); cerr != nil && cerr != mgo.ErrNotFound {
+			err = cerr
+			return
+		}
+	})
+	return
+}
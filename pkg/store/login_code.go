@@ -0,0 +1,59 @@
+package store
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/types"
+)
+
+// LoginCodeStore MongoDB storage for the one-time login codes
+type LoginCodeStore struct {
+	mongoCollection
+}
+
+// NewLoginCodeStoreWithSession create a login code store instance based on mongodb
+func NewLoginCodeStoreWithSession(session *mgo.Session, dbName string) (*LoginCodeStore, error) {
+	ls := &LoginCodeStore{
+		mongoCollection: mongoCollection{
+			dbName:  dbName,
+			cName:   types.DefaultLoginCodeCollection,
+			session: session,
+		},
+	}
+
+	err := ls.ensureIndexes(
+		mgo.Index{Key: []string{"hash"}, Unique: true},
+		// Mongo removes the codes which haven't been exchanged by itself once they expire
+		mgo.Index{Key: []string{"expires_at"}, ExpireAfter: time.Second},
+	)
+	return ls, err
+}
+
+// Set stores a new login code
+func (ls *LoginCodeStore) Set(code *models.LoginCode) (err error) {
+	ls.cHandler(func(c *mgo.Collection) {
+		if cerr := c.Insert(code); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// Take atomically removes the unexpired login code having the given hash and gives it back.
+// mgo.ErrNotFound is returned if there is no such code, it has expired or it has been taken already.
+func (ls *LoginCodeStore) Take(hash string) (code *models.LoginCode, err error) {
+	ls.cHandler(func(c *mgo.Collection) {
+		code = new(models.LoginCode)
+		query := bson.M{"hash": hash, "expires_at": bson.M{"$gt": time.Now()}}
+		if _, cerr := c.Find(query).Apply(mgo.Change{Remove: true}, code); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
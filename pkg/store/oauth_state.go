@@ -0,0 +1,58 @@
+package store
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/types"
+)
+
+// OAuthStateStore MongoDB storage for the states of the pending social logins
+type OAuthStateStore struct {
+	mongoCollection
+}
+
+// NewOAuthStateStoreWithSession create an oauth state store instance based on mongodb
+func NewOAuthStateStoreWithSession(session *mgo.Session, dbName string) (*OAuthStateStore, error) {
+	ss := &OAuthStateStore{
+		mongoCollection: mongoCollection{
+			dbName:  dbName,
+			cName:   types.DefaultOAuthStateCollection,
+			session: session,
+		},
+	}
+
+	err := ss.ensureIndexes(
+		mgo.Index{Key: []string{"state_hash"}, Unique: true},
+		// Mongo removes the states of abandoned logins by itself once they expire
+		mgo.Index{Key: []string{"expires_at"}, ExpireAfter: time.Second},
+	)
+	return ss, err
+}
+
+// Set stores a new oauth state
+func (ss *OAuthStateStore) Set(state *models.OAuthState) (err error) {
+	ss.cHandler(func(c *mgo.Collection) {
+		if cerr := c.Insert(state); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// Take atomically removes and returns the oauth state of the given hash, so that a state can be used only once
+func (ss *OAuthStateStore) Take(stateHash string) (state *models.OAuthState, err error) {
+	ss.cHandler(func(c *mgo.Collection) {
+		state = new(models.OAuthState)
+		change := mgo.Change{Remove: true}
+		if _, cerr := c.Find(bson.M{"state_hash": stateHash}).Apply(change, state); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
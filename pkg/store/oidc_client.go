@@ -0,0 +1,77 @@
+package store
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/types"
+)
+
+// ClientStore MongoDB storage for the registered OpenID Connect clients
+type ClientStore struct {
+	mongoCollection
+}
+
+// NewClientStoreWithSession create a client store instance based on mongodb
+func NewClientStoreWithSession(session *mgo.Session, dbName string) (*ClientStore, error) {
+	cs := &ClientStore{
+		mongoCollection: mongoCollection{
+			dbName:  dbName,
+			cName:   types.DefaultClientCollection,
+			session: session,
+		},
+	}
+
+	err := cs.ensureIndexes(mgo.Index{Key: []string{"client_id"}, Unique: true})
+	return cs, err
+}
+
+// Set stores a new client
+func (cs *ClientStore) Set(client *models.Client) (err error) {
+	cs.cHandler(func(c *mgo.Collection) {
+		t := time.Now()
+		client.CreatedAt = &t
+		if cerr := c.Insert(client); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// Get gets the client having the given client id
+func (cs *ClientStore) Get(clientID string) (client *models.Client, err error) {
+	cs.cHandler(func(c *mgo.Collection) {
+		client = new(models.Client)
+		if cerr := c.Find(bson.M{"client_id": clientID}).One(client); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// GetAll gets all the registered clients
+func (cs *ClientStore) GetAll() (clients []*models.Client, err error) {
+	cs.cHandler(func(c *mgo.Collection) {
+		if cerr := c.Find(bson.M{}).All(&clients); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// Remove removes the client having the given client id
+func (cs *ClientStore) Remove(clientID string) (err error) {
+	cs.cHandler(func(c *mgo.Collection) {
+		if cerr := c.Remove(bson.M{"client_id": clientID}); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
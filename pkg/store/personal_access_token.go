@@ -0,0 +1,93 @@
+package store
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/types"
+)
+
+// PersonalAccessTokenStore MongoDB storage for the personal access tokens
+type PersonalAccessTokenStore struct {
+	mongoCollection
+}
+
+// NewPersonalAccessTokenStoreWithSession create a personal access token store instance based on mongodb
+func NewPersonalAccessTokenStoreWithSession(session *mgo.Session, dbName string) (*PersonalAccessTokenStore, error) {
+	ps := &PersonalAccessTokenStore{
+		mongoCollection: mongoCollection{
+			dbName:  dbName,
+			cName:   types.DefaultPersonalAccessTokenCollection,
+			session: session,
+		},
+	}
+
+	err := ps.ensureIndexes(
+		mgo.Index{Key: []string{"hash"}, Unique: true},
+		mgo.Index{Key: []string{"token_id"}, Unique: true},
+		mgo.Index{Key: []string{"uid"}},
+		// Mongo removes the personal access tokens by itself once they expire
+		mgo.Index{Key: []string{"expires_at"}, ExpireAfter: time.Second},
+	)
+	return ps, err
+}
+
+// Set stores a new personal access token
+func (ps *PersonalAccessTokenStore) Set(token *models.PersonalAccessToken) (err error) {
+	ps.cHandler(func(c *mgo.Collection) {
+		if cerr := c.Insert(token); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// Get gets the personal access token having the given hash
+func (ps *PersonalAccessTokenStore) Get(hash string) (token *models.PersonalAccessToken, err error) {
+	ps.cHandler(func(c *mgo.Collection) {
+		token = new(models.PersonalAccessToken)
+		if cerr := c.Find(bson.M{"hash": hash}).One(token); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// GetByUserUID gets all the personal access tokens of a user, the newest first
+func (ps *PersonalAccessTokenStore) GetByUserUID(uid string) (tokens []*models.PersonalAccessToken, err error) {
+	ps.cHandler(func(c *mgo.Collection) {
+		if cerr := c.Find(bson.M{"uid": uid}).Sort("-created_at").All(&tokens); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// MarkUsed records the time and the ip of the last use of the personal access token having the given hash
+func (ps *PersonalAccessTokenStore) MarkUsed(hash, ip string) (err error) {
+	ps.cHandler(func(c *mgo.Collection) {
+		update := bson.M{"$set": bson.M{"last_used_at": time.Now(), "last_used_ip": ip}}
+		if cerr := c.Update(bson.M{"hash": hash}, update); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// Remove removes the personal access token of the user having the given token id
+func (ps *PersonalAccessTokenStore) Remove(uid, tokenID string) (err error) {
+	ps.cHandler(func(c *mgo.Collection) {
+		if cerr := c.Remove(bson.M{"uid": uid, "token_id": tokenID}); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
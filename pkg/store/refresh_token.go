@@ -0,0 +1,98 @@
+package store
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/types"
+)
+
+// RefreshTokenStore MongoDB storage for the refresh tokens
+type RefreshTokenStore struct {
+	mongoCollection
+}
+
+// NewRefreshTokenStoreWithSession create a refresh token store instance based on mongodb
+func NewRefreshTokenStoreWithSession(session *mgo.Session, dbName string) (*RefreshTokenStore, error) {
+	rs := &RefreshTokenStore{
+		mongoCollection: mongoCollection{
+			dbName:  dbName,
+			cName:   types.DefaultRefreshTokenCollection,
+			session: session,
+		},
+	}
+
+	err := rs.ensureIndexes(
+		mgo.Index{Key: []string{"hash"}, Unique: true},
+		mgo.Index{Key: []string{"family_id"}},
+		mgo.Index{Key: []string{"uid"}},
+		// Mongo removes the refresh tokens by itself once they expire
+		mgo.Index{Key: []string{"expires_at"}, ExpireAfter: time.Second},
+	)
+	return rs, err
+}
+
+// Set stores a new refresh token
+func (rs *RefreshTokenStore) Set(token *models.RefreshToken) (err error) {
+	rs.cHandler(func(c *mgo.Collection) {
+		if cerr := c.Insert(token); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// Get gets the refresh token having the given hash
+func (rs *RefreshTokenStore) Get(hash string) (token *models.RefreshToken, err error) {
+	rs.cHandler(func(c *mgo.Collection) {
+		token = new(models.RefreshToken)
+		if cerr := c.Find(bson.M{"hash": hash}).One(token); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// MarkUsed atomically marks the refresh token having the given hash as used.
+// mgo.ErrNotFound is returned if there is no such token or it has been used already.
+func (rs *RefreshTokenStore) MarkUsed(hash string) (token *models.RefreshToken, err error) {
+	rs.cHandler(func(c *mgo.Collection) {
+		token = new(models.RefreshToken)
+		change := mgo.Change{
+			Update: bson.M{"$set": bson.M{"used_at": time.Now()}},
+		}
+		query := bson.M{"hash": hash, "used_at": bson.M{"$exists": false}}
+		if _, cerr := c.Find(query).Apply(change, token); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// RemoveFamily removes all the refresh tokens of a family
+func (rs *RefreshTokenStore) RemoveFamily(familyID string) (err error) {
+	rs.cHandler(func(c *mgo.Collection) {
+		if _, cerr := c.RemoveAll(bson.M{"family_id": familyID}); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// RemoveByUserUID removes all the refresh tokens issued to a user
+func (rs *RefreshTokenStore) RemoveByUserUID(uid string) (err error) {
+	rs.cHandler(func(c *mgo.Collection) {
+		if _, cerr := c.RemoveAll(bson.M{"uid": uid}); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
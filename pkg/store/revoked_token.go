@@ -0,0 +1,74 @@
+package store
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/types"
+)
+
+// RevocationStore MongoDB storage for the list of revoked tokens
+type RevocationStore struct {
+	mongoCollection
+}
+
+// NewRevocationStoreWithSession create a revocation store instance based on mongodb
+func NewRevocationStoreWithSession(session *mgo.Session, dbName string) (*RevocationStore, error) {
+	rs := &RevocationStore{
+		mongoCollection: mongoCollection{
+			dbName:  dbName,
+			cName:   types.DefaultRevokedTokenCollection,
+			session: session,
+		},
+	}
+
+	err := rs.ensureIndexes(
+		mgo.Index{Key: []string{"jti"}, Unique: true},
+		// Mongo removes the entries by itself once the revoked tokens expire
+		mgo.Index{Key: []string{"expires_at"}, ExpireAfter: time.Second},
+	)
+	return rs, err
+}
+
+// Set adds a token to the revocation list, revoking a token twice is not an error
+func (rs *RevocationStore) Set(token *models.RevokedToken) (err error) {
+	rs.cHandler(func(c *mgo.Collection) {
+		if cerr := c.Insert(token); cerr != nil && !mgo.IsDup(cerr) {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// Claim adds a token to the revocation list, giving false if the token has already been revoked.
+// It lets a single-use token be consumed only once, even by concurrent requests.
+func (rs *RevocationStore) Claim(token *models.RevokedToken) (claimed bool, err error) {
+	rs.cHandler(func(c *mgo.Collection) {
+		cerr := c.Insert(token)
+		if mgo.IsDup(cerr) {
+			return
+		} else if cerr != nil {
+			err = cerr
+			return
+		}
+		claimed = true
+	})
+	return
+}
+
+// IsRevoked checks whether the token having the given jti is in the revocation list
+func (rs *RevocationStore) IsRevoked(jti string) (revoked bool, err error) {
+	rs.cHandler(func(c *mgo.Collection) {
+		count, cerr := c.Find(bson.M{"jti": jti}).Count()
+		if cerr != nil {
+			err = cerr
+			return
+		}
+		revoked = count > 0
+	})
+	return
+}
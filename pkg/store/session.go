@@ -0,0 +1,55 @@
+package store
+
+import (
+	"github.com/globalsign/mgo"
+	log "github.com/golang/glog"
+
+	"github.com/mayadata-io/kubera-auth/pkg/types"
+)
+
+// NewSession dials the mongodb server and logs in if database credentials are configured
+func NewSession(cfg *Config) (*mgo.Session, error) {
+	session, err := mgo.Dial(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if types.DBUser != "" && types.DBPassword != "" {
+		cred := mgo.Credential{
+			Username: types.DBUser,
+			Password: types.DBPassword,
+		}
+		err = session.Login(&cred)
+		if err != nil {
+			log.Errorln("Error connecting database error", err)
+			return nil, err
+		}
+	}
+	return session, nil
+}
+
+// mongoCollection is embedded by the stores which persist their documents in a single collection
+type mongoCollection struct {
+	dbName  string
+	cName   string
+	session *mgo.Session
+}
+
+func (mc *mongoCollection) cHandler(handler func(c *mgo.Collection)) {
+	session := mc.session.Clone()
+	defer session.Close()
+	handler(session.DB(mc.dbName).C(mc.cName))
+}
+
+// ensureIndexes creates the given indexes on the collection if they do not exist already
+func (mc *mongoCollection) ensureIndexes(indexes ...mgo.Index) (err error) {
+	mc.cHandler(func(c *mgo.Collection) {
+		for _, index := range indexes {
+			if cerr := c.EnsureIndex(index); cerr != nil {
+				err = cerr
+				return
+			}
+		}
+	})
+	return
+}
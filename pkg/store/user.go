@@ -1,11 +1,13 @@
 package store
 
 import (
+	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
-	log "github.com/golang/glog"
 
 	"github.com/mayadata-io/kubera-auth/pkg/models"
 	"github.com/mayadata-io/kubera-auth/pkg/types"
@@ -26,29 +28,17 @@ func NewDefaultUserConfig() *UserConfig {
 
 // NewUserStore create a user store instance based on mongodb
 func NewUserStore(cfg *Config, ucfgs ...*UserConfig) (*UserStore, error) {
-	session, err := mgo.Dial(cfg.URL)
+	session, err := NewSession(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	if types.DBUser != "" && types.DBPassword != "" {
-		cred := mgo.Credential{
-			Username: types.DBUser,
-			Password: types.DBPassword,
-		}
-		err = session.Login(&cred)
-		if err != nil {
-			log.Errorln("Error connecting database error", err)
-			return nil, err
-		}
-	}
-
 	return NewUserStoreWithSession(session, cfg.DB, ucfgs...)
 }
 
 // NewUserStoreWithSession create a User store instance based on mongodb
-func NewUserStoreWithSession(session *mgo.Session, dbName string, ucfgs ...*UserConfig) (*UserStore, error) {
-	us := &UserStore{
+func NewUserStoreWithSession(session *mgo.Session, dbName string, ucfgs ...*UserConfig) (us *UserStore, err error) {
+	us = &UserStore{
 		dbName:  dbName,
 		session: session,
 		ucfg:    NewDefaultUserConfig(),
@@ -57,7 +47,21 @@ func NewUserStoreWithSession(session *mgo.Session, dbName string, ucfgs ...*User
 		us.ucfg = ucfgs[0]
 	}
 
-	return us, nil
+	// An account of a provider and a webauthn credential can belong to a single user,
+	// the users without identities or credentials are not indexed
+	indexes := []mgo.Index{
+		{Key: []string{"identities.provider", "identities.social_auth_id"}, Unique: true, Sparse: true},
+		{Key: []string{"webauthn_credentials.id"}, Unique: true, Sparse: true},
+	}
+	us.cHandler(us.ucfg.UsersCName, func(c *mgo.Collection) {
+		for _, index := range indexes {
+			if cerr := c.EnsureIndex(index); cerr != nil {
+				err = cerr
+				return
+			}
+		}
+	})
+	return us, err
 }
 
 // UserStore MongoDB storage for OAuth 2.0
@@ -109,12 +113,242 @@ func (us *UserStore) GetAllUsers() (users []*models.UserCredentials, err error)
 	return
 }
 
-//UpdateUser updates the user
+// userFields are the bson names of the fields of the users
+var userFields = bsonFields(reflect.TypeOf(models.UserCredentials{}))
+
+// atomicUserFields are the fields of the users which are only written by the targeted updates of the store,
+// such as RevokeTokens. UpdateUser leaves them alone so that writing a stale copy of a user can't revert them.
+var atomicUserFields = map[string]bool{
+	"tokens_revoked_at":    true,
+	"mfa":                  true,
+	"webauthn_credentials": true,
+	"magic_link_requests":  true,
+	"failed_logins":        true,
+	"locked_until":         true,
+}
+
+//UpdateUser updates the user, except for the fields of atomicUserFields
 func (us *UserStore) UpdateUser(user *models.UserCredentials) (err error) {
 	us.cHandler(us.ucfg.UsersCName, func(c *mgo.Collection) {
 		t := time.Now()
 		user.UpdatedAt = &t
-		if cerr := c.UpdateId(user.ID, user); cerr != nil {
+		update, cerr := userUpdate(user)
+		if cerr != nil {
+			err = cerr
+			return
+		}
+		if cerr = c.UpdateId(user.ID, update); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// userUpdate gives the update replacing the stored fields of the user by the ones of the given user,
+// the empty fields are unset as a replacement of the whole document would do
+func userUpdate(user *models.UserCredentials) (bson.M, error) {
+	raw, err := bson.Marshal(user)
+	if err != nil {
+		return nil, err
+	}
+	set := bson.M{}
+	if err = bson.Unmarshal(raw, set); err != nil {
+		return nil, err
+	}
+
+	unset := bson.M{}
+	for _, field := range userFields {
+		if _, ok := set[field]; !ok {
+			unset[field] = ""
+		}
+	}
+	delete(set, "_id")
+	delete(unset, "_id")
+	for field := range atomicUserFields {
+		delete(set, field)
+		delete(unset, field)
+	}
+
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	return update, nil
+}
+
+// bsonFields gives the bson names of the fields of the struct type
+func bsonFields(t reflect.Type) []string {
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("bson"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		fields = append(fields, name)
+	}
+	return fields
+}
+
+// RevokeTokens revokes all the tokens issued to the user till the given time and marks the user as logged out
+func (us *UserStore) RevokeTokens(uid string, at time.Time) (err error) {
+	us.cHandler(us.ucfg.UsersCName, func(c *mgo.Collection) {
+		update := bson.M{"$set": bson.M{"tokens_revoked_at": at, "logged_in": false}}
+		if cerr := c.Update(bson.M{"uid": uid}, update); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// SetLoggedIn marks the user as logged in or out
+func (us *UserStore) SetLoggedIn(uid string, loggedIn bool) (err error) {
+	us.cHandler(us.ucfg.UsersCName, func(c *mgo.Collection) {
+		if cerr := c.Update(bson.M{"uid": uid}, bson.M{"$set": bson.M{"logged_in": loggedIn}}); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// SetSocialLogin marks the user as logged in with the photo and the role given by a login provider, the empty
+// ones are left as is
+func (us *UserStore) SetSocialLogin(uid, photo string, role models.Role) (err error) {
+	us.cHandler(us.ucfg.UsersCName, func(c *mgo.Collection) {
+		fields := bson.M{"logged_in": true}
+		if photo != "" {
+			fields["photo"] = photo
+		}
+		if role != "" {
+			fields["role"] = role
+		}
+		if cerr := c.Update(bson.M{"uid": uid}, bson.M{"$set": fields}); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// SetMFA replaces the MFA of the user, it is removed when nil
+func (us *UserStore) SetMFA(uid string, mfa *models.MFA) (err error) {
+	us.cHandler(us.ucfg.UsersCName, func(c *mgo.Collection) {
+		update := bson.M{"$set": bson.M{"mfa": mfa, "updated_at": time.Now()}}
+		if mfa == nil {
+			update = bson.M{"$unset": bson.M{"mfa": ""}, "$set": bson.M{"updated_at": time.Now()}}
+		}
+		if cerr := c.Update(bson.M{"uid": uid}, update); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// UseMFACounter atomically records the time step of an accepted code of the authenticator app, mgo.ErrNotFound is
+// returned when a code of the same or a later time step has already been accepted
+func (us *UserStore) UseMFACounter(uid string, counter int64) (err error) {
+	us.cHandler(us.ucfg.UsersCName, func(c *mgo.Collection) {
+		query := bson.M{
+			"uid":         uid,
+			"mfa.enabled": true,
+			"$or": []bson.M{
+				{"mfa.last_counter": bson.M{"$lt": counter}},
+				{"mfa.last_counter": bson.M{"$exists": false}},
+			},
+		}
+		if cerr := c.Update(query, bson.M{"$set": bson.M{"mfa.last_counter": counter}}); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// UseRecoveryCode atomically removes the hash of a recovery code of the user, mgo.ErrNotFound is returned when
+// the code has already been used
+func (us *UserStore) UseRecoveryCode(uid, hash string) (err error) {
+	us.cHandler(us.ucfg.UsersCName, func(c *mgo.Collection) {
+		query := bson.M{"uid": uid, "mfa.enabled": true, "mfa.recovery_code_hashes": hash}
+		if cerr := c.Update(query, bson.M{"$pull": bson.M{"mfa.recovery_code_hashes": hash}}); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// AddWebAuthnCredential adds the credential to the user, mgo.ErrNotFound is returned when the user already has it
+// and a duplicate key error when another user has it
+func (us *UserStore) AddWebAuthnCredential(uid string, credential models.WebAuthnCredential) (err error) {
+	us.cHandler(us.ucfg.UsersCName, func(c *mgo.Collection) {
+		query := bson.M{"uid": uid, "webauthn_credentials.id": bson.M{"$ne": credential.ID}}
+		update := bson.M{
+			"$push": bson.M{"webauthn_credentials": credential},
+			"$set":  bson.M{"updated_at": time.Now()},
+		}
+		if cerr := c.Update(query, update); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// UseWebAuthnCredential records the signature counter of a login with the credential of the user
+func (us *UserStore) UseWebAuthnCredential(uid, credentialID string, signCount uint32, usedAt time.Time) (err error) {
+	us.cHandler(us.ucfg.UsersCName, func(c *mgo.Collection) {
+		query := bson.M{"uid": uid, "webauthn_credentials.id": credentialID}
+		update := bson.M{"$set": bson.M{
+			"webauthn_credentials.$.sign_count":   signCount,
+			"webauthn_credentials.$.last_used_at": usedAt,
+		}}
+		if cerr := c.Update(query, update); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// RemoveWebAuthnCredential removes the credential of the user
+func (us *UserStore) RemoveWebAuthnCredential(uid, credentialID string) (err error) {
+	us.cHandler(us.ucfg.UsersCName, func(c *mgo.Collection) {
+		update := bson.M{
+			"$pull": bson.M{"webauthn_credentials": bson.M{"id": credentialID}},
+			"$set":  bson.M{"updated_at": time.Now()},
+		}
+		if cerr := c.Update(bson.M{"uid": uid}, update); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// AddMagicLinkRequest records a magic link mailed to the user at the given time, unless the user requested one since
+// intervalStart or already requested limit of them since windowStart, in which case mgo.ErrNotFound is returned.
+// Only the last limit requests are kept, the check and the update are a single operation so that concurrent requests
+// can't exceed the limit.
+func (us *UserStore) AddMagicLinkRequest(uid string, requestedAt, intervalStart, windowStart time.Time, limit int) (err error) {
+	us.cHandler(us.ucfg.UsersCName, func(c *mgo.Collection) {
+		query := bson.M{
+			"uid":                 uid,
+			"magic_link_requests": bson.M{"$not": bson.M{"$gte": intervalStart}},
+			"$or": []bson.M{
+				{"magic_link_requests." + strconv.Itoa(limit-1): bson.M{"$exists": false}},
+				{"magic_link_requests.0": bson.M{"$lt": windowStart}},
+			},
+		}
+		change := mgo.Change{
+			Update: bson.M{"$push": bson.M{"magic_link_requests": bson.M{
+				"$each":  []time.Time{requestedAt},
+				"$slice": -limit,
+			}}},
+		}
+		if _, cerr := c.Find(query).Apply(change, nil); cerr != nil {
 			err = cerr
 			return
 		}
@@ -151,3 +385,107 @@ func (us *UserStore) GetUser(query interface{}) (user *models.UserCredentials, e
 func (us *UserStore) GetUserByID(id bson.ObjectId) (user *models.UserCredentials, err error) {
 	return us.GetUser(bson.M{"_id": id})
 }
+
+// GetUsers gives all the users matching the query
+func (us *UserStore) GetUsers(query interface{}) (users []*models.UserCredentials, err error) {
+	us.cHandler(us.ucfg.UsersCName, func(c *mgo.Collection) {
+		if cerr := c.Find(query).All(&users); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// AddIdentity links the identity to the user, unless the user already has an identity of the same provider
+func (us *UserStore) AddIdentity(uid string, identity models.Identity) (err error) {
+	us.cHandler(us.ucfg.UsersCName, func(c *mgo.Collection) {
+		query := bson.M{
+			"uid":                 uid,
+			"identities.provider": bson.M{"$ne": identity.Provider},
+		}
+		update := bson.M{
+			"$push": bson.M{"identities": identity},
+			"$set":  bson.M{"updated_at": time.Now()},
+		}
+		if cerr := c.Update(query, update); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// IncrementFailedLogins atomically counts a failed login of the user and gives the updated user
+func (us *UserStore) IncrementFailedLogins(uid string) (user *models.UserCredentials, err error) {
+	us.cHandler(us.ucfg.UsersCName, func(c *mgo.Collection) {
+		user = new(models.UserCredentials)
+		change := mgo.Change{
+			Update:    bson.M{"$inc": bson.M{"failed_logins": 1}},
+			ReturnNew: true,
+		}
+		if _, cerr := c.Find(bson.M{"uid": uid}).Apply(change, user); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// LockUser locks the login of the user till the given time
+func (us *UserStore) LockUser(uid string, lockedUntil time.Time) (err error) {
+	us.cHandler(us.ucfg.UsersCName, func(c *mgo.Collection) {
+		if cerr := c.Update(bson.M{"uid": uid}, bson.M{"$set": bson.M{"locked_until": lockedUntil}}); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// UpdatePasswordHash replaces the hash of the password of the user, such as when it is rehashed on login
+func (us *UserStore) UpdatePasswordHash(uid, hash string) (err error) {
+	us.cHandler(us.ucfg.UsersCName, func(c *mgo.Collection) {
+		if cerr := c.Update(bson.M{"uid": uid}, bson.M{"$set": bson.M{"password": hash}}); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// ResetFailedLogins forgets the failed logins of the user, unlocking its login
+func (us *UserStore) ResetFailedLogins(uid string) (err error) {
+	us.cHandler(us.ucfg.UsersCName, func(c *mgo.Collection) {
+		update := bson.M{"$unset": bson.M{"failed_logins": "", "locked_until": ""}}
+		if cerr := c.Update(bson.M{"uid": uid}, update); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// UpdateClientSecrets replaces the client secrets of the service account
+func (us *UserStore) UpdateClientSecrets(uid string, secrets []models.ClientSecret) (err error) {
+	us.cHandler(us.ucfg.UsersCName, func(c *mgo.Collection) {
+		query := bson.M{"uid": uid, "kind": models.ServiceAccountAuth}
+		update := bson.M{"$set": bson.M{"client_secrets": secrets, "updated_at": time.Now()}}
+		if cerr := c.Update(query, update); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// RemoveServiceAccount removes the service account having the given uid, the users can't be removed this way
+func (us *UserStore) RemoveServiceAccount(uid string) (err error) {
+	us.cHandler(us.ucfg.UsersCName, func(c *mgo.Collection) {
+		if cerr := c.Remove(bson.M{"uid": uid, "kind": models.ServiceAccountAuth}); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
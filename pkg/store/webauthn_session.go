@@ -0,0 +1,57 @@
+package store
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/types"
+)
+
+// WebAuthnSessionStore MongoDB storage for the pending WebAuthn ceremonies
+type WebAuthnSessionStore struct {
+	mongoCollection
+}
+
+// NewWebAuthnSessionStoreWithSession create a webauthn session store instance based on mongodb
+func NewWebAuthnSessionStoreWithSession(session *mgo.Session, dbName string) (*WebAuthnSessionStore, error) {
+	ws := &WebAuthnSessionStore{
+		mongoCollection: mongoCollection{
+			dbName:  dbName,
+			cName:   types.DefaultWebAuthnSessionCollection,
+			session: session,
+		},
+	}
+
+	err := ws.ensureIndexes(
+		// Mongo removes the sessions of abandoned ceremonies by itself once they expire
+		mgo.Index{Key: []string{"expires_at"}, ExpireAfter: time.Second},
+	)
+	return ws, err
+}
+
+// Set stores a new webauthn session
+func (ws *WebAuthnSessionStore) Set(session *models.WebAuthnSession) (err error) {
+	ws.cHandler(func(c *mgo.Collection) {
+		if cerr := c.Insert(session); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
+
+// Take atomically removes and returns the webauthn session of the given id, so that a challenge can be used only once
+func (ws *WebAuthnSessionStore) Take(id bson.ObjectId) (session *models.WebAuthnSession, err error) {
+	ws.cHandler(func(c *mgo.Collection) {
+		session = new(models.WebAuthnSession)
+		change := mgo.Change{Remove: true}
+		if _, cerr := c.FindId(id).Apply(change, session); cerr != nil {
+			err = cerr
+			return
+		}
+	})
+	return
+}
@@ -0,0 +1,194 @@
+// Package testutil provides the MongoDB database and the signing keyring on which the tests of the managers run.
+// The tests needing a database are skipped unless TEST_DB_SERVER gives the url of a MongoDB server, such as
+// mongodb://localhost:27017.
+package testutil
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/mayadata-io/kubera-auth/pkg/generates"
+	"github.com/mayadata-io/kubera-auth/pkg/k8s"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	"github.com/mayadata-io/kubera-auth/pkg/store"
+	"github.com/mayadata-io/kubera-auth/pkg/types"
+	"github.com/mayadata-io/kubera-auth/pkg/utils/uuid"
+)
+
+const (
+	// DBServerEnv is the environment variable giving the url of the MongoDB server of the tests
+	DBServerEnv = "TEST_DB_SERVER"
+	// Issuer and Audience are the issuer and the audience of the tokens signed in the tests
+	Issuer   = "https://kubera.example.org/api/auth"
+	Audience = "kubera-auth"
+)
+
+// Stores are the stores of the database of a test
+type Stores struct {
+	User                 *store.UserStore
+	RefreshToken         *store.RefreshTokenStore
+	LoginCode            *store.LoginCodeStore
+	Revocation           *store.RevocationStore
+	Client               *store.ClientStore
+	AuthorizationRequest *store.AuthorizationRequestStore
+	OAuthState           *store.OAuthStateStore
+	LoginAttempt         *store.LoginAttemptStore
+	PersonalAccessToken  *store.PersonalAccessTokenStore
+	DeviceAuthorization  *store.DeviceAuthorizationStore
+}
+
+// NewSession dials the MongoDB server of the tests and gives a database of its own to the test, which is dropped
+// once the test is over. The test is skipped if no server is configured.
+func NewSession(t *testing.T) (*mgo.Session, string) {
+	t.Helper()
+	url := os.Getenv(DBServerEnv)
+	if url == "" {
+		t.Skip(DBServerEnv + " is not set, skipping the test needing a database")
+	}
+
+	session, err := mgo.DialWithTimeout(url, time.Second*10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbName := "test_" + strings.ReplaceAll(uuid.Must(uuid.NewRandom()).String(), "-", "")
+	t.Cleanup(func() {
+		if err := session.DB(dbName).DropDatabase(); err != nil {
+			t.Log("Error dropping the test database ", err)
+		}
+		session.Close()
+	})
+	return session, dbName
+}
+
+// NewStores gives the stores of a database of the test, see NewSession
+func NewStores(t *testing.T) *Stores {
+	t.Helper()
+	session, dbName := NewSession(t)
+
+	stores := &Stores{}
+	var err error
+	must := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	stores.User, err = store.NewUserStoreWithSession(session, dbName, store.NewDefaultUserConfig())
+	must(err)
+	stores.RefreshToken, err = store.NewRefreshTokenStoreWithSession(session, dbName)
+	must(err)
+	stores.LoginCode, err = store.NewLoginCodeStoreWithSession(session, dbName)
+	must(err)
+	stores.Revocation, err = store.NewRevocationStoreWithSession(session, dbName)
+	must(err)
+	stores.Client, err = store.NewClientStoreWithSession(session, dbName)
+	must(err)
+	stores.AuthorizationRequest, err = store.NewAuthorizationRequestStoreWithSession(session, dbName)
+	must(err)
+	stores.OAuthState, err = store.NewOAuthStateStoreWithSession(session, dbName)
+	must(err)
+	stores.LoginAttempt, err = store.NewLoginAttemptStoreWithSession(session, dbName)
+	must(err)
+	stores.PersonalAccessToken, err = store.NewPersonalAccessTokenStoreWithSession(session, dbName)
+	must(err)
+	stores.DeviceAuthorization, err = store.NewDeviceAuthorizationStoreWithSession(session, dbName)
+	must(err)
+	return stores
+}
+
+// NewUser stores an active local user of the role, whose verified email is the username at example.org
+func NewUser(t *testing.T, userStore *store.UserStore, username string, role models.Role) *models.UserCredentials {
+	t.Helper()
+	createdAt := time.Now()
+	user := &models.UserCredentials{
+		ID:              bson.NewObjectId(),
+		UID:             uuid.Must(uuid.NewRandom()).String(),
+		UserName:        username,
+		Name:            username,
+		Email:           username + "@example.org",
+		Kind:            models.LocalAuth,
+		Role:            role,
+		State:           models.StateActive,
+		OnBoardingState: models.BoardingStateVerifiedAndComplete,
+		CreatedAt:       &createdAt,
+	}
+	if err := userStore.Set(user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// NewAccessGenerate gives a token generator whose keyring is kept in the secret of a fake Kubernetes API
+func NewAccessGenerate(t *testing.T) *generates.JWTAccessGenerate {
+	t.Helper()
+	server := httptest.NewServer(&secretServer{
+		secret: corev1.Secret{
+			TypeMeta:   metav1.TypeMeta{Kind: "Secret", APIVersion: "v1"},
+			ObjectMeta: metav1.ObjectMeta{Name: "kubera-auth-test", Namespace: "kubera", ResourceVersion: "1"},
+		},
+	})
+	clientSet, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previousClientSet, previousNamespace, previousCredentials := k8s.ClientSet, types.DefaultNamespace, types.Credentials
+	k8s.ClientSet, types.DefaultNamespace, types.Credentials = clientSet, "kubera", "kubera-auth-test"
+	t.Cleanup(func() {
+		k8s.ClientSet, types.DefaultNamespace, types.Credentials = previousClientSet, previousNamespace, previousCredentials
+		server.Close()
+	})
+
+	accessGenerate := generates.NewJWTAccessGenerate(jwt.SigningMethodES256, time.Hour, Issuer, Audience)
+	accessGenerate.LoginAudiences = []string{"kubera"}
+	return accessGenerate
+}
+
+// secretServer is a fake Kubernetes API serving a single secret
+type secretServer struct {
+	lock   sync.Mutex
+	secret corev1.Secret
+}
+
+func (s *secretServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !strings.HasSuffix(r.URL.Path, "/secrets/"+s.secret.Name) {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var secret corev1.Secret
+		if err := json.NewDecoder(r.Body).Decode(&secret); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		version, _ := strconv.Atoi(s.secret.ResourceVersion)
+		secret.ResourceVersion = strconv.Itoa(version + 1)
+		s.secret = secret
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(&s.secret); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
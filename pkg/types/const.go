@@ -3,14 +3,74 @@ package types
 
 // define the type of authorization request
 const (
-	JWTSecretString      = "JWT_SECRET"
-	GITHUB_CLIENT_ID     = "GITHUB_CLIENT_ID"
-	GITHUB_CLIENT_SECRET = "GITHUB_CLIENT_SECRET"
-	GOOGLE_CLIENT_ID     = "GOOGLE_CLIENT_ID"
-	GOOGLE_CLIENT_SECRET = "GOOGLE_CLIENT_SECRET"
-	GOOGLE_REDIRECT_URL  = "GOOGLE_REDIRECT_URL"
-	DISABLE_LOCALAUTH    = "DISABLE_LOCALAUTH"
-	DISABLE_GITHUBAUTH   = "DISABLE_GITHUBAUTH"
-	DISABLE_GOOGLEAUTH   = "DISABLE_GOOGLEAUTH"
-	BEARER               = "Bearer"
+	JWTSecretString             = "JWT_SECRET"
+	JWTSigningKeyString         = "JWT_SIGNING_KEY"
+	JWTKeyringString            = "JWT_KEYRING"
+	JWT_SIGNING_ALGORITHM       = "JWT_SIGNING_ALGORITHM"
+	JWT_KEY_ROTATION_INTERVAL   = "JWT_KEY_ROTATION_INTERVAL"
+	ISSUER_URL                  = "ISSUER_URL"
+	JWT_AUDIENCE                = "JWT_AUDIENCE"
+	JWT_LOGIN_AUDIENCES         = "JWT_LOGIN_AUDIENCES"
+	ALLOWED_REDIRECT_URLS       = "ALLOWED_REDIRECT_URLS"
+	EMAIL_LINK_PROVIDERS        = "EMAIL_LINK_PROVIDERS"
+	OIDC_PROVIDERS              = "OIDC_PROVIDERS"
+	GITHUB_CLIENT_ID            = "GITHUB_CLIENT_ID"
+	GITHUB_CLIENT_SECRET        = "GITHUB_CLIENT_SECRET"
+	GITHUB_ALLOWED_GROUPS       = "GITHUB_ALLOWED_GROUPS"
+	GITHUB_ADMIN_GROUPS         = "GITHUB_ADMIN_GROUPS"
+	GOOGLE_CLIENT_ID            = "GOOGLE_CLIENT_ID"
+	GOOGLE_CLIENT_SECRET        = "GOOGLE_CLIENT_SECRET"
+	GOOGLE_REDIRECT_URL         = "GOOGLE_REDIRECT_URL"
+	GOOGLE_ALLOWED_DOMAINS      = "GOOGLE_ALLOWED_DOMAINS"
+	GITLAB_CLIENT_ID            = "GITLAB_CLIENT_ID"
+	GITLAB_CLIENT_SECRET        = "GITLAB_CLIENT_SECRET"
+	GITLAB_BASE_URL             = "GITLAB_BASE_URL"
+	BITBUCKET_CLIENT_ID         = "BITBUCKET_CLIENT_ID"
+	BITBUCKET_CLIENT_SECRET     = "BITBUCKET_CLIENT_SECRET"
+	LDAP_URL                    = "LDAP_URL"
+	LDAP_START_TLS              = "LDAP_START_TLS"
+	LDAP_INSECURE_SKIP_VERIFY   = "LDAP_INSECURE_SKIP_VERIFY"
+	LDAP_BIND_DN                = "LDAP_BIND_DN"
+	LDAP_BIND_PASSWORD          = "LDAP_BIND_PASSWORD"
+	LDAP_BASE_DN                = "LDAP_BASE_DN"
+	LDAP_USER_ATTRIBUTE         = "LDAP_USER_ATTRIBUTE"
+	LDAP_EMAIL_ATTRIBUTE        = "LDAP_EMAIL_ATTRIBUTE"
+	LDAP_NAME_ATTRIBUTE         = "LDAP_NAME_ATTRIBUTE"
+	LDAP_GROUP_ATTRIBUTE        = "LDAP_GROUP_ATTRIBUTE"
+	LDAP_ALLOWED_GROUPS         = "LDAP_ALLOWED_GROUPS"
+	LDAP_ADMIN_GROUPS           = "LDAP_ADMIN_GROUPS"
+	SAML_IDP_METADATA_URL       = "SAML_IDP_METADATA_URL"
+	SAML_IDP_METADATA           = "SAML_IDP_METADATA"
+	SAML_ENTITY_ID              = "SAML_ENTITY_ID"
+	SAML_DISPLAY_NAME           = "SAML_DISPLAY_NAME"
+	SAML_EMAIL_ATTRIBUTE        = "SAML_EMAIL_ATTRIBUTE"
+	SAML_NAME_ATTRIBUTE         = "SAML_NAME_ATTRIBUTE"
+	SAML_GROUPS_ATTRIBUTE       = "SAML_GROUPS_ATTRIBUTE"
+	SAML_ALLOWED_GROUPS         = "SAML_ALLOWED_GROUPS"
+	SAML_ADMIN_GROUPS           = "SAML_ADMIN_GROUPS"
+	DISABLE_LOCALAUTH           = "DISABLE_LOCALAUTH"
+	DISABLE_GITHUBAUTH          = "DISABLE_GITHUBAUTH"
+	DISABLE_GOOGLEAUTH          = "DISABLE_GOOGLEAUTH"
+	DISABLE_GITLABAUTH          = "DISABLE_GITLABAUTH"
+	DISABLE_BITBUCKETAUTH       = "DISABLE_BITBUCKETAUTH"
+	DISABLE_MAGICLINKAUTH       = "DISABLE_MAGICLINKAUTH"
+	DISABLE_LDAPAUTH            = "DISABLE_LDAPAUTH"
+	DISABLE_SAMLAUTH            = "DISABLE_SAMLAUTH"
+	ENFORCE_ADMIN_MFA           = "ENFORCE_ADMIN_MFA"
+	WEBAUTHN_RP_ID              = "WEBAUTHN_RP_ID"
+	WEBAUTHN_ORIGINS            = "WEBAUTHN_ORIGINS"
+	PASSWORD_MIN_LENGTH         = "PASSWORD_MIN_LENGTH"
+	PASSWORD_REQUIRE_UPPERCASE  = "PASSWORD_REQUIRE_UPPERCASE"
+	PASSWORD_REQUIRE_LOWERCASE  = "PASSWORD_REQUIRE_LOWERCASE"
+	PASSWORD_REQUIRE_DIGIT      = "PASSWORD_REQUIRE_DIGIT"
+	PASSWORD_REQUIRE_SYMBOL     = "PASSWORD_REQUIRE_SYMBOL"
+	PASSWORD_DISALLOW_USER_INFO = "PASSWORD_DISALLOW_USER_INFO"
+	PASSWORD_HISTORY_SIZE       = "PASSWORD_HISTORY_SIZE"
+	PASSWORD_COMMON_LIST_FILE   = "PASSWORD_COMMON_LIST_FILE"
+	PASSWORD_HASH_ALGORITHM     = "PASSWORD_HASH_ALGORITHM"
+	PASSWORD_BCRYPT_COST        = "PASSWORD_BCRYPT_COST"
+	PASSWORD_ARGON2_MEMORY      = "PASSWORD_ARGON2_MEMORY"
+	PASSWORD_ARGON2_ITERATIONS  = "PASSWORD_ARGON2_ITERATIONS"
+	PASSWORD_ARGON2_PARALLELISM = "PASSWORD_ARGON2_PARALLELISM"
+	BEARER                      = "Bearer"
 )
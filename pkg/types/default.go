@@ -14,20 +14,30 @@ var (
 
 // Authentication related constants
 const (
-	DefaultAuthDB                      string        = "auth"
-	DefaultLocalAuthCollection                       = "usercredentials"
-	GithubState                                      = "github"
-	GoogleState                                      = "google"
-	JWTUserCredentialsKey                            = "userCredentials"
-	TemplatePath                                     = "./templates"
-	KuberaPortalImagePath                            = "/kuberaPortal.png"
-	MayadataLogoImagePath                            = "/mayadata-logo.png"
-	BackgroundEmailImagePath                         = "/bg-kubera-email.png"
-	VerificationEmailTemplatePath                    = "/verificationEmailTemplate.html"
-	ResetPasswordEmailTemplatePath                   = "/resetPasswordEmailTemplate.html"
-	AuthHeaderKey                                    = "Authorization"
-	AuthHeaderPrefix                                 = "Bearer "
-	TimeFormat                                       = time.RFC1123Z
-	VerificationLinkExpirationTimeUnit time.Duration = 10
-	PasswordEncryptionCost             int           = 15
+	DefaultAuthDB                         string        = "auth"
+	DefaultLocalAuthCollection                          = "usercredentials"
+	DefaultRefreshTokenCollection                       = "refreshtokens"
+	DefaultLoginCodeCollection                          = "logincodes"
+	DefaultRevokedTokenCollection                       = "revokedtokens"
+	DefaultClientCollection                             = "clients"
+	DefaultAuthorizationRequestCollection               = "authorizationrequests"
+	DefaultOAuthStateCollection                         = "oauthstates"
+	DefaultLoginAttemptCollection                       = "loginattempts"
+	OAuthStateCookie                                    = "kubera_oauth_state"
+	DefaultWebAuthnSessionCollection                    = "webauthnsessions"
+	DefaultPersonalAccessTokenCollection                = "personalaccesstokens"
+	DefaultDeviceAuthorizationCollection                = "deviceauthorizations"
+	JWTUserCredentialsKey                               = "userCredentials"
+	AccessTokenKey                                      = "accessToken"
+	TemplatePath                                        = "./templates"
+	KuberaPortalImagePath                               = "/kuberaPortal.png"
+	MayadataLogoImagePath                               = "/mayadata-logo.png"
+	BackgroundEmailImagePath                            = "/bg-kubera-email.png"
+	VerificationEmailTemplatePath                       = "/verificationEmailTemplate.html"
+	ResetPasswordEmailTemplatePath                      = "/resetPasswordEmailTemplate.html"
+	MagicLinkEmailTemplatePath                          = "/magicLinkEmailTemplate.html"
+	AuthHeaderKey                                       = "Authorization"
+	AuthHeaderPrefix                                    = "Bearer "
+	TimeFormat                                          = time.RFC1123Z
+	VerificationLinkExpirationTimeUnit    time.Duration = 10
 )
@@ -0,0 +1,22 @@
+// digest helps storing opaque tokens and secrets without storing their plain value
+package digest
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// SHA256 returns the hex encoded sha256 digest of the given value.
+// It must only be used for high entropy values generated by the server, passwords
+// have to be hashed with a slow hashing algorithm instead.
+func SHA256(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// S256 returns the PKCE code challenge of the code verifier, the base64url encoded sha256 digest of the verifier
+func S256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
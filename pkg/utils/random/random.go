@@ -2,7 +2,11 @@
 // nolint
 package random
 
-import "math/rand"
+import (
+	crand "crypto/rand"
+	"encoding/base64"
+	"math/rand"
+)
 
 //GetRandomNumbers generates random strings, can be used to create ids or random secrets
 func GetRandomNumbers(n int) string {
@@ -23,3 +27,13 @@ func GetRandomString(n int) string {
 	}
 	return string(s)
 }
+
+// GetSecureRandomString generates an url safe string from n cryptographically secure random bytes,
+// should be used for tokens and secrets which are handed out to the users
+func GetSecureRandomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := crand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
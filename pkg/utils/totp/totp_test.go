@@ -0,0 +1,54 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+func TestCode(t *testing.T) {
+	// The SHA1 test vectors of RFC 6238, truncated to 6 digits
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	tests := []struct {
+		time int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, test := range tests {
+		code, err := Code(secret, Counter(time.Unix(test.time, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != test.code {
+			t.Errorf("Expected code %s at %d, got %s", test.code, test.time, code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1600000000, 0)
+	code, err := Code(secret, Counter(now.Add(-Period*time.Second)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counter, ok := Validate(secret, code, now)
+	if !ok || counter != Counter(now)-1 {
+		t.Errorf("Expected the code of the previous period to be valid")
+	}
+	if _, ok = Validate(secret, code, now.Add(2*Period*time.Second)); ok {
+		t.Errorf("Expected the code to be expired")
+	}
+	if _, ok = Validate(secret, "12345", now); ok {
+		t.Errorf("Expected a code of the wrong length to be invalid")
+	}
+}
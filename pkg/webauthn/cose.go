@@ -0,0 +1,127 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"math/big"
+
+	"github.com/ugorji/go/codec"
+)
+
+// The COSE algorithms supported for the credentials
+const (
+	AlgES256 int64 = -7
+	AlgEdDSA int64 = -8
+	AlgRS256 int64 = -257
+)
+
+// SupportedAlgorithms are offered to the authenticators on registration, in the order of preference
+var SupportedAlgorithms = []int64{AlgES256, AlgEdDSA, AlgRS256}
+
+// The labels and values of the COSE keys, https://www.iana.org/assignments/cose/cose.xhtml
+const (
+	coseKeyType   = 1
+	coseAlgorithm = 3
+	coseCurve     = -1
+	coseX         = -2
+	coseY         = -3
+	coseN         = -1
+	coseE         = -2
+
+	keyTypeOKP = 1
+	keyTypeEC2 = 2
+	keyTypeRSA = 3
+
+	curveP256    = 1
+	curveEd25519 = 6
+)
+
+// parseCOSEKey parses the public key of a credential and gives it PKIX encoded along with its algorithm
+func parseCOSEKey(data []byte) ([]byte, int64, error) {
+	var key map[int64]interface{}
+	if err := codec.NewDecoderBytes(data, cborHandle).Decode(&key); err != nil {
+		return nil, 0, fmt.Errorf("invalid credential public key: %v", err)
+	}
+
+	keyType, _ := toInt64(key[coseKeyType])
+	algorithm, _ := toInt64(key[coseAlgorithm])
+	var publicKey crypto.PublicKey
+	switch {
+	case keyType == keyTypeEC2 && algorithm == AlgES256:
+		curve, _ := toInt64(key[coseCurve])
+		x, _ := key[coseX].([]byte)
+		y, _ := key[coseY].([]byte)
+		if curve != curveP256 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, fmt.Errorf("invalid P-256 public key")
+		}
+		ecKey := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !ecKey.Curve.IsOnCurve(ecKey.X, ecKey.Y) {
+			return nil, 0, fmt.Errorf("invalid P-256 public key")
+		}
+		publicKey = ecKey
+	case keyType == keyTypeOKP && algorithm == AlgEdDSA:
+		curve, _ := toInt64(key[coseCurve])
+		x, _ := key[coseX].([]byte)
+		if curve != curveEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, fmt.Errorf("invalid Ed25519 public key")
+		}
+		publicKey = ed25519.PublicKey(x)
+	case keyType == keyTypeRSA && algorithm == AlgRS256:
+		n, _ := key[coseN].([]byte)
+		e, _ := key[coseE].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, fmt.Errorf("invalid RSA public key")
+		}
+		publicKey = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	default:
+		return nil, 0, fmt.Errorf("unsupported public key type %d with algorithm %d", keyType, algorithm)
+	}
+
+	pkix, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, 0, err
+	}
+	return pkix, algorithm, nil
+}
+
+// verifySignature verifies the signature of the data with the PKIX encoded public key
+func verifySignature(pkix []byte, algorithm int64, data, signature []byte) error {
+	publicKey, err := x509.ParsePKIXPublicKey(pkix)
+	if err != nil {
+		return err
+	}
+
+	digest := sha256.Sum256(data)
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		if algorithm == AlgES256 && ecdsa.VerifyASN1(key, digest[:], signature) {
+			return nil
+		}
+	case ed25519.PublicKey:
+		if algorithm == AlgEdDSA && ed25519.Verify(key, data, signature) {
+			return nil
+		}
+	case *rsa.PublicKey:
+		if algorithm == AlgRS256 && rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid signature")
+}
+
+// toInt64 converts the integers decoded from CBOR, which are either signed or unsigned
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case uint64:
+		return int64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,179 @@
+package webauthn
+
+import (
+	"encoding/base64"
+	"strings"
+	"time"
+)
+
+// The user verification requirements of the ceremonies
+const (
+	UserVerificationRequired  = "required"
+	UserVerificationPreferred = "preferred"
+)
+
+const publicKeyType = "public-key"
+
+// CreationOptions are the options of the registration ceremony, to be given to navigator.credentials.create()
+// after decoding the base64url encoded challenge and ids
+type CreationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	RP                     RelyingPartyEntity     `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	Attestation            string                 `json:"attestation"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+}
+
+// RequestOptions are the options of the authentication ceremony, to be given to navigator.credentials.get()
+// after decoding the base64url encoded challenge and ids
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	Timeout          int64                  `json:"timeout"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// RelyingPartyEntity describes the relying party to the authenticator
+type RelyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// UserEntity describes the user to the authenticator, the ID is base64url encoded
+type UserEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+// CredentialParameter is an algorithm accepted for a new credential
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+// CredentialDescriptor identifies a credential, the ID is base64url encoded
+type CredentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// AuthenticatorSelection states the requirements on the authenticator of a new credential
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CreationOptions gives the options of a registration ceremony for the user, the existing credentials
+// of the user are excluded so that an authenticator isn't registered twice
+func (rp *RelyingParty) CreationOptions(challenge string, userID []byte, name, displayName string, exclude []string, timeout time.Duration) *CreationOptions {
+	options := &CreationOptions{
+		Challenge: challenge,
+		RP: RelyingPartyEntity{
+			ID:   rp.ID,
+			Name: rp.Name,
+		},
+		User: UserEntity{
+			ID:          base64.RawURLEncoding.EncodeToString(userID),
+			Name:        name,
+			DisplayName: displayName,
+		},
+		Timeout:            timeout.Milliseconds(),
+		Attestation:        "none",
+		ExcludeCredentials: descriptors(exclude),
+		// Resident keys allow the passwordless logins without a username
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: UserVerificationPreferred,
+		},
+	}
+	for _, alg := range SupportedAlgorithms {
+		options.PubKeyCredParams = append(options.PubKeyCredParams, CredentialParameter{Type: publicKeyType, Alg: alg})
+	}
+	return options
+}
+
+// RequestOptions gives the options of an authentication ceremony, allowing any discoverable credential
+// when no credentials are given
+func (rp *RelyingParty) RequestOptions(challenge string, allow []string, userVerification string, timeout time.Duration) *RequestOptions {
+	return &RequestOptions{
+		Challenge:        challenge,
+		Timeout:          timeout.Milliseconds(),
+		RPID:             rp.ID,
+		AllowCredentials: descriptors(allow),
+		UserVerification: userVerification,
+	}
+}
+
+// descriptors describes the credentials of the base64url encoded ids
+func descriptors(ids []string) []CredentialDescriptor {
+	credentials := []CredentialDescriptor{}
+	for _, id := range ids {
+		credentials = append(credentials, CredentialDescriptor{Type: publicKeyType, ID: id})
+	}
+	return credentials
+}
+
+// CredentialResponse is the public key credential given by navigator.credentials.create() or
+// navigator.credentials.get(), with its binary fields base64url encoded. The fields which are only informative,
+// such as the transports or the public key outside of the attestation, are accepted but not used.
+type CredentialResponse struct {
+	ID                      string                 `json:"id"`
+	RawID                   string                 `json:"rawId,omitempty"`
+	Type                    string                 `json:"type"`
+	AuthenticatorAttachment string                 `json:"authenticatorAttachment,omitempty"`
+	ClientExtensionResults  map[string]interface{} `json:"clientExtensionResults,omitempty"`
+	Response                struct {
+		ClientDataJSON     string   `json:"clientDataJSON"`
+		AttestationObject  string   `json:"attestationObject,omitempty"`
+		AuthenticatorData  string   `json:"authenticatorData,omitempty"`
+		Signature          string   `json:"signature,omitempty"`
+		UserHandle         string   `json:"userHandle,omitempty"`
+		Transports         []string `json:"transports,omitempty"`
+		PublicKey          string   `json:"publicKey,omitempty"`
+		PublicKeyAlgorithm int64    `json:"publicKeyAlgorithm,omitempty"`
+	} `json:"response"`
+}
+
+// Assertion decodes the response of an authentication ceremony
+func (r *CredentialResponse) Assertion() (*Assertion, error) {
+	assertion := &Assertion{}
+	var err error
+	if assertion.ClientDataJSON, err = decode(r.Response.ClientDataJSON); err != nil {
+		return nil, err
+	}
+	if assertion.AuthenticatorData, err = decode(r.Response.AuthenticatorData); err != nil {
+		return nil, err
+	}
+	if assertion.Signature, err = decode(r.Response.Signature); err != nil {
+		return nil, err
+	}
+	return assertion, nil
+}
+
+// Attestation decodes the client data and the attestation object of a registration ceremony
+func (r *CredentialResponse) Attestation() ([]byte, []byte, error) {
+	clientDataJSON, err := decode(r.Response.ClientDataJSON)
+	if err != nil {
+		return nil, nil, err
+	}
+	attestationObject, err := decode(r.Response.AttestationObject)
+	if err != nil {
+		return nil, nil, err
+	}
+	return clientDataJSON, attestationObject, nil
+}
+
+// decode decodes a base64url value, with or without padding
+func decode(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+// EncodeID encodes a credential id as given by the browsers
+func EncodeID(id []byte) string {
+	return base64.RawURLEncoding.EncodeToString(id)
+}
@@ -0,0 +1,209 @@
+// webauthn verifies the registration and authentication ceremonies of the WebAuthn credentials, such as passkeys.
+// The attestation statements are not verified, the credentials are registered with the "none" attestation conveyance.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ugorji/go/codec"
+)
+
+// The ceremony types of the client data
+const (
+	typeCreate = "webauthn.create"
+	typeGet    = "webauthn.get"
+)
+
+// The flags of the authenticator data
+const (
+	flagUserPresent            = 0x01
+	flagUserVerified           = 0x04
+	flagAttestedCredentialData = 0x40
+)
+
+// RelyingParty identifies kubera to the authenticators. The credentials are bound to the ID, a domain
+// of the origins from which the portal is served.
+type RelyingParty struct {
+	ID      string
+	Name    string
+	Origins []string
+}
+
+// Credential is a public key credential verified on registration
+type Credential struct {
+	ID []byte
+	// PublicKey is the PKIX encoded public key of the credential
+	PublicKey []byte
+	Algorithm int64
+	SignCount uint32
+	AAGUID    []byte
+}
+
+// Assertion is the response of an authenticator to an authentication ceremony
+type Assertion struct {
+	ClientDataJSON    []byte
+	AuthenticatorData []byte
+	Signature         []byte
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+type attestationObject struct {
+	Fmt      string `codec:"fmt"`
+	AuthData []byte `codec:"authData"`
+}
+
+type authenticatorData struct {
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	aaguid       []byte
+	credentialID []byte
+	publicKey    []byte
+}
+
+var cborHandle = &codec.CborHandle{}
+
+// VerifyRegistration verifies the response of the authenticator to the registration ceremony of the challenge
+// and gives the registered credential. requireUserVerification requires the authenticator to have verified the
+// user, such as with a PIN or biometrics, besides the presence of the user.
+func (rp *RelyingParty) VerifyRegistration(clientDataJSON, attestation []byte, challenge string, requireUserVerification bool) (*Credential, error) {
+	if err := rp.verifyClientData(clientDataJSON, typeCreate, challenge); err != nil {
+		return nil, err
+	}
+
+	var attObj attestationObject
+	if err := codec.NewDecoderBytes(attestation, cborHandle).Decode(&attObj); err != nil {
+		return nil, fmt.Errorf("invalid attestation object: %v", err)
+	}
+	authData, err := parseAuthenticatorData(attObj.AuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err = rp.verifyAuthenticatorData(authData, requireUserVerification); err != nil {
+		return nil, err
+	}
+	if authData.flags&flagAttestedCredentialData == 0 {
+		return nil, fmt.Errorf("authenticator data has no attested credential")
+	}
+
+	publicKey, algorithm, err := parseCOSEKey(authData.publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Credential{
+		ID:        authData.credentialID,
+		PublicKey: publicKey,
+		Algorithm: algorithm,
+		SignCount: authData.signCount,
+		AAGUID:    authData.aaguid,
+	}, nil
+}
+
+// VerifyAssertion verifies the response of the authenticator to the authentication ceremony of the challenge
+// with the public key of the credential, and gives the new sign count of the credential. A sign count which
+// did not increase since the last ceremony means that the authenticator has been cloned.
+func (rp *RelyingParty) VerifyAssertion(assertion *Assertion, challenge string, credential *Credential, requireUserVerification bool) (uint32, error) {
+	if err := rp.verifyClientData(assertion.ClientDataJSON, typeGet, challenge); err != nil {
+		return 0, err
+	}
+
+	authData, err := parseAuthenticatorData(assertion.AuthenticatorData)
+	if err != nil {
+		return 0, err
+	}
+	if err = rp.verifyAuthenticatorData(authData, requireUserVerification); err != nil {
+		return 0, err
+	}
+
+	clientDataHash := sha256.Sum256(assertion.ClientDataJSON)
+	signed := append(append([]byte{}, assertion.AuthenticatorData...), clientDataHash[:]...)
+	if err = verifySignature(credential.PublicKey, credential.Algorithm, signed, assertion.Signature); err != nil {
+		return 0, err
+	}
+
+	// Authenticators which don't count the signatures always give 0
+	if (authData.signCount != 0 || credential.SignCount != 0) && authData.signCount <= credential.SignCount {
+		return 0, fmt.Errorf("sign count %d did not increase from %d", authData.signCount, credential.SignCount)
+	}
+	return authData.signCount, nil
+}
+
+func (rp *RelyingParty) verifyClientData(clientDataJSON []byte, ceremonyType, challenge string) error {
+	var data clientData
+	if err := json.Unmarshal(clientDataJSON, &data); err != nil {
+		return fmt.Errorf("invalid client data: %v", err)
+	}
+	if data.Type != ceremonyType {
+		return fmt.Errorf("unexpected client data type %q", data.Type)
+	}
+	if subtle.ConstantTimeCompare([]byte(data.Challenge), []byte(challenge)) != 1 {
+		return fmt.Errorf("challenge mismatch")
+	}
+	for _, origin := range rp.Origins {
+		if data.Origin == origin {
+			return nil
+		}
+	}
+	return fmt.Errorf("unexpected origin %q", data.Origin)
+}
+
+func (rp *RelyingParty) verifyAuthenticatorData(authData *authenticatorData, requireUserVerification bool) error {
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(authData.rpIDHash, rpIDHash[:]) {
+		return fmt.Errorf("credential is not scoped to the relying party %s", rp.ID)
+	}
+	if authData.flags&flagUserPresent == 0 {
+		return fmt.Errorf("user is not present")
+	}
+	if requireUserVerification && authData.flags&flagUserVerified == 0 {
+		return fmt.Errorf("user is not verified")
+	}
+	return nil
+}
+
+// parseAuthenticatorData parses the binary authenticator data, the extensions are ignored
+func parseAuthenticatorData(data []byte) (*authenticatorData, error) {
+	if len(data) < 37 {
+		return nil, fmt.Errorf("authenticator data is too short")
+	}
+	authData := &authenticatorData{
+		rpIDHash:  data[:32],
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	if authData.flags&flagAttestedCredentialData == 0 {
+		return authData, nil
+	}
+
+	rest := data[37:]
+	if len(rest) < 18 {
+		return nil, fmt.Errorf("attested credential data is too short")
+	}
+	authData.aaguid = rest[:16]
+	idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if len(rest) < idLength {
+		return nil, fmt.Errorf("credential id is too short")
+	}
+	authData.credentialID = rest[:idLength]
+
+	// The public key is followed by the extensions, only the bytes of the key are kept
+	rest = rest[idLength:]
+	decoder := codec.NewDecoderBytes(rest, cborHandle)
+	var key map[int64]interface{}
+	if err := decoder.Decode(&key); err != nil {
+		return nil, fmt.Errorf("invalid credential public key: %v", err)
+	}
+	authData.publicKey = rest[:decoder.NumBytesRead()]
+	return authData, nil
+}
@@ -0,0 +1,121 @@
+package webauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/ugorji/go/codec"
+)
+
+// fakeAuthenticator signs the ceremonies of a relying party with a P-256 key, as a security key would
+type fakeAuthenticator struct {
+	t            *testing.T
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	signCount    uint32
+}
+
+func (a *fakeAuthenticator) authenticatorData(rpID string, flags byte, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append([]byte{}, rpIDHash[:]...)
+	if attested {
+		flags |= flagAttestedCredentialData
+	}
+	data = append(data, flags)
+	data = append(data, make([]byte, 4)...)
+	binary.BigEndian.PutUint32(data[33:], a.signCount)
+	if !attested {
+		return data
+	}
+
+	data = append(data, make([]byte, 16)...)
+	data = append(data, byte(len(a.credentialID)>>8), byte(len(a.credentialID)))
+	data = append(data, a.credentialID...)
+	return append(data, a.encode(map[int64]interface{}{
+		coseKeyType:   keyTypeEC2,
+		coseAlgorithm: AlgES256,
+		coseCurve:     curveP256,
+		coseX:         a.key.X.FillBytes(make([]byte, 32)),
+		coseY:         a.key.Y.FillBytes(make([]byte, 32)),
+	})...)
+}
+
+func (a *fakeAuthenticator) encode(v interface{}) []byte {
+	var out []byte
+	if err := codec.NewEncoderBytes(&out, cborHandle).Encode(v); err != nil {
+		a.t.Fatal(err)
+	}
+	return out
+}
+
+func clientDataJSON(t *testing.T, ceremonyType, challenge, origin string) []byte {
+	data, err := json.Marshal(clientData{Type: ceremonyType, Challenge: challenge, Origin: origin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestCeremonies(t *testing.T) {
+	rp := &RelyingParty{ID: "kubera.example.com", Name: "Kubera", Origins: []string{"https://kubera.example.com"}}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator := &fakeAuthenticator{t: t, key: key, credentialID: []byte("credential")}
+
+	attestation := authenticator.encode(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": authenticator.authenticatorData(rp.ID, flagUserPresent|flagUserVerified, true),
+	})
+	credential, err := rp.VerifyRegistration(clientDataJSON(t, typeCreate, "challenge", rp.Origins[0]), attestation, "challenge", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(credential.ID) != "credential" || credential.Algorithm != AlgES256 {
+		t.Errorf("Unexpected credential: %+v", credential)
+	}
+
+	if _, err = rp.VerifyRegistration(clientDataJSON(t, typeCreate, "challenge", "https://evil.example.com"), attestation, "challenge", true); err == nil {
+		t.Error("Expected an error for a foreign origin")
+	}
+
+	assert := func(challenge string, flags byte) *Assertion {
+		authData := authenticator.authenticatorData(rp.ID, flags, false)
+		clientData := clientDataJSON(t, typeGet, challenge, rp.Origins[0])
+		clientDataHash := sha256.Sum256(clientData)
+		digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+		signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &Assertion{ClientDataJSON: clientData, AuthenticatorData: authData, Signature: signature}
+	}
+
+	authenticator.signCount = 1
+	signCount, err := rp.VerifyAssertion(assert("login", flagUserPresent|flagUserVerified), "login", credential, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signCount != 1 {
+		t.Errorf("Expected sign count 1, got %d", signCount)
+	}
+	credential.SignCount = signCount
+
+	if _, err = rp.VerifyAssertion(assert("login", flagUserPresent|flagUserVerified), "login", credential, true); err == nil {
+		t.Error("Expected an error for a sign count which did not increase")
+	}
+	authenticator.signCount = 2
+	if _, err = rp.VerifyAssertion(assert("login", flagUserPresent), "login", credential, true); err == nil {
+		t.Error("Expected an error for an unverified user")
+	}
+	if _, err = rp.VerifyAssertion(assert("other", flagUserPresent|flagUserVerified), "login", credential, true); err == nil {
+		t.Error("Expected an error for another challenge")
+	}
+}
@@ -0,0 +1,66 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/golang/glog"
+
+	v1 "github.com/mayadata-io/kubera-auth/versionedController/v1"
+)
+
+// registerOIDCRoutes registers the endpoints with which kubera-auth acts as an OpenID Connect provider.
+// These are served at the root, next to the JWKS, so that the issuer is the base url of kubera-auth.
+func registerOIDCRoutes(router *gin.Engine) {
+	router.GET(discoveryRoute, Discovery)
+	router.GET(authorizeRoute, Authorize)
+	router.POST(tokenRoute, OIDCToken)
+
+	routerOIDC := router.Group("")
+	routerOIDC.Use(Middleware)
+	{
+		routerOIDC.POST(authorizeRoute, ApproveAuthorization)
+		routerOIDC.GET(userInfoRoute, UserInfo)
+		routerOIDC.POST(userInfoRoute, UserInfo)
+	}
+}
+
+// Discovery will respond with the OpenID Connect provider metadata
+func Discovery(c *gin.Context) {
+	v1.Server.DiscoveryRequest(c)
+}
+
+// Authorize will be visited by the user when a client requests an authorization code,
+// the user is redirected to the portal for login
+func Authorize(c *gin.Context) {
+	v1.Server.AuthorizeRequest(c)
+}
+
+// ApproveAuthorization will be called by the portal once the user has logged in,
+// it responds with the redirect uri of the client along with the authorization code
+func ApproveAuthorization(c *gin.Context) {
+	type model struct {
+		RequestID string `json:"request_id"`
+	}
+
+	requestModel := &model{}
+	err := c.BindJSON(requestModel)
+	if err != nil {
+		log.Error(err)
+		c.JSON(http.StatusNotAcceptable, gin.H{
+			"message": "Unable to parse JSON",
+		})
+		return
+	}
+	v1.Server.ApproveAuthorizationRequest(c, requestModel.RequestID)
+}
+
+// OIDCToken will exchange an authorization code or a refresh token of a client for tokens
+func OIDCToken(c *gin.Context) {
+	v1.Server.OIDCTokenRequest(c)
+}
+
+// UserInfo will respond with the claims of the user of the access token
+func UserInfo(c *gin.Context) {
+	v1.Server.UserInfoRequest(c)
+}
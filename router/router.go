@@ -6,23 +6,39 @@ import (
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	log "github.com/golang/glog"
 
+	"github.com/mayadata-io/kubera-auth/manager/oidcmanager"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
 	"github.com/mayadata-io/kubera-auth/pkg/models"
-	"github.com/mayadata-io/kubera-auth/pkg/oauth/providers"
 	"github.com/mayadata-io/kubera-auth/pkg/types"
 	v1 "github.com/mayadata-io/kubera-auth/versionedController/v1"
+	"github.com/mayadata-io/kubera-auth/versionedController/v1/clients"
 	"github.com/mayadata-io/kubera-auth/versionedController/v1/configuration"
+	"github.com/mayadata-io/kubera-auth/versionedController/v1/device"
 	"github.com/mayadata-io/kubera-auth/versionedController/v1/email"
+	"github.com/mayadata-io/kubera-auth/versionedController/v1/identities"
+	"github.com/mayadata-io/kubera-auth/versionedController/v1/introspect"
+	"github.com/mayadata-io/kubera-auth/versionedController/v1/keys"
 	"github.com/mayadata-io/kubera-auth/versionedController/v1/login"
+	"github.com/mayadata-io/kubera-auth/versionedController/v1/magiclink"
+	"github.com/mayadata-io/kubera-auth/versionedController/v1/mfa"
 	"github.com/mayadata-io/kubera-auth/versionedController/v1/password"
+	"github.com/mayadata-io/kubera-auth/versionedController/v1/saml"
+	"github.com/mayadata-io/kubera-auth/versionedController/v1/serviceaccounts"
 	"github.com/mayadata-io/kubera-auth/versionedController/v1/signup"
+	"github.com/mayadata-io/kubera-auth/versionedController/v1/tokens"
 	"github.com/mayadata-io/kubera-auth/versionedController/v1/user"
+	"github.com/mayadata-io/kubera-auth/versionedController/v1/webauthn"
 )
 
 const (
 	oauthLoginRoute  = "/oauth"
 	healthCheckRoute = "/health"
+	jwksRoute        = "/.well-known/jwks.json"
+	discoveryRoute   = "/.well-known/openid-configuration"
+	authorizeRoute   = "/authorize"
+	tokenRoute       = "/token"
+	userInfoRoute    = "/userinfo"
 )
 
 var (
@@ -33,6 +49,17 @@ var (
 		configuration.New(),
 		email.New(),
 		signup.New(),
+		keys.New(),
+		clients.New(),
+		introspect.New(),
+		identities.New(),
+		mfa.New(),
+		webauthn.New(),
+		magiclink.New(),
+		saml.New(),
+		tokens.New(),
+		serviceaccounts.New(),
+		device.New(),
 	}
 	unauthenticatedLinks = map[string][]string{
 		"/v1" + v1.TokenRoute:         {http.MethodPost, http.MethodGet},
@@ -41,7 +68,41 @@ var (
 		"/v1" + v1.ConfigurationRoute: {http.MethodGet},
 		"/v1" + healthCheckRoute:      {http.MethodGet},
 		"/v1" + v1.SignupRoute:        {http.MethodPost},
-		"/v1" + v1.PasswordRoute:      {http.MethodGet},
+		// The password is changed either with the login token or the token of a password reset link, see the server
+		"/v1" + v1.PasswordRoute: {http.MethodGet, http.MethodPut},
+		// The introspection request is authenticated by the server, either by the client or the bearer token
+		"/v1" + v1.IntrospectRoute: {http.MethodPost},
+		// The enrollment is authenticated by the server, either by the login token or the MFA challenge token
+		"/v1" + v1.MFARoute: {http.MethodPost, http.MethodPut},
+		// The ceremonies are authenticated by the server, the logins don't need a token
+		"/v1" + v1.WebAuthnRoute + "/register": {http.MethodPost, http.MethodPut},
+		"/v1" + v1.WebAuthnRoute + "/login":    {http.MethodPost, http.MethodPut},
+		"/v1" + v1.MagicLinkRoute:              {http.MethodPost, http.MethodPut},
+		// The responses of the identity provider are validated by the server
+		"/v1" + v1.SAMLRoute + "/metadata": {http.MethodGet},
+		"/v1" + v1.SAMLRoute + "/acs":      {http.MethodPost},
+		// The device starts its login without a user, who is sent to the portal from the verification uri
+		"/v1" + v1.DeviceRoute + "/code": {http.MethodPost},
+		"/v1" + v1.DeviceRoute:           {http.MethodGet},
+	}
+	// routeScopes are the scopes required by the routes, by path and method. A token with a scope, such as a personal
+	// access token, can only be used on the routes listed here, the other routes require a token without scope,
+	// see models.HasScope.
+	routeScopes = map[string]map[string]string{
+		"/v1" + v1.UserRoute: {
+			http.MethodGet:   models.ScopeUsersRead,
+			http.MethodPost:  models.ScopeUsersWrite,
+			http.MethodPut:   models.ScopeUsersWrite,
+			http.MethodPatch: models.ScopeUsersWrite,
+		},
+		"/v1" + v1.UserRoute + "/uid/:userID":        {http.MethodGet: models.ScopeUsersRead},
+		"/v1" + v1.UserRoute + "/username/:username": {http.MethodGet: models.ScopeUsersRead},
+		"/v1" + v1.UserRoute + "/uid/:userID/revoke": {http.MethodPost: models.ScopeUsersWrite},
+		"/v1" + v1.UserRoute + "/uid/:userID/unlock": {http.MethodPost: models.ScopeUsersWrite},
+		"/v1" + v1.ConfigurationRoute:                {http.MethodPut: models.ScopeConfigWrite},
+		"/v1" + v1.ClientsRoute:                      {http.MethodGet: models.ScopeClientsRead, http.MethodPost: models.ScopeClientsWrite},
+		"/v1" + v1.ClientsRoute + "/:clientID":       {http.MethodDelete: models.ScopeClientsWrite},
+		userInfoRoute:                                {http.MethodGet: oidcmanager.ScopeOpenID, http.MethodPost: oidcmanager.ScopeOpenID},
 	}
 )
 
@@ -63,6 +124,8 @@ func New() *gin.Engine {
 	router.Use(cors.New(config))
 
 	v1.InitializeServer()
+	router.GET(jwksRoute, JWKS)
+	registerOIDCRoutes(router)
 	routerV1 := router.Group("/v1")
 	routerV1.Use(Middleware)
 	{
@@ -79,41 +142,15 @@ func HealthCheck(c *gin.Context) {
 	c.Writer.WriteHeader(http.StatusOK)
 }
 
+// JWKS will respond with the public keys which can be used to verify the tokens
+func JWKS(c *gin.Context) {
+	v1.Server.JWKSRequest(c)
+}
+
 // CallbackRequest will be triggered by the provider automatically after the login
 func CallbackRequest(c *gin.Context) {
-	var user *models.UserCredentials
-	var err error
 	urlString := types.PortalURL + "/login?"
-	state := c.Query("state")
-	switch state {
-	case types.GithubState:
-		user, err = providers.GetGithubUser(c)
-		if err != nil {
-			log.Errorln("Error getting user from Github", err)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": err.Error(),
-			})
-			c.Redirect(http.StatusFound, urlString)
-			return
-		}
-	case types.GoogleState:
-		user, err = providers.GetGoogleUser(c)
-		if err != nil {
-			log.Errorln("Error getting user from Google", err)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": err.Error(),
-			})
-			c.Redirect(http.StatusFound, urlString)
-			return
-		}
-	default:
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Invalid state or authentication provider",
-		})
-		c.Redirect(http.StatusFound, urlString)
-		return
-	}
-	v1.Server.SocialLoginRequest(c, user, urlString)
+	v1.Server.SocialLoginRequest(c, urlString)
 }
 
 //Middleware ...
@@ -144,8 +181,14 @@ func Middleware(c *gin.Context) {
 		return
 	}
 
-	jwtUserCredentials, err := v1.Server.GetUserFromToken(token)
-	if err != nil {
+	jwtUserCredentials, err := v1.Server.GetUserFromToken(token, routeScopes[c.FullPath()][c.Request.Method], c.ClientIP())
+	if err == errors.ErrInsufficientScope {
+		c.Abort()
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": err.Error(),
+		})
+		return
+	} else if err != nil {
 		c.Abort()
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error": err.Error(),
@@ -153,4 +196,5 @@ func Middleware(c *gin.Context) {
 		return
 	}
 	c.Set(types.JWTUserCredentialsKey, jwtUserCredentials)
+	c.Set(types.AccessTokenKey, token)
 }
@@ -6,6 +6,9 @@ import (
 	"testing"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/mayadata-io/kubera-auth/manager/oidcmanager"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
 )
 
 func init() {
@@ -29,6 +32,38 @@ func TestHealthCheck(t *testing.T) {
 	}
 }
 
+func TestRouteScopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	registerOIDCRoutes(router)
+	registerControllers(router.Group("/v1"))
+
+	registered := map[string]bool{}
+	for _, route := range router.Routes() {
+		registered[route.Method+" "+route.Path] = true
+	}
+	scopes := map[string]bool{oidcmanager.ScopeOpenID: true}
+	for _, scope := range models.APIScopes {
+		scopes[scope] = true
+	}
+
+	for path, methods := range routeScopes {
+		for method, scope := range methods {
+			if !registered[method+" "+path] {
+				t.Errorf("%s %s: the route requiring a scope isn't registered", method, path)
+			}
+			if !scopes[scope] {
+				t.Errorf("%s %s: unknown scope %q", method, path, scope)
+			}
+			for _, unauthenticated := range unauthenticatedLinks[path] {
+				if unauthenticated == method {
+					t.Errorf("%s %s: the route requiring a scope doesn't require a token", method, path)
+				}
+			}
+		}
+	}
+}
+
 /*
 func TestCallbackRequest(t *testing.T) {
 	type args struct {
@@ -0,0 +1,68 @@
+package clients
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/golang/glog"
+
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	controller "github.com/mayadata-io/kubera-auth/versionedController/v1"
+)
+
+// ClientsController is the extension to GenericController which contains the path of this endpoint too.
+type ClientsController struct {
+	controller.GenericController
+	routePath string
+}
+
+// New creates a new ClientsController
+func New() *ClientsController {
+	return &ClientsController{
+		routePath: controller.ClientsRoute,
+	}
+}
+
+// Get lists the registered OpenID Connect clients, request should be sent by admin
+func (clients *ClientsController) Get(c *gin.Context) {
+	controller.Server.GetClientsRequest(c)
+}
+
+// Post registers an OpenID Connect client, request should be sent by admin
+func (clients *ClientsController) Post(c *gin.Context) {
+	type model struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Public       bool     `json:"public"`
+		Audiences    []string `json:"audiences,omitempty"`
+	}
+
+	requestModel := &model{}
+	err := c.BindJSON(requestModel)
+	if err != nil {
+		log.Error(err)
+		c.JSON(http.StatusNotAcceptable, gin.H{
+			"message": "Unable to parse JSON",
+		})
+		return
+	}
+
+	controller.Server.CreateClientRequest(c, &models.Client{
+		Name:         requestModel.Name,
+		RedirectURIs: requestModel.RedirectURIs,
+		Public:       requestModel.Public,
+		Audiences:    requestModel.Audiences,
+	})
+}
+
+// DeleteByClientID removes a registered OpenID Connect client, request should be sent by admin
+func (clients *ClientsController) DeleteByClientID(c *gin.Context) {
+	clientID := c.Param("clientID")
+	controller.Server.DeleteClientRequest(c, clientID)
+}
+
+// Register will register this controller to the specified router
+func (clients *ClientsController) Register(router *gin.RouterGroup) {
+	controller.RegisterController(router, clients, clients.routePath)
+	router.DELETE(clients.routePath+"/:clientID", clients.DeleteByClientID)
+}
@@ -24,12 +24,31 @@ type Controller struct {
 // Model is the configModel object for this route
 type Model struct {
 	// TODO: See the impact of unexported fields in this struct
-	GithubClientID     *string `json:"GITHUB_CLIENT_ID,omitempty"`
-	GithubClientSecret *string `json:"GITHUB_CLIENT_SECRET,omitempty"`
-	EnableGithub       *bool   `json:"ENABLE_GITHUB,omitempty"`
-	GoogleClientID     *string `json:"GOOGLE_CLIENT_ID,omitempty"`
-	GoogleClientSecret *string `json:"GOOGLE_CLIENT_SECRET,omitempty"`
-	EnableGoogle       *bool   `json:"ENABLE_GOOGLE,omitempty"`
+	GithubClientID        *string `json:"GITHUB_CLIENT_ID,omitempty"`
+	GithubClientSecret    *string `json:"GITHUB_CLIENT_SECRET,omitempty"`
+	EnableGithub          *bool   `json:"ENABLE_GITHUB,omitempty"`
+	GoogleClientID        *string `json:"GOOGLE_CLIENT_ID,omitempty"`
+	GoogleClientSecret    *string `json:"GOOGLE_CLIENT_SECRET,omitempty"`
+	EnableGoogle          *bool   `json:"ENABLE_GOOGLE,omitempty"`
+	GitlabClientID        *string `json:"GITLAB_CLIENT_ID,omitempty"`
+	GitlabClientSecret    *string `json:"GITLAB_CLIENT_SECRET,omitempty"`
+	GitlabBaseURL         *string `json:"GITLAB_BASE_URL,omitempty"`
+	EnableGitlab          *bool   `json:"ENABLE_GITLAB,omitempty"`
+	BitbucketClientID     *string `json:"BITBUCKET_CLIENT_ID,omitempty"`
+	BitbucketClientSecret *string `json:"BITBUCKET_CLIENT_SECRET,omitempty"`
+	EnableBitbucket       *bool   `json:"ENABLE_BITBUCKET,omitempty"`
+	EnableMagicLink       *bool   `json:"ENABLE_MAGICLINK,omitempty"`
+	EnableLDAP            *bool   `json:"ENABLE_LDAP,omitempty"`
+	EnableSAML            *bool   `json:"ENABLE_SAML,omitempty"`
+	EnforceAdminMFA       *bool   `json:"ENFORCE_ADMIN_MFA,omitempty"`
+	// The password policy of the local users
+	PasswordMinLength        *int  `json:"PASSWORD_MIN_LENGTH,omitempty"`
+	PasswordRequireUppercase *bool `json:"PASSWORD_REQUIRE_UPPERCASE,omitempty"`
+	PasswordRequireLowercase *bool `json:"PASSWORD_REQUIRE_LOWERCASE,omitempty"`
+	PasswordRequireDigit     *bool `json:"PASSWORD_REQUIRE_DIGIT,omitempty"`
+	PasswordRequireSymbol    *bool `json:"PASSWORD_REQUIRE_SYMBOL,omitempty"`
+	PasswordDisallowUserInfo *bool `json:"PASSWORD_DISALLOW_USER_INFO,omitempty"`
+	PasswordHistorySize      *int  `json:"PASSWORD_HISTORY_SIZE,omitempty"`
 }
 
 // New creates a new controller for configs endpoint
@@ -49,7 +68,7 @@ func (configurationController *Controller) Put(c *gin.Context) {
 		return
 	}
 	// 2. Verify authorization
-	jwtUserCredentials, err := controller.Server.GetUserFromToken(tokenString)
+	jwtUserCredentials, err := controller.Server.GetUserFromToken(tokenString, models.ScopeConfigWrite, c.ClientIP())
 	if err != nil || jwtUserCredentials == nil {
 		c.JSON(http.StatusUnauthorized, err.Error())
 		return
@@ -84,55 +103,157 @@ func (configurationController *Controller) updateToK8s(c *gin.Context, requestMo
 		c.JSON(http.StatusPreconditionFailed, gin.H{
 			"message": "Unable to persist config change",
 		})
+		return
 	}
-	githubDisable, _ := strconv.ParseBool(cm.Data[types.DISABLE_GITHUBAUTH])
+	githubDisable := isDisabled(cm.Data, types.DISABLE_GITHUBAUTH)
 	githubEnable := !githubDisable
-	googDisable, _ := strconv.ParseBool(cm.Data[types.DISABLE_GOOGLEAUTH])
+	googDisable := isDisabled(cm.Data, types.DISABLE_GOOGLEAUTH)
 	googEnable := !googDisable
 	githubClientID := cm.Data[types.GITHUB_CLIENT_ID]
 	githubClientSecret := cm.Data[types.GITHUB_CLIENT_SECRET]
 	googClientID := cm.Data[types.GOOGLE_CLIENT_ID]
 	googClientSecret := cm.Data[types.GOOGLE_CLIENT_SECRET]
+	gitlabDisable := isDisabled(cm.Data, types.DISABLE_GITLABAUTH)
+	gitlabEnable := !gitlabDisable
+	gitlabClientID := cm.Data[types.GITLAB_CLIENT_ID]
+	gitlabClientSecret := cm.Data[types.GITLAB_CLIENT_SECRET]
+	gitlabBaseURL := cm.Data[types.GITLAB_BASE_URL]
+	bitbucketDisable := isDisabled(cm.Data, types.DISABLE_BITBUCKETAUTH)
+	bitbucketEnable := !bitbucketDisable
+	bitbucketClientID := cm.Data[types.BITBUCKET_CLIENT_ID]
+	bitbucketClientSecret := cm.Data[types.BITBUCKET_CLIENT_SECRET]
+	magicLinkEnable := !isDisabled(cm.Data, types.DISABLE_MAGICLINKAUTH)
+	ldapEnable := !isDisabled(cm.Data, types.DISABLE_LDAPAUTH)
+	samlEnable := !isDisabled(cm.Data, types.DISABLE_SAMLAUTH)
+	enforceAdminMFA, _ := strconv.ParseBool(cm.Data[types.ENFORCE_ADMIN_MFA])
+	// The password policy falls back to the current one for the values missing from the configmap. The current
+	// policy is shared by the requests in progress, the configured one is a copy replacing it.
+	policy := *controller.Server.PasswordPolicy()
+	passwordMinLength := parseInt(cm.Data, types.PASSWORD_MIN_LENGTH, policy.MinLength)
+	passwordRequireUppercase := parseBool(cm.Data, types.PASSWORD_REQUIRE_UPPERCASE, policy.RequireUppercase)
+	passwordRequireLowercase := parseBool(cm.Data, types.PASSWORD_REQUIRE_LOWERCASE, policy.RequireLowercase)
+	passwordRequireDigit := parseBool(cm.Data, types.PASSWORD_REQUIRE_DIGIT, policy.RequireDigit)
+	passwordRequireSymbol := parseBool(cm.Data, types.PASSWORD_REQUIRE_SYMBOL, policy.RequireSymbol)
+	passwordDisallowUserInfo := parseBool(cm.Data, types.PASSWORD_DISALLOW_USER_INFO, policy.DisallowUserInfo)
+	passwordHistorySize := parseInt(cm.Data, types.PASSWORD_HISTORY_SIZE, policy.HistorySize)
 	cfgMapModel := Model{
-		GithubClientID:     &githubClientID,
-		GithubClientSecret: &githubClientSecret,
-		EnableGithub:       &githubEnable,
-		GoogleClientID:     &googClientID,
-		GoogleClientSecret: &googClientSecret,
-		EnableGoogle:       &googEnable,
+		GithubClientID:           &githubClientID,
+		GithubClientSecret:       &githubClientSecret,
+		EnableGithub:             &githubEnable,
+		GoogleClientID:           &googClientID,
+		GoogleClientSecret:       &googClientSecret,
+		EnableGoogle:             &googEnable,
+		GitlabClientID:           &gitlabClientID,
+		GitlabClientSecret:       &gitlabClientSecret,
+		GitlabBaseURL:            &gitlabBaseURL,
+		EnableGitlab:             &gitlabEnable,
+		BitbucketClientID:        &bitbucketClientID,
+		BitbucketClientSecret:    &bitbucketClientSecret,
+		EnableBitbucket:          &bitbucketEnable,
+		EnableMagicLink:          &magicLinkEnable,
+		EnableLDAP:               &ldapEnable,
+		EnableSAML:               &samlEnable,
+		EnforceAdminMFA:          &enforceAdminMFA,
+		PasswordMinLength:        &passwordMinLength,
+		PasswordRequireUppercase: &passwordRequireUppercase,
+		PasswordRequireLowercase: &passwordRequireLowercase,
+		PasswordRequireDigit:     &passwordRequireDigit,
+		PasswordRequireSymbol:    &passwordRequireSymbol,
+		PasswordDisallowUserInfo: &passwordDisallowUserInfo,
+		PasswordHistorySize:      &passwordHistorySize,
 	}
 	// update the configmap model with data from the request-model
-	if err := mergo.Merge(&cfgMapModel, &requestModel); err != nil {
+	if err := mergo.Merge(&cfgMapModel, requestModel, mergo.WithOverride); err != nil {
 		log.Error("Error merging to cfgMapModelg", err)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"message": "Unable to update configs",
 		})
+		return
 	}
-
-	// merge the request data
-	if err := mergo.Merge(&cm.Data, &cfgMapModel); err != nil {
-		log.Error("Error merging to cfgMap", err)
+	if *cfgMapModel.PasswordMinLength < 0 || *cfgMapModel.PasswordHistorySize < 0 {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"message": "Unable to update config",
+			"message": "The password length and history size can't be negative",
 		})
+		return
 	}
+
+	// merge the request data
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[types.GITHUB_CLIENT_ID] = *cfgMapModel.GithubClientID
+	cm.Data[types.GITHUB_CLIENT_SECRET] = *cfgMapModel.GithubClientSecret
+	cm.Data[types.DISABLE_GITHUBAUTH] = strconv.FormatBool(!*cfgMapModel.EnableGithub)
+	cm.Data[types.GOOGLE_CLIENT_ID] = *cfgMapModel.GoogleClientID
+	cm.Data[types.GOOGLE_CLIENT_SECRET] = *cfgMapModel.GoogleClientSecret
+	cm.Data[types.DISABLE_GOOGLEAUTH] = strconv.FormatBool(!*cfgMapModel.EnableGoogle)
+	cm.Data[types.GITLAB_CLIENT_ID] = *cfgMapModel.GitlabClientID
+	cm.Data[types.GITLAB_CLIENT_SECRET] = *cfgMapModel.GitlabClientSecret
+	cm.Data[types.GITLAB_BASE_URL] = *cfgMapModel.GitlabBaseURL
+	cm.Data[types.DISABLE_GITLABAUTH] = strconv.FormatBool(!*cfgMapModel.EnableGitlab)
+	cm.Data[types.BITBUCKET_CLIENT_ID] = *cfgMapModel.BitbucketClientID
+	cm.Data[types.BITBUCKET_CLIENT_SECRET] = *cfgMapModel.BitbucketClientSecret
+	cm.Data[types.DISABLE_BITBUCKETAUTH] = strconv.FormatBool(!*cfgMapModel.EnableBitbucket)
+	cm.Data[types.DISABLE_MAGICLINKAUTH] = strconv.FormatBool(!*cfgMapModel.EnableMagicLink)
+	cm.Data[types.DISABLE_LDAPAUTH] = strconv.FormatBool(!*cfgMapModel.EnableLDAP)
+	cm.Data[types.DISABLE_SAMLAUTH] = strconv.FormatBool(!*cfgMapModel.EnableSAML)
+	cm.Data[types.ENFORCE_ADMIN_MFA] = strconv.FormatBool(*cfgMapModel.EnforceAdminMFA)
+	cm.Data[types.PASSWORD_MIN_LENGTH] = strconv.Itoa(*cfgMapModel.PasswordMinLength)
+	cm.Data[types.PASSWORD_REQUIRE_UPPERCASE] = strconv.FormatBool(*cfgMapModel.PasswordRequireUppercase)
+	cm.Data[types.PASSWORD_REQUIRE_LOWERCASE] = strconv.FormatBool(*cfgMapModel.PasswordRequireLowercase)
+	cm.Data[types.PASSWORD_REQUIRE_DIGIT] = strconv.FormatBool(*cfgMapModel.PasswordRequireDigit)
+	cm.Data[types.PASSWORD_REQUIRE_SYMBOL] = strconv.FormatBool(*cfgMapModel.PasswordRequireSymbol)
+	cm.Data[types.PASSWORD_DISALLOW_USER_INFO] = strconv.FormatBool(*cfgMapModel.PasswordDisallowUserInfo)
+	cm.Data[types.PASSWORD_HISTORY_SIZE] = strconv.Itoa(*cfgMapModel.PasswordHistorySize)
 	_, err = k8s.ClientSet.CoreV1().ConfigMaps(types.DefaultNamespace).Update(c.Request.Context(), cm, metav1.UpdateOptions{})
 	if err != nil {
 		log.Errorln("Error updating configmap ", err)
 		c.String(http.StatusInternalServerError, "Error enabling OAuth config")
+		return
 	}
 	// TODO: Add config for localAuth
-	controller.Server.Config.DisableGoogleAuth = !*cfgMapModel.EnableGoogle
-	controller.Server.Config.DisableGithubAuth = !*cfgMapModel.EnableGithub
+	controller.Server.Providers.SetEnabled(models.GoogleAuth, *cfgMapModel.EnableGoogle)
+	controller.Server.Providers.SetEnabled(models.GithubAuth, *cfgMapModel.EnableGithub)
+	controller.Server.Providers.SetEnabled(models.GitlabAuth, *cfgMapModel.EnableGitlab)
+	controller.Server.Providers.SetEnabled(models.BitbucketAuth, *cfgMapModel.EnableBitbucket)
+	controller.Server.Providers.SetEnabled(models.SAMLAuth, *cfgMapModel.EnableSAML)
+	controller.Server.Config.DisableMagicLinkAuth = !*cfgMapModel.EnableMagicLink
+	controller.Server.Config.DisableLDAPAuth = !*cfgMapModel.EnableLDAP
+	controller.Server.Config.EnforceAdminMFA = *cfgMapModel.EnforceAdminMFA
+	policy.MinLength = *cfgMapModel.PasswordMinLength
+	policy.RequireUppercase = *cfgMapModel.PasswordRequireUppercase
+	policy.RequireLowercase = *cfgMapModel.PasswordRequireLowercase
+	policy.RequireDigit = *cfgMapModel.PasswordRequireDigit
+	policy.RequireSymbol = *cfgMapModel.PasswordRequireSymbol
+	policy.DisallowUserInfo = *cfgMapModel.PasswordDisallowUserInfo
+	policy.HistorySize = *cfgMapModel.PasswordHistorySize
+	controller.Server.SetPasswordPolicy(&policy)
 	c.JSON(http.StatusOK, cfgMapModel)
 	// Set a nice success response with the Model
 }
 
 func (configurationController *Controller) Get(c *gin.Context) {
+	policy := controller.Server.PasswordPolicy()
 	authData := map[string]interface{}{
-		types.DISABLE_GITHUBAUTH: controller.Server.Config.DisableGithubAuth,
-		types.DISABLE_LOCALAUTH:  controller.Server.Config.DisableLocalAuth,
-		types.DISABLE_GOOGLEAUTH: controller.Server.Config.DisableGithubAuth,
+		types.DISABLE_GITHUBAUTH:    !controller.Server.Providers.Enabled(models.GithubAuth),
+		types.DISABLE_LOCALAUTH:     controller.Server.Config.DisableLocalAuth,
+		types.DISABLE_GOOGLEAUTH:    !controller.Server.Providers.Enabled(models.GoogleAuth),
+		types.DISABLE_GITLABAUTH:    !controller.Server.Providers.Enabled(models.GitlabAuth),
+		types.DISABLE_BITBUCKETAUTH: !controller.Server.Providers.Enabled(models.BitbucketAuth),
+		types.DISABLE_MAGICLINKAUTH: controller.Server.Config.DisableMagicLinkAuth,
+		types.DISABLE_LDAPAUTH:      controller.Server.Config.DisableLDAPAuth,
+		types.DISABLE_SAMLAUTH:      !controller.Server.Providers.Enabled(models.SAMLAuth),
+		types.ENFORCE_ADMIN_MFA:     controller.Server.Config.EnforceAdminMFA,
+		// The password policy is public, so that the portal can show it on signup
+		types.PASSWORD_MIN_LENGTH:         policy.MinLength,
+		types.PASSWORD_REQUIRE_UPPERCASE:  policy.RequireUppercase,
+		types.PASSWORD_REQUIRE_LOWERCASE:  policy.RequireLowercase,
+		types.PASSWORD_REQUIRE_DIGIT:      policy.RequireDigit,
+		types.PASSWORD_REQUIRE_SYMBOL:     policy.RequireSymbol,
+		types.PASSWORD_DISALLOW_USER_INFO: policy.DisallowUserInfo,
+		types.PASSWORD_HISTORY_SIZE:       policy.HistorySize,
+		// PROVIDERS lists all the social login providers, so that the portal can offer the enabled ones
+		"PROVIDERS": controller.Server.Providers.Providers(),
 	}
 
 	tokenString, err := getTokenFromHeader(c.Request)
@@ -140,16 +261,56 @@ func (configurationController *Controller) Get(c *gin.Context) {
 		log.Errorln("Invalid Token: Unable to parse jwt")
 	}
 
-	jwtUserCredentials, err := controller.Server.GetUserFromToken(tokenString)
+	jwtUserCredentials, err := controller.Server.GetUserFromToken(tokenString, models.ScopeConfigRead, c.ClientIP())
 	if err == nil && jwtUserCredentials.Role == models.RoleAdmin {
-		authData[types.GITHUB_CLIENT_ID] = controller.Server.GithubConfig.ClientID
-		authData[types.GITHUB_CLIENT_SECRET] = controller.Server.GithubConfig.ClientSecret
-		authData[types.GOOGLE_CLIENT_ID] = controller.Server.GoogleConfig.ClientID
-		authData[types.GOOGLE_CLIENT_SECRET] = controller.Server.GoogleConfig.ClientSecret
+		if github, ok := controller.Server.Providers.Lookup(models.GithubAuth); ok {
+			authData[types.GITHUB_CLIENT_ID] = github.Config().ClientID
+			authData[types.GITHUB_CLIENT_SECRET] = github.Config().ClientSecret
+		}
+		if google, ok := controller.Server.Providers.Lookup(models.GoogleAuth); ok {
+			authData[types.GOOGLE_CLIENT_ID] = google.Config().ClientID
+			authData[types.GOOGLE_CLIENT_SECRET] = google.Config().ClientSecret
+		}
+		if gitlab, ok := controller.Server.Providers.Lookup(models.GitlabAuth); ok {
+			authData[types.GITLAB_CLIENT_ID] = gitlab.Config().ClientID
+			authData[types.GITLAB_CLIENT_SECRET] = gitlab.Config().ClientSecret
+			authData[types.GITLAB_BASE_URL] = gitlab.Config().Issuer
+		}
+		if bitbucket, ok := controller.Server.Providers.Lookup(models.BitbucketAuth); ok {
+			authData[types.BITBUCKET_CLIENT_ID] = bitbucket.Config().ClientID
+			authData[types.BITBUCKET_CLIENT_SECRET] = bitbucket.Config().ClientSecret
+		}
 	}
 	c.JSON(http.StatusOK, authData)
 }
 
+// isDisabled reads a disable flag of the configmap, the social logins are disabled unless configured otherwise
+func isDisabled(data map[string]string, key string) bool {
+	disabled, err := strconv.ParseBool(data[key])
+	if err != nil {
+		return true
+	}
+	return disabled
+}
+
+// parseBool reads a flag of the configmap, giving the default value if it is missing or invalid
+func parseBool(data map[string]string, key string, defaultValue bool) bool {
+	b, err := strconv.ParseBool(data[key])
+	if err != nil {
+		return defaultValue
+	}
+	return b
+}
+
+// parseInt reads a number of the configmap, giving the default value if it is missing or invalid
+func parseInt(data map[string]string, key string, defaultValue int) int {
+	i, err := strconv.Atoi(data[key])
+	if err != nil {
+		return defaultValue
+	}
+	return i
+}
+
 func getTokenFromHeader(r *http.Request) (string, error) {
 	auth := r.Header.Get(types.AuthHeaderKey)
 	prefix := types.AuthHeaderPrefix
@@ -0,0 +1,77 @@
+package device
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/golang/glog"
+
+	controller "github.com/mayadata-io/kubera-auth/versionedController/v1"
+)
+
+// DeviceController is the extension to GenericController which contains the path of this endpoint too.
+type DeviceController struct {
+	controller.GenericController
+	routePath string
+}
+
+// New creates a new DeviceController
+func New() *DeviceController {
+	return &DeviceController{
+		routePath: controller.DeviceRoute,
+	}
+}
+
+// Get is the verification uri shown by the device, it sends the user to the device page of the portal
+// along with the "user_code" if given
+func (device *DeviceController) Get(c *gin.Context) {
+	controller.Server.DeviceVerificationRequest(c, c.Query("user_code"))
+}
+
+// Post approves or denies the login of the device showing the user code, request should be sent by the logged in user
+func (device *DeviceController) Post(c *gin.Context) {
+	type model struct {
+		UserCode string `json:"user_code"`
+		Approved bool   `json:"approved"`
+	}
+
+	requestModel := &model{}
+	err := c.BindJSON(requestModel)
+	if err != nil {
+		log.Error(err)
+		c.JSON(http.StatusNotAcceptable, gin.H{
+			"message": "Unable to parse JSON",
+		})
+		return
+	}
+
+	controller.Server.DecideDeviceRequest(c, requestModel.UserCode, requestModel.Approved)
+}
+
+// Code starts the login of a device for the optional "scope", the device then polls the token endpoint
+// with the device code grant
+func (device *DeviceController) Code(c *gin.Context) {
+	type model struct {
+		Scope string `json:"scope,omitempty"`
+	}
+
+	requestModel := &model{}
+	if c.Request.ContentLength != 0 {
+		err := c.BindJSON(requestModel)
+		if err != nil {
+			log.Error(err)
+			c.JSON(http.StatusNotAcceptable, gin.H{
+				"message": "Unable to parse JSON",
+			})
+			return
+		}
+	}
+
+	controller.Server.DeviceCodeRequest(c, requestModel.Scope)
+}
+
+// Register will register this controller to the specified router
+func (device *DeviceController) Register(router *gin.RouterGroup) {
+	controller.RegisterController(router, device, device.routePath)
+	router.POST(device.routePath+"/code", device.Code)
+}
@@ -53,19 +53,7 @@ func (emailController *EmailController) Get(c *gin.Context) {
 	token := c.Query("access")
 	redirectURL := types.PortalURL + "/verified-email"
 
-	jwtUserCredentials, err := controller.Server.GetUserFromToken(token)
-	if err != nil {
-		log.Error("Error occurred while parsing jwt token error: " + err.Error())
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": err.Error(),
-		})
-		// Redirecting user back to UI if access token is not valid
-		c.Redirect(http.StatusPermanentRedirect, redirectURL)
-		return
-	}
-	c.Set(types.JWTUserCredentialsKey, jwtUserCredentials)
-
-	controller.Server.VerifyEmail(c, redirectURL)
+	controller.Server.VerifyEmail(c, token, redirectURL)
 }
 
 // Register will register this controller to the specified router
@@ -0,0 +1,63 @@
+package identities
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/golang/glog"
+
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	controller "github.com/mayadata-io/kubera-auth/versionedController/v1"
+)
+
+// IdentitiesController is the extension to GenericController which contains the path of this endpoint too.
+type IdentitiesController struct {
+	controller.GenericController
+	routePath string
+}
+
+// New creates a new IdentitiesController
+func New() *IdentitiesController {
+	return &IdentitiesController{
+		routePath: controller.IdentitiesRoute,
+	}
+}
+
+// Get lists the accounts of the social login providers linked to the user
+func (identities *IdentitiesController) Get(c *gin.Context) {
+	controller.Server.GetIdentitiesRequest(c)
+}
+
+// Post starts linking an account of a social login provider to the user. The response contains the url
+// of the login page of the provider, to which the user has to be sent.
+// An optional "redirect_to" tells the portal where to send the user once the account is linked.
+func (identities *IdentitiesController) Post(c *gin.Context) {
+	type model struct {
+		Provider   models.AuthType `json:"provider"`
+		RedirectTo string          `json:"redirect_to,omitempty"`
+	}
+
+	requestModel := &model{}
+	err := c.BindJSON(requestModel)
+	if err != nil {
+		log.Error(err)
+		c.JSON(http.StatusNotAcceptable, gin.H{
+			"message": "Unable to parse JSON",
+		})
+		return
+	}
+
+	controller.Server.LinkIdentityRequest(c, requestModel.Provider, requestModel.RedirectTo)
+}
+
+// DeleteByProvider unlinks the account of the provider from the user
+func (identities *IdentitiesController) DeleteByProvider(c *gin.Context) {
+	provider := c.Param("provider")
+	controller.Server.UnlinkIdentityRequest(c, models.AuthType(provider))
+}
+
+// Register will register this controller to the specified router
+func (identities *IdentitiesController) Register(router *gin.RouterGroup) {
+	controller.RegisterController(router, identities, identities.routePath)
+	router.DELETE(identities.routePath+"/:provider", identities.DeleteByProvider)
+}
@@ -0,0 +1,30 @@
+package introspect
+
+import (
+	"github.com/gin-gonic/gin"
+
+	controller "github.com/mayadata-io/kubera-auth/versionedController/v1"
+)
+
+// IntrospectController is the extension to GenericController which contains the path of this endpoint too.
+type IntrospectController struct {
+	controller.GenericController
+	routePath string
+}
+
+// New creates a new IntrospectController
+func New() *IntrospectController {
+	return &IntrospectController{
+		routePath: controller.IntrospectRoute,
+	}
+}
+
+// Post tells whether the token in the `token` form parameter is active along with its claims
+func (introspect *IntrospectController) Post(c *gin.Context) {
+	controller.Server.IntrospectRequest(c, c.PostForm("token"))
+}
+
+// Register will register this controller to the specified router
+func (introspect *IntrospectController) Register(router *gin.RouterGroup) {
+	controller.RegisterController(router, introspect, introspect.routePath)
+}
@@ -0,0 +1,36 @@
+package keys
+
+import (
+	"github.com/gin-gonic/gin"
+
+	controller "github.com/mayadata-io/kubera-auth/versionedController/v1"
+)
+
+// KeysController is the extension to GenericController which contains the path of this endpoint too.
+type KeysController struct {
+	controller.GenericController
+	routePath string
+}
+
+// New creates a new KeysController
+func New() *KeysController {
+	return &KeysController{
+		routePath: controller.KeysRoute,
+	}
+}
+
+// Get lists the keys used for signing and verifying the tokens, request should be sent by admin
+func (keys *KeysController) Get(c *gin.Context) {
+	controller.Server.GetKeysRequest(c)
+}
+
+// Post rotates the signing key, request should be sent by admin.
+// The previous key is still accepted for verification until the tokens signed with it expire.
+func (keys *KeysController) Post(c *gin.Context) {
+	controller.Server.RotateKeysRequest(c)
+}
+
+// Register will register this controller to the specified router
+func (keys *KeysController) Register(router *gin.RouterGroup) {
+	controller.RegisterController(router, keys, keys.routePath)
+}
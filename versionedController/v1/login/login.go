@@ -6,10 +6,9 @@ import (
 
 	"github.com/gin-gonic/gin"
 	log "github.com/golang/glog"
+	"github.com/mayadata-io/kubera-auth/pkg/errors"
 	"github.com/mayadata-io/kubera-auth/pkg/models"
-	"github.com/mayadata-io/kubera-auth/pkg/types"
 	controller "github.com/mayadata-io/kubera-auth/versionedController/v1"
-	"golang.org/x/oauth2"
 )
 
 //LoginController is the type the request in which the request will be parsed
@@ -20,8 +19,22 @@ type LoginController struct {
 
 // Model defines the json struct in which the request will be parsed
 type Model struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	// GrantType defaults to the password grant if not specified
+	GrantType    string `json:"grant_type,omitempty"`
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	// Code is the one-time login code the portal is redirected with after a social login, or the code
+	// of the user completing the login with MFA along with MFAToken
+	Code     string `json:"code,omitempty"`
+	MFAToken string `json:"mfa_token,omitempty"`
+	// Scope restricts the login token to some routes of the API, such as "users:read config:read"
+	Scope string `json:"scope,omitempty"`
+	// ClientID and ClientSecret authenticate a service account with the client credentials grant
+	ClientID     string `json:"client_id,omitempty"`
+	ClientSecret string `json:"client_secret,omitempty"`
+	// DeviceCode is polled for by a device such as the CLI with the device code grant
+	DeviceCode string `json:"device_code,omitempty"`
 }
 
 // New creates a new LoginUser
@@ -37,7 +50,7 @@ func init() {
 	}
 }
 
-// Post lets a user login into the kubera-core
+// Post lets a user login into the kubera-core or refresh an access token
 func (login *LoginController) Post(c *gin.Context) {
 	loginModel := &Model{}
 	err := c.BindJSON(loginModel)
@@ -48,40 +61,52 @@ func (login *LoginController) Post(c *gin.Context) {
 		})
 		return
 	}
-	controller.Server.LocalLoginRequest(c, loginModel.Username, loginModel.Password)
+
+	switch models.GrantType(loginModel.GrantType) {
+	case "", models.PasswordGrant:
+		controller.Server.LocalLoginRequest(c, loginModel.Username, loginModel.Password, loginModel.Scope)
+	case models.RefreshTokenGrant:
+		controller.Server.RefreshTokenRequest(c, loginModel.RefreshToken)
+	case models.LoginCodeGrant:
+		controller.Server.LoginCodeRequest(c, loginModel.Code)
+	case models.MFAGrant:
+		controller.Server.MFALoginRequest(c, loginModel.MFAToken, loginModel.Code)
+	case models.LDAPGrant:
+		controller.Server.LDAPLoginRequest(c, loginModel.Username, loginModel.Password, loginModel.Scope)
+	case models.ClientCredentialsGrant:
+		controller.Server.ClientCredentialsLoginRequest(c, loginModel.ClientID, loginModel.ClientSecret, loginModel.Scope)
+	case models.DeviceCodeGrant:
+		controller.Server.DeviceCodeLoginRequest(c, loginModel.DeviceCode)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": errors.ErrUnsupportedGrantType.Error(),
+		})
+	}
 }
 
 // Get will be triggered on GET request on the same path as Login along with a "auth_type" parameter
 // so as to identify the type of login user is up to. This has to be triggered through a href request
 // so that the user is able to be redirected to provider page for login.
 // Javascript Get Request can block the redirection of user
+// An optional "redirect_to" parameter tells the portal where to send the user after the login.
 func (login *LoginController) Get(c *gin.Context) {
 	authType := c.Query("auth_type")
-	switch models.AuthType(authType) {
-	case models.GithubAuth:
-		if !controller.Server.Config.DisableGithubAuth {
-			githubURL := controller.Server.GithubConfig.AuthCodeURL(types.GithubState)
-			c.Redirect(http.StatusFound, githubURL)
-		} else {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Authentication type not allowed",
-			})
-		}
-	case models.GoogleAuth:
-		if !controller.Server.Config.DisableGoogleAuth {
-			googleURL := controller.Server.GoogleConfig.AuthCodeURL(types.GoogleState,
-				oauth2.SetAuthURLParam("include_granted_scopes", "true"), oauth2.AccessTypeOnline)
-			c.Redirect(http.StatusFound, googleURL)
-		} else {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Authentication type not allowed",
-			})
-		}
-	default:
+	redirectTo := c.Query("redirect_to")
+	if _, ok := controller.Server.Providers.Lookup(models.AuthType(authType)); !ok {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Unknown Authentication Type",
 		})
+		return
+	}
+
+	provider, ok := controller.Server.Providers.Get(models.AuthType(authType))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Authentication type not allowed",
+		})
+		return
 	}
+	controller.Server.SocialLoginRedirect(c, provider, redirectTo)
 }
 
 // Delete lets a user logout of the kubera-core
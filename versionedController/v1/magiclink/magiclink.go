@@ -0,0 +1,65 @@
+package magiclink
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/golang/glog"
+
+	controller "github.com/mayadata-io/kubera-auth/versionedController/v1"
+)
+
+// MagicLinkController is the extension to GenericController which contains the path of this endpoint too.
+type MagicLinkController struct {
+	controller.GenericController
+	routePath string
+}
+
+// Model is the request model of the magic link endpoint
+type Model struct {
+	Email  string `json:"email,omitempty"`
+	Access string `json:"access,omitempty"`
+}
+
+// New creates a new MagicLinkController
+func New() *MagicLinkController {
+	return &MagicLinkController{
+		routePath: controller.MagicLinkRoute,
+	}
+}
+
+// Post mails a sign-in link to the user owning the verified email
+func (magicLink *MagicLinkController) Post(c *gin.Context) {
+	requestModel, ok := bindModel(c)
+	if !ok {
+		return
+	}
+	controller.Server.MagicLinkRequest(c, requestModel.Email)
+}
+
+// Put exchanges the "access" token of the sign-in link for a login token
+func (magicLink *MagicLinkController) Put(c *gin.Context) {
+	requestModel, ok := bindModel(c)
+	if !ok {
+		return
+	}
+	controller.Server.MagicLinkLoginRequest(c, requestModel.Access)
+}
+
+func bindModel(c *gin.Context) (*Model, bool) {
+	requestModel := &Model{}
+	err := c.BindJSON(requestModel)
+	if err != nil {
+		log.Error(err)
+		c.JSON(http.StatusNotAcceptable, gin.H{
+			"message": "Unable to parse JSON",
+		})
+		return nil, false
+	}
+	return requestModel, true
+}
+
+// Register will register this controller to the specified router
+func (magicLink *MagicLinkController) Register(router *gin.RouterGroup) {
+	controller.RegisterController(router, magicLink, magicLink.routePath)
+}
@@ -0,0 +1,63 @@
+package mfa
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/golang/glog"
+
+	controller "github.com/mayadata-io/kubera-auth/versionedController/v1"
+)
+
+// MFAController is the extension to GenericController which contains the path of this endpoint too.
+type MFAController struct {
+	controller.GenericController
+	routePath string
+}
+
+// New creates a new MFAController
+func New() *MFAController {
+	return &MFAController{
+		routePath: controller.MFARoute,
+	}
+}
+
+// Get gives the multi-factor authentication status of the user
+func (mfa *MFAController) Get(c *gin.Context) {
+	controller.Server.GetMFARequest(c)
+}
+
+// Post starts the enrollment of the user, the response contains the secret along with the otpauth uri
+// which has to be added to an authenticator app
+func (mfa *MFAController) Post(c *gin.Context) {
+	controller.Server.StartMFAEnrollmentRequest(c)
+}
+
+// Put confirms the enrollment of the user with a code of the authenticator app
+func (mfa *MFAController) Put(c *gin.Context) {
+	type model struct {
+		Code string `json:"code"`
+	}
+
+	requestModel := &model{}
+	err := c.BindJSON(requestModel)
+	if err != nil {
+		log.Error(err)
+		c.JSON(http.StatusNotAcceptable, gin.H{
+			"message": "Unable to parse JSON",
+		})
+		return
+	}
+
+	controller.Server.ConfirmMFAEnrollmentRequest(c, requestModel.Code)
+}
+
+// Delete disables multi-factor authentication for the user, a "code" parameter is required
+func (mfa *MFAController) Delete(c *gin.Context) {
+	controller.Server.DisableMFARequest(c, c.Query("code"))
+}
+
+// Register will register this controller to the specified router
+func (mfa *MFAController) Register(router *gin.RouterGroup) {
+	controller.RegisterController(router, mfa, mfa.routePath)
+}
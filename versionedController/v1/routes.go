@@ -1,10 +1,21 @@
 package v1
 
 const (
-	TokenRoute         = "/token"
-	UserRoute          = "/user"
-	PasswordRoute      = "/password"
-	ConfigurationRoute = "/configuration"
-	EmailRoute         = "/email"
-	SignupRoute        = "/signup"
+	TokenRoute           = "/token"
+	UserRoute            = "/user"
+	PasswordRoute        = "/password"
+	ConfigurationRoute   = "/configuration"
+	EmailRoute           = "/email"
+	SignupRoute          = "/signup"
+	KeysRoute            = "/keys"
+	ClientsRoute         = "/clients"
+	IntrospectRoute      = "/introspect"
+	IdentitiesRoute      = "/identities"
+	MFARoute             = "/mfa"
+	WebAuthnRoute        = "/webauthn"
+	MagicLinkRoute       = "/magiclink"
+	SAMLRoute            = "/saml"
+	TokensRoute          = "/tokens"
+	ServiceAccountsRoute = "/serviceaccounts"
+	DeviceRoute          = "/device"
 )
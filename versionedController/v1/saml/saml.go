@@ -0,0 +1,39 @@
+package saml
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/mayadata-io/kubera-auth/pkg/types"
+	controller "github.com/mayadata-io/kubera-auth/versionedController/v1"
+)
+
+// SAMLController is the extension to GenericController which contains the path of this endpoint too.
+type SAMLController struct {
+	controller.GenericController
+	routePath string
+}
+
+// New creates a new SAMLController
+func New() *SAMLController {
+	return &SAMLController{
+		routePath: controller.SAMLRoute,
+	}
+}
+
+// Metadata responds with the metadata of kubera as service provider
+func (samlController *SAMLController) Metadata(c *gin.Context) {
+	controller.Server.SAMLMetadataRequest(c)
+}
+
+// ACS is the assertion consumer service, to which the identity provider posts its response after the login
+func (samlController *SAMLController) ACS(c *gin.Context) {
+	urlString := types.PortalURL + "/login?"
+	controller.Server.SAMLCallbackRequest(c, urlString)
+}
+
+// Register will register this controller to the specified router
+func (samlController *SAMLController) Register(router *gin.RouterGroup) {
+	controller.RegisterController(router, samlController, samlController.routePath)
+	router.GET(samlController.routePath+"/metadata", samlController.Metadata)
+	router.POST(samlController.routePath+"/acs", samlController.ACS)
+}
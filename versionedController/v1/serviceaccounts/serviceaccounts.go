@@ -0,0 +1,90 @@
+package serviceaccounts
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/golang/glog"
+
+	"github.com/mayadata-io/kubera-auth/manager/serviceaccountmanager"
+	"github.com/mayadata-io/kubera-auth/pkg/models"
+	controller "github.com/mayadata-io/kubera-auth/versionedController/v1"
+)
+
+// ServiceAccountsController is the extension to GenericController which contains the path of this endpoint too.
+type ServiceAccountsController struct {
+	controller.GenericController
+	routePath string
+}
+
+// New creates a new ServiceAccountsController
+func New() *ServiceAccountsController {
+	return &ServiceAccountsController{
+		routePath: controller.ServiceAccountsRoute,
+	}
+}
+
+// Get lists the service accounts, request should be sent by admin
+func (serviceAccounts *ServiceAccountsController) Get(c *gin.Context) {
+	controller.Server.GetServiceAccountsRequest(c)
+}
+
+// Post creates a service account of the given role, "user" by default, request should be sent by admin
+func (serviceAccounts *ServiceAccountsController) Post(c *gin.Context) {
+	type model struct {
+		Name string      `json:"name"`
+		Role models.Role `json:"role,omitempty"`
+	}
+
+	requestModel := &model{}
+	err := c.BindJSON(requestModel)
+	if err != nil {
+		log.Error(err)
+		c.JSON(http.StatusNotAcceptable, gin.H{
+			"message": "Unable to parse JSON",
+		})
+		return
+	}
+
+	controller.Server.CreateServiceAccountRequest(c, requestModel.Name, requestModel.Role)
+}
+
+// RotateSecret adds a new client secret to the service account. The previous secrets stay valid for
+// "overlap_hours", 24 hours by default, 0 revokes them at once.
+func (serviceAccounts *ServiceAccountsController) RotateSecret(c *gin.Context) {
+	type model struct {
+		OverlapHours *int `json:"overlap_hours,omitempty"`
+	}
+
+	requestModel := &model{}
+	if c.Request.ContentLength != 0 {
+		err := c.BindJSON(requestModel)
+		if err != nil {
+			log.Error(err)
+			c.JSON(http.StatusNotAcceptable, gin.H{
+				"message": "Unable to parse JSON",
+			})
+			return
+		}
+	}
+
+	overlap := serviceaccountmanager.DefaultSecretOverlap
+	if requestModel.OverlapHours != nil {
+		overlap = time.Duration(*requestModel.OverlapHours) * time.Hour
+	}
+	controller.Server.RotateServiceAccountSecretRequest(c, c.Param("clientID"), overlap)
+}
+
+// DeleteByClientID removes a service account, request should be sent by admin
+func (serviceAccounts *ServiceAccountsController) DeleteByClientID(c *gin.Context) {
+	clientID := c.Param("clientID")
+	controller.Server.DeleteServiceAccountRequest(c, clientID)
+}
+
+// Register will register this controller to the specified router
+func (serviceAccounts *ServiceAccountsController) Register(router *gin.RouterGroup) {
+	controller.RegisterController(router, serviceAccounts, serviceAccounts.routePath)
+	router.POST(serviceAccounts.routePath+"/:clientID/secrets", serviceAccounts.RotateSecret)
+	router.DELETE(serviceAccounts.routePath+"/:clientID", serviceAccounts.DeleteByClientID)
+}
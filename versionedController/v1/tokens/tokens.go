@@ -0,0 +1,62 @@
+package tokens
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/golang/glog"
+
+	controller "github.com/mayadata-io/kubera-auth/versionedController/v1"
+)
+
+// TokensController is the extension to GenericController which contains the path of this endpoint too.
+type TokensController struct {
+	controller.GenericController
+	routePath string
+}
+
+// New creates a new TokensController
+func New() *TokensController {
+	return &TokensController{
+		routePath: controller.TokensRoute,
+	}
+}
+
+// Get lists the personal access tokens of the user, the tokens themselves are never shown again
+func (tokens *TokensController) Get(c *gin.Context) {
+	controller.Server.GetPersonalAccessTokensRequest(c)
+}
+
+// Post creates a personal access token of the user for the given space separated scope, such as "users:read".
+// The token expires after "expires_in_days", 30 days by default. The token is only part of this response.
+func (tokens *TokensController) Post(c *gin.Context) {
+	type model struct {
+		Name          string `json:"name"`
+		Scope         string `json:"scope"`
+		ExpiresInDays int    `json:"expires_in_days,omitempty"`
+	}
+
+	requestModel := &model{}
+	err := c.BindJSON(requestModel)
+	if err != nil {
+		log.Error(err)
+		c.JSON(http.StatusNotAcceptable, gin.H{
+			"message": "Unable to parse JSON",
+		})
+		return
+	}
+
+	controller.Server.CreatePersonalAccessTokenRequest(c, requestModel.Name, requestModel.Scope, requestModel.ExpiresInDays)
+}
+
+// DeleteByTokenID revokes a personal access token of the user
+func (tokens *TokensController) DeleteByTokenID(c *gin.Context) {
+	tokenID := c.Param("tokenID")
+	controller.Server.DeletePersonalAccessTokenRequest(c, tokenID)
+}
+
+// Register will register this controller to the specified router
+func (tokens *TokensController) Register(router *gin.RouterGroup) {
+	controller.RegisterController(router, tokens, tokens.routePath)
+	router.DELETE(tokens.routePath+"/:tokenID", tokens.DeleteByTokenID)
+}
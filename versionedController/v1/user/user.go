@@ -145,9 +145,23 @@ func (user *UserController) GetByUsername(c *gin.Context) {
 	controller.Server.GetUserByUserName(c, userID)
 }
 
+// RevokeTokens revokes all the tokens of a particular user, request should be sent by admin
+func (user *UserController) RevokeTokens(c *gin.Context) {
+	userID := c.Param("userID")
+	controller.Server.RevokeUserTokensRequest(c, userID)
+}
+
+// Unlock unlocks the login of a particular user locked after too many failed logins, request should be sent by admin
+func (user *UserController) Unlock(c *gin.Context) {
+	userID := c.Param("userID")
+	controller.Server.UnlockUserRequest(c, userID)
+}
+
 // Register will register this controller to the specified router
 func (user *UserController) Register(router *gin.RouterGroup) {
 	controller.RegisterController(router, user, user.routePath)
 	router.GET(user.routePath+"/uid/:userID", user.GetByUID)
 	router.GET(user.routePath+"/username/:username", user.GetByUsername)
+	router.POST(user.routePath+"/uid/:userID/revoke", user.RevokeTokens)
+	router.POST(user.routePath+"/uid/:userID/unlock", user.Unlock)
 }
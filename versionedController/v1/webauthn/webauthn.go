@@ -0,0 +1,91 @@
+package webauthn
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/golang/glog"
+
+	"github.com/mayadata-io/kubera-auth/pkg/webauthn"
+	controller "github.com/mayadata-io/kubera-auth/versionedController/v1"
+)
+
+// WebAuthnController is the extension to GenericController which contains the path of this endpoint too.
+type WebAuthnController struct {
+	controller.GenericController
+	routePath string
+}
+
+type ceremonyModel struct {
+	SessionID  string                       `json:"session_id"`
+	Name       string                       `json:"name"`
+	Credential *webauthn.CredentialResponse `json:"credential"`
+}
+
+// New creates a new WebAuthnController
+func New() *WebAuthnController {
+	return &WebAuthnController{
+		routePath: controller.WebAuthnRoute,
+	}
+}
+
+// Get gives the webauthn credentials registered by the user
+func (w *WebAuthnController) Get(c *gin.Context) {
+	controller.Server.GetWebAuthnCredentialsRequest(c)
+}
+
+// StartRegistration gives the options of navigator.credentials.create() along with the session of the ceremony
+func (w *WebAuthnController) StartRegistration(c *gin.Context) {
+	controller.Server.StartWebAuthnRegistrationRequest(c)
+}
+
+// FinishRegistration registers the credential created by the browser under the given name
+func (w *WebAuthnController) FinishRegistration(c *gin.Context) {
+	requestModel, ok := bindCeremony(c)
+	if !ok {
+		return
+	}
+	controller.Server.FinishWebAuthnRegistrationRequest(c, requestModel.SessionID, requestModel.Name, requestModel.Credential)
+}
+
+// StartLogin gives the options of navigator.credentials.get() along with the session of the ceremony
+func (w *WebAuthnController) StartLogin(c *gin.Context) {
+	controller.Server.StartWebAuthnLoginRequest(c)
+}
+
+// FinishLogin logs in the user with the assertion of the credential
+func (w *WebAuthnController) FinishLogin(c *gin.Context) {
+	requestModel, ok := bindCeremony(c)
+	if !ok {
+		return
+	}
+	controller.Server.FinishWebAuthnLoginRequest(c, requestModel.SessionID, requestModel.Credential)
+}
+
+// DeleteCredential removes a webauthn credential of the user
+func (w *WebAuthnController) DeleteCredential(c *gin.Context) {
+	controller.Server.DeleteWebAuthnCredentialRequest(c, c.Param("credentialID"))
+}
+
+func bindCeremony(c *gin.Context) (*ceremonyModel, bool) {
+	requestModel := &ceremonyModel{}
+	err := c.BindJSON(requestModel)
+	if err != nil || requestModel.Credential == nil {
+		log.Error(err)
+		c.JSON(http.StatusNotAcceptable, gin.H{
+			"message": "Unable to parse JSON",
+		})
+		return nil, false
+	}
+	return requestModel, true
+}
+
+// Register will register this controller to the specified router
+func (w *WebAuthnController) Register(router *gin.RouterGroup) {
+	controller.RegisterController(router, w, w.routePath)
+	router.POST(w.routePath+"/register", w.StartRegistration)
+	router.PUT(w.routePath+"/register", w.FinishRegistration)
+	router.POST(w.routePath+"/login", w.StartLogin)
+	router.PUT(w.routePath+"/login", w.FinishLogin)
+	router.DELETE(w.routePath+"/credentials/:credentialID", w.DeleteCredential)
+}